@@ -1,85 +1,311 @@
-package main
-
-import (
-	"log"
-
-	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/logger"
-	"github.com/gofiber/fiber/v2/middleware/recover"
-	"github.com/joho/godotenv"
-
-	"github.com/voltrun/backend/internal/api"
-	"github.com/voltrun/backend/internal/storage"
-	"github.com/voltrun/backend/internal/utils"
-)
-
-func main() {
-	// Load .env file
-	if err := godotenv.Load(); err != nil {
-		log.Println("Warning: .env file not found, using environment variables")
-	}
-
-	// Load configuration
-	config := utils.LoadConfig()
-
-	// Initialize logger
-	if err := utils.InitLogger(); err != nil {
-		log.Fatalf("Failed to initialize logger: %v", err)
-	}
-	defer utils.Logger.Sync()
-
-	utils.Info("Starting VoltRun backend server")
-
-	// Initialize database
-	if err := storage.InitDB(); err != nil {
-		utils.Error("Failed to initialize database")
-		log.Fatalf("Database initialization failed: %v", err)
-	}
-
-	// Create Fiber app
-	app := fiber.New(fiber.Config{
-		AppName: "VoltRun v1.0.0",
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			code := fiber.StatusInternalServerError
-			if e, ok := err.(*fiber.Error); ok {
-				code = e.Code
-			}
-			utils.Error("Request error")
-			return c.Status(code).JSON(fiber.Map{
-				"error": err.Error(),
-			})
-		},
-	})
-
-	// Middleware
-	app.Use(recover.New())
-	app.Use(logger.New())
-	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
-	}))
-
-	// Health check
-	app.Get("/health", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{
-			"status":  "healthy",
-			"service": "voltrun-backend",
-			"version": "1.0.0",
-		})
-	})
-
-	// Setup API routes
-	api.SetupRoutes(app)
-
-	// Start server
-	utils.Info("Server starting on port " + config.Port)
-	log.Printf("🚀 VoltRun server listening on http://localhost:%s", config.Port)
-	log.Printf("📊 Health check: http://localhost:%s/health", config.Port)
-	log.Printf("� API endpoint: http://localhost:%s/api", config.Port)
-	
-	if err := app.Listen(":" + config.Port); err != nil {
-		utils.Error("Server failed to start")
-		log.Fatal(err)
-	}
-}
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/joho/godotenv"
+	"go.uber.org/zap"
+
+	"github.com/voltrun/backend/internal/api"
+	"github.com/voltrun/backend/internal/auth"
+	"github.com/voltrun/backend/internal/exec"
+	"github.com/voltrun/backend/internal/metering"
+	"github.com/voltrun/backend/internal/metrics"
+	"github.com/voltrun/backend/internal/objectstore"
+	"github.com/voltrun/backend/internal/oidc"
+	"github.com/voltrun/backend/internal/payloads"
+	"github.com/voltrun/backend/internal/quotas"
+	"github.com/voltrun/backend/internal/ratelimit"
+	"github.com/voltrun/backend/internal/retention"
+	"github.com/voltrun/backend/internal/secrets"
+	"github.com/voltrun/backend/internal/storage"
+	"github.com/voltrun/backend/internal/tracing"
+	"github.com/voltrun/backend/internal/triggers"
+	"github.com/voltrun/backend/internal/utils"
+	"github.com/voltrun/backend/internal/vm"
+	"github.com/voltrun/backend/internal/workflows"
+	"github.com/voltrun/backend/scheduler"
+)
+
+func main() {
+	// Load .env file
+	if err := godotenv.Load(); err != nil {
+		log.Println("Warning: .env file not found, using environment variables")
+	}
+
+	// Load configuration
+	config := utils.LoadConfig()
+
+	// Initialize logger
+	if err := utils.InitLogger(); err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	defer utils.Logger.Sync()
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(config, os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
+	utils.Info("Starting VoltRun backend server")
+
+	// Initialize tracing before anything opens spans
+	shutdownTracing, err := tracing.Init(context.Background(), config.TracingExporter, config.TracingSampleRatio)
+	if err != nil {
+		log.Fatalf("Tracing initialization failed: %v", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// Initialize secrets encryption
+	if err := secrets.InitKeyring(config.SecretsMasterKeyID, config.SecretsMasterKey, config.SecretsPreviousKeys); err != nil {
+		log.Fatalf("Secrets keyring initialization failed: %v", err)
+	}
+
+	// Initialize database
+	if err := storage.InitDB(config.DatabaseDriver, config.DatabaseURL); err != nil {
+		utils.Error("Failed to initialize database")
+		log.Fatalf("Database initialization failed: %v", err)
+	}
+	if config.MigrateOnStart {
+		if _, err := storage.MigrateUp(); err != nil {
+			log.Fatalf("Database migration failed: %v", err)
+		}
+	}
+
+	// Re-encrypt secrets still sealed with a retired master key
+	rotated, err := secrets.RotateKeys()
+	if err != nil {
+		log.Fatalf("Secrets key rotation failed: %v", err)
+	}
+	if rotated > 0 {
+		utils.Info("Re-encrypted secrets with the active master key", zap.Int("count", rotated))
+	}
+
+	// Enable rate and concurrency limits; replicas must share the postgres store
+	var limitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if config.RateLimitStore == "postgres" {
+		if storage.IsSQLite() {
+			log.Fatalf("RATE_LIMIT_STORE=postgres requires the postgres database driver")
+		}
+		limitStore = ratelimit.NewPostgresStore()
+	}
+	ratelimit.Init(limitStore, ratelimit.Limits{
+		RequestsPerSecond: config.RateLimitRPS,
+		Burst:             config.RateLimitBurst,
+		MaxConcurrent:     config.MaxConcurrent,
+		QueueTimeout:      time.Duration(config.ConcurrencyQueueWait) * time.Second,
+	})
+
+	// Open the object store for offloaded payloads and archived executions
+	objectStore, err := objectstore.Open(context.Background(), objectstore.Config{
+		Backend:   config.ObjectStore,
+		Dir:       config.ObjectStoreDir,
+		Endpoint:  config.S3Endpoint,
+		Bucket:    config.S3Bucket,
+		Region:    config.S3Region,
+		AccessKey: config.S3AccessKey,
+		SecretKey: config.S3SecretKey,
+		UseSSL:    config.S3UseSSL,
+	})
+	if err != nil {
+		log.Fatalf("Object store initialization failed: %v", err)
+	}
+	payloads.Init(objectStore, payloads.Limits{
+		MaxInputBytes:    config.MaxInputBytes,
+		MaxOutputBytes:   config.MaxOutputBytes,
+		MaxLogBytes:      config.MaxLogBytes,
+		OffloadThreshold: config.PayloadOffloadThreshold,
+	})
+
+	repos := storage.NewGormRepos(storage.DB)
+	metering.Init(repos)
+	quotas.Init(repos)
+	vmManager := vm.NewVMManager()
+	engine := exec.NewExecutionEngine(vmManager, repos)
+	metrics.RegisterCollectors(vmManager)
+
+	// Start the cron scheduler; safe to run on every replica
+	if config.SchedulerEnabled {
+		interval := time.Duration(config.SchedulerPollInterval) * time.Second
+		scheduler.New(engine, interval).Start(context.Background())
+		utils.Info("Cron scheduler started", zap.Duration("interval", interval))
+	}
+
+	// Start the event trigger consumer
+	// SQLite has no LISTEN/NOTIFY, so consumers rely on polling
+	listenDSN := config.DatabaseURL
+	if storage.IsSQLite() {
+		listenDSN = ""
+	}
+	eventQueue := triggers.NewPostgresQueue(
+		listenDSN,
+		time.Duration(config.EventsPollInterval)*time.Second,
+		time.Duration(config.EventsVisibilityTimeout)*time.Second,
+		config.EventsMaxDeliveries,
+	)
+	if config.EventsEnabled {
+		go func() {
+			if err := eventQueue.Run(context.Background(), triggers.ExecuteBatch(engine)); err != nil {
+				utils.Error("Event consumer stopped", zap.Error(err))
+			}
+		}()
+		utils.Info("Event consumer started")
+	}
+
+	// Start the workflow runner; it also resumes runs left by stopped replicas
+	workflowRunner := workflows.NewRunner(
+		engine,
+		time.Duration(config.WorkflowsPollInterval)*time.Second,
+		time.Duration(config.WorkflowsLeaseTimeout)*time.Second,
+	)
+	workflowRunner.Start(context.Background())
+
+	// Start the retention reaper; safe to run on every replica
+	var archive objectstore.Store
+	if config.ArchiveEnabled {
+		archive = objectStore
+	}
+	retention.NewReaper(archive, retention.Policy{
+		Days:     config.RetentionDays,
+		MaxCount: config.RetentionMaxCount,
+	}, time.Duration(config.RetentionSweepInterval)*time.Second).Start(context.Background())
+
+	// Re-arm async retries that were waiting before a restart
+	if err := api.ResumeRetries(); err != nil {
+		utils.Error("Failed to resume pending retries", zap.Error(err))
+	}
+
+	// Create Fiber app
+	app := fiber.New(fiber.Config{
+		AppName: "VoltRun v1.0.0",
+		// Leave room for the request envelope around a maximum-size input
+		BodyLimit: config.MaxInputBytes + 1<<20,
+		ErrorHandler: func(c *fiber.Ctx, err error) error {
+			code := fiber.StatusInternalServerError
+			if e, ok := err.(*fiber.Error); ok {
+				code = e.Code
+			}
+			utils.Error("Request error")
+			return c.Status(code).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		},
+	})
+
+	// Middleware
+	app.Use(recover.New())
+	app.Use(metrics.Middleware())
+	app.Use(tracing.Middleware())
+	app.Use(logger.New())
+	app.Use(cors.New(cors.Config{
+		AllowOrigins: "*",
+		AllowHeaders: "Origin, Content-Type, Accept, Authorization, X-Organization-ID",
+	}))
+
+	// Health check
+	app.Get("/health", func(c *fiber.Ctx) error {
+		return c.JSON(fiber.Map{
+			"status":  "healthy",
+			"service": "voltrun-backend",
+			"version": "1.0.0",
+		})
+	})
+
+	// Prometheus metrics
+	app.Get("/metrics", metrics.Handler())
+
+	// Setup API routes
+	auth.AccessTokenTTL = time.Duration(config.AccessTokenTTL) * time.Minute
+	auth.RefreshTokenTTL = time.Duration(config.RefreshTokenTTL) * time.Hour
+	api.IdempotencyTTL = time.Duration(config.IdempotencyKeyTTL) * time.Hour
+	api.ObjectStore = objectStore
+	oidcProviders := make([]oidc.ProviderConfig, len(config.OIDCProviders))
+	for i, provider := range config.OIDCProviders {
+		oidcProviders[i] = oidc.ProviderConfig{
+			Name:         provider.Name,
+			DisplayName:  provider.DisplayName,
+			Issuer:       provider.Issuer,
+			ClientID:     provider.ClientID,
+			ClientSecret: provider.ClientSecret,
+			Scopes:       provider.Scopes,
+		}
+	}
+	if err := oidc.Init(oidcProviders, config.OIDCRedirectBaseURL); err != nil {
+		log.Fatalf("OIDC configuration invalid: %v", err)
+	}
+	api.OIDCLoginRedirectURL = config.OIDCLoginRedirectURL
+	api.SetupRoutes(app, repos, engine, eventQueue, workflowRunner)
+
+	// Start server
+	utils.Info("Server starting on port " + config.Port)
+	log.Printf("🚀 VoltRun server listening on http://localhost:%s", config.Port)
+	log.Printf("📊 Health check: http://localhost:%s/health", config.Port)
+	log.Printf("📈 Metrics: http://localhost:%s/metrics", config.Port)
+	log.Printf("� API endpoint: http://localhost:%s/api", config.Port)
+
+	if err := app.Listen(":" + config.Port); err != nil {
+		utils.Error("Server failed to start")
+		log.Fatal(err)
+	}
+}
+
+// runMigrate implements "voltrun migrate [status|up|down [steps]]"
+func runMigrate(config *utils.Config, args []string) error {
+	if err := storage.InitDB(config.DatabaseDriver, config.DatabaseURL); err != nil {
+		return err
+	}
+
+	command := "status"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "status":
+		statuses, err := storage.MigrationStatuses()
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, applied)
+		}
+	case "up":
+		count, err := storage.MigrateUp()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migration(s)\n", count)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid step count %q", args[1])
+			}
+			steps = n
+		}
+		count, err := storage.MigrateDown(steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Rolled back %d migration(s)\n", count)
+	default:
+		return fmt.Errorf("unknown migrate command %q (use status, up or down [steps])", command)
+	}
+	return nil
+}
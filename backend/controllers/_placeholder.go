@@ -1,3 +1,3 @@
-package controllers
-
-// controller placeholders (functions, auth, etc.)
+package controllers
+
+// controller placeholders (functions, auth, etc.)
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/auth"
+	"github.com/voltrun/backend/internal/metering"
+	"github.com/voltrun/backend/internal/quotas"
+	"github.com/voltrun/backend/internal/secrets"
+	"github.com/voltrun/backend/internal/storage"
+	"github.com/voltrun/backend/internal/utils"
+)
+
+// testServer is the API running against in-memory repositories, with a
+// user and an active function in the user's personal organization
+type testServer struct {
+	t        *testing.T
+	app      *fiber.App
+	repos    *storage.Repos
+	user     *storage.User
+	token    string
+	function *storage.Function
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	t.Setenv("JWT_SECRET", "test-secret")
+	if err := utils.InitLogger(); err != nil {
+		t.Fatal(err)
+	}
+	if err := secrets.InitKeyring("test", "test-key", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	repositories := storage.NewMemoryRepos()
+	metering.Init(repositories)
+	quotas.Init(repositories)
+	app := fiber.New()
+	SetupRoutes(app, repositories, nil, nil, nil)
+
+	s := &testServer{t: t, app: app, repos: repositories}
+	s.user, s.token = s.newUser("owner@example.com")
+	s.function = s.newFunction(s.user)
+	return s
+}
+
+// newUser creates a user with a personal organization and returns it with
+// an access token
+func (s *testServer) newUser(email string) (*storage.User, string) {
+	s.t.Helper()
+	user := &storage.User{Email: email, Password: "x"}
+	if err := s.repos.Users.Create(context.Background(), user); err != nil {
+		s.t.Fatal(err)
+	}
+	token, err := auth.GenerateToken(user.ID, user.Email, uuid.New())
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return user, token
+}
+
+// newFunction creates an active function in the user's personal organization
+func (s *testServer) newFunction(user *storage.User) *storage.Function {
+	s.t.Helper()
+	function := &storage.Function{
+		OrganizationID: user.ID,
+		UserID:         user.ID,
+		Name:           "hello",
+		Runtime:        "nodejs",
+		Code:           "exports.handler = async () => ({})",
+		Status:         "active",
+	}
+	if err := s.repos.Functions.Create(context.Background(), function); err != nil {
+		s.t.Fatal(err)
+	}
+	return function
+}
+
+// do sends a request as the server's user
+func (s *testServer) do(method, path string, body interface{}) (int, []byte) {
+	s.t.Helper()
+	return s.doAs(s.token, method, path, body)
+}
+
+// doAs sends a request with token, encoding body as JSON unless it is nil
+func (s *testServer) doAs(token, method, path string, body interface{}) (int, []byte) {
+	s.t.Helper()
+	return s.request(token, uuid.Nil, method, path, body)
+}
+
+// doIn sends a request as the server's user acting on organizationID
+func (s *testServer) doIn(organizationID uuid.UUID, method, path string, body interface{}) (int, []byte) {
+	s.t.Helper()
+	return s.request(s.token, organizationID, method, path, body)
+}
+
+// request sends a request with token, naming organizationID in the
+// organization header unless it is nil
+func (s *testServer) request(token string, organizationID uuid.UUID, method, path string, body interface{}) (int, []byte) {
+	s.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if organizationID != uuid.Nil {
+		req.Header.Set(OrganizationHeader, organizationID.String())
+	}
+	resp, err := s.app.Test(req, -1)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	return resp.StatusCode, data
+}
+
+// functionPath returns the path of a function route
+func (s *testServer) functionPath(suffix string) string {
+	return "/api/functions/" + s.function.ID.String() + suffix
+}
+
+// expectStatus fails the test when got is not want
+func expectStatus(t *testing.T, got, want int, body []byte) {
+	t.Helper()
+	if got != want {
+		t.Fatalf("status = %d, want %d: %s", got, want, body)
+	}
+}
+
+// decode unmarshals a response body
+func decode(t *testing.T, body []byte, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(body, v); err != nil {
+		t.Fatalf("decode %s: %v", body, err)
+	}
+}
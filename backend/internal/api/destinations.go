@@ -0,0 +1,215 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/destinations"
+	"github.com/voltrun/backend/internal/exec"
+	"github.com/voltrun/backend/internal/payloads"
+	"github.com/voltrun/backend/internal/secrets"
+	"github.com/voltrun/backend/internal/storage"
+	"github.com/voltrun/backend/internal/utils"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+)
+
+// maxChainDepth stops function destinations from chaining forever
+const maxChainDepth = 8
+
+// rotateDestinationSecret issues a new HMAC key for http callbacks
+func rotateDestinationSecret(c *fiber.Ctx) error {
+	function, err := findFunction(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Function not found"})
+	}
+
+	secret, err := issueDestinationSecret(function)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to generate secret"})
+	}
+	if err := repos.Functions.Save(c.UserContext(), function); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update function"})
+	}
+
+	// Return the secret only once
+	return c.JSON(fiber.Map{
+		"secret":  secret,
+		"message": "Save this secret securely. It won't be shown again.",
+	})
+}
+
+// issueDestinationSecret generates and encrypts a new callback signing key
+func issueDestinationSecret(function *storage.Function) (string, error) {
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", err
+	}
+	secret := "dsec_" + hex.EncodeToString(secretBytes)
+
+	ciphertext, keyID, err := secrets.Encrypt(secret)
+	if err != nil {
+		return "", err
+	}
+	function.DestinationSecretCiphertext = ciphertext
+	function.DestinationSecretKeyID = keyID
+	return secret, nil
+}
+
+// applyDestinations validates and stores function-level destinations.
+// A nil destination is left unchanged and one with an empty type is
+// cleared. If an http destination is configured on a function without a
+// signing secret, one is generated and returned so it can be shown once.
+func applyDestinations(ctx context.Context, function *storage.Function, onSuccess, onFailure *destinations.Destination) (string, error) {
+	needsSecret := false
+	for _, target := range []struct {
+		destination *destinations.Destination
+		column      *datatypes.JSON
+	}{
+		{onSuccess, &function.OnSuccess},
+		{onFailure, &function.OnFailure},
+	} {
+		if target.destination == nil {
+			continue
+		}
+		if target.destination.Type == "" {
+			*target.column = nil
+			continue
+		}
+		if err := validateDestination(ctx, function.OrganizationID, target.destination); err != nil {
+			return "", err
+		}
+		*target.column = datatypes.JSON(marshalJSON(target.destination))
+		needsSecret = needsSecret || target.destination.Type == destinations.TypeHTTP
+	}
+
+	if needsSecret && function.DestinationSecretCiphertext == "" {
+		return issueDestinationSecret(function)
+	}
+	return "", nil
+}
+
+// validateDestination checks a destination and that a chained function
+// belongs to the same organization
+func validateDestination(ctx context.Context, organizationID uuid.UUID, destination *destinations.Destination) error {
+	if err := destination.Validate(); err != nil {
+		return err
+	}
+	if destination.Type == destinations.TypeFunction {
+		if _, err := repos.Functions.GetForOrganization(ctx, *destination.FunctionID, organizationID); err != nil {
+			return errors.New("destination function not found")
+		}
+	}
+	return nil
+}
+
+// dispatchDestination delivers the final outcome of an async execution to
+// its on_success or on_failure destination, preferring per-request ones
+func dispatchDestination(executionID uuid.UUID, function storage.Function, succeeded bool) {
+	execution, err := repos.Executions.Get(context.Background(), executionID)
+	if err != nil {
+		return
+	}
+	if err := payloads.Hydrate(context.Background(), execution); err != nil {
+		utils.Error("Failed to load execution payloads", zap.String("execution_id", executionID.String()), zap.Error(err))
+		return
+	}
+
+	raw := execution.OnFailure
+	if succeeded {
+		raw = execution.OnSuccess
+	}
+	if len(raw) == 0 {
+		raw = function.OnFailure
+		if succeeded {
+			raw = function.OnSuccess
+		}
+	}
+	if len(raw) == 0 {
+		return
+	}
+
+	var destination destinations.Destination
+	if err := json.Unmarshal(raw, &destination); err != nil {
+		utils.Error("Invalid destination", zap.String("execution_id", executionID.String()), zap.Error(err))
+		return
+	}
+
+	completedAt := time.Now()
+	if execution.CompletedAt != nil {
+		completedAt = *execution.CompletedAt
+	}
+	record := destinations.Record{
+		ExecutionID:         execution.ID,
+		OriginalExecutionID: execution.OriginalExecutionID,
+		FunctionID:          execution.FunctionID,
+		Status:              execution.Status,
+		Attempt:             execution.Attempt,
+		TriggerType:         execution.TriggerType,
+		Input:               json.RawMessage(execution.Input),
+		Output:              json.RawMessage(execution.Output),
+		Error:               execution.Error,
+		ErrorClass:          execution.ErrorClass,
+		DurationMS:          execution.DurationMS,
+		CompletedAt:         completedAt,
+	}
+
+	logger := utils.Logger.With(
+		zap.String("execution_id", executionID.String()),
+		zap.String("destination", destination.Type),
+	)
+
+	switch destination.Type {
+	case destinations.TypeHTTP:
+		secret, err := secrets.Decrypt(function.DestinationSecretCiphertext, function.DestinationSecretKeyID)
+		if err != nil {
+			logger.Error("Failed to load destination secret", zap.Error(err))
+			return
+		}
+		if err := destinations.DeliverHTTP(context.Background(), destination.URL, secret, record); err != nil {
+			logger.Error("Callback delivery failed", zap.Error(err))
+		}
+
+	case destinations.TypeFunction:
+		if execution.ChainDepth >= maxChainDepth {
+			logger.Warn("Destination chain too deep; not invoking next function")
+			return
+		}
+		next, err := repos.Functions.GetForOrganization(context.Background(), *destination.FunctionID, function.OrganizationID)
+		if err != nil || next.Status != "active" {
+			logger.Error("Destination function not found", zap.Error(err))
+			return
+		}
+
+		input := make(map[string]interface{})
+		json.Unmarshal(marshalJSON(record), &input)
+
+		chained := storage.Execution{
+			UserID:         next.UserID,
+			OrganizationID: next.OrganizationID,
+			FunctionID:     next.ID,
+			Status:         "pending",
+			TriggerType:    exec.TriggerDestination,
+			TriggerSource:  execution.ID.String(),
+			Input:          marshalJSON(input),
+			ChainDepth:     execution.ChainDepth + 1,
+		}
+		offloadPayloads(context.Background(), &chained)
+		if err := repos.Executions.Create(context.Background(), &chained); err != nil {
+			logger.Error("Failed to create chained execution", zap.Error(err))
+			return
+		}
+		go executeAsync(context.Background(), chained.ID, *next, input)
+
+	case destinations.TypeQueue:
+		if _, err := eventPublisher.Publish(context.Background(), function.OrganizationID, destination.Topic, marshalJSON(record)); err != nil {
+			logger.Error("Failed to publish destination event", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/auth"
+	"github.com/voltrun/backend/internal/storage"
+	"github.com/voltrun/backend/internal/triggers"
+)
+
+// maxEventBatchSize bounds how many messages one invocation receives
+const maxEventBatchSize = 100
+
+// eventPublisher queues messages published through the API
+var eventPublisher triggers.Publisher
+
+type EventTriggerRequest struct {
+	Topic     string `json:"topic"`
+	BatchSize int    `json:"batch_size"`
+	Enabled   *bool  `json:"enabled"`
+}
+
+// Event trigger handlers
+func listEventTriggers(c *fiber.Ctx) error {
+	organizationID, err := auth.GetOrganizationID(c)
+	if err != nil {
+		return c.Status(403).JSON(fiber.Map{"error": "Forbidden"})
+	}
+
+	functionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Function not found"})
+	}
+	eventTriggers, err := repos.EventTriggers.ListForFunction(c.UserContext(), functionID, organizationID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch event triggers"})
+	}
+
+	return c.JSON(eventTriggers)
+}
+
+func createEventTrigger(c *fiber.Ctx) error {
+	userID, err := auth.GetUserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	function, err := findFunction(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Function not found"})
+	}
+
+	var req EventTriggerRequest
+	if err := c.BodyParser(&req); err != nil || req.Topic == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if req.BatchSize == 0 {
+		req.BatchSize = 1
+	}
+	if req.BatchSize < 1 || req.BatchSize > maxEventBatchSize {
+		return c.Status(400).JSON(fiber.Map{"error": "batch_size must be between 1 and 100"})
+	}
+
+	eventTrigger := storage.EventTrigger{
+		FunctionID:     function.ID,
+		UserID:         userID,
+		OrganizationID: function.OrganizationID,
+		Topic:          req.Topic,
+		BatchSize:      req.BatchSize,
+		Enabled:        req.Enabled == nil || *req.Enabled,
+	}
+
+	if err := repos.EventTriggers.Create(c.UserContext(), &eventTrigger); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create event trigger"})
+	}
+
+	return c.Status(201).JSON(eventTrigger)
+}
+
+func updateEventTrigger(c *fiber.Ctx) error {
+	eventTrigger, err := findEventTrigger(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Event trigger not found"})
+	}
+
+	var req EventTriggerRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	// Topic is immutable; queued messages are bound to the trigger
+	if req.BatchSize != 0 {
+		if req.BatchSize < 1 || req.BatchSize > maxEventBatchSize {
+			return c.Status(400).JSON(fiber.Map{"error": "batch_size must be between 1 and 100"})
+		}
+		eventTrigger.BatchSize = req.BatchSize
+	}
+	if req.Enabled != nil {
+		eventTrigger.Enabled = *req.Enabled
+	}
+
+	if err := repos.EventTriggers.Save(c.UserContext(), eventTrigger); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update event trigger"})
+	}
+
+	return c.JSON(eventTrigger)
+}
+
+func deleteEventTrigger(c *fiber.Ctx) error {
+	eventTrigger, err := findEventTrigger(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Event trigger not found"})
+	}
+
+	// Undelivered and dead-lettered messages go with the trigger
+	if err := repos.EventTriggers.Delete(c.UserContext(), eventTrigger); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete event trigger"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Event trigger deleted successfully"})
+}
+
+// findEventTrigger loads the :triggerId trigger of the :id function in the
+// request's organization
+func findEventTrigger(c *fiber.Ctx) (*storage.EventTrigger, error) {
+	organizationID, err := auth.GetOrganizationID(c)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.Parse(c.Params("triggerId"))
+	if err != nil {
+		return nil, storage.ErrNotFound
+	}
+	functionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, storage.ErrNotFound
+	}
+	return repos.EventTriggers.GetForFunction(c.UserContext(), id, functionID, organizationID)
+}
+
+func listEventDeadLetters(c *fiber.Ctx) error {
+	eventTrigger, err := findEventTrigger(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Event trigger not found"})
+	}
+
+	deadLetters, err := repos.EventTriggers.ListDeadLetters(c.UserContext(), eventTrigger.ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch dead letters"})
+	}
+
+	return c.JSON(deadLetters)
+}
+
+// redriveEventDeadLetter queues a dead-lettered message for its trigger
+// again with a fresh delivery count
+func redriveEventDeadLetter(c *fiber.Ctx) error {
+	eventTrigger, err := findEventTrigger(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Event trigger not found"})
+	}
+
+	id, err := uuid.Parse(c.Params("deadLetterId"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Dead letter not found"})
+	}
+	deadLetter, err := repos.EventTriggers.GetDeadLetter(c.UserContext(), id, eventTrigger.ID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Dead letter not found"})
+	}
+
+	message, err := repos.EventTriggers.Redrive(c.UserContext(), deadLetter)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to redrive dead letter"})
+	}
+
+	return c.Status(202).JSON(fiber.Map{"message_id": message.ID})
+}
+
+func deleteEventDeadLetter(c *fiber.Ctx) error {
+	eventTrigger, err := findEventTrigger(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Event trigger not found"})
+	}
+
+	id, err := uuid.Parse(c.Params("deadLetterId"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Dead letter not found"})
+	}
+	if err := repos.EventTriggers.DeleteDeadLetter(c.UserContext(), id, eventTrigger.ID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "Dead letter not found"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete dead letter"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Dead letter deleted successfully"})
+}
+
+// publishEvent queues the request body for every trigger on the
+// organization's topic
+func publishEvent(c *fiber.Ctx) error {
+	organizationID, err := auth.GetOrganizationID(c)
+	if err != nil {
+		return c.Status(403).JSON(fiber.Map{"error": "Forbidden"})
+	}
+
+	body := c.Body()
+	if !json.Valid(body) {
+		return c.Status(400).JSON(fiber.Map{"error": "Event payload must be valid JSON"})
+	}
+
+	queued, err := eventPublisher.Publish(c.Context(), organizationID, c.Params("topic"), json.RawMessage(body))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to publish event"})
+	}
+
+	return c.Status(202).JSON(fiber.Map{
+		"topic":  c.Params("topic"),
+		"queued": queued,
+	})
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/storage"
+)
+
+func TestEventTriggers(t *testing.T) {
+	s := newTestServer(t)
+
+	status, body := s.do("POST", s.functionPath("/event-triggers"), map[string]interface{}{"topic": "orders", "batch_size": 500})
+	expectStatus(t, status, 400, body)
+
+	status, body = s.do("POST", s.functionPath("/event-triggers"), map[string]string{"topic": "orders"})
+	expectStatus(t, status, 201, body)
+	var created storage.EventTrigger
+	decode(t, body, &created)
+	if created.BatchSize != 1 || !created.Enabled || created.OrganizationID != s.function.OrganizationID {
+		t.Fatalf("trigger = %+v, want an enabled batch of 1 in the function's organization", created)
+	}
+	path := s.functionPath("/event-triggers/" + created.ID.String())
+
+	status, body = s.do("PUT", path, map[string]interface{}{"batch_size": 10, "enabled": false})
+	expectStatus(t, status, 200, body)
+	var updated storage.EventTrigger
+	decode(t, body, &updated)
+	if updated.BatchSize != 10 || updated.Enabled || updated.Topic != "orders" {
+		t.Fatalf("trigger = %+v, want a disabled batch of 10 on orders", updated)
+	}
+
+	status, body = s.do("GET", s.functionPath("/event-triggers"), nil)
+	expectStatus(t, status, 200, body)
+	var listed []storage.EventTrigger
+	decode(t, body, &listed)
+	if len(listed) != 1 || listed[0].BatchSize != 10 {
+		t.Fatalf("triggers = %+v, want the updated one", listed)
+	}
+
+	status, body = s.do("GET", path+"/dead-letters", nil)
+	expectStatus(t, status, 200, body)
+	if string(body) != "[]" {
+		t.Fatalf("dead letters = %s, want none", body)
+	}
+	status, body = s.do("POST", path+"/dead-letters/"+uuid.NewString()+"/redrive", nil)
+	expectStatus(t, status, 404, body)
+
+	status, body = s.do("DELETE", path, nil)
+	expectStatus(t, status, 200, body)
+	status, body = s.do("GET", path+"/dead-letters", nil)
+	expectStatus(t, status, 404, body)
+}
+
+func TestEventTriggersOfOtherOrganization(t *testing.T) {
+	s := newTestServer(t)
+	status, body := s.do("POST", s.functionPath("/event-triggers"), map[string]string{"topic": "orders"})
+	expectStatus(t, status, 201, body)
+	var created storage.EventTrigger
+	decode(t, body, &created)
+
+	_, token := s.newUser("other@example.com")
+	status, body = s.doAs(token, "GET", s.functionPath("/event-triggers"), nil)
+	expectStatus(t, status, 200, body)
+	if string(body) != "[]" {
+		t.Fatalf("triggers = %s, want none", body)
+	}
+	status, body = s.doAs(token, "POST", s.functionPath("/event-triggers"), map[string]string{"topic": "orders"})
+	expectStatus(t, status, 404, body)
+	status, body = s.doAs(token, "DELETE", s.functionPath("/event-triggers/"+created.ID.String()), nil)
+	expectStatus(t, status, 404, body)
+}
@@ -0,0 +1,279 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/auth"
+	"github.com/voltrun/backend/internal/objectstore"
+	"github.com/voltrun/backend/internal/payloads"
+	"github.com/voltrun/backend/internal/retention"
+	"github.com/voltrun/backend/internal/storage"
+	"github.com/voltrun/backend/internal/utils"
+	"go.uber.org/zap"
+)
+
+// ObjectStore holds archived executions and offloaded payloads; set
+// before SetupRoutes
+var ObjectStore objectstore.Store
+
+const (
+	defaultExecutionPageSize = 50
+	maxExecutionPageSize     = 200
+)
+
+// ExecutionSummary is the list view of an execution
+type ExecutionSummary struct {
+	ID            uuid.UUID        `json:"id"`
+	FunctionID    uuid.UUID        `json:"function_id"`
+	Status        string           `json:"status"`
+	TriggerType   string           `json:"trigger_type"`
+	TriggerSource string           `json:"trigger_source,omitempty"`
+	Error         string           `json:"error,omitempty"`
+	ErrorClass    string           `json:"error_class,omitempty"`
+	DurationMS    int64            `json:"duration_ms"`
+	MemoryUsed    int              `json:"memory_used"`
+	ColdStart     bool             `json:"cold_start"`
+	Attempt       int              `json:"attempt"`
+	StartedAt     *time.Time       `json:"started_at,omitempty"`
+	CompletedAt   *time.Time       `json:"completed_at,omitempty"`
+	CreatedAt     time.Time        `json:"created_at"`
+	Function      *FunctionSummary `json:"function,omitempty"`
+}
+
+// FunctionSummary identifies an execution's function without its code
+type FunctionSummary struct {
+	ID      uuid.UUID `json:"id"`
+	Name    string    `json:"name"`
+	Runtime string    `json:"runtime"`
+}
+
+// listExecutions pages through the organization's executions, newest first.
+// Query parameters: limit, cursor (next_cursor of the previous page),
+// status, function_id, trigger_type, from and to (RFC 3339 or
+// YYYY-MM-DD), min_duration_ms, max_duration_ms and q, a full-text search
+// over logs and errors.
+func listExecutions(c *fiber.Ctx) error {
+	organizationID, err := auth.GetOrganizationID(c)
+	if err != nil {
+		return c.Status(403).JSON(fiber.Map{"error": "Forbidden"})
+	}
+	filter, err := executionFilter(c, organizationID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// Fetch one extra row to learn whether another page exists
+	limit := filter.Limit
+	filter.Limit++
+	executions, err := repos.Executions.List(c.UserContext(), filter)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch executions"})
+	}
+
+	var nextCursor string
+	if len(executions) > limit {
+		executions = executions[:limit]
+		last := executions[limit-1]
+		nextCursor = encodeExecutionCursor(last.CreatedAt, last.ID)
+	}
+
+	summaries := make([]ExecutionSummary, 0, len(executions))
+	for _, execution := range executions {
+		summaries = append(summaries, summarizeExecution(&execution))
+	}
+
+	return c.JSON(fiber.Map{
+		"executions":  summaries,
+		"next_cursor": nextCursor,
+	})
+}
+
+// executionFilter parses the listing parameters of the request
+func executionFilter(c *fiber.Ctx, organizationID uuid.UUID) (storage.ExecutionFilter, error) {
+	filter := storage.ExecutionFilter{
+		OrganizationID: organizationID,
+		Limit:          c.QueryInt("limit", defaultExecutionPageSize),
+		Search:         strings.TrimSpace(c.Query("q")),
+	}
+	if filter.Limit < 1 || filter.Limit > maxExecutionPageSize {
+		return filter, fmt.Errorf("limit must be between 1 and %d", maxExecutionPageSize)
+	}
+
+	if status := c.Query("status"); status != "" {
+		filter.Statuses = strings.Split(status, ",")
+	}
+	if value := c.Query("function_id"); value != "" {
+		functionID, err := uuid.Parse(value)
+		if err != nil {
+			return filter, errors.New("invalid function_id")
+		}
+		filter.FunctionID = &functionID
+	}
+	if triggerType := c.Query("trigger_type"); triggerType != "" {
+		filter.TriggerTypes = strings.Split(triggerType, ",")
+	}
+
+	if value := c.Query("from"); value != "" {
+		from, err := parseUsageTime(value)
+		if err != nil {
+			return filter, errors.New("invalid from; use RFC 3339 or YYYY-MM-DD")
+		}
+		filter.From = &from
+	}
+	if value := c.Query("to"); value != "" {
+		to, err := parseUsageTime(value)
+		if err != nil {
+			return filter, errors.New("invalid to; use RFC 3339 or YYYY-MM-DD")
+		}
+		filter.To = &to
+	}
+
+	if c.Query("min_duration_ms") != "" {
+		minDuration := int64(c.QueryInt("min_duration_ms"))
+		filter.MinDurationMS = &minDuration
+	}
+	if c.Query("max_duration_ms") != "" {
+		maxDuration := int64(c.QueryInt("max_duration_ms"))
+		filter.MaxDurationMS = &maxDuration
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		createdAt, id, err := decodeExecutionCursor(cursor)
+		if err != nil {
+			return filter, errors.New("Invalid cursor")
+		}
+		filter.After = &storage.ExecutionCursor{CreatedAt: createdAt, ID: id}
+	}
+
+	return filter, nil
+}
+
+func summarizeExecution(execution *storage.Execution) ExecutionSummary {
+	summary := ExecutionSummary{
+		ID:            execution.ID,
+		FunctionID:    execution.FunctionID,
+		Status:        execution.Status,
+		TriggerType:   execution.TriggerType,
+		TriggerSource: execution.TriggerSource,
+		Error:         execution.Error,
+		ErrorClass:    execution.ErrorClass,
+		DurationMS:    execution.DurationMS,
+		MemoryUsed:    execution.MemoryUsed,
+		ColdStart:     execution.ColdStart,
+		Attempt:       execution.Attempt,
+		StartedAt:     execution.StartedAt,
+		CompletedAt:   execution.CompletedAt,
+		CreatedAt:     execution.CreatedAt,
+	}
+	if execution.Function.ID != uuid.Nil {
+		summary.Function = &FunctionSummary{
+			ID:      execution.Function.ID,
+			Name:    execution.Function.Name,
+			Runtime: execution.Function.Runtime,
+		}
+	}
+	return summary
+}
+
+// offloadPayloads moves large payloads of a new execution row to the
+// object store. Failures leave placeholders and are only logged.
+func offloadPayloads(ctx context.Context, execution *storage.Execution) {
+	if err := payloads.Offload(ctx, execution); err != nil {
+		utils.Error("Failed to offload execution payloads", zap.String("execution_id", execution.ID.String()), zap.Error(err))
+	}
+}
+
+// listArchivedExecutions pages through executions the retention reaper
+// moved to the object store. It accepts limit, cursor, function_id, from
+// and to like listExecutions.
+func listArchivedExecutions(c *fiber.Ctx) error {
+	organizationID, err := auth.GetOrganizationID(c)
+	if err != nil {
+		return c.Status(403).JSON(fiber.Map{"error": "Forbidden"})
+	}
+	filter, err := executionFilter(c, organizationID)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	limit := filter.Limit
+	filter.Limit++
+	archived, err := repos.Archives.List(c.UserContext(), filter)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch archived executions"})
+	}
+
+	var nextCursor string
+	if len(archived) > limit {
+		archived = archived[:limit]
+		last := archived[limit-1]
+		nextCursor = encodeExecutionCursor(last.CreatedAt, last.ID)
+	}
+
+	return c.JSON(fiber.Map{
+		"executions":  archived,
+		"next_cursor": nextCursor,
+	})
+}
+
+// getArchivedExecution returns the full record of an archived execution
+func getArchivedExecution(c *fiber.Ctx) error {
+	execution, err := loadArchivedExecution(c, c.Params("id"))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, objectstore.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "Archived execution not found"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to read archive"})
+	}
+	return c.JSON(execution)
+}
+
+// loadArchivedExecution reads an execution back from its archive object
+func loadArchivedExecution(c *fiber.Ctx, id string) (*storage.Execution, error) {
+	organizationID, err := auth.GetOrganizationID(c)
+	if err != nil {
+		return nil, err
+	}
+	archivedID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, storage.ErrNotFound
+	}
+	archived, err := repos.Archives.GetForOrganization(c.UserContext(), archivedID, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	return retention.Fetch(c.UserContext(), ObjectStore, archived)
+}
+
+// encodeExecutionCursor makes an opaque keyset cursor from the last row
+func encodeExecutionCursor(createdAt time.Time, id uuid.UUID) string {
+	raw := createdAt.UTC().Format(time.RFC3339Nano) + "|" + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeExecutionCursor(cursor string) (time.Time, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+	createdAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return time.Time{}, uuid.Nil, errors.New("malformed cursor")
+	}
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+	return t, parsed, nil
+}
@@ -0,0 +1,184 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/objectstore"
+	"github.com/voltrun/backend/internal/storage"
+)
+
+// executionPage is the response of the execution listing routes
+type executionPage struct {
+	Executions []struct {
+		ID uuid.UUID `json:"id"`
+	} `json:"executions"`
+	NextCursor string `json:"next_cursor"`
+}
+
+// newExecution stores an execution of the server's function created at
+// createdAt
+func (s *testServer) newExecution(status string, durationMS int64, logs string, createdAt time.Time) *storage.Execution {
+	s.t.Helper()
+	execution := &storage.Execution{
+		OrganizationID: s.function.OrganizationID,
+		UserID:         s.user.ID,
+		FunctionID:     s.function.ID,
+		Status:         status,
+		TriggerType:    "http",
+		DurationMS:     durationMS,
+		Logs:           logs,
+		CreatedAt:      createdAt,
+	}
+	if err := s.repos.Executions.Create(context.Background(), execution); err != nil {
+		s.t.Fatal(err)
+	}
+	return execution
+}
+
+// listIDs fetches every page of path and returns the IDs in order
+func (s *testServer) listIDs(path string, query url.Values) []uuid.UUID {
+	s.t.Helper()
+	var ids []uuid.UUID
+	for {
+		status, body := s.do("GET", path+"?"+query.Encode(), nil)
+		expectStatus(s.t, status, 200, body)
+		var page executionPage
+		decode(s.t, body, &page)
+		for _, execution := range page.Executions {
+			ids = append(ids, execution.ID)
+		}
+		if page.NextCursor == "" {
+			return ids
+		}
+		query.Set("cursor", page.NextCursor)
+	}
+}
+
+func TestListExecutions(t *testing.T) {
+	s := newTestServer(t)
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	oldest := s.newExecution("success", 100, "booted cache", start)
+	failed := s.newExecution("failed", 2000, "timeout contacting db", start.Add(time.Hour))
+	newest := s.newExecution("success", 300, "cache miss", start.Add(2*time.Hour))
+
+	other, _ := s.newUser("other@example.com")
+	hidden := s.newExecution("success", 100, "cache", start)
+	hidden.OrganizationID = other.ID
+	if err := s.repos.Executions.Save(context.Background(), hidden); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query url.Values
+		want  []uuid.UUID
+	}{
+		{"newest first across pages", url.Values{"limit": {"2"}}, []uuid.UUID{newest.ID, failed.ID, oldest.ID}},
+		{"status", url.Values{"status": {"failed"}}, []uuid.UUID{failed.ID}},
+		{"statuses", url.Values{"status": {"success,failed"}}, []uuid.UUID{newest.ID, failed.ID, oldest.ID}},
+		{"function", url.Values{"function_id": {s.function.ID.String()}}, []uuid.UUID{newest.ID, failed.ID, oldest.ID}},
+		{"time range", url.Values{"from": {start.Add(time.Hour).Format(time.RFC3339)}, "to": {start.Add(2 * time.Hour).Format(time.RFC3339)}}, []uuid.UUID{failed.ID}},
+		{"duration", url.Values{"min_duration_ms": {"200"}, "max_duration_ms": {"1000"}}, []uuid.UUID{newest.ID}},
+		{"search", url.Values{"q": {"cache"}}, []uuid.UUID{newest.ID, oldest.ID}},
+		{"search exclusion", url.Values{"q": {"cache -miss"}}, []uuid.UUID{oldest.ID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := s.listIDs("/api/executions", tt.query)
+			if len(got) != len(tt.want) {
+				t.Fatalf("executions = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("executions = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	for _, query := range []string{"limit=0", "limit=1000", "function_id=nope", "from=yesterday", "cursor=nope"} {
+		status, body := s.do("GET", "/api/executions?"+query, nil)
+		expectStatus(t, status, 400, body)
+	}
+}
+
+func TestArchivedExecutions(t *testing.T) {
+	s := newTestServer(t)
+	store := objectstore.NewLocalStore(t.TempDir())
+	ObjectStore = store
+	t.Cleanup(func() { ObjectStore = nil })
+
+	// Archive two executions into one object, as the retention reaper does
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	var archived []storage.ArchivedExecution
+	for i := 0; i < 2; i++ {
+		execution := storage.Execution{
+			ID:             uuid.New(),
+			OrganizationID: s.function.OrganizationID,
+			UserID:         s.user.ID,
+			FunctionID:     s.function.ID,
+			Status:         "success",
+			Logs:           "archived",
+			CreatedAt:      start.Add(time.Duration(i) * time.Hour),
+		}
+		if err := json.NewEncoder(gz).Encode(&execution); err != nil {
+			t.Fatal(err)
+		}
+		row := storage.ArchivedExecution{
+			ID:             execution.ID,
+			OrganizationID: execution.OrganizationID,
+			UserID:         execution.UserID,
+			FunctionID:     execution.FunctionID,
+			Status:         execution.Status,
+			CreatedAt:      execution.CreatedAt,
+			ObjectKey:      "executions/archive.jsonl.gz",
+			ArchivedAt:     time.Now(),
+		}
+		if err := s.repos.Archives.Create(context.Background(), &row); err != nil {
+			t.Fatal(err)
+		}
+		archived = append(archived, row)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put(context.Background(), "executions/archive.jsonl.gz", buf.Bytes(), "application/gzip"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := s.listIDs("/api/executions/archived", url.Values{"limit": {"1"}})
+	if len(got) != 2 || got[0] != archived[1].ID || got[1] != archived[0].ID {
+		t.Fatalf("archived = %v, want newest first", got)
+	}
+
+	status, body := s.do("GET", "/api/executions/archived/"+archived[0].ID.String(), nil)
+	expectStatus(t, status, 200, body)
+	var execution storage.Execution
+	decode(t, body, &execution)
+	if execution.ID != archived[0].ID || execution.Logs != "archived" {
+		t.Fatalf("archived execution = %+v", execution)
+	}
+
+	// Other organizations neither list nor read the archive
+	_, token := s.newUser("other@example.com")
+	status, body = s.doAs(token, "GET", "/api/executions/archived", nil)
+	expectStatus(t, status, 200, body)
+	var page executionPage
+	decode(t, body, &page)
+	if len(page.Executions) != 0 {
+		t.Fatalf("archived = %s, want none", body)
+	}
+	for _, id := range []string{archived[0].ID.String(), uuid.NewString(), "nope"} {
+		status, body = s.doAs(token, "GET", "/api/executions/archived/"+id, nil)
+		expectStatus(t, status, 404, body)
+	}
+}
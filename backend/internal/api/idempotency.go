@@ -0,0 +1,71 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/payloads"
+	"github.com/voltrun/backend/internal/storage"
+)
+
+// IdempotencyKeyHeader lets clients retry execute requests safely
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength bounds client-supplied keys
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyTTL is how long a key keeps returning its original execution
+var IdempotencyTTL = 24 * time.Hour
+
+var (
+	errIdempotencyKeyTooLong = errors.New("Idempotency-Key must be at most 255 characters")
+	errIdempotencyKeyReused  = errors.New("Idempotency-Key was already used with a different request body")
+)
+
+// createIdempotentExecution stores the execution under the key, or returns
+// the execution a previous request with the same key created. Keys belong
+// to the caller within the execution's organization, so members never see
+// each other's executions. Payloads are only offloaded once the key is
+// claimed, so replays leave nothing behind in the object store. A nil
+// result means the execution was created and should be started.
+func createIdempotentExecution(ctx context.Context, key string, callerID uuid.UUID, body []byte, execution *storage.Execution) (*storage.Execution, error) {
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, errIdempotencyKeyTooLong
+	}
+	sum := sha256.Sum256(body)
+	requestHash := hex.EncodeToString(sum[:])
+
+	record := &storage.IdempotencyKey{
+		OrganizationID: execution.OrganizationID,
+		UserID:         callerID,
+		FunctionID:     execution.FunctionID,
+		Key:            key,
+		RequestHash:    requestHash,
+		ExecutionID:    execution.ID,
+		ExpiresAt:      time.Now().Add(IdempotencyTTL),
+	}
+	offloaded := false
+	existing, err := repos.Idempotency.Claim(ctx, record, execution, func() {
+		offloadPayloads(ctx, execution)
+		offloaded = true
+	})
+	if err != nil {
+		if offloaded {
+			payloads.Delete(ctx, execution)
+		}
+		return nil, err
+	}
+	if existing == nil {
+		return nil, nil
+	}
+
+	// Another request already claimed the key
+	if existing.RequestHash != requestHash {
+		return nil, errIdempotencyKeyReused
+	}
+	return repos.Executions.Get(ctx, existing.ExecutionID)
+}
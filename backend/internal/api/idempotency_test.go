@@ -0,0 +1,94 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/storage"
+)
+
+func TestCreateIdempotentExecution(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	other, _ := s.newUser("other@example.com")
+
+	newExecution := func() *storage.Execution {
+		return &storage.Execution{
+			ID:             uuid.New(),
+			OrganizationID: s.function.OrganizationID,
+			UserID:         s.user.ID,
+			FunctionID:     s.function.ID,
+			Status:         "pending",
+		}
+	}
+
+	first := newExecution()
+	original, err := createIdempotentExecution(ctx, "order-1", s.user.ID, []byte(`{"a":1}`), first)
+	if err != nil || original != nil {
+		t.Fatalf("first request = %v, %v; want a new execution", original, err)
+	}
+	if _, err := s.repos.Executions.Get(ctx, first.ID); err != nil {
+		t.Fatalf("first execution was not stored: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		key      string
+		callerID uuid.UUID
+		body     string
+		wantErr  error
+		replayed bool
+	}{
+		{"retry replays the original", "order-1", s.user.ID, `{"a":1}`, nil, true},
+		{"different body is rejected", "order-1", s.user.ID, `{"a":2}`, errIdempotencyKeyReused, false},
+		{"another caller has its own keys", "order-1", other.ID, `{"a":1}`, nil, false},
+		{"another key creates an execution", "order-2", s.user.ID, `{"a":1}`, nil, false},
+		{"overlong key is rejected", strings.Repeat("k", maxIdempotencyKeyLength+1), s.user.ID, `{}`, errIdempotencyKeyTooLong, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			execution := newExecution()
+			original, err := createIdempotentExecution(ctx, tt.key, tt.callerID, []byte(tt.body), execution)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.replayed != (original != nil) {
+				t.Fatalf("original = %+v, want replayed %v", original, tt.replayed)
+			}
+			if tt.replayed && original.ID != first.ID {
+				t.Fatalf("replayed %s, want %s", original.ID, first.ID)
+			}
+
+			_, err = s.repos.Executions.Get(ctx, execution.ID)
+			if created := tt.wantErr == nil && !tt.replayed; created != (err == nil) {
+				t.Fatalf("execution stored = %v, want %v", err == nil, created)
+			}
+		})
+	}
+}
+
+func TestCreateIdempotentExecutionAfterExpiry(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+	ttl := IdempotencyTTL
+	IdempotencyTTL = -time.Minute
+	t.Cleanup(func() { IdempotencyTTL = ttl })
+
+	for i := 0; i < 2; i++ {
+		execution := &storage.Execution{
+			ID:             uuid.New(),
+			OrganizationID: s.function.OrganizationID,
+			UserID:         s.user.ID,
+			FunctionID:     s.function.ID,
+			Status:         "pending",
+		}
+		original, err := createIdempotentExecution(ctx, "order-1", s.user.ID, []byte(`{}`), execution)
+		if err != nil || original != nil {
+			t.Fatalf("request %d = %v, %v; want the expired key reused", i, original, err)
+		}
+	}
+}
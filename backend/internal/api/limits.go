@@ -0,0 +1,55 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/voltrun/backend/internal/ratelimit"
+	"github.com/voltrun/backend/internal/storage"
+)
+
+// LimitsRequest configures a function's request rate and reserved concurrency
+type LimitsRequest struct {
+	RateLimitRPS        *float64 `json:"rate_limit_rps"`
+	RateLimitBurst      *int     `json:"rate_limit_burst"`
+	ReservedConcurrency *int     `json:"reserved_concurrency"`
+}
+
+// applyLimits validates and applies the provided limits. Reservations must
+// leave at least one slot in the user's shared pool.
+func applyLimits(function *storage.Function, limits *LimitsRequest) error {
+	if limits.RateLimitRPS != nil {
+		if *limits.RateLimitRPS < 0 {
+			return errors.New("limits.rate_limit_rps must not be negative")
+		}
+		function.RateLimitRPS = *limits.RateLimitRPS
+	}
+	if limits.RateLimitBurst != nil {
+		if *limits.RateLimitBurst < 0 {
+			return errors.New("limits.rate_limit_burst must not be negative")
+		}
+		function.RateLimitBurst = *limits.RateLimitBurst
+	}
+	if function.RateLimitRPS > 0 && function.RateLimitBurst == 0 {
+		function.RateLimitBurst = int(math.Ceil(function.RateLimitRPS))
+	}
+
+	if limits.ReservedConcurrency != nil {
+		reserved := *limits.ReservedConcurrency
+		if reserved < 0 {
+			return errors.New("limits.reserved_concurrency must not be negative")
+		}
+		if reserved > 0 && ratelimit.MaxConcurrent() > 0 {
+			others, err := ratelimit.ReservedConcurrency(function.OrganizationID, function.ID)
+			if err != nil {
+				return err
+			}
+			if available := ratelimit.MaxConcurrent() - 1 - others; reserved > available {
+				return fmt.Errorf("limits.reserved_concurrency must not exceed %d", available)
+			}
+		}
+		function.ReservedConcurrency = reserved
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/voltrun/backend/internal/metering"
+)
+
+const (
+	defaultMetricsWindow = 24 * time.Hour
+	maxMetricsWindow     = 30 * 24 * time.Hour
+
+	// Windows up to this long are bucketed by minute unless asked otherwise
+	minuteBucketWindow = 6 * time.Hour
+	// Minute buckets are refused beyond this to keep the series small
+	maxMinuteBucketWindow = 24 * time.Hour
+)
+
+// getFunctionMetrics reports a function's recent health. Query
+// parameters: window (such as 15m, 6h or 7d; default 24h) and bucket
+// (minute or hour; chosen from the window by default).
+func getFunctionMetrics(c *fiber.Ctx) error {
+	function, err := findFunction(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Function not found"})
+	}
+
+	window := defaultMetricsWindow
+	if value := c.Query("window"); value != "" {
+		if window, err = parseWindow(value); err != nil || window <= 0 || window > maxMetricsWindow {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid window; use a duration such as 1h or 7d, up to 30d"})
+		}
+	}
+
+	bucket := metering.BucketHour
+	if window <= minuteBucketWindow {
+		bucket = metering.BucketMinute
+	}
+	if value := c.Query("bucket"); value != "" {
+		bucket = value
+	}
+	if bucket == metering.BucketMinute && window > maxMinuteBucketWindow {
+		return c.Status(400).JSON(fiber.Map{"error": "Minute buckets are limited to windows of 24h or less"})
+	}
+
+	to := time.Now().UTC()
+	from := to.Add(-window)
+	summary, series, err := metering.FunctionStats(c.UserContext(), function, from, to, bucket)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{
+		"function_id": function.ID,
+		"window":      c.Query("window", "24h"),
+		"from":        from,
+		"to":          to,
+		"bucket":      bucket,
+		"summary":     summary,
+		"series":      series,
+	})
+}
+
+// parseWindow accepts Go durations plus a day suffix, as in 7d
+func parseWindow(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/voltrun/backend/internal/oidc"
+	"github.com/voltrun/backend/internal/storage"
+	"github.com/voltrun/backend/internal/utils"
+	"go.uber.org/zap"
+)
+
+// OIDCLoginRedirectURL is the frontend page a finished OIDC login returns
+// to, with the tokens or an error in the URL fragment. When empty the
+// callback responds with JSON instead.
+var OIDCLoginRedirectURL string
+
+// errIdentityConflict means an OIDC login matched an existing account by
+// an email address the provider has not verified
+var errIdentityConflict = errors.New("email belongs to an existing account")
+
+func listOIDCProviders(c *fiber.Ctx) error {
+	providers := []fiber.Map{}
+	for _, provider := range oidc.Providers() {
+		providers = append(providers, fiber.Map{
+			"name":         provider.Name,
+			"display_name": provider.DisplayName,
+			"login_url":    "/api/auth/oidc/" + provider.Name + "/login",
+		})
+	}
+	return c.JSON(providers)
+}
+
+// handleOIDCLogin redirects the browser to the provider's sign-in page
+func handleOIDCLogin(c *fiber.Ctx) error {
+	authURL, err := oidc.Begin(c.UserContext(), c.Params("provider"))
+	if err != nil {
+		if errors.Is(err, oidc.ErrUnknownProvider) {
+			return c.Status(404).JSON(fiber.Map{"error": "OIDC provider not found"})
+		}
+		utils.Error("Failed to start OIDC login", zap.String("provider", c.Params("provider")), zap.Error(err))
+		return c.Status(502).JSON(fiber.Map{"error": "Failed to reach OIDC provider"})
+	}
+	return c.Redirect(authURL, fiber.StatusFound)
+}
+
+// handleOIDCCallback completes a login, provisioning or linking the user,
+// and starts a session like handleLogin does
+func handleOIDCCallback(c *fiber.Ctx) error {
+	if providerError := c.Query("error"); providerError != "" {
+		return oidcLoginFailed(c, 401, "OIDC login failed: "+providerError)
+	}
+
+	identity, err := oidc.Complete(c.UserContext(), c.Params("provider"), c.Query("state"), c.Query("code"))
+	switch {
+	case errors.Is(err, oidc.ErrUnknownProvider):
+		return oidcLoginFailed(c, 404, "OIDC provider not found")
+	case errors.Is(err, oidc.ErrInvalidState):
+		return oidcLoginFailed(c, 400, "Invalid or expired login attempt")
+	case err != nil:
+		utils.Warn("OIDC login failed", zap.String("provider", c.Params("provider")), zap.Error(err))
+		return oidcLoginFailed(c, 401, "OIDC login failed")
+	}
+
+	user, err := resolveOIDCUser(c, identity)
+	if err != nil {
+		if errors.Is(err, errIdentityConflict) {
+			return oidcLoginFailed(c, 409, "An account with this email already exists; the provider must verify the email to link it")
+		}
+		utils.Error("Failed to resolve OIDC user", zap.String("provider", identity.Provider), zap.Error(err))
+		return oidcLoginFailed(c, 500, "Failed to sign in")
+	}
+
+	response, err := startSession(c, user)
+	if err != nil {
+		return oidcLoginFailed(c, 500, "Failed to generate token")
+	}
+	if OIDCLoginRedirectURL == "" {
+		return c.JSON(response)
+	}
+
+	fragment := url.Values{}
+	fragment.Set("token", response["token"].(string))
+	fragment.Set("refresh_token", response["refresh_token"].(string))
+	fragment.Set("expires_in", strconv.Itoa(response["expires_in"].(int)))
+	return c.Redirect(OIDCLoginRedirectURL+"#"+fragment.Encode(), fiber.StatusFound)
+}
+
+// resolveOIDCUser returns the user an identity signs in as. Known
+// identities map to their user; otherwise the identity is linked to the
+// account with the same verified email, or a new user is provisioned.
+func resolveOIDCUser(c *fiber.Ctx, identity *oidc.Identity) (*storage.User, error) {
+	ctx := c.UserContext()
+	linked, err := repos.Identities.Get(ctx, identity.Issuer, identity.Subject)
+	if err == nil {
+		return repos.Users.Get(ctx, linked.UserID)
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		return nil, err
+	}
+	if identity.Email == "" {
+		return nil, errors.New("OIDC provider returned no email claim")
+	}
+
+	user, err := repos.Users.GetByEmail(ctx, identity.Email)
+	switch {
+	case err == nil:
+		// Linking on an unverified email would let anyone who controls
+		// the provider account take over the local one
+		if !identity.EmailVerified {
+			return nil, errIdentityConflict
+		}
+	case errors.Is(err, storage.ErrNotFound):
+		name := identity.Name
+		if name == "" {
+			name = identity.Email
+		}
+		// Provisioned users have no password and sign in through the
+		// provider only
+		user = &storage.User{Email: identity.Email, Name: name}
+		if err := repos.Users.Create(ctx, user); err != nil {
+			return nil, err
+		}
+		utils.Info("Provisioned user from OIDC login", zap.String("provider", identity.Provider), zap.String("user_id", user.ID.String()))
+	default:
+		return nil, err
+	}
+
+	if err := repos.Identities.Create(ctx, &storage.UserIdentity{
+		UserID:   user.ID,
+		Provider: identity.Provider,
+		Issuer:   identity.Issuer,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	}); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// oidcLoginFailed reports a failed login to the frontend when one is
+// configured, since the callback is a browser navigation
+func oidcLoginFailed(c *fiber.Ctx, status int, message string) error {
+	if OIDCLoginRedirectURL == "" {
+		return c.Status(status).JSON(fiber.Map{"error": message})
+	}
+	fragment := url.Values{}
+	fragment.Set("error", message)
+	return c.Redirect(OIDCLoginRedirectURL+"#"+fragment.Encode(), fiber.StatusFound)
+}
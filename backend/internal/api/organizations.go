@@ -0,0 +1,450 @@
+package api
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/auth"
+	"github.com/voltrun/backend/internal/quotas"
+	"github.com/voltrun/backend/internal/storage"
+)
+
+// OrganizationHeader selects the organization a request acts on. Requests
+// without it act on the caller's personal organization.
+const OrganizationHeader = "X-Organization-ID"
+
+// roleRank orders roles so a route can require a minimum one
+var roleRank = map[string]int{
+	storage.RoleViewer:    1,
+	storage.RoleDeveloper: 2,
+	storage.RoleAdmin:     3,
+	storage.RoleOwner:     4,
+}
+
+type OrganizationRequest struct {
+	Name string `json:"name"`
+}
+
+type MemberRequest struct {
+	Role string `json:"role"`
+}
+
+type InvitationRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type AcceptInvitationRequest struct {
+	Token string `json:"token"`
+}
+
+// requireRole resolves the organization of the request, from the :orgId
+// route parameter or the X-Organization-ID header, and rejects callers
+// whose role in it is below minimum. It must run after AuthRequired.
+func requireRole(minimum string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, err := auth.GetUserID(c)
+		if err != nil {
+			return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+		}
+
+		organizationID := userID
+		requested := c.Params("orgId")
+		if requested == "" {
+			requested = c.Get(OrganizationHeader)
+		}
+		if requested != "" {
+			organizationID, err = uuid.Parse(requested)
+			if err != nil {
+				return c.Status(400).JSON(fiber.Map{"error": "Invalid organization ID"})
+			}
+		}
+
+		// Organizations the caller does not belong to look nonexistent
+		member, err := repos.Organizations.GetMember(c.UserContext(), organizationID, userID)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				return c.Status(404).JSON(fiber.Map{"error": "Organization not found"})
+			}
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch organization"})
+		}
+		if roleRank[member.Role] < roleRank[minimum] {
+			return c.Status(403).JSON(fiber.Map{"error": "Requires the " + minimum + " role"})
+		}
+
+		auth.SetOrganization(c, organizationID, member.Role)
+		return c.Next()
+	}
+}
+
+// Organization handlers
+func listOrganizations(c *fiber.Ctx) error {
+	userID, err := auth.GetUserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	memberships, err := repos.Organizations.ListForUser(c.UserContext(), userID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch organizations"})
+	}
+
+	organizations := make([]fiber.Map, 0, len(memberships))
+	for _, membership := range memberships {
+		organizations = append(organizations, organizationResponse(&membership.Organization, membership.Role))
+	}
+	return c.JSON(organizations)
+}
+
+func createOrganization(c *fiber.Ctx) error {
+	userID, err := auth.GetUserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	var req OrganizationRequest
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "name is required"})
+	}
+
+	organization := storage.Organization{Name: strings.TrimSpace(req.Name)}
+	if err := repos.Organizations.Create(c.UserContext(), &organization, userID); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create organization"})
+	}
+
+	return c.Status(201).JSON(organizationResponse(&organization, storage.RoleOwner))
+}
+
+func getOrganization(c *fiber.Ctx) error {
+	organization, err := currentOrganization(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Organization not found"})
+	}
+
+	quota, err := quotas.GetStatus(c.UserContext(), organization)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch plan usage"})
+	}
+
+	response := organizationResponse(organization, auth.GetRole(c))
+	response["quota"] = quota
+	response["retention"] = fiber.Map{
+		"days":      organization.RetentionDays,
+		"max_count": organization.RetentionMaxCount,
+	}
+	return c.JSON(response)
+}
+
+func updateOrganization(c *fiber.Ctx) error {
+	organization, err := currentOrganization(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Organization not found"})
+	}
+
+	var req OrganizationRequest
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "name is required"})
+	}
+
+	organization.Name = strings.TrimSpace(req.Name)
+	if err := repos.Organizations.Save(c.UserContext(), organization); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update organization"})
+	}
+
+	return c.JSON(organizationResponse(organization, auth.GetRole(c)))
+}
+
+func deleteOrganization(c *fiber.Ctx) error {
+	organization, err := currentOrganization(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Organization not found"})
+	}
+	if organization.Personal {
+		return c.Status(400).JSON(fiber.Map{"error": "Personal organizations cannot be deleted"})
+	}
+
+	if err := repos.Organizations.Delete(c.UserContext(), organization); err != nil {
+		if errors.Is(err, storage.ErrOrganizationNotEmpty) {
+			return c.Status(409).JSON(fiber.Map{"error": "Delete or move the organization's functions and workflows first"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete organization"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Organization deleted successfully"})
+}
+
+// Member handlers
+func listMembers(c *fiber.Ctx) error {
+	organizationID, err := auth.GetOrganizationID(c)
+	if err != nil {
+		return c.Status(403).JSON(fiber.Map{"error": "Forbidden"})
+	}
+
+	members, err := repos.Organizations.ListMembers(c.UserContext(), organizationID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch members"})
+	}
+
+	response := make([]fiber.Map, 0, len(members))
+	for _, member := range members {
+		response = append(response, fiber.Map{
+			"user_id":   member.UserID,
+			"email":     member.User.Email,
+			"name":      member.User.Name,
+			"role":      member.Role,
+			"joined_at": member.CreatedAt,
+		})
+	}
+	return c.JSON(response)
+}
+
+// updateMember changes a member's role. Only owners may grant the owner
+// role or change another owner's role, and the last owner stays an owner.
+func updateMember(c *fiber.Ctx) error {
+	member, err := findMember(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Member not found"})
+	}
+
+	var req MemberRequest
+	if err := c.BodyParser(&req); err != nil || roleRank[req.Role] == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "role must be owner, admin, developer or viewer"})
+	}
+
+	if (req.Role == storage.RoleOwner || member.Role == storage.RoleOwner) && auth.GetRole(c) != storage.RoleOwner {
+		return c.Status(403).JSON(fiber.Map{"error": "Only owners can change ownership"})
+	}
+	if member.Role == storage.RoleOwner && req.Role != storage.RoleOwner {
+		last, err := isLastOwner(c, member.OrganizationID)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to update member"})
+		}
+		if last {
+			return c.Status(409).JSON(fiber.Map{"error": "An organization needs at least one owner"})
+		}
+	}
+
+	member.Role = req.Role
+	if err := repos.Organizations.SaveMember(c.UserContext(), member); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update member"})
+	}
+
+	return c.JSON(member)
+}
+
+// removeMember removes a member, or lets any member leave. Removing
+// someone else needs the admin role, and the owner role to remove an owner.
+func removeMember(c *fiber.Ctx) error {
+	userID, err := auth.GetUserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	member, err := findMember(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Member not found"})
+	}
+
+	role := auth.GetRole(c)
+	if member.UserID != userID {
+		if roleRank[role] < roleRank[storage.RoleAdmin] {
+			return c.Status(403).JSON(fiber.Map{"error": "Requires the admin role"})
+		}
+		if member.Role == storage.RoleOwner && role != storage.RoleOwner {
+			return c.Status(403).JSON(fiber.Map{"error": "Only owners can remove owners"})
+		}
+	}
+	if member.Role == storage.RoleOwner {
+		last, err := isLastOwner(c, member.OrganizationID)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to remove member"})
+		}
+		if last {
+			return c.Status(409).JSON(fiber.Map{"error": "An organization needs at least one owner"})
+		}
+	}
+
+	if err := repos.Organizations.RemoveMember(c.UserContext(), member); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to remove member"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Member removed successfully"})
+}
+
+// Invitation handlers
+func listInvitations(c *fiber.Ctx) error {
+	organizationID, err := auth.GetOrganizationID(c)
+	if err != nil {
+		return c.Status(403).JSON(fiber.Map{"error": "Forbidden"})
+	}
+
+	invitations, err := repos.Invitations.ListPending(c.UserContext(), organizationID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch invitations"})
+	}
+
+	return c.JSON(invitations)
+}
+
+// createInvitation invites an email address to the organization. The
+// token is only returned here; the inviter passes it on to the invitee.
+func createInvitation(c *fiber.Ctx) error {
+	userID, err := auth.GetUserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	organization, err := currentOrganization(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Organization not found"})
+	}
+	if organization.Personal {
+		return c.Status(400).JSON(fiber.Map{"error": "Personal organizations cannot have other members"})
+	}
+
+	var req InvitationRequest
+	if err := c.BodyParser(&req); err != nil || strings.TrimSpace(req.Email) == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "email is required"})
+	}
+	if req.Role == "" {
+		req.Role = storage.RoleDeveloper
+	}
+	if roleRank[req.Role] == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "role must be owner, admin, developer or viewer"})
+	}
+	if req.Role == storage.RoleOwner && auth.GetRole(c) != storage.RoleOwner {
+		return c.Status(403).JSON(fiber.Map{"error": "Only owners can invite owners"})
+	}
+
+	email := strings.TrimSpace(req.Email)
+	if invitee, err := repos.Users.GetByEmail(c.UserContext(), email); err == nil {
+		if _, err := repos.Organizations.GetMember(c.UserContext(), organization.ID, invitee.ID); err == nil {
+			return c.Status(409).JSON(fiber.Map{"error": "User is already a member"})
+		}
+	}
+
+	token, tokenHash, err := auth.NewInvitationToken()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to generate invitation"})
+	}
+	invitation := storage.OrganizationInvitation{
+		OrganizationID: organization.ID,
+		Email:          email,
+		Role:           req.Role,
+		TokenHash:      tokenHash,
+		InvitedBy:      userID,
+		ExpiresAt:      time.Now().Add(auth.InvitationTTL),
+	}
+	if err := repos.Invitations.Create(c.UserContext(), &invitation); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create invitation"})
+	}
+
+	return c.Status(201).JSON(fiber.Map{
+		"invitation": invitation,
+		"token":      token,
+		"message":    "Share this token with the invitee. It will not be shown again.",
+	})
+}
+
+func revokeInvitation(c *fiber.Ctx) error {
+	organizationID, err := auth.GetOrganizationID(c)
+	if err != nil {
+		return c.Status(403).JSON(fiber.Map{"error": "Forbidden"})
+	}
+
+	id, err := uuid.Parse(c.Params("invitationId"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Invitation not found"})
+	}
+	if err := repos.Invitations.Delete(c.UserContext(), id, organizationID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "Invitation not found"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to revoke invitation"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Invitation revoked successfully"})
+}
+
+// acceptInvitation joins the organization of an invitation sent to the
+// caller's email address
+func acceptInvitation(c *fiber.Ctx) error {
+	userID, err := auth.GetUserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	var req AcceptInvitationRequest
+	if err := c.BodyParser(&req); err != nil || req.Token == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "token is required"})
+	}
+
+	user, err := repos.Users.Get(c.UserContext(), userID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	member, err := repos.Invitations.Accept(c.UserContext(), auth.HashToken(req.Token), user)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return c.Status(404).JSON(fiber.Map{"error": "Invalid or expired invitation"})
+	case errors.Is(err, storage.ErrInvitationEmailMismatch):
+		return c.Status(403).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, storage.ErrAlreadyMember):
+		return c.Status(409).JSON(fiber.Map{"error": "You are already a member of this organization"})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to accept invitation"})
+	}
+
+	organization, err := repos.Organizations.Get(c.UserContext(), member.OrganizationID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch organization"})
+	}
+	return c.JSON(organizationResponse(organization, member.Role))
+}
+
+// currentOrganization loads the organization resolved by requireRole
+func currentOrganization(c *fiber.Ctx) (*storage.Organization, error) {
+	organizationID, err := auth.GetOrganizationID(c)
+	if err != nil {
+		return nil, err
+	}
+	return repos.Organizations.Get(c.UserContext(), organizationID)
+}
+
+// findMember loads the :userId member of the request's organization
+func findMember(c *fiber.Ctx) (*storage.OrganizationMember, error) {
+	organizationID, err := auth.GetOrganizationID(c)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := uuid.Parse(c.Params("userId"))
+	if err != nil {
+		return nil, storage.ErrNotFound
+	}
+	return repos.Organizations.GetMember(c.UserContext(), organizationID, userID)
+}
+
+// isLastOwner reports whether the organization has a single owner, who
+// may then not leave or step down
+func isLastOwner(c *fiber.Ctx, organizationID uuid.UUID) (bool, error) {
+	owners, err := repos.Organizations.CountOwners(c.UserContext(), organizationID)
+	if err != nil {
+		return false, err
+	}
+	return owners <= 1, nil
+}
+
+func organizationResponse(organization *storage.Organization, role string) fiber.Map {
+	return fiber.Map{
+		"id":         organization.ID,
+		"name":       organization.Name,
+		"personal":   organization.Personal,
+		"plan":       organization.Plan,
+		"role":       role,
+		"created_at": organization.CreatedAt,
+	}
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/quotas"
+)
+
+// currentUser is the response of GET /api/auth/me
+type currentUser struct {
+	Organization struct {
+		ID   uuid.UUID `json:"id"`
+		Plan string    `json:"plan"`
+		Role string    `json:"role"`
+	} `json:"organization"`
+	Quota *quotas.Status `json:"quota"`
+}
+
+func TestCurrentUserQuota(t *testing.T) {
+	s := newTestServer(t)
+	other, _ := s.newUser("other@example.com")
+
+	status, body := s.do("POST", "/api/organizations", map[string]string{"name": "team"})
+	expectStatus(t, status, 201, body)
+	var team struct {
+		ID uuid.UUID `json:"id"`
+	}
+	decode(t, body, &team)
+
+	tests := []struct {
+		name         string
+		organization uuid.UUID
+		status       int
+		want         uuid.UUID
+		functions    int64
+	}{
+		{"personal organization by default", uuid.Nil, 200, s.user.ID, 1},
+		{"organization from the header", team.ID, 200, team.ID, 0},
+		{"organization the user is not in", other.ID, 404, uuid.Nil, 0},
+		{"unknown organization", uuid.New(), 404, uuid.Nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := s.doIn(tt.organization, "GET", "/api/auth/me", nil)
+			expectStatus(t, status, tt.status, body)
+			if tt.status != 200 {
+				return
+			}
+
+			var me currentUser
+			decode(t, body, &me)
+			if me.Organization.ID != tt.want {
+				t.Fatalf("organization = %s, want %s", me.Organization.ID, tt.want)
+			}
+			if me.Quota == nil || me.Quota.Plan != me.Organization.Plan {
+				t.Fatalf("quota = %+v, want the %q plan's", me.Quota, me.Organization.Plan)
+			}
+			if me.Quota.Functions != tt.functions {
+				t.Fatalf("functions = %d, want %d", me.Quota.Functions, tt.functions)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/voltrun/backend/internal/quotas"
+)
+
+// quotaResponse maps a quota check failure to its HTTP status: 402 once a
+// monthly allowance is used up, 403 when a request exceeds a plan maximum
+func quotaResponse(c *fiber.Ctx, err error) error {
+	var limitErr *quotas.LimitError
+	if !errors.As(err, &limitErr) {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to check plan limits"})
+	}
+
+	status := fiber.StatusForbidden
+	if errors.Is(err, quotas.ErrQuotaExceeded) {
+		status = fiber.StatusPaymentRequired
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error": err.Error(),
+		"limit": limitErr.Limit,
+		"used":  limitErr.Used,
+		"max":   limitErr.Max,
+	})
+}
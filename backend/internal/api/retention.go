@@ -0,0 +1,57 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RetentionRequest configures how long executions are kept. Zero
+// restores the inherited setting.
+type RetentionRequest struct {
+	Days     *int `json:"days"`
+	MaxCount *int `json:"max_count"`
+}
+
+// applyRetention validates and applies the provided retention settings
+func applyRetention(days, maxCount *int, req *RetentionRequest) error {
+	if req.Days != nil {
+		if *req.Days < 0 {
+			return errors.New("retention.days must not be negative")
+		}
+		*days = *req.Days
+	}
+	if req.MaxCount != nil {
+		if *req.MaxCount < 0 {
+			return errors.New("retention.max_count must not be negative")
+		}
+		*maxCount = *req.MaxCount
+	}
+	return nil
+}
+
+// updateOrganizationRetention sets the retention applied to every
+// function of the organization that does not override it
+func updateOrganizationRetention(c *fiber.Ctx) error {
+	organization, err := currentOrganization(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Organization not found"})
+	}
+
+	var req RetentionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if err := applyRetention(&organization.RetentionDays, &organization.RetentionMaxCount, &req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := repos.Organizations.Save(c.UserContext(), organization); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update retention"})
+	}
+
+	return c.JSON(fiber.Map{
+		"retention_days":      organization.RetentionDays,
+		"retention_max_count": organization.RetentionMaxCount,
+	})
+}
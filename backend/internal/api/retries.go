@@ -0,0 +1,304 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/exec"
+	"github.com/voltrun/backend/internal/payloads"
+	"github.com/voltrun/backend/internal/storage"
+	"github.com/voltrun/backend/internal/tracing"
+	"github.com/voltrun/backend/internal/utils"
+	"go.uber.org/zap"
+)
+
+// maxRetryAttempts bounds the total attempts a retry policy may request
+const maxRetryAttempts = 10
+
+// RetryPolicyRequest configures retries for asynchronous invocations
+type RetryPolicyRequest struct {
+	MaxAttempts   int      `json:"max_attempts"`
+	BackoffSec    int      `json:"backoff_sec"`
+	MaxBackoffSec int      `json:"max_backoff_sec"`
+	RetryOn       []string `json:"retry_on"`
+}
+
+// applyRetryPolicy validates and applies the provided retry settings
+func applyRetryPolicy(function *storage.Function, policy *RetryPolicyRequest) error {
+	if policy.MaxAttempts != 0 {
+		if policy.MaxAttempts < 1 || policy.MaxAttempts > maxRetryAttempts {
+			return errors.New("retry_policy.max_attempts must be between 1 and 10")
+		}
+		function.RetryMaxAttempts = policy.MaxAttempts
+	}
+	if policy.BackoffSec != 0 {
+		if policy.BackoffSec < 1 {
+			return errors.New("retry_policy.backoff_sec must be positive")
+		}
+		function.RetryBackoffSec = policy.BackoffSec
+	}
+	if policy.MaxBackoffSec != 0 {
+		function.RetryMaxBackoffSec = policy.MaxBackoffSec
+	}
+	if function.RetryMaxBackoffSec < function.RetryBackoffSec {
+		return errors.New("retry_policy.max_backoff_sec must not be less than backoff_sec")
+	}
+	if policy.RetryOn != nil {
+		retryOn := strings.Join(policy.RetryOn, ",")
+		if len(policy.RetryOn) == 0 || !exec.ValidateRetryOn(retryOn) {
+			return errors.New("retry_policy.retry_on must list timeout, handler or system")
+		}
+		function.RetryOn = retryOn
+	}
+	return nil
+}
+
+// Dead letter handlers
+func listDeadLetters(c *fiber.Ctx) error {
+	function, err := findFunction(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Function not found"})
+	}
+
+	deadLetters, err := repos.DeadLetters.ListForFunction(c.UserContext(), function.ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch dead letters"})
+	}
+	for i := range deadLetters {
+		if err := payloads.HydrateDeadLetter(c.UserContext(), &deadLetters[i]); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to load dead letter input"})
+		}
+	}
+
+	return c.JSON(deadLetters)
+}
+
+func replayDeadLetter(c *fiber.Ctx) error {
+	function, deadLetter, err := findDeadLetter(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := payloads.HydrateDeadLetter(c.UserContext(), deadLetter); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load dead letter input"})
+	}
+
+	// Input may be any JSON value, not only an object
+	var input interface{} = map[string]interface{}{}
+	if len(deadLetter.Input) > 0 {
+		if err := json.Unmarshal(deadLetter.Input, &input); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to decode dead letter input"})
+		}
+	}
+
+	// A replay starts a fresh retry chain
+	executionID := uuid.New()
+	execution := storage.Execution{
+		ID:             executionID,
+		UserID:         function.UserID,
+		OrganizationID: function.OrganizationID,
+		FunctionID:     function.ID,
+		Status:         "pending",
+		TriggerType:    deadLetter.TriggerType,
+		TriggerSource:  deadLetter.TriggerSource,
+		Input:          deadLetter.Input,
+	}
+	offloadPayloads(c.UserContext(), &execution)
+	if err := repos.Executions.Create(c.UserContext(), &execution); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create execution record"})
+	}
+
+	if err := repos.DeadLetters.MarkReplayed(c.UserContext(), deadLetter.ID, executionID); err != nil {
+		utils.Error("Failed to mark dead letter replayed", zap.String("dead_letter_id", deadLetter.ID.String()), zap.Error(err))
+	}
+
+	go executeAsync(tracing.Detach(c.UserContext()), executionID, *function, input)
+
+	return c.Status(201).JSON(fiber.Map{
+		"execution_id": executionID,
+		"status":       "pending",
+		"message":      "Dead letter replay started",
+	})
+}
+
+func deleteDeadLetter(c *fiber.Ctx) error {
+	_, deadLetter, err := findDeadLetter(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := payloads.DeleteDeadLetter(c.UserContext(), deadLetter); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete dead letter input"})
+	}
+	if err := repos.DeadLetters.Delete(c.UserContext(), deadLetter); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete dead letter"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Dead letter deleted successfully"})
+}
+
+// findDeadLetter loads the :deadLetterId dead letter of the :id function
+// in the request's organization. The error names what was not found.
+func findDeadLetter(c *fiber.Ctx) (*storage.Function, *storage.DeadLetter, error) {
+	function, err := findFunction(c)
+	if err != nil {
+		return nil, nil, errors.New("Function not found")
+	}
+	id, err := uuid.Parse(c.Params("deadLetterId"))
+	if err != nil {
+		return nil, nil, errors.New("Dead letter not found")
+	}
+	deadLetter, err := repos.DeadLetters.GetForFunction(c.UserContext(), id, function.ID)
+	if err != nil {
+		return nil, nil, errors.New("Dead letter not found")
+	}
+	return function, deadLetter, nil
+}
+
+// handleAsyncFailure schedules the next attempt of a failed async
+// execution, or dead-letters it once the retry policy is exhausted
+func handleAsyncFailure(executionID uuid.UUID, function storage.Function, errorClass, errorMsg string) {
+	execution, err := repos.Executions.Get(context.Background(), executionID)
+	if err != nil {
+		utils.Error("Failed to load failed execution", zap.String("execution_id", executionID.String()), zap.Error(err))
+		return
+	}
+	// Retries and dead letters get their own copy of the input
+	if err := payloads.Hydrate(context.Background(), execution); err != nil {
+		utils.Error("Failed to load failed execution input", zap.String("execution_id", executionID.String()), zap.Error(err))
+		return
+	}
+
+	originalID := execution.ID
+	if execution.OriginalExecutionID != nil {
+		originalID = *execution.OriginalExecutionID
+	}
+
+	if exec.ShouldRetry(&function, errorClass, execution.Attempt) {
+		delay := exec.RetryDelay(&function, execution.Attempt)
+		retryAt := time.Now().Add(delay)
+		retry := storage.Execution{
+			UserID:              execution.UserID,
+			OrganizationID:      execution.OrganizationID,
+			FunctionID:          execution.FunctionID,
+			Status:              "pending",
+			TriggerType:         execution.TriggerType,
+			TriggerSource:       execution.TriggerSource,
+			Input:               execution.Input,
+			Attempt:             execution.Attempt + 1,
+			OriginalExecutionID: &originalID,
+			NextRetryAt:         &retryAt,
+			OnSuccess:           execution.OnSuccess,
+			OnFailure:           execution.OnFailure,
+			ChainDepth:          execution.ChainDepth,
+		}
+		offloadPayloads(context.Background(), &retry)
+		if err := repos.Executions.Create(context.Background(), &retry); err != nil {
+			utils.Error("Failed to schedule retry", zap.String("execution_id", executionID.String()), zap.Error(err))
+			return
+		}
+		scheduleRetry(retry.ID, delay)
+		return
+	}
+
+	deadLetter := storage.DeadLetter{
+		FunctionID:          execution.FunctionID,
+		UserID:              execution.UserID,
+		ExecutionID:         execution.ID,
+		OriginalExecutionID: originalID,
+		Input:               execution.Input,
+		Error:               errorMsg,
+		ErrorClass:          errorClass,
+		Attempts:            execution.Attempt,
+		TriggerType:         execution.TriggerType,
+		TriggerSource:       execution.TriggerSource,
+	}
+	// Inputs over the threshold go to the object store, as executions' do
+	if err := payloads.OffloadDeadLetter(context.Background(), &deadLetter); err != nil {
+		utils.Error("Failed to offload dead letter input", zap.String("execution_id", executionID.String()), zap.Error(err))
+	}
+	if err := repos.DeadLetters.Create(context.Background(), &deadLetter); err != nil {
+		utils.Error("Failed to store dead letter", zap.String("execution_id", executionID.String()), zap.Error(err))
+	}
+
+	// The failure is final, so notify the on_failure destination
+	dispatchDestination(execution.ID, function, false)
+}
+
+// scheduleRetry runs a pending retry once its backoff has elapsed
+func scheduleRetry(executionID uuid.UUID, delay time.Duration) {
+	time.AfterFunc(delay, func() {
+		runRetry(executionID)
+	})
+}
+
+// runRetry claims and executes a pending retry. The claim clears
+// next_retry_at atomically, so a retry armed on several replicas runs once.
+func runRetry(executionID uuid.UUID) {
+	ctx := context.Background()
+	claimed, err := repos.Executions.ClaimRetry(ctx, executionID)
+	if err != nil || !claimed {
+		return
+	}
+
+	execution, err := repos.Executions.Get(ctx, executionID)
+	if err != nil {
+		return
+	}
+
+	function, err := repos.Functions.Get(ctx, execution.FunctionID)
+	if err != nil {
+		failRetry(*execution, "function no longer exists")
+		return
+	}
+
+	// Failures are saved from the stored row, never with the hydrated input
+	stored := *execution
+	if err := payloads.Hydrate(ctx, execution); err != nil {
+		failRetry(stored, "input could not be loaded")
+		return
+	}
+	var input interface{} = map[string]interface{}{}
+	if len(execution.Input) > 0 {
+		if err := json.Unmarshal(execution.Input, &input); err != nil {
+			failRetry(stored, "input could not be decoded")
+			return
+		}
+	}
+
+	executeAsync(ctx, execution.ID, *function, input)
+}
+
+// failRetry marks a claimed retry failed without running it
+func failRetry(execution storage.Execution, message string) {
+	execution.Status = "failed"
+	execution.Error = message
+	if err := repos.Executions.Save(context.Background(), &execution); err != nil {
+		utils.Error("Failed to fail retry", zap.String("execution_id", execution.ID.String()), zap.Error(err))
+	}
+}
+
+// ResumeRetries re-arms retries that were waiting when the server stopped
+func ResumeRetries() error {
+	pending, err := repos.Executions.ListPendingRetries(context.Background())
+	if err != nil {
+		return err
+	}
+
+	for _, execution := range pending {
+		delay := time.Until(*execution.NextRetryAt)
+		if delay < 0 {
+			delay = 0
+		}
+		scheduleRetry(execution.ID, delay)
+	}
+
+	if len(pending) > 0 {
+		utils.Info("Resumed pending retries", zap.Int("count", len(pending)))
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/storage"
+)
+
+// newDeadLetter stores a dead letter for the server's function
+func (s *testServer) newDeadLetter() *storage.DeadLetter {
+	s.t.Helper()
+	executionID := uuid.New()
+	deadLetter := &storage.DeadLetter{
+		FunctionID:          s.function.ID,
+		UserID:              s.user.ID,
+		ExecutionID:         executionID,
+		OriginalExecutionID: executionID,
+		Input:               []byte(`{"order":1}`),
+		Error:               "boom",
+		Attempts:            3,
+		TriggerType:         "http",
+	}
+	if err := s.repos.DeadLetters.Create(s.t.Context(), deadLetter); err != nil {
+		s.t.Fatal(err)
+	}
+	return deadLetter
+}
+
+func TestDeadLetters(t *testing.T) {
+	s := newTestServer(t)
+	deadLetter := s.newDeadLetter()
+
+	status, body := s.do("GET", s.functionPath("/dead-letters"), nil)
+	expectStatus(t, status, 200, body)
+	var listed []storage.DeadLetter
+	decode(t, body, &listed)
+	if len(listed) != 1 || listed[0].ID != deadLetter.ID || string(listed[0].Input) != `{"order":1}` {
+		t.Fatalf("dead letters = %+v, want the stored one with its input", listed)
+	}
+
+	path := s.functionPath("/dead-letters/" + deadLetter.ID.String())
+	status, body = s.do("DELETE", path, nil)
+	expectStatus(t, status, 200, body)
+	status, body = s.do("DELETE", path, nil)
+	expectStatus(t, status, 404, body)
+	status, body = s.do("POST", path+"/replay", nil)
+	expectStatus(t, status, 404, body)
+}
+
+func TestDeadLettersOfOtherOrganization(t *testing.T) {
+	s := newTestServer(t)
+	deadLetter := s.newDeadLetter()
+
+	_, token := s.newUser("other@example.com")
+	path := s.functionPath("/dead-letters/" + deadLetter.ID.String())
+	status, body := s.doAs(token, "GET", s.functionPath("/dead-letters"), nil)
+	expectStatus(t, status, 404, body)
+	status, body = s.doAs(token, "POST", path+"/replay", nil)
+	expectStatus(t, status, 404, body)
+	status, body = s.doAs(token, "DELETE", path, nil)
+	expectStatus(t, status, 404, body)
+
+	if _, err := s.repos.DeadLetters.GetForFunction(t.Context(), deadLetter.ID, s.function.ID); err != nil {
+		t.Fatalf("dead letter was deleted: %v", err)
+	}
+}
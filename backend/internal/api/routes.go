@@ -1,593 +1,862 @@
-package api
-
-import (
-	"context"
-	"crypto/rand"
-	"encoding/hex"
-	"encoding/json"
-	"fmt"
-
-	"github.com/gofiber/fiber/v2"
-	"github.com/google/uuid"
-	"github.com/voltrun/backend/internal/auth"
-	"github.com/voltrun/backend/internal/exec"
-	"github.com/voltrun/backend/internal/storage"
-	"github.com/voltrun/backend/internal/vm"
-)
-
-// SetupRoutes registers all API routes
-func SetupRoutes(app *fiber.App) {
-	api := app.Group("/api")
-
-	// Auth routes
-	authGroup := api.Group("/auth")
-	authGroup.Post("/register", handleRegister)
-	authGroup.Post("/login", handleLogin)
-	authGroup.Post("/refresh", handleRefresh)
-	authGroup.Get("/me", auth.AuthRequired(), handleGetCurrentUser)
-
-	// Protected routes (require authentication)
-	// Functions routes
-	functions := api.Group("/functions")
-	functions.Use(auth.AuthRequired())
-	functions.Get("/", listFunctions)
-	functions.Post("/", createFunction)
-	functions.Get("/:id", getFunction)
-	functions.Put("/:id", updateFunction)
-	functions.Delete("/:id", deleteFunction)
-	functions.Post("/:id/execute", executeFunction)
-
-	// Executions routes
-	executions := api.Group("/executions")
-	executions.Use(auth.AuthRequired())
-	executions.Get("/", listExecutions)
-	executions.Get("/:id", getExecution)
-	executions.Get("/:id/logs", getExecutionLogs)
-
-	// API Keys routes
-	keys := api.Group("/keys")
-	keys.Use(auth.AuthRequired())
-	keys.Get("/", listAPIKeys)
-	keys.Post("/", createAPIKey)
-	keys.Delete("/:id", deleteAPIKey)
-}
-
-// Auth handlers
-type RegisterRequest struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"`
-	Name     string `json:"name" validate:"required"`
-}
-
-type LoginRequest struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required"`
-}
-
-func handleRegister(c *fiber.Ctx) error {
-	var req RegisterRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
-	}
-
-	// Check if user already exists
-	var existingUser storage.User
-	if err := storage.DB.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Email already registered"})
-	}
-
-	// Hash password
-	hashedPassword, err := auth.HashPassword(req.Password)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to process password"})
-	}
-
-	// Create user
-	user := storage.User{
-		Email:    req.Email,
-		Password: hashedPassword,
-		Name:     req.Name,
-	}
-
-	if err := storage.DB.Create(&user).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to create user"})
-	}
-
-	// Generate JWT token
-	token, err := auth.GenerateToken(user.ID, user.Email)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to generate token"})
-	}
-
-	return c.Status(201).JSON(fiber.Map{
-		"token": token,
-		"user": fiber.Map{
-			"id":    user.ID,
-			"email": user.Email,
-			"name":  user.Name,
-		},
-	})
-}
-
-func handleLogin(c *fiber.Ctx) error {
-	var req LoginRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
-	}
-
-	// Find user by email
-	var user storage.User
-	if err := storage.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
-		return c.Status(401).JSON(fiber.Map{"error": "Invalid email or password"})
-	}
-
-	// Check password
-	if !auth.CheckPassword(req.Password, user.Password) {
-		return c.Status(401).JSON(fiber.Map{"error": "Invalid email or password"})
-	}
-
-	// Generate JWT token
-	token, err := auth.GenerateToken(user.ID, user.Email)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to generate token"})
-	}
-
-	return c.JSON(fiber.Map{
-		"token": token,
-		"user": fiber.Map{
-			"id":    user.ID,
-			"email": user.Email,
-			"name":  user.Name,
-		},
-	})
-}
-
-func handleRefresh(c *fiber.Ctx) error {
-	userID, err := auth.GetUserID(c)
-	if err != nil {
-		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
-	}
-
-	var user storage.User
-	if err := storage.DB.First(&user, "id = ?", userID).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
-	}
-
-	// Generate new JWT token
-	token, err := auth.GenerateToken(user.ID, user.Email)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to generate token"})
-	}
-
-	return c.JSON(fiber.Map{
-		"token": token,
-		"user": fiber.Map{
-			"id":    user.ID,
-			"email": user.Email,
-			"name":  user.Name,
-		},
-	})
-}
-
-func handleGetCurrentUser(c *fiber.Ctx) error {
-	userID, err := auth.GetUserID(c)
-	if err != nil {
-		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
-	}
-
-	var user storage.User
-	if err := storage.DB.First(&user, "id = ?", userID).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
-	}
-
-	return c.JSON(fiber.Map{
-		"user": fiber.Map{
-			"id":    user.ID,
-			"email": user.Email,
-			"name":  user.Name,
-		},
-	})
-}
-
-// Function handlers
-func listFunctions(c *fiber.Ctx) error {
-	userID, err := auth.GetUserID(c)
-	if err != nil {
-		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
-	}
-
-	var functions []storage.Function
-	result := storage.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&functions)
-	if result.Error != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch functions"})
-	}
-	return c.JSON(functions)
-}
-
-type CreateFunctionRequest struct {
-	Name        string `json:"name" validate:"required"`
-	Description string `json:"description"`
-	Runtime     string `json:"runtime" validate:"required"`
-	Code        string `json:"code" validate:"required"`
-	EntryPoint  string `json:"entry_point"`
-	MemoryMB    int    `json:"memory_mb"`
-	TimeoutSec  int    `json:"timeout_sec"`
-}
-
-func createFunction(c *fiber.Ctx) error {
-	var req CreateFunctionRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
-	}
-
-	// Get authenticated user ID from JWT token
-	userID, err := auth.GetUserID(c)
-	if err != nil {
-		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
-	}
-
-	// Set defaults
-	if req.EntryPoint == "" {
-		req.EntryPoint = "index.handler"
-	}
-	if req.MemoryMB == 0 {
-		req.MemoryMB = 128
-	}
-	if req.TimeoutSec == 0 {
-		req.TimeoutSec = 30
-	}
-
-	function := storage.Function{
-		UserID:      userID,
-		Name:        req.Name,
-		Description: req.Description,
-		Runtime:     req.Runtime,
-		Code:        req.Code,
-		EntryPoint:  req.EntryPoint,
-		MemoryMB:    req.MemoryMB,
-		TimeoutSec:  req.TimeoutSec,
-		Status:      "active",
-	}
-
-	if err := storage.DB.Create(&function).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to create function"})
-	}
-
-	return c.Status(201).JSON(function)
-}
-
-func getFunction(c *fiber.Ctx) error {
-	userID, err := auth.GetUserID(c)
-	if err != nil {
-		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
-	}
-
-	id := c.Params("id")
-	var function storage.Function
-	
-	if err := storage.DB.Where("id = ? AND user_id = ?", id, userID).First(&function).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{"error": "Function not found"})
-	}
-	
-	return c.JSON(function)
-}
-
-type UpdateFunctionRequest struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	Code        string `json:"code"`
-	EntryPoint  string `json:"entry_point"`
-	MemoryMB    int    `json:"memory_mb"`
-	TimeoutSec  int    `json:"timeout_sec"`
-	Status      string `json:"status"`
-}
-
-func updateFunction(c *fiber.Ctx) error {
-	userID, err := auth.GetUserID(c)
-	if err != nil {
-		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
-	}
-
-	id := c.Params("id")
-	var function storage.Function
-	
-	if err := storage.DB.Where("id = ? AND user_id = ?", id, userID).First(&function).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{"error": "Function not found"})
-	}
-
-	var req UpdateFunctionRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
-	}
-
-	// Update only provided fields
-	if req.Name != "" {
-		function.Name = req.Name
-	}
-	if req.Description != "" {
-		function.Description = req.Description
-	}
-	if req.Code != "" {
-		function.Code = req.Code
-	}
-	if req.EntryPoint != "" {
-		function.EntryPoint = req.EntryPoint
-	}
-	if req.MemoryMB > 0 {
-		function.MemoryMB = req.MemoryMB
-	}
-	if req.TimeoutSec > 0 {
-		function.TimeoutSec = req.TimeoutSec
-	}
-	if req.Status != "" {
-		function.Status = req.Status
-	}
-
-	if err := storage.DB.Save(&function).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to update function"})
-	}
-
-	return c.JSON(function)
-}
-
-func deleteFunction(c *fiber.Ctx) error {
-	userID, err := auth.GetUserID(c)
-	if err != nil {
-		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
-	}
-
-	id := c.Params("id")
-	var function storage.Function
-	
-	if err := storage.DB.Where("id = ? AND user_id = ?", id, userID).First(&function).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{"error": "Function not found"})
-	}
-
-	// Delete associated executions first
-	storage.DB.Where("function_id = ?", id).Delete(&storage.Execution{})
-
-	// Delete function
-	if err := storage.DB.Delete(&function).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete function"})
-	}
-
-	return c.JSON(fiber.Map{"message": "Function deleted successfully"})
-}
-
-type ExecuteFunctionRequest struct {
-	Input map[string]interface{} `json:"input"`
-}
-
-func executeFunction(c *fiber.Ctx) error {
-	userID, err := auth.GetUserID(c)
-	if err != nil {
-		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
-	}
-
-	id := c.Params("id")
-	var function storage.Function
-	
-	if err := storage.DB.Where("id = ? AND user_id = ?", id, userID).First(&function).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{"error": "Function not found"})
-	}
-
-	var req ExecuteFunctionRequest
-	if err := c.BodyParser(&req); err != nil {
-		// Default to empty input if not provided
-		req.Input = make(map[string]interface{})
-	}
-
-	// Marshal input to JSON bytes for JSONB
-	inputJSON, err := json.Marshal(req.Input)
-	if err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid input format"})
-	}
-
-	// Create execution record
-	executionID := uuid.New()
-	execution := storage.Execution{
-		ID:         executionID,
-		UserID:     userID,
-		FunctionID: function.ID,
-		Status:     "pending",
-		Input:      inputJSON,
-	}
-
-	if err := storage.DB.Create(&execution).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to create execution record"})
-	}
-
-	// Execute function asynchronously
-	go executeAsync(executionID, function, req.Input)
-
-	return c.Status(201).JSON(fiber.Map{
-		"execution_id": executionID,
-		"status":       "pending",
-		"message":      "Function execution started",
-	})
-}
-
-// Execution handlers
-func listExecutions(c *fiber.Ctx) error {
-	userID, err := auth.GetUserID(c)
-	if err != nil {
-		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
-	}
-
-	functionID := c.Query("function_id")
-	query := storage.DB.Where("user_id = ?", userID)
-
-	if functionID != "" {
-		query = query.Where("function_id = ?", functionID)
-	}
-
-	var executions []storage.Execution
-	if err := query.Order("created_at DESC").Preload("Function").Find(&executions).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch executions"})
-	}
-
-	return c.JSON(executions)
-}
-
-func getExecution(c *fiber.Ctx) error {
-	userID, err := auth.GetUserID(c)
-	if err != nil {
-		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
-	}
-
-	id := c.Params("id")
-	var execution storage.Execution
-
-	if err := storage.DB.Where("id = ? AND user_id = ?", id, userID).Preload("Function").First(&execution).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{"error": "Execution not found"})
-	}
-
-	return c.JSON(execution)
-}
-
-func getExecutionLogs(c *fiber.Ctx) error {
-	userID, err := auth.GetUserID(c)
-	if err != nil {
-		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
-	}
-
-	id := c.Params("id")
-	var execution storage.Execution
-
-	if err := storage.DB.Where("id = ? AND user_id = ?", id, userID).First(&execution).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{"error": "Execution not found"})
-	}
-
-	return c.JSON(fiber.Map{
-		"execution_id": execution.ID,
-		"logs":         execution.Logs,
-		"status":       execution.Status,
-	})
-}
-
-// API Key handlers
-func listAPIKeys(c *fiber.Ctx) error {
-	userID, err := auth.GetUserID(c)
-	if err != nil {
-		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
-	}
-
-	var keys []storage.APIKey
-	if err := storage.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch API keys"})
-	}
-
-	return c.JSON(keys)
-}
-
-type CreateAPIKeyRequest struct {
-	Name string `json:"name" validate:"required"`
-}
-
-func createAPIKey(c *fiber.Ctx) error {
-	userID, err := auth.GetUserID(c)
-	if err != nil {
-		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
-	}
-
-	var req CreateAPIKeyRequest
-	if err := c.BodyParser(&req); err != nil {
-		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
-	}
-
-	// Generate random API key
-	keyBytes := make([]byte, 32)
-	if _, err := rand.Read(keyBytes); err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to generate key"})
-	}
-	rawKey := "vr_" + hex.EncodeToString(keyBytes)
-
-	// Hash the key for storage
-	hashedKey, err := auth.HashPassword(rawKey)
-	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to process key"})
-	}
-
-	// Store only prefix for display
-	prefix := rawKey[:12]
-
-	apiKey := storage.APIKey{
-		UserID: userID,
-		Name:   req.Name,
-		Key:    hashedKey,
-		Prefix: prefix,
-	}
-
-	if err := storage.DB.Create(&apiKey).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to create API key"})
-	}
-
-	// Return the raw key only once
-	return c.Status(201).JSON(fiber.Map{
-		"id":     apiKey.ID,
-		"name":   apiKey.Name,
-		"key":    rawKey,
-		"prefix": prefix,
-		"message": "Save this key securely. It won't be shown again.",
-	})
-}
-
-func deleteAPIKey(c *fiber.Ctx) error {
-	userID, err := auth.GetUserID(c)
-	if err != nil {
-		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
-	}
-
-	id := c.Params("id")
-	var apiKey storage.APIKey
-
-	if err := storage.DB.Where("id = ? AND user_id = ?", id, userID).First(&apiKey).Error; err != nil {
-		return c.Status(404).JSON(fiber.Map{"error": "API key not found"})
-	}
-
-	if err := storage.DB.Delete(&apiKey).Error; err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete API key"})
-	}
-
-	return c.JSON(fiber.Map{"message": "API key deleted successfully"})
-}
-
-// marshalJSON converts a map to JSON bytes for JSONB
-func marshalJSON(data interface{}) []byte {
-	bytes, _ := json.Marshal(data)
-	return bytes
-}
-
-// executeAsync executes a function asynchronously
-func executeAsync(executionID uuid.UUID, function storage.Function, input map[string]interface{}) {
-	ctx := context.Background()
-	
-	// Create VM manager and execution engine
-	vmManager := vm.NewVMManager()
-	engine := exec.NewExecutionEngine(vmManager)
-	
-	// Execute the function
-	result, err := engine.Execute(ctx, exec.ExecutionRequest{
-		FunctionID: function.ID,
-		Input:      input,
-		UserID:     function.UserID,
-	})
-	
-	if err != nil {
-		// Update execution with error
-		storage.DB.Model(&storage.Execution{}).Where("id = ?", executionID).Updates(map[string]interface{}{
-			"status": "failed",
-			"error":  err.Error(),
-			"logs":   fmt.Sprintf("Execution failed: %v", err),
-		})
-		return
-	}
-	
-	// Update execution with results
-	storage.DB.Model(&storage.Execution{}).Where("id = ?", executionID).Updates(map[string]interface{}{
-		"status":      result.Status,
-		"output":      marshalJSON(result.Output),
-		"logs":        result.Logs,
-		"duration_ms": result.DurationMS,
-	})
-}
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/auth"
+	"github.com/voltrun/backend/internal/destinations"
+	"github.com/voltrun/backend/internal/exec"
+	"github.com/voltrun/backend/internal/payloads"
+	"github.com/voltrun/backend/internal/quotas"
+	"github.com/voltrun/backend/internal/ratelimit"
+	"github.com/voltrun/backend/internal/retention"
+	"github.com/voltrun/backend/internal/storage"
+	"github.com/voltrun/backend/internal/tracing"
+	"github.com/voltrun/backend/internal/triggers"
+	"github.com/voltrun/backend/internal/utils"
+	"github.com/voltrun/backend/internal/workflows"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+)
+
+// engine runs asynchronous executions started by the API
+var engine *exec.ExecutionEngine
+
+// repos backs the user, function, execution and API key handlers
+var repos *storage.Repos
+
+// SetupRoutes registers all API routes
+func SetupRoutes(app *fiber.App, repositories *storage.Repos, executionEngine *exec.ExecutionEngine, publisher triggers.Publisher, runner *workflows.Runner) {
+	repos = repositories
+	engine = executionEngine
+	eventPublisher = publisher
+	workflowRunner = runner
+
+	api := app.Group("/api")
+
+	// The caller's role in the organization named by :orgId, or by the
+	// X-Organization-ID header, gates every resource route
+	viewer := requireRole(storage.RoleViewer)
+	developer := requireRole(storage.RoleDeveloper)
+	admin := requireRole(storage.RoleAdmin)
+	owner := requireRole(storage.RoleOwner)
+
+	// Auth routes
+	authGroup := api.Group("/auth")
+	authGroup.Post("/register", handleRegister)
+	authGroup.Post("/login", handleLogin)
+	authGroup.Post("/refresh", handleRefresh)
+	authGroup.Get("/oidc/providers", listOIDCProviders)
+	authGroup.Get("/oidc/:provider/login", handleOIDCLogin)
+	authGroup.Get("/oidc/:provider/callback", handleOIDCCallback)
+	authGroup.Post("/logout", auth.AuthRequired(), handleLogout)
+	authGroup.Get("/sessions", auth.AuthRequired(), listSessions)
+	authGroup.Delete("/sessions", auth.AuthRequired(), revokeOtherSessions)
+	authGroup.Delete("/sessions/:id", auth.AuthRequired(), revokeSession)
+	authGroup.Get("/me", auth.AuthRequired(), viewer, handleGetCurrentUser)
+
+	// Organizations routes
+	organizations := api.Group("/organizations")
+	organizations.Use(auth.AuthRequired())
+	organizations.Get("/", listOrganizations)
+	organizations.Post("/", createOrganization)
+	organizations.Get("/:orgId", viewer, getOrganization)
+	organizations.Put("/:orgId", admin, updateOrganization)
+	organizations.Delete("/:orgId", owner, deleteOrganization)
+	organizations.Put("/:orgId/retention", admin, updateOrganizationRetention)
+	organizations.Get("/:orgId/members", viewer, listMembers)
+	organizations.Put("/:orgId/members/:userId", admin, updateMember)
+	organizations.Delete("/:orgId/members/:userId", viewer, removeMember)
+	organizations.Get("/:orgId/invitations", admin, listInvitations)
+	organizations.Post("/:orgId/invitations", admin, createInvitation)
+	organizations.Delete("/:orgId/invitations/:invitationId", admin, revokeInvitation)
+	api.Post("/invitations/accept", auth.AuthRequired(), acceptInvitation)
+
+	// Protected routes (require authentication)
+	// Functions routes
+	functions := api.Group("/functions")
+	functions.Use(auth.AuthRequired())
+	functions.Get("/", viewer, listFunctions)
+	functions.Post("/", developer, createFunction)
+	functions.Get("/:id", viewer, getFunction)
+	functions.Put("/:id", developer, updateFunction)
+	functions.Delete("/:id", developer, deleteFunction)
+	functions.Post("/:id/execute", developer, ratelimit.Middleware(), executeFunction)
+	functions.Get("/:id/metrics", viewer, getFunctionMetrics)
+	functions.Get("/:id/secrets", viewer, listFunctionSecrets)
+	functions.Put("/:id/secrets/:name", developer, putFunctionSecret)
+	functions.Delete("/:id/secrets/:name", developer, deleteFunctionSecret)
+	functions.Get("/:id/schedules", viewer, listSchedules)
+	functions.Post("/:id/schedules", developer, createSchedule)
+	functions.Put("/:id/schedules/:scheduleId", developer, updateSchedule)
+	functions.Delete("/:id/schedules/:scheduleId", developer, deleteSchedule)
+	functions.Get("/:id/webhooks", viewer, listWebhooks)
+	functions.Post("/:id/webhooks", developer, createWebhook)
+	functions.Delete("/:id/webhooks/:webhookId", developer, deleteWebhook)
+	functions.Get("/:id/event-triggers", viewer, listEventTriggers)
+	functions.Post("/:id/event-triggers", developer, createEventTrigger)
+	functions.Put("/:id/event-triggers/:triggerId", developer, updateEventTrigger)
+	functions.Delete("/:id/event-triggers/:triggerId", developer, deleteEventTrigger)
+	functions.Get("/:id/event-triggers/:triggerId/dead-letters", viewer, listEventDeadLetters)
+	functions.Post("/:id/event-triggers/:triggerId/dead-letters/:deadLetterId/redrive", developer, redriveEventDeadLetter)
+	functions.Delete("/:id/event-triggers/:triggerId/dead-letters/:deadLetterId", developer, deleteEventDeadLetter)
+	functions.Get("/:id/dead-letters", viewer, listDeadLetters)
+	functions.Post("/:id/dead-letters/:deadLetterId/replay", developer, replayDeadLetter)
+	functions.Delete("/:id/dead-letters/:deadLetterId", developer, deleteDeadLetter)
+	functions.Post("/:id/destination-secret", developer, rotateDestinationSecret)
+
+	// Webhook deliveries are authenticated by their signature
+	api.Post("/webhooks/:id", handleWebhook)
+
+	// Events routes
+	events := api.Group("/events")
+	events.Use(auth.AuthRequired())
+	events.Post("/:topic", developer, ratelimit.Middleware(), publishEvent)
+
+	// Workflows routes
+	workflowGroup := api.Group("/workflows")
+	workflowGroup.Use(auth.AuthRequired())
+	workflowGroup.Get("/", viewer, listWorkflows)
+	workflowGroup.Post("/", developer, createWorkflow)
+	workflowGroup.Get("/:id", viewer, getWorkflow)
+	workflowGroup.Put("/:id", developer, updateWorkflow)
+	workflowGroup.Delete("/:id", developer, deleteWorkflow)
+	workflowGroup.Get("/:id/runs", viewer, listWorkflowRuns)
+	workflowGroup.Post("/:id/runs", developer, startWorkflowRun)
+	workflowGroup.Get("/:id/runs/:runId", viewer, getWorkflowRun)
+	workflowGroup.Post("/:id/runs/:runId/cancel", developer, cancelWorkflowRun)
+
+	// Executions routes
+	executions := api.Group("/executions")
+	executions.Use(auth.AuthRequired(), viewer)
+	executions.Get("/", listExecutions)
+	executions.Get("/archived", listArchivedExecutions)
+	executions.Get("/archived/:id", getArchivedExecution)
+	executions.Get("/:id", getExecution)
+	executions.Get("/:id/logs", getExecutionLogs)
+	executions.Get("/:id/output", getExecutionOutput)
+
+	// Usage routes
+	api.Get("/usage", auth.AuthRequired(), viewer, getUsage)
+
+	// API Keys routes
+	keys := api.Group("/keys")
+	keys.Use(auth.AuthRequired(), admin)
+	keys.Get("/", listAPIKeys)
+	keys.Post("/", createAPIKey)
+	keys.Delete("/:id", deleteAPIKey)
+}
+
+// Auth handlers
+type RegisterRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,min=6"`
+	Name     string `json:"name" validate:"required"`
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+func handleRegister(c *fiber.Ctx) error {
+	var req RegisterRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	// Check if user already exists
+	if _, err := repos.Users.GetByEmail(c.UserContext(), req.Email); err == nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Email already registered"})
+	}
+
+	// Hash password
+	hashedPassword, err := auth.HashPassword(req.Password)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to process password"})
+	}
+
+	// Create user
+	user := storage.User{
+		Email:    req.Email,
+		Password: hashedPassword,
+		Name:     req.Name,
+	}
+
+	if err := repos.Users.Create(c.UserContext(), &user); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create user"})
+	}
+
+	response, err := startSession(c, &user)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to generate token"})
+	}
+
+	return c.Status(201).JSON(response)
+}
+
+func handleLogin(c *fiber.Ctx) error {
+	var req LoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	// Find user by email
+	user, err := repos.Users.GetByEmail(c.UserContext(), req.Email)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid email or password"})
+	}
+
+	// Check password
+	if !auth.CheckPassword(req.Password, user.Password) {
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid email or password"})
+	}
+
+	response, err := startSession(c, user)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to generate token"})
+	}
+
+	return c.JSON(response)
+}
+
+func handleGetCurrentUser(c *fiber.Ctx) error {
+	userID, err := auth.GetUserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	user, err := repos.Users.Get(c.UserContext(), userID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "User not found"})
+	}
+
+	// Plan and usage are those of the selected organization, the
+	// personal one unless X-Organization-ID names another
+	organization, err := currentOrganization(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Organization not found"})
+	}
+	quota, err := quotas.GetStatus(c.UserContext(), organization)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch plan usage"})
+	}
+
+	return c.JSON(fiber.Map{
+		"user": fiber.Map{
+			"id":    user.ID,
+			"email": user.Email,
+			"name":  user.Name,
+		},
+		"organization": organizationResponse(organization, auth.GetRole(c)),
+		"quota":        quota,
+	})
+}
+
+// Function handlers
+func listFunctions(c *fiber.Ctx) error {
+	organizationID, err := auth.GetOrganizationID(c)
+	if err != nil {
+		return c.Status(403).JSON(fiber.Map{"error": "Forbidden"})
+	}
+
+	functions, err := repos.Functions.ListForOrganization(c.UserContext(), organizationID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch functions"})
+	}
+	return c.JSON(functions)
+}
+
+type CreateFunctionRequest struct {
+	Name        string `json:"name" validate:"required"`
+	Description string `json:"description"`
+	Runtime     string `json:"runtime" validate:"required"`
+	Code        string `json:"code" validate:"required"`
+	EntryPoint  string `json:"entry_point"`
+	MemoryMB    int    `json:"memory_mb"`
+	TimeoutSec  int    `json:"timeout_sec"`
+
+	Environment map[string]string         `json:"environment"`
+	RetryPolicy *RetryPolicyRequest       `json:"retry_policy"`
+	Limits      *LimitsRequest            `json:"limits"`
+	Retention   *RetentionRequest         `json:"retention"`
+	OnSuccess   *destinations.Destination `json:"on_success"`
+	OnFailure   *destinations.Destination `json:"on_failure"`
+}
+
+func createFunction(c *fiber.Ctx) error {
+	var req CreateFunctionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	// Get authenticated user ID from JWT token
+	userID, err := auth.GetUserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	organizationID, err := auth.GetOrganizationID(c)
+	if err != nil {
+		return c.Status(403).JSON(fiber.Map{"error": "Forbidden"})
+	}
+
+	// Set defaults
+	if req.EntryPoint == "" {
+		req.EntryPoint = "index.handler"
+	}
+	if req.MemoryMB == 0 {
+		req.MemoryMB = 128
+	}
+	if req.TimeoutSec == 0 {
+		req.TimeoutSec = 30
+	}
+	if err := quotas.CheckFunction(c.UserContext(), organizationID, req.MemoryMB, req.TimeoutSec, true); err != nil {
+		return quotaResponse(c, err)
+	}
+
+	environment, err := marshalEnvironment(req.Environment)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	function := storage.Function{
+		OrganizationID: organizationID,
+		UserID:         userID,
+		Name:           req.Name,
+		Description:    req.Description,
+		Runtime:        req.Runtime,
+		Code:           req.Code,
+		EntryPoint:     req.EntryPoint,
+		MemoryMB:       req.MemoryMB,
+		TimeoutSec:     req.TimeoutSec,
+		Status:         "active",
+		Environment:    environment,
+
+		RetryMaxAttempts:   1,
+		RetryBackoffSec:    2,
+		RetryMaxBackoffSec: 300,
+		RetryOn:            exec.ErrorClassTimeout + "," + exec.ErrorClassSystem,
+	}
+	if req.RetryPolicy != nil {
+		if err := applyRetryPolicy(&function, req.RetryPolicy); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	if req.Limits != nil {
+		if err := applyLimits(&function, req.Limits); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	if req.Retention != nil {
+		if err := applyRetention(&function.RetentionDays, &function.RetentionMaxCount, req.Retention); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	destinationSecret, err := applyDestinations(c.UserContext(), &function, req.OnSuccess, req.OnFailure)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := repos.Functions.Create(c.UserContext(), &function); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create function"})
+	}
+
+	if destinationSecret != "" {
+		return c.Status(201).JSON(fiber.Map{
+			"function":           function,
+			"destination_secret": destinationSecret,
+		})
+	}
+	return c.Status(201).JSON(function)
+}
+
+func getFunction(c *fiber.Ctx) error {
+	function, err := findFunction(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Function not found"})
+	}
+
+	stored, err := repos.Secrets.ListForFunction(c.UserContext(), function.ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch secrets"})
+	}
+	for _, secret := range stored {
+		function.SecretNames = append(function.SecretNames, secret.Name)
+	}
+
+	return c.JSON(function)
+}
+
+type UpdateFunctionRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Code        string `json:"code"`
+	EntryPoint  string `json:"entry_point"`
+	MemoryMB    int    `json:"memory_mb"`
+	TimeoutSec  int    `json:"timeout_sec"`
+	Status      string `json:"status"`
+
+	// Environment replaces all plain variables when present
+	Environment map[string]string         `json:"environment"`
+	RetryPolicy *RetryPolicyRequest       `json:"retry_policy"`
+	Limits      *LimitsRequest            `json:"limits"`
+	Retention   *RetentionRequest         `json:"retention"`
+	OnSuccess   *destinations.Destination `json:"on_success"` // empty type clears
+	OnFailure   *destinations.Destination `json:"on_failure"`
+}
+
+func updateFunction(c *fiber.Ctx) error {
+	function, err := findFunction(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Function not found"})
+	}
+
+	var req UpdateFunctionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	// Update only provided fields
+	if req.Name != "" {
+		function.Name = req.Name
+	}
+	if req.Description != "" {
+		function.Description = req.Description
+	}
+	if req.Code != "" {
+		function.Code = req.Code
+	}
+	if req.EntryPoint != "" {
+		function.EntryPoint = req.EntryPoint
+	}
+	if req.MemoryMB > 0 {
+		function.MemoryMB = req.MemoryMB
+	}
+	if req.TimeoutSec > 0 {
+		function.TimeoutSec = req.TimeoutSec
+	}
+	if req.MemoryMB > 0 || req.TimeoutSec > 0 {
+		if err := quotas.CheckFunction(c.UserContext(), function.OrganizationID, function.MemoryMB, function.TimeoutSec, false); err != nil {
+			return quotaResponse(c, err)
+		}
+	}
+	if req.Status != "" {
+		function.Status = req.Status
+	}
+	if req.Environment != nil {
+		environment, err := marshalEnvironment(req.Environment)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		function.Environment = environment
+	}
+	if req.RetryPolicy != nil {
+		if err := applyRetryPolicy(function, req.RetryPolicy); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	if req.Limits != nil {
+		if err := applyLimits(function, req.Limits); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	if req.Retention != nil {
+		if err := applyRetention(&function.RetentionDays, &function.RetentionMaxCount, req.Retention); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	destinationSecret, err := applyDestinations(c.UserContext(), function, req.OnSuccess, req.OnFailure)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := repos.Functions.Save(c.UserContext(), function); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update function"})
+	}
+
+	if destinationSecret != "" {
+		return c.JSON(fiber.Map{
+			"function":           function,
+			"destination_secret": destinationSecret,
+		})
+	}
+	return c.JSON(function)
+}
+
+func deleteFunction(c *fiber.Ctx) error {
+	function, err := findFunction(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Function not found"})
+	}
+
+	// Delete associated executions, secrets and triggers first
+	if err := payloads.DeleteFunction(c.UserContext(), function.ID); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete execution payloads"})
+	}
+	if err := repos.Executions.DeleteForFunction(c.UserContext(), function.ID); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete executions"})
+	}
+	if err := retention.DeleteArchives(c.UserContext(), ObjectStore, function.ID); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete archived executions"})
+	}
+
+	// Delete function
+	if err := repos.Functions.Delete(c.UserContext(), function); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete function"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Function deleted successfully"})
+}
+
+// ExecuteFunctionRequest is the JSON invocation body. Requests with any
+// other Content-Type pass the raw body to the function instead, wrapped
+// by payloads.EncodeBody.
+type ExecuteFunctionRequest struct {
+	Input interface{} `json:"input"`
+
+	// Destinations for this invocation only, overriding the function's
+	OnSuccess *destinations.Destination `json:"on_success"`
+	OnFailure *destinations.Destination `json:"on_failure"`
+}
+
+func executeFunction(c *fiber.Ctx) error {
+	function, err := findFunction(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Function not found"})
+	}
+
+	// Executions count against the plan of the function's organization
+	if err := quotas.CheckExecution(c.UserContext(), function.OrganizationID); err != nil {
+		return quotaResponse(c, err)
+	}
+
+	var req ExecuteFunctionRequest
+	contentType := c.Get(fiber.HeaderContentType)
+	switch body := c.Body(); {
+	case len(body) == 0:
+		// Default to empty input if not provided
+		req.Input = make(map[string]interface{})
+	case contentType == "" || payloads.IsJSON(contentType):
+		if err := json.Unmarshal(body, &req); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+		}
+	default:
+		req.Input = payloads.EncodeBody(strings.Clone(contentType), body)
+	}
+
+	// Marshal input to JSON bytes for JSONB
+	inputJSON, err := json.Marshal(req.Input)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid input format"})
+	}
+	if err := payloads.CheckInput(inputJSON); err != nil {
+		return c.Status(413).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// Create execution record
+	executionID := uuid.New()
+	execution := storage.Execution{
+		ID:             executionID,
+		OrganizationID: function.OrganizationID,
+		UserID:         function.UserID,
+		FunctionID:     function.ID,
+		Status:         "pending",
+		TriggerType:    exec.TriggerHTTP,
+		Input:          inputJSON,
+	}
+
+	// Per-request destinations override the function's for this run only
+	for _, override := range []struct {
+		destination *destinations.Destination
+		column      *datatypes.JSON
+	}{
+		{req.OnSuccess, &execution.OnSuccess},
+		{req.OnFailure, &execution.OnFailure},
+	} {
+		if override.destination == nil || override.destination.Type == "" {
+			continue
+		}
+		if err := validateDestination(c.UserContext(), function.OrganizationID, override.destination); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		if override.destination.Type == destinations.TypeHTTP && function.DestinationSecretCiphertext == "" {
+			return c.Status(400).JSON(fiber.Map{"error": "Function has no destination secret; create one first"})
+		}
+		*override.column = datatypes.JSON(marshalJSON(override.destination))
+	}
+
+	if key := c.Get(IdempotencyKeyHeader); key != "" {
+		callerID, err := auth.GetUserID(c)
+		if err != nil {
+			return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+		}
+		original, err := createIdempotentExecution(c.UserContext(), key, callerID, c.Body(), &execution)
+		switch {
+		case errors.Is(err, errIdempotencyKeyTooLong):
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		case errors.Is(err, errIdempotencyKeyReused):
+			return c.Status(422).JSON(fiber.Map{"error": err.Error()})
+		case err != nil:
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to create execution record"})
+		case original != nil:
+			// A retry of an earlier request; report that execution instead
+			if err := payloads.Hydrate(c.UserContext(), original); err != nil {
+				return c.Status(500).JSON(fiber.Map{"error": "Failed to load execution output"})
+			}
+			c.Set("Idempotent-Replayed", "true")
+			return c.JSON(fiber.Map{
+				"execution_id": original.ID,
+				"status":       original.Status,
+				"output":       original.Output,
+				"error":        original.Error,
+				"message":      "Execution already started for this Idempotency-Key",
+			})
+		}
+	} else {
+		offloadPayloads(c.UserContext(), &execution)
+		if err := repos.Executions.Create(c.UserContext(), &execution); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to create execution record"})
+		}
+	}
+
+	// Execute function asynchronously
+	go executeAsync(tracing.Detach(c.UserContext()), executionID, *function, req.Input)
+
+	return c.Status(201).JSON(fiber.Map{
+		"execution_id": executionID,
+		"status":       "pending",
+		"message":      "Function execution started",
+	})
+}
+
+// Execution handlers
+func getExecution(c *fiber.Ctx) error {
+	id := c.Params("id")
+	execution, err := findExecution(c, id)
+	if err != nil {
+		archived, archiveErr := loadArchivedExecution(c, id)
+		if archiveErr != nil {
+			return c.Status(404).JSON(fiber.Map{"error": "Execution not found"})
+		}
+		return c.JSON(archived)
+	}
+	if err := payloads.Hydrate(c.UserContext(), execution); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load execution payloads"})
+	}
+
+	return c.JSON(execution)
+}
+
+func getExecutionLogs(c *fiber.Ctx) error {
+	id := c.Params("id")
+	execution, err := findExecution(c, id)
+	if err != nil {
+		if execution, err = loadArchivedExecution(c, id); err != nil {
+			return c.Status(404).JSON(fiber.Map{"error": "Execution not found"})
+		}
+	}
+	if err := payloads.Hydrate(c.UserContext(), execution); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load execution logs"})
+	}
+
+	return c.JSON(fiber.Map{
+		"execution_id": execution.ID,
+		"logs":         execution.Logs,
+		"status":       execution.Status,
+	})
+}
+
+// getExecutionOutput returns the function's return value as-is: binary
+// and text envelopes are decoded and served with their content type,
+// anything else as JSON
+func getExecutionOutput(c *fiber.Ctx) error {
+	id := c.Params("id")
+	execution, err := findExecution(c, id)
+	if err != nil {
+		if execution, err = loadArchivedExecution(c, id); err != nil {
+			return c.Status(404).JSON(fiber.Map{"error": "Execution not found"})
+		}
+	}
+	if execution.Status != "success" {
+		return c.Status(409).JSON(fiber.Map{"error": "Execution has no output", "status": execution.Status})
+	}
+	if err := payloads.Hydrate(c.UserContext(), execution); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load execution output"})
+	}
+
+	var output interface{}
+	if len(execution.Output) > 0 {
+		if err := json.Unmarshal(execution.Output, &output); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to decode execution output"})
+		}
+	}
+	if contentType, body, ok := payloads.DecodeBody(output); ok {
+		c.Set(fiber.HeaderContentType, contentType)
+		return c.Send(body)
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	if len(execution.Output) == 0 {
+		return c.SendString("null")
+	}
+	return c.Send(execution.Output)
+}
+
+// API Key handlers
+func listAPIKeys(c *fiber.Ctx) error {
+	organizationID, err := auth.GetOrganizationID(c)
+	if err != nil {
+		return c.Status(403).JSON(fiber.Map{"error": "Forbidden"})
+	}
+
+	keys, err := repos.APIKeys.ListForOrganization(c.UserContext(), organizationID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch API keys"})
+	}
+
+	return c.JSON(keys)
+}
+
+type CreateAPIKeyRequest struct {
+	Name string `json:"name" validate:"required"`
+}
+
+func createAPIKey(c *fiber.Ctx) error {
+	userID, err := auth.GetUserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	organizationID, err := auth.GetOrganizationID(c)
+	if err != nil {
+		return c.Status(403).JSON(fiber.Map{"error": "Forbidden"})
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	// Generate random API key
+	keyBytes := make([]byte, 32)
+	if _, err := rand.Read(keyBytes); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to generate key"})
+	}
+	rawKey := "vr_" + hex.EncodeToString(keyBytes)
+
+	// Hash the key for storage
+	hashedKey, err := auth.HashPassword(rawKey)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to process key"})
+	}
+
+	// Store only prefix for display
+	prefix := rawKey[:12]
+
+	apiKey := storage.APIKey{
+		OrganizationID: organizationID,
+		UserID:         userID,
+		Name:           req.Name,
+		Key:            hashedKey,
+		Prefix:         prefix,
+	}
+
+	if err := repos.APIKeys.Create(c.UserContext(), &apiKey); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create API key"})
+	}
+
+	// Return the raw key only once
+	return c.Status(201).JSON(fiber.Map{
+		"id":      apiKey.ID,
+		"name":    apiKey.Name,
+		"key":     rawKey,
+		"prefix":  prefix,
+		"message": "Save this key securely. It won't be shown again.",
+	})
+}
+
+func deleteAPIKey(c *fiber.Ctx) error {
+	organizationID, err := auth.GetOrganizationID(c)
+	if err != nil {
+		return c.Status(403).JSON(fiber.Map{"error": "Forbidden"})
+	}
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "API key not found"})
+	}
+	apiKey, err := repos.APIKeys.GetForOrganization(c.UserContext(), id, organizationID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "API key not found"})
+	}
+
+	if err := repos.APIKeys.Delete(c.UserContext(), apiKey); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete API key"})
+	}
+
+	return c.JSON(fiber.Map{"message": "API key deleted successfully"})
+}
+
+// findFunction loads the function named by the id route parameter from
+// the request's organization
+func findFunction(c *fiber.Ctx) (*storage.Function, error) {
+	organizationID, err := auth.GetOrganizationID(c)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, storage.ErrNotFound
+	}
+	return repos.Functions.GetForOrganization(c.UserContext(), id, organizationID)
+}
+
+// findExecution loads one of the organization's live executions
+func findExecution(c *fiber.Ctx, id string) (*storage.Execution, error) {
+	organizationID, err := auth.GetOrganizationID(c)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return nil, storage.ErrNotFound
+	}
+	return repos.Executions.GetForOrganization(c.UserContext(), parsed, organizationID)
+}
+
+// marshalJSON converts a map to JSON bytes for JSONB
+func marshalJSON(data interface{}) []byte {
+	bytes, _ := json.Marshal(data)
+	return bytes
+}
+
+// executeAsync runs an execution the caller stored as pending. The
+// engine records its progress and result; failures then go through the
+// retry policy and successes to the on_success destination. ctx carries
+// the trace of whatever started the execution and must outlive the request.
+func executeAsync(ctx context.Context, executionID uuid.UUID, function storage.Function, input interface{}) {
+	result, err := engine.Execute(ctx, exec.ExecutionRequest{
+		ExecutionID: executionID,
+		FunctionID:  function.ID,
+		Input:       input,
+		UserID:      function.UserID,
+	})
+
+	switch {
+	case errors.Is(err, exec.ErrExecutionStarted):
+		// Another caller already ran it
+		utils.Warn("Execution is no longer pending", zap.String("execution_id", executionID.String()))
+	case err != nil:
+		handleAsyncFailure(executionID, function, exec.ClassifyError(err), err.Error())
+	case result.Status == "failed":
+		handleAsyncFailure(executionID, function, result.ErrorClass, result.Error)
+	default:
+		dispatchDestination(executionID, function, true)
+	}
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/auth"
+	"github.com/voltrun/backend/internal/storage"
+	"github.com/voltrun/backend/scheduler"
+	"gorm.io/datatypes"
+)
+
+type ScheduleRequest struct {
+	Expression string                 `json:"expression"`
+	Timezone   string                 `json:"timezone"`
+	Input      map[string]interface{} `json:"input"`
+	Enabled    *bool                  `json:"enabled"`
+}
+
+// Schedule handlers
+func listSchedules(c *fiber.Ctx) error {
+	function, err := findFunction(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Function not found"})
+	}
+
+	schedules, err := repos.Schedules.ListForFunction(c.UserContext(), function.ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch schedules"})
+	}
+
+	return c.JSON(schedules)
+}
+
+func createSchedule(c *fiber.Ctx) error {
+	userID, err := auth.GetUserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	function, err := findFunction(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Function not found"})
+	}
+
+	var req ScheduleRequest
+	if err := c.BodyParser(&req); err != nil || req.Expression == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if req.Timezone == "" {
+		req.Timezone = "UTC"
+	}
+	if req.Input == nil {
+		req.Input = make(map[string]interface{})
+	}
+
+	schedule := storage.Schedule{
+		FunctionID: function.ID,
+		UserID:     userID,
+		Expression: req.Expression,
+		Timezone:   req.Timezone,
+		Input:      datatypes.JSON(marshalJSON(req.Input)),
+		Enabled:    req.Enabled == nil || *req.Enabled,
+	}
+	if err := armSchedule(&schedule); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := repos.Schedules.Create(c.UserContext(), &schedule); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create schedule"})
+	}
+
+	return c.Status(201).JSON(schedule)
+}
+
+func updateSchedule(c *fiber.Ctx) error {
+	function, err := findFunction(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Function not found"})
+	}
+
+	id, err := uuid.Parse(c.Params("scheduleId"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Schedule not found"})
+	}
+	schedule, err := repos.Schedules.GetForFunction(c.UserContext(), id, function.ID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Schedule not found"})
+	}
+
+	var req ScheduleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	// Update only provided fields
+	if req.Expression != "" {
+		schedule.Expression = req.Expression
+	}
+	if req.Timezone != "" {
+		schedule.Timezone = req.Timezone
+	}
+	if req.Input != nil {
+		schedule.Input = datatypes.JSON(marshalJSON(req.Input))
+	}
+	if req.Enabled != nil {
+		schedule.Enabled = *req.Enabled
+	}
+	if err := armSchedule(schedule); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := repos.Schedules.Save(c.UserContext(), schedule); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update schedule"})
+	}
+
+	return c.JSON(schedule)
+}
+
+func deleteSchedule(c *fiber.Ctx) error {
+	function, err := findFunction(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Function not found"})
+	}
+
+	id, err := uuid.Parse(c.Params("scheduleId"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Schedule not found"})
+	}
+	if err := repos.Schedules.Delete(c.UserContext(), id, function.ID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "Schedule not found"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete schedule"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Schedule deleted successfully"})
+}
+
+// armSchedule validates the expression and computes the next activation
+func armSchedule(schedule *storage.Schedule) error {
+	next, err := scheduler.NextRun(schedule.Expression, schedule.Timezone, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if schedule.Enabled {
+		schedule.NextRunAt = &next
+	} else {
+		schedule.NextRunAt = nil
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/voltrun/backend/internal/storage"
+)
+
+func TestSchedules(t *testing.T) {
+	s := newTestServer(t)
+
+	status, body := s.do("POST", s.functionPath("/schedules"), map[string]string{"expression": "not a cron"})
+	expectStatus(t, status, 400, body)
+
+	status, body = s.do("POST", s.functionPath("/schedules"), map[string]string{"expression": "*/5 * * * *"})
+	expectStatus(t, status, 201, body)
+	var created storage.Schedule
+	decode(t, body, &created)
+	if !created.Enabled || created.Timezone != "UTC" || created.NextRunAt == nil {
+		t.Fatalf("schedule = %+v, want enabled in UTC with a next run", created)
+	}
+
+	// Disabling a schedule disarms it
+	status, body = s.do("PUT", s.functionPath("/schedules/"+created.ID.String()), map[string]bool{"enabled": false})
+	expectStatus(t, status, 200, body)
+	var updated storage.Schedule
+	decode(t, body, &updated)
+	if updated.Enabled || updated.NextRunAt != nil || updated.Expression != created.Expression {
+		t.Fatalf("schedule = %+v, want disabled without a next run", updated)
+	}
+
+	status, body = s.do("GET", s.functionPath("/schedules"), nil)
+	expectStatus(t, status, 200, body)
+	var listed []storage.Schedule
+	decode(t, body, &listed)
+	if len(listed) != 1 || listed[0].ID != created.ID {
+		t.Fatalf("schedules = %+v, want the created one", listed)
+	}
+
+	status, body = s.do("DELETE", s.functionPath("/schedules/"+created.ID.String()), nil)
+	expectStatus(t, status, 200, body)
+	status, body = s.do("DELETE", s.functionPath("/schedules/"+created.ID.String()), nil)
+	expectStatus(t, status, 404, body)
+}
+
+func TestSchedulesOfOtherOrganization(t *testing.T) {
+	s := newTestServer(t)
+	status, body := s.do("POST", s.functionPath("/schedules"), map[string]string{"expression": "@every 1h"})
+	expectStatus(t, status, 201, body)
+	var created storage.Schedule
+	decode(t, body, &created)
+
+	// Another user's function cannot reach the schedule either
+	other, token := s.newUser("other@example.com")
+	otherFunction := s.newFunction(other)
+	path := "/api/functions/" + otherFunction.ID.String() + "/schedules/" + created.ID.String()
+	status, body = s.doAs(token, "DELETE", path, nil)
+	expectStatus(t, status, 404, body)
+
+	status, body = s.doAs(token, "GET", s.functionPath("/schedules"), nil)
+	expectStatus(t, status, 404, body)
+	status, body = s.doAs(token, "DELETE", s.functionPath("/schedules/"+created.ID.String()), nil)
+	expectStatus(t, status, 404, body)
+}
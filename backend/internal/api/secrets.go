@@ -0,0 +1,85 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/voltrun/backend/internal/secrets"
+	"github.com/voltrun/backend/internal/storage"
+	"gorm.io/datatypes"
+)
+
+type PutSecretRequest struct {
+	Value string `json:"value" validate:"required"`
+}
+
+// Secret handlers
+func listFunctionSecrets(c *fiber.Ctx) error {
+	function, err := findFunction(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Function not found"})
+	}
+
+	stored, err := repos.Secrets.ListForFunction(c.UserContext(), function.ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch secrets"})
+	}
+
+	return c.JSON(stored)
+}
+
+func putFunctionSecret(c *fiber.Ctx) error {
+	function, err := findFunction(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Function not found"})
+	}
+
+	var req PutSecretRequest
+	if err := c.BodyParser(&req); err != nil || req.Value == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	name := c.Params("name")
+	if err := secrets.ValidateName(name); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	secret, err := secrets.NewSecret(function, name, req.Value)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to store secret"})
+	}
+	if err := repos.Secrets.Put(c.UserContext(), secret); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to store secret"})
+	}
+
+	return c.JSON(secret)
+}
+
+func deleteFunctionSecret(c *fiber.Ctx) error {
+	function, err := findFunction(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Function not found"})
+	}
+
+	if err := repos.Secrets.Delete(c.UserContext(), function.ID, c.Params("name")); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "Secret not found"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete secret"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Secret deleted successfully"})
+}
+
+// marshalEnvironment validates plain environment variables and encodes them for JSONB
+func marshalEnvironment(env map[string]string) (datatypes.JSON, error) {
+	if env == nil {
+		env = map[string]string{}
+	}
+	for name := range env {
+		if err := secrets.ValidateName(name); err != nil {
+			return nil, err
+		}
+	}
+	return datatypes.JSON(marshalJSON(env)), nil
+}
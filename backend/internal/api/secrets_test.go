@@ -0,0 +1,51 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/voltrun/backend/internal/secrets"
+	"github.com/voltrun/backend/internal/storage"
+)
+
+func TestFunctionSecrets(t *testing.T) {
+	s := newTestServer(t)
+
+	status, body := s.do("PUT", s.functionPath("/secrets/API_KEY"), map[string]string{"value": "first"})
+	expectStatus(t, status, 200, body)
+	status, body = s.do("PUT", s.functionPath("/secrets/API_KEY"), map[string]string{"value": "second"})
+	expectStatus(t, status, 200, body)
+
+	// Putting a name again replaces its value
+	status, body = s.do("GET", s.functionPath("/secrets"), nil)
+	expectStatus(t, status, 200, body)
+	var listed []storage.FunctionSecret
+	decode(t, body, &listed)
+	if len(listed) != 1 || listed[0].Name != "API_KEY" || listed[0].Ciphertext != "" {
+		t.Fatalf("secrets = %+v, want only API_KEY without its ciphertext", listed)
+	}
+	stored, err := s.repos.Secrets.ListForFunction(t.Context(), s.function.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := secrets.Decrypt(stored[0].Ciphertext, stored[0].KeyID); err != nil || value != "second" {
+		t.Fatalf("stored value = %q, %v; want second", value, err)
+	}
+
+	status, body = s.do("PUT", s.functionPath("/secrets/VOLTRUN_TOKEN"), map[string]string{"value": "x"})
+	expectStatus(t, status, 400, body)
+
+	status, body = s.do("DELETE", s.functionPath("/secrets/API_KEY"), nil)
+	expectStatus(t, status, 200, body)
+	status, body = s.do("DELETE", s.functionPath("/secrets/API_KEY"), nil)
+	expectStatus(t, status, 404, body)
+}
+
+func TestFunctionSecretsOfOtherOrganization(t *testing.T) {
+	s := newTestServer(t)
+	_, token := s.newUser("other@example.com")
+
+	status, body := s.doAs(token, "PUT", s.functionPath("/secrets/API_KEY"), map[string]string{"value": "x"})
+	expectStatus(t, status, 404, body)
+	status, body = s.doAs(token, "GET", s.functionPath("/secrets"), nil)
+	expectStatus(t, status, 404, body)
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/auth"
+	"github.com/voltrun/backend/internal/storage"
+	"github.com/voltrun/backend/internal/utils"
+	"go.uber.org/zap"
+)
+
+// maxUserAgentLength bounds the user agent recorded on a session
+const maxUserAgentLength = 256
+
+// RefreshRequest exchanges a refresh token for new tokens
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// startSession signs the user in on a new session and returns the login
+// response: a short-lived access token and the session's refresh token
+func startSession(c *fiber.Ctx, user *storage.User) (fiber.Map, error) {
+	refreshToken, refreshHash, err := auth.NewRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	userAgent := c.Get(fiber.HeaderUserAgent)
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	session := storage.Session{
+		UserID:     user.ID,
+		UserAgent:  strings.Clone(userAgent),
+		IPAddress:  strings.Clone(c.IP()),
+		ExpiresAt:  now.Add(auth.RefreshTokenTTL),
+		LastUsedAt: now,
+	}
+	if err := repos.Sessions.Create(c.UserContext(), &session, &storage.RefreshToken{TokenHash: refreshHash}); err != nil {
+		return nil, err
+	}
+
+	return tokenResponse(user, session.ID, refreshToken)
+}
+
+// tokenResponse mints an access token for the session
+func tokenResponse(user *storage.User, sessionID uuid.UUID, refreshToken string) (fiber.Map, error) {
+	token, err := auth.GenerateToken(user.ID, user.Email, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return fiber.Map{
+		"token":         token,
+		"expires_in":    int(auth.AccessTokenTTL.Seconds()),
+		"refresh_token": refreshToken,
+		"session_id":    sessionID,
+		"user": fiber.Map{
+			"id":    user.ID,
+			"email": user.Email,
+			"name":  user.Name,
+		},
+	}, nil
+}
+
+// handleRefresh rotates a refresh token. Each refresh token works once;
+// presenting a rotated one again means it leaked, so its session is
+// revoked.
+func handleRefresh(c *fiber.Ctx) error {
+	var req RefreshRequest
+	if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "refresh_token is required"})
+	}
+
+	refreshToken, refreshHash, err := auth.NewRefreshToken()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to generate token"})
+	}
+	session, err := repos.Sessions.Rotate(c.UserContext(), auth.HashToken(req.RefreshToken),
+		&storage.RefreshToken{TokenHash: refreshHash}, time.Now().Add(auth.RefreshTokenTTL))
+	switch {
+	case errors.Is(err, storage.ErrRefreshTokenReused):
+		utils.Warn("Refresh token reuse detected; session revoked", zap.String("ip", c.IP()))
+		return c.Status(401).JSON(fiber.Map{"error": "Refresh token was already used; the session has been revoked"})
+	case errors.Is(err, storage.ErrNotFound):
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid or expired refresh token"})
+	case err != nil:
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to refresh session"})
+	}
+
+	user, err := repos.Users.Get(c.UserContext(), session.UserID)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Invalid or expired refresh token"})
+	}
+
+	response, err := tokenResponse(user, session.ID, refreshToken)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to generate token"})
+	}
+	return c.JSON(response)
+}
+
+// handleLogout revokes the session of the calling access token. The
+// access token itself stays valid until it expires.
+func handleLogout(c *fiber.Ctx) error {
+	userID, err := auth.GetUserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	sessionID := auth.GetSessionID(c)
+	if sessionID != uuid.Nil {
+		err := repos.Sessions.Revoke(c.UserContext(), sessionID, userID)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to log out"})
+		}
+	}
+
+	return c.JSON(fiber.Map{"message": "Logged out successfully"})
+}
+
+func listSessions(c *fiber.Ctx) error {
+	userID, err := auth.GetUserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	sessions, err := repos.Sessions.ListActiveForUser(c.UserContext(), userID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch sessions"})
+	}
+	current := auth.GetSessionID(c)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current
+	}
+
+	return c.JSON(sessions)
+}
+
+func revokeSession(c *fiber.Ctx) error {
+	userID, err := auth.GetUserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Session not found"})
+	}
+	if err := repos.Sessions.Revoke(c.UserContext(), id, userID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": "Session not found"})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to revoke session"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Session revoked successfully"})
+}
+
+// revokeOtherSessions signs the user out everywhere except the calling
+// session
+func revokeOtherSessions(c *fiber.Ctx) error {
+	userID, err := auth.GetUserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	revoked, err := repos.Sessions.RevokeAllForUser(c.UserContext(), userID, auth.GetSessionID(c))
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to revoke sessions"})
+	}
+
+	return c.JSON(fiber.Map{"revoked": revoked})
+}
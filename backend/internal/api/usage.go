@@ -0,0 +1,108 @@
+package api
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/voltrun/backend/internal/auth"
+	"github.com/voltrun/backend/internal/metering"
+)
+
+// defaultUsageWindow is reported when from is omitted
+const defaultUsageWindow = 30 * 24 * time.Hour
+
+// getUsage reports metered usage for the request's organization. Query
+// parameters: from and to (RFC 3339 or YYYY-MM-DD), group_by
+// (comma-separated function plus one of hour, day or month) and
+// format=csv.
+func getUsage(c *fiber.Ctx) error {
+	organizationID, err := auth.GetOrganizationID(c)
+	if err != nil {
+		return c.Status(403).JSON(fiber.Map{"error": "Forbidden"})
+	}
+
+	to := time.Now().UTC()
+	if value := c.Query("to"); value != "" {
+		if to, err = parseUsageTime(value); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid to; use RFC 3339 or YYYY-MM-DD"})
+		}
+	}
+	from := to.Add(-defaultUsageWindow)
+	if value := c.Query("from"); value != "" {
+		if from, err = parseUsageTime(value); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "Invalid from; use RFC 3339 or YYYY-MM-DD"})
+		}
+	}
+
+	// An explicit empty group_by asks for a single total
+	groupBy := []string{metering.GroupDay}
+	if c.Context().QueryArgs().Has("group_by") {
+		groupBy = nil
+		if value := c.Query("group_by"); value != "" {
+			groupBy = strings.Split(value, ",")
+		}
+	}
+
+	usage, err := metering.Query(c.UserContext(), organizationID, from, to, groupBy)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if c.Query("format") == "csv" {
+		body, err := usageCSV(usage)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to encode usage"})
+		}
+		c.Set(fiber.HeaderContentType, "text/csv")
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="usage.csv"`)
+		return c.Send(body)
+	}
+
+	return c.JSON(fiber.Map{
+		"from":     from,
+		"to":       to,
+		"group_by": groupBy,
+		"usage":    usage,
+	})
+}
+
+// parseUsageTime accepts an RFC 3339 timestamp or a UTC date
+func parseUsageTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// usageCSV renders usage rows for the chargeback export
+func usageCSV(usage []metering.Usage) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"period", "function_id", "invocations", "errors", "duration_ms", "gb_seconds", "egress_bytes"})
+
+	for _, row := range usage {
+		var period, functionID string
+		if row.Period != nil {
+			period = row.Period.UTC().Format(time.RFC3339)
+		}
+		if row.FunctionID != nil {
+			functionID = row.FunctionID.String()
+		}
+		w.Write([]string{
+			period,
+			functionID,
+			strconv.FormatInt(row.Invocations, 10),
+			strconv.FormatInt(row.Errors, 10),
+			strconv.FormatInt(row.DurationMS, 10),
+			strconv.FormatFloat(row.GBSeconds, 'f', 6, 64),
+			strconv.FormatInt(row.EgressBytes, 10),
+		})
+	}
+
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
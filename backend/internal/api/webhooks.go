@@ -0,0 +1,237 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/auth"
+	"github.com/voltrun/backend/internal/exec"
+	"github.com/voltrun/backend/internal/payloads"
+	"github.com/voltrun/backend/internal/secrets"
+	"github.com/voltrun/backend/internal/storage"
+	"github.com/voltrun/backend/internal/tracing"
+	"github.com/voltrun/backend/internal/triggers"
+)
+
+type CreateWebhookRequest struct {
+	Name            string `json:"name" validate:"required"`
+	Provider        string `json:"provider"`
+	Secret          string `json:"secret"`
+	SignatureHeader string `json:"signature_header"`
+	Algorithm       string `json:"algorithm"`
+	TimestampHeader string `json:"timestamp_header"`
+	ToleranceSec    int    `json:"tolerance_sec"`
+}
+
+// Webhook handlers
+func listWebhooks(c *fiber.Ctx) error {
+	function, err := findFunction(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Function not found"})
+	}
+
+	webhooks, err := repos.Webhooks.ListForFunction(c.UserContext(), function.ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch webhooks"})
+	}
+
+	return c.JSON(webhooks)
+}
+
+func createWebhook(c *fiber.Ctx) error {
+	userID, err := auth.GetUserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+
+	function, err := findFunction(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Function not found"})
+	}
+
+	var req CreateWebhookRequest
+	if err := c.BodyParser(&req); err != nil || req.Name == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	// Set defaults
+	if req.Provider == "" {
+		req.Provider = triggers.ProviderGeneric
+	}
+	if req.Algorithm == "" {
+		req.Algorithm = "sha256"
+	}
+	if req.ToleranceSec == 0 {
+		req.ToleranceSec = 300
+	}
+	if req.Provider == triggers.ProviderGeneric && req.SignatureHeader == "" {
+		req.SignatureHeader = triggers.DefaultSignatureHeader
+	}
+	if req.Provider == triggers.ProviderGeneric && req.TimestampHeader == "" {
+		req.TimestampHeader = triggers.DefaultTimestampHeader
+	}
+	if req.Secret == "" {
+		secretBytes := make([]byte, 32)
+		if _, err := rand.Read(secretBytes); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to generate secret"})
+		}
+		req.Secret = "whsec_" + hex.EncodeToString(secretBytes)
+	}
+
+	if err := triggers.ValidateWebhookConfig(triggers.WebhookConfig{
+		Provider:  req.Provider,
+		Algorithm: req.Algorithm,
+		Tolerance: time.Duration(req.ToleranceSec) * time.Second,
+	}); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	ciphertext, keyID, err := secrets.Encrypt(req.Secret)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to encrypt secret"})
+	}
+
+	webhook := storage.WebhookTrigger{
+		FunctionID:       function.ID,
+		UserID:           userID,
+		Name:             req.Name,
+		Provider:         req.Provider,
+		SecretCiphertext: ciphertext,
+		SecretKeyID:      keyID,
+		SignatureHeader:  req.SignatureHeader,
+		Algorithm:        req.Algorithm,
+		TimestampHeader:  req.TimestampHeader,
+		ToleranceSec:     req.ToleranceSec,
+		Enabled:          true,
+	}
+
+	if err := repos.Webhooks.Create(c.UserContext(), &webhook); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create webhook"})
+	}
+
+	// Return the secret only once
+	return c.Status(201).JSON(fiber.Map{
+		"webhook": webhook,
+		"url":     "/api/webhooks/" + webhook.ID.String(),
+		"secret":  req.Secret,
+		"message": "Save this secret securely. It won't be shown again.",
+	})
+}
+
+func deleteWebhook(c *fiber.Ctx) error {
+	function, err := findFunction(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Function not found"})
+	}
+
+	id, err := uuid.Parse(c.Params("webhookId"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Webhook not found"})
+	}
+	webhook, err := repos.Webhooks.GetForFunction(c.UserContext(), id, function.ID)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Webhook not found"})
+	}
+
+	if err := repos.Webhooks.Delete(c.UserContext(), webhook); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete webhook"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Webhook deleted successfully"})
+}
+
+// handleWebhook verifies a delivery and starts the function asynchronously
+func handleWebhook(c *fiber.Ctx) error {
+	webhookID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Webhook not found"})
+	}
+
+	webhook, err := repos.Webhooks.Get(c.UserContext(), webhookID)
+	if err != nil || !webhook.Enabled {
+		return c.Status(404).JSON(fiber.Map{"error": "Webhook not found"})
+	}
+
+	secret, err := secrets.Decrypt(webhook.SecretCiphertext, webhook.SecretKeyID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to load webhook secret"})
+	}
+
+	body := c.Body()
+	config := triggers.WebhookConfig{
+		Provider:        webhook.Provider,
+		Secret:          secret,
+		SignatureHeader: webhook.SignatureHeader,
+		Algorithm:       webhook.Algorithm,
+		TimestampHeader: webhook.TimestampHeader,
+		Tolerance:       time.Duration(webhook.ToleranceSec) * time.Second,
+	}
+	header := func(name string) string { return c.Get(name) }
+	if err := triggers.VerifyWebhook(config, header, body, time.Now()); err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	function, err := repos.Functions.Get(c.UserContext(), webhook.FunctionID)
+	if err != nil || function.Status != "active" {
+		return c.Status(404).JSON(fiber.Map{"error": "Function not found"})
+	}
+
+	if err := payloads.CheckInput(body); err != nil {
+		return c.Status(413).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// Fiber reuses request buffers, so copy everything the goroutine keeps
+	headers := make(map[string]string)
+	for key, values := range c.GetReqHeaders() {
+		headers[strings.Clone(key)] = strings.Clone(strings.Join(values, ","))
+	}
+	query := make(map[string]string)
+	for key, value := range c.Queries() {
+		query[strings.Clone(key)] = strings.Clone(value)
+	}
+	input := triggers.WebhookEvent(webhook.Provider, strings.Clone(c.Method()), headers, query, body)
+
+	executionID := uuid.New()
+	execution := storage.Execution{
+		ID:             executionID,
+		UserID:         function.UserID,
+		OrganizationID: function.OrganizationID,
+		FunctionID:     function.ID,
+		Status:         "pending",
+		TriggerType:    exec.TriggerWebhook,
+		TriggerSource:  webhook.ID.String(),
+		Input:          marshalJSON(input),
+	}
+
+	// Providers without a signed timestamp are deduplicated by delivery
+	// ID, recorded together with the execution so failed requests can be
+	// redelivered
+	if deliveryID := triggers.DeliveryID(config, header); deliveryID != "" {
+		claimed, err := repos.Webhooks.ClaimDelivery(c.UserContext(), webhook.ID, strings.Clone(deliveryID), &execution, func() {
+			offloadPayloads(c.UserContext(), &execution)
+		})
+		if err != nil {
+			payloads.Delete(c.UserContext(), &execution)
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to create execution record"})
+		}
+		if !claimed {
+			return c.Status(409).JSON(fiber.Map{"error": "Webhook delivery already processed"})
+		}
+	} else {
+		offloadPayloads(c.UserContext(), &execution)
+		if err := repos.Executions.Create(c.UserContext(), &execution); err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": "Failed to create execution record"})
+		}
+	}
+
+	go executeAsync(tracing.Detach(c.UserContext()), executionID, *function, input)
+
+	return c.Status(202).JSON(fiber.Map{
+		"execution_id": executionID,
+		"status":       "pending",
+	})
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/storage"
+	"github.com/voltrun/backend/internal/triggers"
+)
+
+type createdWebhook struct {
+	Webhook storage.WebhookTrigger `json:"webhook"`
+	URL     string                 `json:"url"`
+	Secret  string                 `json:"secret"`
+}
+
+func TestWebhooks(t *testing.T) {
+	s := newTestServer(t)
+
+	status, body := s.do("POST", s.functionPath("/webhooks"), map[string]string{"name": "deploys"})
+	expectStatus(t, status, 201, body)
+	var created createdWebhook
+	decode(t, body, &created)
+	if !strings.HasPrefix(created.Secret, "whsec_") || created.Webhook.Provider != triggers.ProviderGeneric {
+		t.Fatalf("webhook = %+v, want a generated secret and the generic provider", created)
+	}
+	if created.URL != "/api/webhooks/"+created.Webhook.ID.String() {
+		t.Fatalf("url = %q", created.URL)
+	}
+
+	status, body = s.do("GET", s.functionPath("/webhooks"), nil)
+	expectStatus(t, status, 200, body)
+	var listed []storage.WebhookTrigger
+	decode(t, body, &listed)
+	if len(listed) != 1 || listed[0].ID != created.Webhook.ID {
+		t.Fatalf("webhooks = %+v, want the created one", listed)
+	}
+
+	// Deliveries without a valid signature never start the function
+	status, body = s.doAs("", "POST", created.URL, map[string]string{"event": "push"})
+	expectStatus(t, status, 401, body)
+
+	status, body = s.do("DELETE", s.functionPath("/webhooks/"+created.Webhook.ID.String()), nil)
+	expectStatus(t, status, 200, body)
+	status, body = s.doAs("", "POST", created.URL, map[string]string{"event": "push"})
+	expectStatus(t, status, 404, body)
+}
+
+func TestWebhooksOfOtherOrganization(t *testing.T) {
+	s := newTestServer(t)
+	status, body := s.do("POST", s.functionPath("/webhooks"), map[string]string{"name": "deploys"})
+	expectStatus(t, status, 201, body)
+	var created createdWebhook
+	decode(t, body, &created)
+
+	_, token := s.newUser("other@example.com")
+	status, body = s.doAs(token, "GET", s.functionPath("/webhooks"), nil)
+	expectStatus(t, status, 404, body)
+	status, body = s.doAs(token, "DELETE", s.functionPath("/webhooks/"+created.Webhook.ID.String()), nil)
+	expectStatus(t, status, 404, body)
+
+	if _, err := s.repos.Webhooks.Get(t.Context(), created.Webhook.ID); err != nil {
+		t.Fatalf("webhook was deleted: %v", err)
+	}
+}
+
+func TestWebhookDeliveryReleasedOnError(t *testing.T) {
+	s := newTestServer(t)
+	status, body := s.do("POST", s.functionPath("/webhooks"), map[string]string{
+		"name":     "pushes",
+		"provider": triggers.ProviderGitHub,
+		"secret":   "github-secret",
+	})
+	expectStatus(t, status, 201, body)
+	var created createdWebhook
+	decode(t, body, &created)
+
+	// The function cannot run, so the delivery is rejected
+	s.function.Status = "inactive"
+	if err := s.repos.Functions.Save(context.Background(), s.function); err != nil {
+		t.Fatal(err)
+	}
+	payload := []byte(`{"ref":"refs/heads/main"}`)
+	mac := hmac.New(sha256.New, []byte("github-secret"))
+	mac.Write(payload)
+	req := httptest.NewRequest("POST", created.URL, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	req.Header.Set("X-GitHub-Delivery", "delivery-1")
+	resp, err := s.app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	expectStatus(t, resp.StatusCode, 404, nil)
+
+	// GitHub may redeliver it once the function is fixed
+	execution := &storage.Execution{ID: uuid.New(), FunctionID: s.function.ID, Status: "pending"}
+	claimed, err := s.repos.Webhooks.ClaimDelivery(context.Background(), created.Webhook.ID, "delivery-1", execution, func() {})
+	if err != nil || !claimed {
+		t.Fatalf("claim = %v, %v; want the delivery unclaimed", claimed, err)
+	}
+	if _, err := s.repos.Executions.Get(context.Background(), execution.ID); err != nil {
+		t.Fatalf("execution was not stored with the delivery: %v", err)
+	}
+	claimed, err = s.repos.Webhooks.ClaimDelivery(context.Background(), created.Webhook.ID, "delivery-1", &storage.Execution{}, func() {})
+	if err != nil || claimed {
+		t.Fatalf("second claim = %v, %v; want the delivery already claimed", claimed, err)
+	}
+}
@@ -0,0 +1,286 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/auth"
+	"github.com/voltrun/backend/internal/quotas"
+	"github.com/voltrun/backend/internal/storage"
+	"github.com/voltrun/backend/internal/workflows"
+	"gorm.io/datatypes"
+)
+
+// workflowRunner executes workflow runs started through the API
+var workflowRunner *workflows.Runner
+
+// WorkflowRequest creates or updates a workflow. Definition is either a
+// JSON object or a string holding YAML.
+type WorkflowRequest struct {
+	Name        string          `json:"name"`
+	Description *string         `json:"description"`
+	Definition  json.RawMessage `json:"definition"`
+}
+
+type StartWorkflowRunRequest struct {
+	Input interface{} `json:"input"`
+}
+
+// Workflow handlers
+func listWorkflows(c *fiber.Ctx) error {
+	organizationID, err := auth.GetOrganizationID(c)
+	if err != nil {
+		return c.Status(403).JSON(fiber.Map{"error": "Forbidden"})
+	}
+
+	list, err := repos.Workflows.ListForOrganization(c.UserContext(), organizationID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch workflows"})
+	}
+
+	return c.JSON(list)
+}
+
+func createWorkflow(c *fiber.Ctx) error {
+	userID, err := auth.GetUserID(c)
+	if err != nil {
+		return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+	}
+	organizationID, err := auth.GetOrganizationID(c)
+	if err != nil {
+		return c.Status(403).JSON(fiber.Map{"error": "Forbidden"})
+	}
+
+	var req WorkflowRequest
+	if err := c.BodyParser(&req); err != nil || req.Name == "" || len(req.Definition) == 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	definition, err := parseWorkflowDefinition(c.UserContext(), organizationID, req.Definition)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	workflow := storage.Workflow{
+		UserID:         userID,
+		OrganizationID: organizationID,
+		Name:           req.Name,
+		Definition:     definition,
+	}
+	if req.Description != nil {
+		workflow.Description = *req.Description
+	}
+
+	if err := repos.Workflows.Create(c.UserContext(), &workflow); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to create workflow"})
+	}
+
+	return c.Status(201).JSON(workflow)
+}
+
+func getWorkflow(c *fiber.Ctx) error {
+	workflow, err := findWorkflow(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Workflow not found"})
+	}
+
+	return c.JSON(workflow)
+}
+
+func updateWorkflow(c *fiber.Ctx) error {
+	workflow, err := findWorkflow(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Workflow not found"})
+	}
+
+	var req WorkflowRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	// Update only provided fields; running runs keep their own copy
+	if req.Name != "" {
+		workflow.Name = req.Name
+	}
+	if req.Description != nil {
+		workflow.Description = *req.Description
+	}
+	if len(req.Definition) > 0 {
+		definition, err := parseWorkflowDefinition(c.UserContext(), workflow.OrganizationID, req.Definition)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		workflow.Definition = definition
+	}
+
+	if err := repos.Workflows.Save(c.UserContext(), workflow); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to update workflow"})
+	}
+
+	return c.JSON(workflow)
+}
+
+func deleteWorkflow(c *fiber.Ctx) error {
+	workflow, err := findWorkflow(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Workflow not found"})
+	}
+
+	// Stop in-flight runs before removing their history
+	runs, err := repos.Workflows.ListRuns(c.UserContext(), workflow.ID, workflow.OrganizationID, 0)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch workflow runs"})
+	}
+	for _, run := range runs {
+		workflows.Cancel(run.ID, workflow.OrganizationID)
+	}
+
+	if err := repos.Workflows.Delete(c.UserContext(), workflow); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to delete workflow"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Workflow deleted successfully"})
+}
+
+// Workflow run handlers
+func listWorkflowRuns(c *fiber.Ctx) error {
+	organizationID, err := auth.GetOrganizationID(c)
+	if err != nil {
+		return c.Status(403).JSON(fiber.Map{"error": "Forbidden"})
+	}
+
+	workflowID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Workflow not found"})
+	}
+	runs, err := repos.Workflows.ListRuns(c.UserContext(), workflowID, organizationID, 100)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch workflow runs"})
+	}
+
+	return c.JSON(runs)
+}
+
+func startWorkflowRun(c *fiber.Ctx) error {
+	workflow, err := findWorkflow(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Workflow not found"})
+	}
+
+	// Runs count against the plan of the workflow's organization
+	if err := quotas.CheckExecution(c.UserContext(), workflow.OrganizationID); err != nil {
+		return quotaResponse(c, err)
+	}
+
+	var req StartWorkflowRunRequest
+	if err := c.BodyParser(&req); err != nil {
+		// Default to empty input if not provided
+		req.Input = map[string]interface{}{}
+	}
+
+	run, err := workflowRunner.StartRun(workflow, req.Input)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(201).JSON(run)
+}
+
+// getWorkflowRun returns a run together with its recorded steps
+func getWorkflowRun(c *fiber.Ctx) error {
+	run, err := findWorkflowRun(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Workflow run not found"})
+	}
+
+	steps, err := repos.Workflows.ListSteps(c.UserContext(), run.ID)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to fetch workflow steps"})
+	}
+
+	return c.JSON(fiber.Map{
+		"run":   run,
+		"steps": steps,
+	})
+}
+
+func cancelWorkflowRun(c *fiber.Ctx) error {
+	run, err := findWorkflowRun(c)
+	if err != nil {
+		return c.Status(404).JSON(fiber.Map{"error": "Workflow run not found"})
+	}
+
+	if err := workflows.Cancel(run.ID, run.OrganizationID); err != nil {
+		if errors.Is(err, workflows.ErrRunNotActive) {
+			return c.Status(409).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(500).JSON(fiber.Map{"error": "Failed to cancel workflow run"})
+	}
+
+	return c.JSON(fiber.Map{"message": "Workflow run cancelled"})
+}
+
+// findWorkflow loads the :id workflow of the request's organization
+func findWorkflow(c *fiber.Ctx) (*storage.Workflow, error) {
+	organizationID, err := auth.GetOrganizationID(c)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, storage.ErrNotFound
+	}
+	return repos.Workflows.GetForOrganization(c.UserContext(), id, organizationID)
+}
+
+// findWorkflowRun loads the :runId run of the :id workflow in the
+// request's organization
+func findWorkflowRun(c *fiber.Ctx) (*storage.WorkflowRun, error) {
+	organizationID, err := auth.GetOrganizationID(c)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.Parse(c.Params("runId"))
+	if err != nil {
+		return nil, storage.ErrNotFound
+	}
+	workflowID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return nil, storage.ErrNotFound
+	}
+	return repos.Workflows.GetRun(c.UserContext(), id, workflowID, organizationID)
+}
+
+// parseWorkflowDefinition validates a JSON or YAML definition, checks that
+// every referenced function belongs to the organization and returns it as JSON
+func parseWorkflowDefinition(ctx context.Context, organizationID uuid.UUID, raw json.RawMessage) (datatypes.JSON, error) {
+	source := []byte(raw)
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		source = []byte(text)
+	}
+
+	definition, err := workflows.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	checked := make(map[uuid.UUID]bool)
+	for _, id := range definition.FunctionIDs() {
+		if checked[id] {
+			continue
+		}
+		checked[id] = true
+		if _, err := repos.Functions.GetForOrganization(ctx, id, organizationID); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				return nil, errors.New("workflow references a function that does not exist")
+			}
+			return nil, err
+		}
+	}
+
+	return datatypes.JSON(marshalJSON(definition)), nil
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/storage"
+)
+
+// workflowDefinition runs one task on functionID
+func workflowDefinition(functionID uuid.UUID) map[string]interface{} {
+	return map[string]interface{}{
+		"start_at": "run",
+		"steps": map[string]interface{}{
+			"run":  map[string]interface{}{"type": "task", "function_id": functionID, "next": "done"},
+			"done": map[string]interface{}{"type": "succeed"},
+		},
+	}
+}
+
+func TestWorkflows(t *testing.T) {
+	s := newTestServer(t)
+
+	status, body := s.do("POST", "/api/workflows", map[string]interface{}{
+		"name":       "orders",
+		"definition": workflowDefinition(s.function.ID),
+	})
+	expectStatus(t, status, 201, body)
+	var created storage.Workflow
+	decode(t, body, &created)
+	path := "/api/workflows/" + created.ID.String()
+
+	status, body = s.do("PUT", path, map[string]string{"name": "renamed"})
+	expectStatus(t, status, 200, body)
+	status, body = s.do("GET", path, nil)
+	expectStatus(t, status, 200, body)
+	var fetched storage.Workflow
+	decode(t, body, &fetched)
+	if fetched.Name != "renamed" {
+		t.Fatalf("name = %q, want renamed", fetched.Name)
+	}
+
+	status, body = s.do("GET", "/api/workflows", nil)
+	expectStatus(t, status, 200, body)
+	var listed []storage.Workflow
+	decode(t, body, &listed)
+	if len(listed) != 1 || listed[0].ID != created.ID {
+		t.Fatalf("workflows = %+v, want the created one", listed)
+	}
+
+	status, body = s.do("GET", path+"/runs", nil)
+	expectStatus(t, status, 200, body)
+	if string(body) != "[]" {
+		t.Fatalf("runs = %s, want none", body)
+	}
+	status, body = s.do("GET", path+"/runs/"+uuid.NewString(), nil)
+	expectStatus(t, status, 404, body)
+
+	status, body = s.do("DELETE", path, nil)
+	expectStatus(t, status, 200, body)
+	status, body = s.do("GET", path, nil)
+	expectStatus(t, status, 404, body)
+}
+
+func TestWorkflowsReferencingOtherOrganization(t *testing.T) {
+	s := newTestServer(t)
+	other, token := s.newUser("other@example.com")
+	otherFunction := s.newFunction(other)
+
+	// Definitions may only name the organization's own functions
+	status, body := s.do("POST", "/api/workflows", map[string]interface{}{
+		"name":       "orders",
+		"definition": workflowDefinition(otherFunction.ID),
+	})
+	expectStatus(t, status, 400, body)
+
+	status, body = s.do("POST", "/api/workflows", map[string]interface{}{
+		"name":       "orders",
+		"definition": workflowDefinition(s.function.ID),
+	})
+	expectStatus(t, status, 201, body)
+	var created storage.Workflow
+	decode(t, body, &created)
+	path := "/api/workflows/" + created.ID.String()
+
+	status, body = s.doAs(token, "GET", path, nil)
+	expectStatus(t, status, 404, body)
+	status, body = s.doAs(token, "DELETE", path, nil)
+	expectStatus(t, status, 404, body)
+	status, body = s.doAs(token, "GET", path+"/runs", nil)
+	expectStatus(t, status, 200, body)
+	if string(body) != "[]" {
+		t.Fatalf("runs = %s, want none", body)
+	}
+}
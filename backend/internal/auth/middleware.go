@@ -1,57 +1,86 @@
-package auth
-
-import (
-	"strings"
-
-	"github.com/gofiber/fiber/v2"
-	"github.com/google/uuid"
-)
-
-// AuthRequired middleware validates JWT tokens
-func AuthRequired() fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		authHeader := c.Get("Authorization")
-		if authHeader == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "missing authorization header",
-			})
-		}
-
-		// Extract token from "Bearer <token>"
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "invalid authorization header format",
-			})
-		}
-
-		token := parts[1]
-		claims, err := ValidateToken(token)
-		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "invalid or expired token",
-			})
-		}
-
-		// Store user info in context
-		c.Locals("userID", claims.UserID)
-		c.Locals("email", claims.Email)
-
-		return c.Next()
-	}
-}
-
-// GetUserID retrieves the authenticated user ID from context
-func GetUserID(c *fiber.Ctx) (uuid.UUID, error) {
-	userID, ok := c.Locals("userID").(uuid.UUID)
-	if !ok {
-		return uuid.Nil, fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
-	}
-	return userID, nil
-}
-
-// GetUserEmail retrieves the authenticated user email from context
-func GetUserEmail(c *fiber.Ctx) string {
-	email, _ := c.Locals("email").(string)
-	return email
-}
+package auth
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// AuthRequired middleware validates JWT tokens
+func AuthRequired() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		authHeader := c.Get("Authorization")
+		if authHeader == "" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "missing authorization header",
+			})
+		}
+
+		// Extract token from "Bearer <token>"
+		parts := strings.Split(authHeader, " ")
+		if len(parts) != 2 || parts[0] != "Bearer" {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "invalid authorization header format",
+			})
+		}
+
+		token := parts[1]
+		claims, err := ValidateToken(token)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error": "invalid or expired token",
+			})
+		}
+
+		// Store user info in context
+		c.Locals("userID", claims.UserID)
+		c.Locals("email", claims.Email)
+		c.Locals("sessionID", claims.SessionID)
+
+		return c.Next()
+	}
+}
+
+// GetUserID retrieves the authenticated user ID from context
+func GetUserID(c *fiber.Ctx) (uuid.UUID, error) {
+	userID, ok := c.Locals("userID").(uuid.UUID)
+	if !ok {
+		return uuid.Nil, fiber.NewError(fiber.StatusUnauthorized, "user not authenticated")
+	}
+	return userID, nil
+}
+
+// GetUserEmail retrieves the authenticated user email from context
+func GetUserEmail(c *fiber.Ctx) string {
+	email, _ := c.Locals("email").(string)
+	return email
+}
+
+// GetSessionID retrieves the session of the authenticated access token
+func GetSessionID(c *fiber.Ctx) uuid.UUID {
+	sessionID, _ := c.Locals("sessionID").(uuid.UUID)
+	return sessionID
+}
+
+// SetOrganization records the organization a request acts on and the
+// caller's role in it
+func SetOrganization(c *fiber.Ctx, organizationID uuid.UUID, role string) {
+	c.Locals("organizationID", organizationID)
+	c.Locals("role", role)
+}
+
+// GetOrganizationID retrieves the organization set by SetOrganization
+func GetOrganizationID(c *fiber.Ctx) (uuid.UUID, error) {
+	organizationID, ok := c.Locals("organizationID").(uuid.UUID)
+	if !ok {
+		return uuid.Nil, fiber.NewError(fiber.StatusForbidden, "no organization selected")
+	}
+	return organizationID, nil
+}
+
+// GetRole retrieves the caller's role in the request's organization
+func GetRole(c *fiber.Ctx) string {
+	role, _ := c.Locals("role").(string)
+	return role
+}
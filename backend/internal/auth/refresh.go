@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// RefreshTokenTTL is how long a session lasts without being refreshed
+var RefreshTokenTTL = 30 * 24 * time.Hour
+
+// InvitationTTL is how long an organization invitation can be accepted
+var InvitationTTL = 7 * 24 * time.Hour
+
+// NewRefreshToken returns a random refresh token and the hash to store
+func NewRefreshToken() (string, string, error) {
+	return newToken("vrt_")
+}
+
+// NewInvitationToken returns a random invitation token and the hash to store
+func NewInvitationToken() (string, string, error) {
+	return newToken("vin_")
+}
+
+func newToken(prefix string) (string, string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	token := prefix + hex.EncodeToString(raw)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the stored form of a refresh or invitation token.
+// Tokens are random, so a fast hash suffices and allows lookup by hash.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,134 @@
+package destinations
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// addresses are the cases shared by the publicIP, checkHost and
+// dialControl tests
+var addresses = []struct {
+	ip     string
+	public bool
+}{
+	{"93.184.216.34", true},
+	{"8.8.8.8", true},
+	{"2606:4700:4700::1111", true},
+	{"::ffff:93.184.216.34", true},
+
+	// loopback
+	{"127.0.0.1", false},
+	{"127.1.2.3", false},
+	{"::1", false},
+	// private
+	{"10.0.0.1", false},
+	{"172.16.0.1", false},
+	{"172.31.255.255", false},
+	{"192.168.1.1", false},
+	{"fd00::1", false},
+	// link-local, including the cloud metadata service
+	{"169.254.169.254", false},
+	{"fe80::1", false},
+	// unspecified and multicast
+	{"0.0.0.0", false},
+	{"::", false},
+	{"224.0.0.1", false},
+	{"ff02::1", false},
+	// reserved ranges
+	{"0.1.2.3", false},
+	{"100.64.0.1", false},
+	{"192.0.0.8", false},
+	{"192.0.2.1", false},
+	{"198.18.0.1", false},
+	{"198.51.100.1", false},
+	{"203.0.113.1", false},
+	{"255.255.255.255", false},
+	{"2001:db8::1", false},
+	// IPv4 addresses embedded in IPv6
+	{"::ffff:127.0.0.1", false},
+	{"::ffff:10.0.0.1", false},
+	{"::ffff:169.254.169.254", false},
+	{"::ffff:100.64.0.1", false},
+	{"64:ff9b::7f00:1", false},
+	{"64:ff9b::a00:1", false},
+}
+
+func TestPublicIP(t *testing.T) {
+	for _, tt := range addresses {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := publicIP(net.ParseIP(tt.ip)); got != tt.public {
+				t.Fatalf("publicIP(%s) = %v, want %v", tt.ip, got, tt.public)
+			}
+		})
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	tests := []struct {
+		host    string
+		allowed bool
+	}{
+		{"example.com", true},
+		{"localhost", false},
+		{"LocalHost", false},
+		{"api.localhost", false},
+		{"localhost.example.com", true},
+		// Names are resolved when dialing, not here
+		{"internal.example.com", true},
+	}
+	for _, address := range addresses {
+		tests = append(tests, struct {
+			host    string
+			allowed bool
+		}{address.ip, address.public})
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			err := checkHost(tt.host)
+			if tt.allowed != (err == nil) || (err != nil && !errors.Is(err, ErrForbiddenAddress)) {
+				t.Fatalf("checkHost(%s) = %v, want allowed %v", tt.host, err, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestDialControl(t *testing.T) {
+	for _, tt := range addresses {
+		t.Run(tt.ip, func(t *testing.T) {
+			err := dialControl("tcp", net.JoinHostPort(tt.ip, "443"), nil)
+			if tt.public != (err == nil) || (err != nil && !errors.Is(err, ErrForbiddenAddress)) {
+				t.Fatalf("dialControl(%s) = %v, want allowed %v", tt.ip, err, tt.public)
+			}
+		})
+	}
+
+	// Resolved addresses always carry a port
+	if err := dialControl("tcp", "93.184.216.34", nil); err == nil {
+		t.Fatal("address without a port accepted")
+	}
+}
+
+func TestClientRefusesInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// The server listens on loopback; "localhost" resolves there too, so
+	// the refusal comes from the dialer and not from checkHost
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, url := range []string{server.URL, "http://localhost:" + port} {
+		resp, err := newClient().Get(url)
+		if err == nil {
+			resp.Body.Close()
+		}
+		if !errors.Is(err, ErrForbiddenAddress) {
+			t.Fatalf("GET %s = %v, want %v", url, err, ErrForbiddenAddress)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package destinations
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Destination types
+const (
+	TypeHTTP     = "http"
+	TypeFunction = "function"
+	TypeQueue    = "queue"
+)
+
+// Headers set on HTTP callbacks
+const (
+	SignatureHeader = "X-VoltRun-Signature"
+	TimestampHeader = "X-VoltRun-Timestamp"
+)
+
+const (
+	maxDeliveryAttempts = 5
+	initialBackoff      = time.Second
+	requestTimeout      = 10 * time.Second
+)
+
+var client = newClient()
+
+// Destination is where the outcome of an async execution is delivered
+type Destination struct {
+	Type       string     `json:"type"`                  // http, function, queue
+	URL        string     `json:"url,omitempty"`         // http
+	FunctionID *uuid.UUID `json:"function_id,omitempty"` // function
+	Topic      string     `json:"topic,omitempty"`       // queue
+}
+
+// Validate checks that the fields required by the destination type are set
+func (d *Destination) Validate() error {
+	switch d.Type {
+	case TypeHTTP:
+		parsed, err := url.Parse(d.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return errors.New("http destination requires an http(s) url")
+		}
+		if err := checkHost(parsed.Hostname()); err != nil {
+			return err
+		}
+	case TypeFunction:
+		if d.FunctionID == nil || *d.FunctionID == uuid.Nil {
+			return errors.New("function destination requires function_id")
+		}
+	case TypeQueue:
+		if d.Topic == "" {
+			return errors.New("queue destination requires topic")
+		}
+	default:
+		return fmt.Errorf("unsupported destination type: %q", d.Type)
+	}
+	return nil
+}
+
+// Record is the payload delivered to a destination
+type Record struct {
+	ExecutionID         uuid.UUID       `json:"execution_id"`
+	OriginalExecutionID *uuid.UUID      `json:"original_execution_id,omitempty"`
+	FunctionID          uuid.UUID       `json:"function_id"`
+	Status              string          `json:"status"`
+	Attempt             int             `json:"attempt"`
+	TriggerType         string          `json:"trigger_type"`
+	Input               json.RawMessage `json:"input,omitempty"`
+	Output              json.RawMessage `json:"output,omitempty"`
+	Error               string          `json:"error,omitempty"`
+	ErrorClass          string          `json:"error_class,omitempty"`
+	DurationMS          int64           `json:"duration_ms"`
+	CompletedAt         time.Time       `json:"completed_at"`
+}
+
+// Sign computes the callback signature over "<timestamp>.<body>", the
+// same scheme generic webhook triggers verify
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// DeliverHTTP POSTs a signed record, retrying with exponential backoff
+// until the receiver answers with a 2xx status. Targets that resolve to a
+// non-public address fail with ErrForbiddenAddress and are not retried.
+func DeliverHTTP(ctx context.Context, target, secret string, record Record) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	backoff := initialBackoff
+	for attempt := 1; ; attempt++ {
+		err = post(ctx, target, secret, body)
+		if err == nil || attempt == maxDeliveryAttempts || errors.Is(err, ErrForbiddenAddress) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func post(ctx context.Context, target, secret string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	// Sign at send time so the timestamp stays fresh across retries
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(secret, timestamp, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("callback returned status %d", resp.StatusCode)
+	}
+	return nil
+}
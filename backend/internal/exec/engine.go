@@ -1,216 +1,435 @@
-package exec
-
-import (
-	"context"
-	"encoding/json"
-	"fmt"
-	"time"
-
-	"github.com/google/uuid"
-	"github.com/voltrun/backend/internal/runners"
-	"github.com/voltrun/backend/internal/storage"
-	"github.com/voltrun/backend/internal/vm"
-	"gorm.io/datatypes"
-)
-
-// ExecutionEngine handles function execution
-type ExecutionEngine struct {
-	vmManager *vm.VMManager
-}
-
-// NewExecutionEngine creates a new execution engine
-func NewExecutionEngine(vmManager *vm.VMManager) *ExecutionEngine {
-	return &ExecutionEngine{
-		vmManager: vmManager,
-	}
-}
-
-// ExecutionRequest represents a function execution request
-type ExecutionRequest struct {
-	FunctionID uuid.UUID              `json:"function_id"`
-	Input      map[string]interface{} `json:"input"`
-	UserID     uuid.UUID              `json:"user_id"`
-}
-
-// ExecutionResult represents the result of a function execution
-type ExecutionResult struct {
-	ExecutionID uuid.UUID              `json:"execution_id"`
-	Output      map[string]interface{} `json:"output"`
-	Logs        string                 `json:"logs"`
-	Error       string                 `json:"error,omitempty"`
-	DurationMS  int64                  `json:"duration_ms"`
-	Status      string                 `json:"status"`
-}
-
-// Execute runs a function in an isolated VM
-func (e *ExecutionEngine) Execute(ctx context.Context, req ExecutionRequest) (*ExecutionResult, error) {
-	startTime := time.Now()
-
-	// Fetch function from database
-	function, err := e.getFunction(req.FunctionID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch function: %w", err)
-	}
-
-	// Create execution record
-	execution := &storage.Execution{
-		ID:         uuid.New(),
-		UserID:     req.UserID,
-		FunctionID: req.FunctionID,
-		Status:     "pending",
-		Input:      marshalJSON(req.Input),
-		CreatedAt:  time.Now(),
-	}
-
-	if err := storage.DB.Create(execution).Error; err != nil {
-		return nil, fmt.Errorf("failed to create execution record: %w", err)
-	}
-
-	// Update status to running
-	now := time.Now()
-	execution.Status = "running"
-	execution.StartedAt = &now
-	storage.DB.Save(execution)
-
-	// Create VM configuration
-	vmConfig := vm.VMConfig{
-		ID:          vm.GenerateVMID(),
-		MemoryMB:    function.MemoryMB,
-		CPUs:        1,
-		TimeoutSec:  function.TimeoutSec,
-		Environment: map[string]string{},
-	}
-
-	// Create and start VM
-	vmInstance, err := e.vmManager.CreateVM(ctx, vmConfig)
-	if err != nil {
-		e.updateExecutionError(execution, fmt.Sprintf("VM creation failed: %v", err))
-		return nil, fmt.Errorf("failed to create VM: %w", err)
-	}
-
-	// Execute function inside VM
-	result, err := e.executeInVM(ctx, vmInstance, function, req.Input)
-	
-	// Cleanup: destroy VM
-	defer e.vmManager.DestroyVM(ctx, vmInstance.ID)
-
-	duration := time.Since(startTime).Milliseconds()
-	completedAt := time.Now()
-
-	if err != nil {
-		execution.Status = "failed"
-		execution.Error = err.Error()
-		execution.DurationMS = duration
-		execution.CompletedAt = &completedAt
-		storage.DB.Save(execution)
-
-		return &ExecutionResult{
-			ExecutionID: execution.ID,
-			Status:      "failed",
-			Error:       err.Error(),
-			DurationMS:  duration,
-		}, nil
-	}
-
-	// Update execution record with results
-	execution.Status = "success"
-	execution.Output = marshalJSON(result.Output)
-	execution.Logs = result.Logs
-	execution.DurationMS = duration
-	execution.CompletedAt = &completedAt
-	storage.DB.Save(execution)
-
-	return &ExecutionResult{
-		ExecutionID: execution.ID,
-		Output:      result.Output,
-		Logs:        result.Logs,
-		DurationMS:  duration,
-		Status:      "success",
-	}, nil
-}
-
-// executeInVM executes code inside a VM (placeholder)
-func (e *ExecutionEngine) executeInVM(ctx context.Context, vm *vm.VM, function *storage.Function, input map[string]interface{}) (*ExecutionResult, error) {
-	// TODO: Implement actual code execution inside Firecracker VM
-	// This is a placeholder that simulates execution
-	
-	// Based on runtime, dispatch to appropriate runner
-	runtime := function.Runtime
-	
-	// Normalize Node.js runtime versions to "nodejs"
-	if runtime == "nodejs" || runtime == "nodejs18" || runtime == "nodejs20" || runtime == "nodejs22" {
-		runtime = "nodejs"
-	}
-	
-	// Normalize Python runtime versions to "python"
-	if runtime == "python" || runtime == "python3.9" || runtime == "python3.10" || runtime == "python3.11" || runtime == "python3.12" {
-		runtime = "python"
-	}
-	
-	switch runtime {
-	case "nodejs":
-		return e.executeNodeJS(ctx, function, input)
-	case "python":
-		return e.executePython(ctx, function, input)
-	default:
-		return nil, fmt.Errorf("unsupported runtime: %s", function.Runtime)
-	}
-}
-
-// executeNodeJS executes Node.js code
-func (e *ExecutionEngine) executeNodeJS(ctx context.Context, function *storage.Function, input map[string]interface{}) (*ExecutionResult, error) {
-	runner := &runners.NodeRunner{}
-	timeout := time.Duration(function.TimeoutSec) * time.Second
-	
-	result, err := runner.Execute(ctx, function.Code, input, timeout)
-	if err != nil {
-		return nil, fmt.Errorf("node execution failed: %w", err)
-	}
-	
-	return &ExecutionResult{
-		Output:     result.Output,
-		Logs:       result.Logs,
-		DurationMS: result.DurationMS,
-	}, nil
-}
-
-// executePython executes Python code
-func (e *ExecutionEngine) executePython(ctx context.Context, function *storage.Function, input map[string]interface{}) (*ExecutionResult, error) {
-	runner := &runners.PythonRunner{}
-	timeout := time.Duration(function.TimeoutSec) * time.Second
-	
-	result, err := runner.Execute(ctx, function.Code, input, timeout)
-	if err != nil {
-		return nil, fmt.Errorf("python execution failed: %w", err)
-	}
-	
-	return &ExecutionResult{
-		Output:     result.Output,
-		Logs:       result.Logs,
-		DurationMS: result.DurationMS,
-	}, nil
-}
-
-// getFunction retrieves a function from the database
-func (e *ExecutionEngine) getFunction(functionID uuid.UUID) (*storage.Function, error) {
-	var function storage.Function
-	if err := storage.DB.First(&function, "id = ?", functionID).Error; err != nil {
-		return nil, err
-	}
-	return &function, nil
-}
-
-// updateExecutionError updates an execution with error status
-func (e *ExecutionEngine) updateExecutionError(execution *storage.Execution, errorMsg string) {
-	now := time.Now()
-	execution.Status = "failed"
-	execution.Error = errorMsg
-	execution.CompletedAt = &now
-	storage.DB.Save(execution)
-}
-
-// marshalJSON converts a map to JSON bytes
-func marshalJSON(data interface{}) datatypes.JSON {
-	bytes, _ := json.Marshal(data)
-	return datatypes.JSON(bytes)
-}
+package exec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/metering"
+	"github.com/voltrun/backend/internal/metrics"
+	"github.com/voltrun/backend/internal/payloads"
+	"github.com/voltrun/backend/internal/quotas"
+	"github.com/voltrun/backend/internal/ratelimit"
+	"github.com/voltrun/backend/internal/runners"
+	"github.com/voltrun/backend/internal/secrets"
+	"github.com/voltrun/backend/internal/storage"
+	"github.com/voltrun/backend/internal/tracing"
+	"github.com/voltrun/backend/internal/utils"
+	"github.com/voltrun/backend/internal/vm"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+)
+
+// Trigger types recorded on executions
+const (
+	TriggerHTTP        = "http"
+	TriggerSchedule    = "schedule"
+	TriggerWebhook     = "webhook"
+	TriggerEvent       = "event"
+	TriggerDestination = "destination"
+	TriggerWorkflow    = "workflow"
+)
+
+// ExecutionEngine handles function execution
+type ExecutionEngine struct {
+	vmManager       *vm.VMManager
+	functions       storage.FunctionRepo
+	executions      storage.ExecutionRepo
+	functionSecrets storage.SecretRepo
+}
+
+// NewExecutionEngine creates a new execution engine
+func NewExecutionEngine(vmManager *vm.VMManager, repos *storage.Repos) *ExecutionEngine {
+	return &ExecutionEngine{
+		vmManager:       vmManager,
+		functions:       repos.Functions,
+		executions:      repos.Executions,
+		functionSecrets: repos.Secrets,
+	}
+}
+
+// ErrExecutionStarted is returned when ExecutionRequest.ExecutionID names
+// an execution that is no longer pending
+var ErrExecutionStarted = errors.New("execution already started")
+
+// ExecutionRequest represents a function execution request
+type ExecutionRequest struct {
+	// ExecutionID runs an execution the caller already stored as pending,
+	// whose function FunctionID may omit but must not contradict; when nil
+	// the engine creates the record from the fields below
+	ExecutionID uuid.UUID `json:"execution_id,omitempty"`
+
+	FunctionID uuid.UUID   `json:"function_id"`
+	Input      interface{} `json:"input"`
+	UserID     uuid.UUID   `json:"user_id"`
+
+	// TriggerType records what started the execution (http, schedule, webhook, event, ...)
+	TriggerType   string `json:"trigger_type,omitempty"`
+	TriggerSource string `json:"trigger_source,omitempty"`
+}
+
+// ExecutionResult represents the result of a function execution
+type ExecutionResult struct {
+	ExecutionID uuid.UUID   `json:"execution_id"`
+	Output      interface{} `json:"output"`
+	Logs        string      `json:"logs"`
+	Error       string      `json:"error,omitempty"`
+	ErrorClass  string      `json:"error_class,omitempty"`
+	DurationMS  int64       `json:"duration_ms"`
+	Status      string      `json:"status"`
+}
+
+// Execute runs a function in an isolated VM. The engine owns the
+// execution record from pending through running to success or failed,
+// whichever entry point started it.
+func (e *ExecutionEngine) Execute(ctx context.Context, req ExecutionRequest) (*ExecutionResult, error) {
+	ctx, span := tracing.Tracer.Start(ctx, "ExecutionEngine.Execute", trace.WithAttributes(
+		attribute.String("function.id", req.FunctionID.String()),
+		attribute.String("trigger.type", req.TriggerType),
+	))
+	defer span.End()
+
+	result, err := e.execute(ctx, req)
+	switch {
+	case err != nil:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	case result.Status == "failed":
+		span.SetAttributes(attribute.String("error.class", result.ErrorClass))
+		span.SetStatus(codes.Error, result.Error)
+	}
+	return result, err
+}
+
+func (e *ExecutionEngine) execute(ctx context.Context, req ExecutionRequest) (*ExecutionResult, error) {
+	execution, function, err := e.begin(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.String("execution.id", execution.ID.String()))
+
+	// Wait for a concurrency slot; the execution stays pending while queued
+	release, err := ratelimit.Acquire(ctx, function)
+	if err != nil {
+		return nil, e.updateExecutionError(ctx, execution, ErrorClassSystem, fmt.Sprintf("Queueing failed: %v", err),
+			&ExecutionError{Class: ErrorClassSystem, Err: err})
+	}
+	defer release()
+
+	// Claim the execution; only one caller moves it from pending to
+	// running, and the duration is measured from here
+	startedAt := time.Now()
+	claimed, err := e.executions.ClaimPending(ctx, execution.ID, startedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim execution: %w", err)
+	}
+	if !claimed {
+		return nil, fmt.Errorf("execution %s: %w", execution.ID, ErrExecutionStarted)
+	}
+	execution.Status = "running"
+	execution.StartedAt = &startedAt
+
+	// Resolve environment variables and decrypt secrets
+	stored, err := e.functionSecrets.ListForFunction(ctx, function.ID)
+	if err != nil {
+		return nil, e.updateExecutionError(ctx, execution, ErrorClassSystem, fmt.Sprintf("Environment resolution failed: %v", err),
+			fmt.Errorf("failed to load secrets: %w", err))
+	}
+	env, secretValues, err := secrets.ResolveEnvironment(function, stored)
+	if err != nil {
+		return nil, e.updateExecutionError(ctx, execution, ErrorClassSystem, fmt.Sprintf("Environment resolution failed: %v", err),
+			fmt.Errorf("failed to resolve environment: %w", err))
+	}
+
+	// Create VM configuration
+	vmConfig := vm.VMConfig{
+		ID:          vm.GenerateVMID(),
+		MemoryMB:    function.MemoryMB,
+		CPUs:        1,
+		TimeoutSec:  function.TimeoutSec,
+		Environment: env,
+	}
+
+	// Create and start VM
+	vmInstance, err := e.vmManager.CreateVM(ctx, vmConfig)
+	if err != nil {
+		return nil, e.updateExecutionError(ctx, execution, ErrorClassSystem, fmt.Sprintf("VM creation failed: %v", err),
+			fmt.Errorf("failed to create VM: %w", err))
+	}
+	// A cold start is one that had to boot the VM serving it
+	execution.ColdStart = !vmInstance.Reused
+	if execution.ColdStart {
+		metrics.VMStarts.Inc("cold")
+	} else {
+		metrics.VMStarts.Inc("warm")
+	}
+
+	// Execute function inside VM
+	result, err := e.executeInVM(ctx, vmInstance, function, req.Input, vmConfig.Environment)
+
+	// Cleanup: destroy VM
+	defer e.vmManager.DestroyVM(ctx, vmInstance.ID)
+
+	completedAt := time.Now()
+	duration := completedAt.Sub(startedAt).Milliseconds()
+
+	// Outputs over the limit fail the execution rather than bloat storage
+	var output datatypes.JSON
+	if err == nil {
+		output = marshalJSON(result.Output)
+		if limitErr := payloads.CheckOutput(output); limitErr != nil {
+			err = &ExecutionError{Class: ErrorClassHandler, Err: limitErr}
+		}
+	}
+
+	if err != nil {
+		errorMsg := secrets.Redact(err.Error(), secretValues)
+		errorClass := ClassifyError(err)
+		var logs string
+		if result != nil {
+			logs = payloads.TruncateLogs(secrets.Redact(result.Logs, secretValues))
+		}
+		execution.Status = "failed"
+		execution.Error = errorMsg
+		execution.ErrorClass = errorClass
+		execution.Logs = logs
+		execution.DurationMS = duration
+		execution.CompletedAt = &completedAt
+		e.meter(ctx, execution, function)
+		e.offload(ctx, execution)
+		if err := e.executions.Save(ctx, execution); err != nil {
+			return nil, fmt.Errorf("failed to save execution: %w", err)
+		}
+
+		return &ExecutionResult{
+			ExecutionID: execution.ID,
+			Status:      "failed",
+			Error:       errorMsg,
+			ErrorClass:  errorClass,
+			Logs:        logs,
+			DurationMS:  duration,
+		}, nil
+	}
+
+	// Secrets must never be persisted in captured output
+	result.Logs = payloads.TruncateLogs(secrets.Redact(result.Logs, secretValues))
+
+	// Update execution record with results
+	execution.Status = "success"
+	execution.Output = output
+	execution.Logs = result.Logs
+	execution.DurationMS = duration
+	execution.CompletedAt = &completedAt
+	e.meter(ctx, execution, function)
+	e.offload(ctx, execution)
+	if err := e.executions.Save(ctx, execution); err != nil {
+		return nil, fmt.Errorf("failed to save execution: %w", err)
+	}
+
+	return &ExecutionResult{
+		ExecutionID: execution.ID,
+		Output:      result.Output,
+		Logs:        result.Logs,
+		DurationMS:  duration,
+		Status:      "success",
+	}, nil
+}
+
+// begin returns the pending execution named by req.ExecutionID, or
+// creates one, together with its function. An existing execution always
+// runs its own function. An existing execution that cannot run is marked
+// failed; a new one is only created once the function and quota checks
+// pass.
+func (e *ExecutionEngine) begin(ctx context.Context, req ExecutionRequest) (*storage.Execution, *storage.Function, error) {
+	var execution *storage.Execution
+	functionID := req.FunctionID
+	if req.ExecutionID != uuid.Nil {
+		existing, err := e.executions.Get(ctx, req.ExecutionID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to fetch execution: %w", err)
+		}
+		if existing.Status != "pending" {
+			return nil, nil, fmt.Errorf("execution %s is %s: %w", existing.ID, existing.Status, ErrExecutionStarted)
+		}
+		if functionID != uuid.Nil && functionID != existing.FunctionID {
+			return nil, nil, fmt.Errorf("execution %s belongs to function %s, not %s", existing.ID, existing.FunctionID, functionID)
+		}
+		execution = existing
+		functionID = existing.FunctionID
+	}
+
+	// Fetch function from database
+	function, err := e.functions.Get(ctx, functionID)
+	if err != nil {
+		err = fmt.Errorf("failed to fetch function: %w", err)
+		if execution != nil {
+			err = e.updateExecutionError(ctx, execution, ErrorClassSystem, err.Error(), err)
+		}
+		return nil, nil, err
+	}
+
+	// Monthly quotas apply to every trigger and are charged to the
+	// function's organization
+	if err := quotas.CheckExecution(ctx, function.OrganizationID); err != nil {
+		class := ErrorClassSystem
+		if errors.Is(err, quotas.ErrQuotaExceeded) {
+			class = ErrorClassQuota
+		}
+		var failure error = &ExecutionError{Class: class, Err: err}
+		if execution != nil {
+			failure = e.updateExecutionError(ctx, execution, class, err.Error(), failure)
+		}
+		return nil, nil, failure
+	}
+
+	if execution != nil {
+		return execution, function, nil
+	}
+
+	triggerType := req.TriggerType
+	if triggerType == "" {
+		triggerType = TriggerHTTP
+	}
+
+	// Create execution record
+	execution = &storage.Execution{
+		ID:             uuid.New(),
+		UserID:         req.UserID,
+		OrganizationID: function.OrganizationID,
+		FunctionID:     functionID,
+		Status:         "pending",
+		TriggerType:    triggerType,
+		TriggerSource:  req.TriggerSource,
+		Input:          marshalJSON(req.Input),
+		CreatedAt:      time.Now(),
+	}
+	e.offload(ctx, execution)
+
+	if err := e.executions.Create(ctx, execution); err != nil {
+		return nil, nil, fmt.Errorf("failed to create execution record: %w", err)
+	}
+	return execution, function, nil
+}
+
+// executeInVM executes code inside a VM (placeholder)
+func (e *ExecutionEngine) executeInVM(ctx context.Context, vm *vm.VM, function *storage.Function, input interface{}, env map[string]string) (*ExecutionResult, error) {
+	// TODO: Implement actual code execution inside Firecracker VM
+	// This is a placeholder that simulates execution
+
+	// Based on runtime, dispatch to appropriate runner
+	runtime := function.Runtime
+
+	// Normalize Node.js runtime versions to "nodejs"
+	if runtime == "nodejs" || runtime == "nodejs18" || runtime == "nodejs20" || runtime == "nodejs22" {
+		runtime = "nodejs"
+	}
+
+	// Normalize Python runtime versions to "python"
+	if runtime == "python" || runtime == "python3.9" || runtime == "python3.10" || runtime == "python3.11" || runtime == "python3.12" {
+		runtime = "python"
+	}
+
+	switch runtime {
+	case "nodejs":
+		return e.executeNodeJS(ctx, function, input, env)
+	case "python":
+		return e.executePython(ctx, function, input, env)
+	default:
+		return nil, &ExecutionError{Class: ErrorClassSystem, Err: fmt.Errorf("unsupported runtime: %s", function.Runtime)}
+	}
+}
+
+// executeNodeJS executes Node.js code
+func (e *ExecutionEngine) executeNodeJS(ctx context.Context, function *storage.Function, input interface{}, env map[string]string) (*ExecutionResult, error) {
+	runner := &runners.NodeRunner{}
+	timeout := time.Duration(function.TimeoutSec) * time.Second
+
+	result, err := runner.Execute(ctx, function.Code, input, env, timeout)
+	if err != nil {
+		return nil, &ExecutionError{Class: ErrorClassSystem, Err: fmt.Errorf("node execution failed: %w", err)}
+	}
+	if result.Error != "" {
+		// The handler threw or exited non-zero; keep its logs for debugging
+		return &ExecutionResult{Logs: result.Logs, DurationMS: result.DurationMS},
+			&ExecutionError{Class: runnerErrorClass(result), Err: fmt.Errorf("node execution failed: %s", result.Error)}
+	}
+
+	return &ExecutionResult{
+		Output:     result.Output,
+		Logs:       result.Logs,
+		DurationMS: result.DurationMS,
+	}, nil
+}
+
+// executePython executes Python code
+func (e *ExecutionEngine) executePython(ctx context.Context, function *storage.Function, input interface{}, env map[string]string) (*ExecutionResult, error) {
+	runner := &runners.PythonRunner{}
+	timeout := time.Duration(function.TimeoutSec) * time.Second
+
+	result, err := runner.Execute(ctx, function.Code, input, env, timeout)
+	if err != nil {
+		return nil, &ExecutionError{Class: ErrorClassSystem, Err: fmt.Errorf("python execution failed: %w", err)}
+	}
+	if result.Error != "" {
+		// The handler raised or exited non-zero; keep its logs for debugging
+		return &ExecutionResult{Logs: result.Logs, DurationMS: result.DurationMS},
+			&ExecutionError{Class: runnerErrorClass(result), Err: fmt.Errorf("python execution failed: %s", result.Error)}
+	}
+
+	return &ExecutionResult{
+		Output:     result.Output,
+		Logs:       result.Logs,
+		DurationMS: result.DurationMS,
+	}, nil
+}
+
+// runnerErrorClass distinguishes timeouts from handler failures
+func runnerErrorClass(result *runners.ExecutionResult) string {
+	if result.ExitCode == runners.ExitCodeTimeout {
+		return ErrorClassTimeout
+	}
+	return ErrorClassHandler
+}
+
+// meter adds a completed execution to the usage rollups and metrics
+func (e *ExecutionEngine) meter(ctx context.Context, execution *storage.Execution, function *storage.Function) {
+	metrics.RecordExecution(function.Runtime, execution.TriggerType, execution.Status,
+		time.Duration(execution.DurationMS)*time.Millisecond)
+	if err := metering.Record(ctx, execution, function.MemoryMB); err != nil {
+		utils.Error("Failed to record usage", zap.String("execution_id", execution.ID.String()), zap.Error(err))
+	}
+}
+
+// offload moves large payloads to the object store before a save. A
+// failure leaves placeholders in the row and is only logged.
+func (e *ExecutionEngine) offload(ctx context.Context, execution *storage.Execution) {
+	if err := payloads.Offload(ctx, execution); err != nil {
+		utils.Error("Failed to offload execution payloads", zap.String("execution_id", execution.ID.String()), zap.Error(err))
+	}
+}
+
+// updateExecutionError marks an execution that failed before or outside
+// its handler and returns cause, joined with any error saving the record
+func (e *ExecutionEngine) updateExecutionError(ctx context.Context, execution *storage.Execution, errorClass, errorMsg string, cause error) error {
+	now := time.Now()
+	execution.Status = "failed"
+	execution.Error = errorMsg
+	execution.ErrorClass = errorClass
+	execution.CompletedAt = &now
+	if err := e.executions.Save(ctx, execution); err != nil {
+		return errors.Join(cause, fmt.Errorf("failed to save execution: %w", err))
+	}
+	return cause
+}
+
+// marshalJSON converts a map to JSON bytes
+func marshalJSON(data interface{}) datatypes.JSON {
+	bytes, _ := json.Marshal(data)
+	return datatypes.JSON(bytes)
+}
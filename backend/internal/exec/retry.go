@@ -0,0 +1,94 @@
+package exec
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/voltrun/backend/internal/storage"
+)
+
+// Error classes used by retry policies
+const (
+	ErrorClassTimeout = "timeout" // the function exceeded its timeout
+	ErrorClassHandler = "handler" // user code threw or exited non-zero
+	ErrorClassSystem  = "system"  // platform failure: VM, secrets, database
+)
+
+// ErrorClassQuota marks executions refused by the account's plan. It is
+// not a valid retry_on class: retrying cannot succeed before the quota resets.
+const ErrorClassQuota = "quota"
+
+// ErrorClasses lists every valid error class
+var ErrorClasses = []string{ErrorClassTimeout, ErrorClassHandler, ErrorClassSystem}
+
+// ExecutionError is a failed execution tagged with its error class
+type ExecutionError struct {
+	Class string
+	Err   error
+}
+
+func (e *ExecutionError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ExecutionError) Unwrap() error {
+	return e.Err
+}
+
+// ClassifyError returns the error class of err, defaulting to system
+func ClassifyError(err error) string {
+	var execErr *ExecutionError
+	if errors.As(err, &execErr) {
+		return execErr.Class
+	}
+	return ErrorClassSystem
+}
+
+// ValidateRetryOn checks a comma-separated list of error classes
+func ValidateRetryOn(retryOn string) bool {
+	for _, class := range strings.Split(retryOn, ",") {
+		valid := false
+		for _, known := range ErrorClasses {
+			if strings.TrimSpace(class) == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return false
+		}
+	}
+	return true
+}
+
+// ShouldRetry reports whether a failed attempt should be retried under
+// the function's retry policy
+func ShouldRetry(function *storage.Function, errorClass string, attempt int) bool {
+	if attempt >= function.RetryMaxAttempts {
+		return false
+	}
+	for _, class := range strings.Split(function.RetryOn, ",") {
+		if strings.TrimSpace(class) == errorClass {
+			return true
+		}
+	}
+	return false
+}
+
+// RetryDelay returns the exponential backoff before the attempt after
+// the given one, capped at the function's maximum backoff
+func RetryDelay(function *storage.Function, attempt int) time.Duration {
+	delay := time.Duration(function.RetryBackoffSec) * time.Second
+	maxDelay := time.Duration(function.RetryMaxBackoffSec) * time.Second
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= maxDelay {
+			return maxDelay
+		}
+	}
+	if delay > maxDelay {
+		return maxDelay
+	}
+	return delay
+}
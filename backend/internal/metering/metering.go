@@ -0,0 +1,91 @@
+package metering
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/storage"
+)
+
+// Grouping dimensions accepted by Query
+const (
+	GroupFunction = "function"
+	GroupHour     = "hour"
+	GroupDay      = "day"
+	GroupMonth    = "month"
+)
+
+// Usage is one aggregated row of metered usage
+type Usage = storage.UsageTotal
+
+// repos holds the rollups and executions metering reads and writes
+var repos *storage.Repos
+
+// Init sets the repositories used for metering
+func Init(repositories *storage.Repos) {
+	repos = repositories
+}
+
+// Record adds a finished execution to its hourly rollup. Compute is
+// billed on the memory the execution used, or on the function's
+// allocation when usage was not measured. Egress counts the bytes of
+// output returned to the caller.
+func Record(ctx context.Context, execution *storage.Execution, allocatedMB int) error {
+	memoryMB := execution.MemoryUsed
+	if memoryMB == 0 {
+		memoryMB = allocatedMB
+	}
+	gbSeconds := float64(memoryMB) / 1024 * float64(execution.DurationMS) / 1000
+
+	var errorCount int64
+	if execution.Status == "failed" {
+		errorCount = 1
+	}
+
+	completedAt := time.Now()
+	if execution.CompletedAt != nil {
+		completedAt = *execution.CompletedAt
+	}
+
+	rollup := storage.UsageRollup{
+		OrganizationID: execution.OrganizationID,
+		FunctionID:     execution.FunctionID,
+		Hour:           completedAt.UTC().Truncate(time.Hour),
+		Invocations:    1,
+		Errors:         errorCount,
+		DurationMS:     execution.DurationMS,
+		GBSeconds:      gbSeconds,
+		EgressBytes:    int64(len(execution.Output)),
+	}
+
+	return repos.Usage.Add(ctx, &rollup)
+}
+
+// Query sums an organization's rollups in [from, to) grouped by the given
+// dimensions: function and at most one of hour, day or month
+func Query(ctx context.Context, organizationID uuid.UUID, from, to time.Time, groupBy []string) ([]Usage, error) {
+	if !to.After(from) {
+		return nil, errors.New("to must be after from")
+	}
+
+	byFunction := false
+	period := ""
+	for _, dimension := range groupBy {
+		switch dimension {
+		case GroupFunction:
+			byFunction = true
+		case GroupHour, GroupDay, GroupMonth:
+			if period != "" {
+				return nil, errors.New("group_by accepts only one of hour, day or month")
+			}
+			period = dimension
+		default:
+			return nil, fmt.Errorf("unsupported group_by %q", dimension)
+		}
+	}
+
+	return repos.Usage.Sum(ctx, organizationID, from, to, byFunction, period)
+}
@@ -0,0 +1,94 @@
+package metering
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/voltrun/backend/internal/storage"
+)
+
+// Buckets accepted by FunctionStats
+const (
+	BucketMinute = "minute"
+	BucketHour   = "hour"
+)
+
+// Stats summarises finished executions of a function over a period
+type Stats struct {
+	Period         *time.Time `json:"period,omitempty"`
+	Invocations    int64      `json:"invocations"`
+	Errors         int64      `json:"errors"`
+	ErrorRate      float64    `json:"error_rate"`
+	ColdStarts     int64      `json:"cold_starts"`
+	ColdStartRatio float64    `json:"cold_start_ratio"`
+	P50MS          float64    `json:"p50_ms"`
+	P90MS          float64    `json:"p90_ms"`
+	P99MS          float64    `json:"p99_ms"`
+	AvgMemoryMB    float64    `json:"avg_memory_mb"`
+	MaxMemoryMB    int        `json:"max_memory_mb"`
+}
+
+// FunctionStats returns a summary of a function's executions created in
+// [from, to) and a time series bucketed by minute or hour. Buckets without
+// executions are included with zero values so the series has no gaps.
+func FunctionStats(ctx context.Context, function *storage.Function, from, to time.Time, bucket string) (*Stats, []Stats, error) {
+	if !to.After(from) {
+		return nil, nil, errors.New("to must be after from")
+	}
+	var step time.Duration
+	switch bucket {
+	case BucketMinute:
+		step = time.Minute
+	case BucketHour:
+		step = time.Hour
+	default:
+		return nil, nil, fmt.Errorf("unsupported bucket %q", bucket)
+	}
+
+	total, rows, err := repos.Executions.Stats(ctx, function, from, to, bucket)
+	if err != nil {
+		return nil, nil, err
+	}
+	summary := newStats(*total)
+
+	byPeriod := make(map[time.Time]storage.ExecutionStats, len(rows))
+	for _, row := range rows {
+		byPeriod[*row.Period] = row
+	}
+
+	var series []Stats
+	for period := from.UTC().Truncate(step); period.Before(to); period = period.Add(step) {
+		start := period
+		row := newStats(byPeriod[period])
+		row.Period = &start
+		series = append(series, row)
+	}
+
+	return &summary, series, nil
+}
+
+// newStats derives the ratios of aggregated executions
+func newStats(aggregate storage.ExecutionStats) Stats {
+	stats := Stats{
+		Invocations: aggregate.Invocations,
+		Errors:      aggregate.Errors,
+		ColdStarts:  aggregate.ColdStarts,
+		P50MS:       aggregate.P50MS,
+		P90MS:       aggregate.P90MS,
+		P99MS:       aggregate.P99MS,
+		AvgMemoryMB: aggregate.AvgMemoryMB,
+		MaxMemoryMB: aggregate.MaxMemoryMB,
+	}
+	stats.computeRatios()
+	return stats
+}
+
+func (s *Stats) computeRatios() {
+	if s.Invocations == 0 {
+		return
+	}
+	s.ErrorRate = float64(s.Errors) / float64(s.Invocations)
+	s.ColdStartRatio = float64(s.ColdStarts) / float64(s.Invocations)
+}
@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"bytes"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/voltrun/backend/internal/storage"
+	"github.com/voltrun/backend/internal/utils"
+	"github.com/voltrun/backend/internal/vm"
+	"go.uber.org/zap"
+)
+
+// Default is the registry served on /metrics
+var Default = &Registry{}
+
+var (
+	// Invocations counts finished executions
+	Invocations = Default.NewCounterVec("voltrun_invocations_total",
+		"Function executions by runtime, trigger and final status.", "runtime", "trigger", "status")
+
+	// ExecutionDuration observes execution wall time
+	ExecutionDuration = Default.NewHistogramVec("voltrun_execution_duration_seconds",
+		"Execution duration in seconds by runtime and status.", DefaultBuckets, "runtime", "status")
+
+	// VMStarts counts VMs booted for an execution (cold) or reused (warm)
+	VMStarts = Default.NewCounterVec("voltrun_vm_starts_total",
+		"VM starts by type: cold boots a new VM, warm reuses one.", "type")
+
+	httpRequests = Default.NewCounterVec("voltrun_http_requests_total",
+		"HTTP requests by method, route and status code.", "method", "route", "status")
+
+	httpDuration = Default.NewHistogramVec("voltrun_http_request_duration_seconds",
+		"HTTP request latency in seconds by method and route.", DefaultBuckets, "method", "route")
+)
+
+// RecordExecution updates the invocation metrics for a finished execution
+func RecordExecution(runtime, trigger, status string, duration time.Duration) {
+	Invocations.Inc(runtime, trigger, status)
+	ExecutionDuration.Observe(duration.Seconds(), runtime, status)
+}
+
+// RegisterCollectors adds gauges read at scrape time: VMs tracked by the
+// manager, database pool statistics and queue depths
+func RegisterCollectors(manager *vm.VMManager) {
+	Default.NewGaugeFunc("voltrun_active_vms", "VMs currently tracked by the VM manager.", func() map[string]float64 {
+		vms, err := manager.ListVMs()
+		if err != nil {
+			return nil
+		}
+		return map[string]float64{"": float64(len(vms))}
+	})
+
+	Default.NewGaugeFunc("voltrun_db_connections", "Database pool connections by state.", func() map[string]float64 {
+		sqlDB, err := storage.DB.DB()
+		if err != nil {
+			return nil
+		}
+		stats := sqlDB.Stats()
+		return map[string]float64{
+			Labels("open"):   float64(stats.OpenConnections),
+			Labels("in_use"): float64(stats.InUse),
+			Labels("idle"):   float64(stats.Idle),
+			Labels("max"):    float64(stats.MaxOpenConnections),
+		}
+	}, "state")
+
+	Default.NewGaugeFunc("voltrun_db_wait_count", "Connections waited for since start.", func() map[string]float64 {
+		sqlDB, err := storage.DB.DB()
+		if err != nil {
+			return nil
+		}
+		return map[string]float64{"": float64(sqlDB.Stats().WaitCount)}
+	})
+
+	Default.NewGaugeFunc("voltrun_db_wait_seconds", "Total time spent waiting for a connection.", func() map[string]float64 {
+		sqlDB, err := storage.DB.DB()
+		if err != nil {
+			return nil
+		}
+		return map[string]float64{"": sqlDB.Stats().WaitDuration.Seconds()}
+	})
+
+	Default.NewGaugeFunc("voltrun_queue_depth", "Work waiting to run by queue.", func() map[string]float64 {
+		var pending, retries, events int64
+		if err := storage.DB.Model(&storage.Execution{}).
+			Where("status = ? AND next_retry_at IS NULL", "pending").Count(&pending).Error; err != nil {
+			utils.Error("Failed to read queue depth", zap.Error(err))
+			return nil
+		}
+		storage.DB.Model(&storage.Execution{}).
+			Where("status = ? AND next_retry_at IS NOT NULL", "pending").Count(&retries)
+		storage.DB.Model(&storage.EventMessage{}).Count(&events)
+		return map[string]float64{
+			Labels("executions"): float64(pending),
+			Labels("retries"):    float64(retries),
+			Labels("events"):     float64(events),
+		}
+	}, "queue")
+}
+
+// Handler serves the registry in the Prometheus text format
+func Handler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var buf bytes.Buffer
+		Default.Write(&buf)
+		c.Set(fiber.HeaderContentType, "text/plain; version=0.0.4; charset=utf-8")
+		return c.Send(buf.Bytes())
+	}
+}
+
+// Middleware records request counts and latency. Requests are labelled
+// with the matched route pattern so IDs in paths do not add series.
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			if e, ok := err.(*fiber.Error); ok {
+				status = e.Code
+			} else {
+				status = fiber.StatusInternalServerError
+			}
+		}
+
+		route := c.Route().Path
+		method := c.Method()
+		httpRequests.Inc(method, route, strconv.Itoa(status))
+		httpDuration.Observe(time.Since(start).Seconds(), method, route)
+		return err
+	}
+}
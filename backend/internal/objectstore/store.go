@@ -0,0 +1,118 @@
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Backends accepted by Open
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+// ErrNotFound is returned when a key has no object
+var ErrNotFound = errors.New("object not found")
+
+// Store keeps blobs that are too large or too cold for Postgres
+type Store interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Config selects and configures a backend
+type Config struct {
+	Backend string // local or s3
+
+	// Local filesystem
+	Dir string
+
+	// S3-compatible (AWS S3, MinIO, ...)
+	Endpoint  string
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+}
+
+// Open creates the configured store
+func Open(ctx context.Context, config Config) (Store, error) {
+	switch config.Backend {
+	case BackendLocal:
+		return NewLocalStore(config.Dir), nil
+	case BackendS3:
+		return NewS3Store(ctx, config)
+	default:
+		return nil, fmt.Errorf("unsupported object store %q", config.Backend)
+	}
+}
+
+// LocalStore keeps objects as files under a directory. It suits single
+// node deployments; replicas need a shared volume or S3.
+type LocalStore struct {
+	dir string
+}
+
+// NewLocalStore creates a store rooted at dir. Directories are created on
+// first write.
+func NewLocalStore(dir string) *LocalStore {
+	return &LocalStore{dir: dir}
+}
+
+// Put implements Store
+func (s *LocalStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see partial objects
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Get implements Store
+func (s *LocalStore) Get(ctx context.Context, key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Delete implements Store
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// path maps a key to a file, refusing keys that escape the root
+func (s *LocalStore) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" || strings.Contains(key, "..") {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return filepath.Join(s.dir, clean), nil
+}
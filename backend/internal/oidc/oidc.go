@@ -0,0 +1,244 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"github.com/voltrun/backend/internal/storage"
+	"golang.org/x/oauth2"
+)
+
+var (
+	ErrUnknownProvider = errors.New("unknown OIDC provider")
+	ErrInvalidState    = errors.New("invalid or expired OIDC login state")
+)
+
+// loginStateTTL bounds how long a user may spend at the provider
+const loginStateTTL = 10 * time.Minute
+
+// ProviderConfig describes one OpenID Connect issuer users can sign in with
+type ProviderConfig struct {
+	Name         string // used in URLs, e.g. "google"
+	DisplayName  string
+	Issuer       string
+	ClientID     string
+	ClientSecret string   // empty for public clients, which rely on PKCE alone
+	Scopes       []string // requested in addition to "openid"
+}
+
+// Identity is the verified result of a completed login
+type Identity struct {
+	Provider      string
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// provider discovers its issuer on first use, so a provider that is down
+// at startup does not keep the server from booting
+type provider struct {
+	config      ProviderConfig
+	redirectURL string
+
+	mu       sync.Mutex
+	oauth2   *oauth2.Config
+	verifier *gooidc.IDTokenVerifier
+}
+
+var providers []*provider
+
+// Init configures the package-level providers. Callbacks are expected at
+// redirectBaseURL + "/api/auth/oidc/<name>/callback".
+func Init(configs []ProviderConfig, redirectBaseURL string) error {
+	configured := make([]*provider, 0, len(configs))
+	seen := make(map[string]bool)
+	for _, config := range configs {
+		if config.Name == "" || config.Issuer == "" || config.ClientID == "" {
+			return fmt.Errorf("OIDC provider %q needs an issuer and a client id", config.Name)
+		}
+		if seen[config.Name] {
+			return fmt.Errorf("OIDC provider %q is configured twice", config.Name)
+		}
+		seen[config.Name] = true
+		if config.DisplayName == "" {
+			config.DisplayName = config.Name
+		}
+		configured = append(configured, &provider{
+			config:      config,
+			redirectURL: strings.TrimSuffix(redirectBaseURL, "/") + "/api/auth/oidc/" + config.Name + "/callback",
+		})
+	}
+
+	providers = configured
+	return nil
+}
+
+// Providers returns the configured providers in configuration order
+func Providers() []ProviderConfig {
+	configs := make([]ProviderConfig, len(providers))
+	for i, p := range providers {
+		configs[i] = p.config
+	}
+	return configs
+}
+
+func lookup(name string) (*provider, error) {
+	for _, p := range providers {
+		if p.config.Name == name {
+			return p, nil
+		}
+	}
+	return nil, ErrUnknownProvider
+}
+
+// discover fetches the issuer's metadata once; failures are retried on
+// the next login
+func (p *provider) discover(ctx context.Context) (*oauth2.Config, *gooidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.oauth2 == nil {
+		issuer, err := gooidc.NewProvider(ctx, p.config.Issuer)
+		if err != nil {
+			return nil, nil, fmt.Errorf("discover OIDC issuer %s: %w", p.config.Issuer, err)
+		}
+		p.oauth2 = &oauth2.Config{
+			ClientID:     p.config.ClientID,
+			ClientSecret: p.config.ClientSecret,
+			Endpoint:     issuer.Endpoint(),
+			RedirectURL:  p.redirectURL,
+			Scopes:       append([]string{gooidc.ScopeOpenID}, p.config.Scopes...),
+		}
+		p.verifier = issuer.Verifier(&gooidc.Config{ClientID: p.config.ClientID})
+	}
+	return p.oauth2, p.verifier, nil
+}
+
+// Begin starts a login with the named provider and returns the URL to
+// send the user to. The state, nonce and PKCE verifier are stored until
+// the callback.
+func Begin(ctx context.Context, name string) (string, error) {
+	p, err := lookup(name)
+	if err != nil {
+		return "", err
+	}
+	config, _, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := randomString()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", err
+	}
+	loginState := storage.OIDCLoginState{
+		State:        state,
+		Provider:     name,
+		CodeVerifier: oauth2.GenerateVerifier(),
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().Add(loginStateTTL),
+	}
+
+	db := storage.DB.WithContext(ctx)
+	// Abandoned logins are cleaned up as new ones start
+	if err := db.Where("expires_at < ?", time.Now()).Delete(&storage.OIDCLoginState{}).Error; err != nil {
+		return "", err
+	}
+	if err := db.Create(&loginState).Error; err != nil {
+		return "", err
+	}
+
+	return config.AuthCodeURL(state,
+		oauth2.S256ChallengeOption(loginState.CodeVerifier),
+		gooidc.Nonce(nonce),
+	), nil
+}
+
+// Complete finishes a login from the provider's callback: it redeems the
+// code with the stored PKCE verifier and verifies the returned ID token.
+// Each state can be completed once.
+func Complete(ctx context.Context, name, state, code string) (*Identity, error) {
+	p, err := lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if state == "" || code == "" {
+		return nil, ErrInvalidState
+	}
+
+	var loginState storage.OIDCLoginState
+	db := storage.DB.WithContext(ctx)
+	if err := db.Where("state = ? AND provider = ?", state, name).First(&loginState).Error; err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, ErrInvalidState
+		}
+		return nil, err
+	}
+	// Deleting claims the state, so a replayed callback finds nothing
+	claim := db.Where("state = ?", state).Delete(&storage.OIDCLoginState{})
+	if claim.Error != nil {
+		return nil, claim.Error
+	}
+	if claim.RowsAffected == 0 || loginState.ExpiresAt.Before(time.Now()) {
+		return nil, ErrInvalidState
+	}
+
+	config, verifier, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	token, err := config.Exchange(ctx, code, oauth2.VerifierOption(loginState.CodeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("exchange OIDC code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("OIDC token response has no id_token")
+	}
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("verify OIDC id token: %w", err)
+	}
+	if idToken.Nonce != loginState.Nonce {
+		return nil, errors.New("OIDC id token nonce mismatch")
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("parse OIDC claims: %w", err)
+	}
+
+	return &Identity{
+		Provider:      name,
+		Issuer:        idToken.Issuer,
+		Subject:       idToken.Subject,
+		Email:         strings.TrimSpace(claims.Email),
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+	}, nil
+}
+
+func randomString() (string, error) {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
@@ -0,0 +1,85 @@
+package payloads
+
+import (
+	"encoding/base64"
+	"mime"
+	"strings"
+	"unicode/utf8"
+)
+
+// Raw bodies travel through JSON as an envelope. Text stays readable;
+// anything else is base64 encoded and flagged so handlers can decode it.
+const (
+	BodyField        = "body"
+	ContentTypeField = "content_type"
+	Base64Field      = "is_base64_encoded"
+)
+
+// DefaultContentType is assumed for binary bodies that don't declare one
+const DefaultContentType = "application/octet-stream"
+
+// IsJSON reports whether a Content-Type header denotes JSON
+func IsJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// EncodeBody wraps a raw body in the envelope. Text content types that
+// hold valid UTF-8 are passed as a string; everything else as base64.
+func EncodeBody(contentType string, body []byte) map[string]interface{} {
+	if contentType == "" {
+		contentType = DefaultContentType
+	}
+	envelope := map[string]interface{}{ContentTypeField: contentType}
+	if isText(contentType) && utf8.Valid(body) {
+		envelope[BodyField] = string(body)
+		envelope[Base64Field] = false
+	} else {
+		envelope[BodyField] = base64.StdEncoding.EncodeToString(body)
+		envelope[Base64Field] = true
+	}
+	return envelope
+}
+
+// DecodeBody unwraps a value produced by EncodeBody or returned by a
+// handler in the same shape. ok is false for any other value, which
+// callers should treat as plain JSON.
+func DecodeBody(value interface{}) (contentType string, body []byte, ok bool) {
+	envelope, isMap := value.(map[string]interface{})
+	if !isMap {
+		return "", nil, false
+	}
+	contentType, _ = envelope[ContentTypeField].(string)
+	raw, isString := envelope[BodyField].(string)
+	if contentType == "" || !isString {
+		return "", nil, false
+	}
+
+	if encoded, _ := envelope[Base64Field].(bool); encoded {
+		decoded, err := base64.StdEncoding.DecodeString(raw)
+		if err != nil {
+			return "", nil, false
+		}
+		return contentType, decoded, true
+	}
+	return contentType, []byte(raw), true
+}
+
+// isText reports whether a content type carries human-readable text
+func isText(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		mediaType == "application/xml",
+		mediaType == "application/x-www-form-urlencoded",
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	return false
+}
@@ -0,0 +1,229 @@
+package payloads
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/objectstore"
+	"github.com/voltrun/backend/internal/storage"
+	"gorm.io/datatypes"
+)
+
+// Limits bounds the payloads an execution may carry
+type Limits struct {
+	MaxInputBytes    int // larger inputs are rejected
+	MaxOutputBytes   int // larger outputs fail the execution
+	MaxLogBytes      int // longer logs keep only their tail
+	OffloadThreshold int // larger payloads move to the object store
+}
+
+var (
+	ErrInputTooLarge  = errors.New("input too large")
+	ErrOutputTooLarge = errors.New("output too large")
+)
+
+var (
+	store  objectstore.Store
+	limits = Limits{
+		MaxInputBytes:    6 << 20,
+		MaxOutputBytes:   6 << 20,
+		MaxLogBytes:      10 << 20,
+		OffloadThreshold: 256 << 10,
+	}
+)
+
+// Init configures the object store and limits. Without a store nothing
+// is offloaded, but the size limits still apply.
+func Init(s objectstore.Store, l Limits) {
+	store = s
+	limits = l
+}
+
+// CheckInput rejects an encoded input above the limit
+func CheckInput(input []byte) error {
+	if limits.MaxInputBytes > 0 && len(input) > limits.MaxInputBytes {
+		return fmt.Errorf("%w: %d bytes exceeds the %d byte limit", ErrInputTooLarge, len(input), limits.MaxInputBytes)
+	}
+	return nil
+}
+
+// CheckOutput rejects an encoded output above the limit
+func CheckOutput(output []byte) error {
+	if limits.MaxOutputBytes > 0 && len(output) > limits.MaxOutputBytes {
+		return fmt.Errorf("%w: %d bytes exceeds the %d byte limit", ErrOutputTooLarge, len(output), limits.MaxOutputBytes)
+	}
+	return nil
+}
+
+// TruncateLogs keeps the tail of logs over the limit, where errors usually are
+func TruncateLogs(logs string) string {
+	if limits.MaxLogBytes <= 0 || len(logs) <= limits.MaxLogBytes {
+		return logs
+	}
+	dropped := len(logs) - limits.MaxLogBytes
+	return fmt.Sprintf("[... %d bytes truncated ...]\n", dropped) + logs[dropped:]
+}
+
+// field is one offloadable column of an execution
+type field struct {
+	name        string
+	ref         *string
+	contentType string
+	get         func() []byte
+	set         func([]byte)
+}
+
+func fields(execution *storage.Execution) []field {
+	return []field{
+		{"input", &execution.InputRef, "application/json",
+			func() []byte { return execution.Input },
+			func(b []byte) { execution.Input = datatypes.JSON(b) }},
+		{"output", &execution.OutputRef, "application/json",
+			func() []byte { return execution.Output },
+			func(b []byte) { execution.Output = datatypes.JSON(b) }},
+		{"logs", &execution.LogsRef, "text/plain; charset=utf-8",
+			func() []byte { return []byte(execution.Logs) },
+			func(b []byte) { execution.Logs = string(b) }},
+	}
+}
+
+func deadLetterFields(deadLetter *storage.DeadLetter) []field {
+	return []field{
+		{"input", &deadLetter.InputRef, "application/json",
+			func() []byte { return deadLetter.Input },
+			func(b []byte) { deadLetter.Input = datatypes.JSON(b) }},
+	}
+}
+
+// unavailable replaces a payload that could not be stored externally
+var unavailable = []byte(`{"error":"payload too large to store"}`)
+
+// Offload moves input, output and logs above the threshold to the object
+// store and clears their columns. If the store fails, oversized values are
+// replaced with a placeholder so the row stays small, and the error is
+// returned for logging.
+func Offload(ctx context.Context, execution *storage.Execution) error {
+	if execution.ID == uuid.Nil {
+		execution.ID = uuid.New()
+	}
+	prefix := fmt.Sprintf("payloads/%s/%s/%s", execution.UserID, execution.FunctionID, execution.ID)
+	return offload(ctx, prefix, fields(execution))
+}
+
+// OffloadDeadLetter moves a dead letter's input above the threshold to the
+// object store, like Offload
+func OffloadDeadLetter(ctx context.Context, deadLetter *storage.DeadLetter) error {
+	if deadLetter.ID == uuid.Nil {
+		deadLetter.ID = uuid.New()
+	}
+	prefix := fmt.Sprintf("payloads/%s/%s/dead-letters/%s", deadLetter.UserID, deadLetter.FunctionID, deadLetter.ID)
+	return offload(ctx, prefix, deadLetterFields(deadLetter))
+}
+
+func offload(ctx context.Context, prefix string, fields []field) error {
+	var errs []error
+	for _, f := range fields {
+		data := f.get()
+		if limits.OffloadThreshold <= 0 || len(data) <= limits.OffloadThreshold {
+			continue
+		}
+
+		key := prefix + "/" + f.name
+		if store == nil {
+			errs = append(errs, errors.New("no object store configured"))
+		} else if err := store.Put(ctx, key, data, f.contentType); err != nil {
+			errs = append(errs, fmt.Errorf("failed to store %s: %w", f.name, err))
+		} else {
+			*f.ref = key
+			f.set(nil)
+			continue
+		}
+
+		if f.name == "logs" {
+			f.set([]byte(data[len(data)-limits.OffloadThreshold:]))
+		} else {
+			f.set(unavailable)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Hydrate loads offloaded payloads back into the execution
+func Hydrate(ctx context.Context, execution *storage.Execution) error {
+	return hydrate(ctx, fields(execution))
+}
+
+// HydrateDeadLetter loads an offloaded input back into the dead letter
+func HydrateDeadLetter(ctx context.Context, deadLetter *storage.DeadLetter) error {
+	return hydrate(ctx, deadLetterFields(deadLetter))
+}
+
+func hydrate(ctx context.Context, fields []field) error {
+	for _, f := range fields {
+		if *f.ref == "" {
+			continue
+		}
+		if store == nil {
+			return errors.New("no object store configured")
+		}
+		data, err := store.Get(ctx, *f.ref)
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %w", f.name, err)
+		}
+		f.set(data)
+	}
+	return nil
+}
+
+// Delete removes an execution's offloaded payloads
+func Delete(ctx context.Context, execution *storage.Execution) error {
+	return remove(ctx, fields(execution))
+}
+
+// DeleteDeadLetter removes a dead letter's offloaded input
+func DeleteDeadLetter(ctx context.Context, deadLetter *storage.DeadLetter) error {
+	return remove(ctx, deadLetterFields(deadLetter))
+}
+
+func remove(ctx context.Context, fields []field) error {
+	for _, f := range fields {
+		if *f.ref == "" || store == nil {
+			continue
+		}
+		if err := store.Delete(ctx, *f.ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteFunction removes the offloaded payloads of every execution and
+// dead letter of a function, ahead of deleting the rows
+func DeleteFunction(ctx context.Context, functionID uuid.UUID) error {
+	var executions []storage.Execution
+	if err := storage.DB.Select("id", "input_ref", "output_ref", "logs_ref").
+		Where("function_id = ? AND (input_ref <> '' OR output_ref <> '' OR logs_ref <> '')", functionID).
+		Find(&executions).Error; err != nil {
+		return err
+	}
+	for i := range executions {
+		if err := Delete(ctx, &executions[i]); err != nil {
+			return err
+		}
+	}
+
+	var deadLetters []storage.DeadLetter
+	if err := storage.DB.Select("id", "input_ref").
+		Where("function_id = ? AND input_ref <> ''", functionID).
+		Find(&deadLetters).Error; err != nil {
+		return err
+	}
+	for i := range deadLetters {
+		if err := DeleteDeadLetter(ctx, &deadLetters[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package quotas
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/metering"
+	"github.com/voltrun/backend/internal/storage"
+)
+
+// Plan names
+const (
+	PlanFree   = "free"
+	PlanPro    = "pro"
+	PlanCustom = "custom" // limits stored on the organization
+)
+
+var (
+	// ErrQuotaExceeded means a monthly allowance is used up (HTTP 402)
+	ErrQuotaExceeded = errors.New("monthly quota exceeded")
+	// ErrPlanLimit means a request asks for more than the plan allows (HTTP 403)
+	ErrPlanLimit = errors.New("plan limit exceeded")
+)
+
+// Limits are a plan's allowances. Zero means unlimited.
+type Limits struct {
+	MonthlyInvocations int64   `json:"monthly_invocations"`
+	MonthlyGBSeconds   float64 `json:"monthly_gb_seconds"`
+	MaxFunctions       int     `json:"max_functions"`
+	MaxMemoryMB        int     `json:"max_memory_mb"`
+	MaxTimeoutSec      int     `json:"max_timeout_sec"`
+}
+
+// Plans holds the built-in plan definitions
+var Plans = map[string]Limits{
+	PlanFree: {
+		MonthlyInvocations: 100000,
+		MonthlyGBSeconds:   40000,
+		MaxFunctions:       10,
+		MaxMemoryMB:        512,
+		MaxTimeoutSec:      30,
+	},
+	PlanPro: {
+		MonthlyInvocations: 5000000,
+		MonthlyGBSeconds:   2000000,
+		MaxFunctions:       200,
+		MaxMemoryMB:        3008,
+		MaxTimeoutSec:      900,
+	},
+}
+
+// repos holds the organizations and functions quotas are checked against
+var repos *storage.Repos
+
+// Init sets the repositories used for quota checks
+func Init(repositories *storage.Repos) {
+	repos = repositories
+}
+
+// LimitError describes which limit a request ran into
+type LimitError struct {
+	Kind  error // ErrQuotaExceeded or ErrPlanLimit
+	Limit string
+	Used  float64
+	Max   float64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s: %s is %g, plan allows %g", e.Kind, e.Limit, e.Used, e.Max)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Kind
+}
+
+// Status is an organization's plan with this month's usage against it
+type Status struct {
+	Plan        string    `json:"plan"`
+	Limits      Limits    `json:"limits"`
+	PeriodStart time.Time `json:"period_start"`
+	Invocations int64     `json:"invocations"`
+	GBSeconds   float64   `json:"gb_seconds"`
+	Functions   int64     `json:"functions"`
+}
+
+// LimitsFor returns the allowances of the organization's plan
+func LimitsFor(organization *storage.Organization) (Limits, error) {
+	if organization.Plan == PlanCustom {
+		var limits Limits
+		if len(organization.PlanLimits) > 0 {
+			if err := json.Unmarshal(organization.PlanLimits, &limits); err != nil {
+				return Limits{}, fmt.Errorf("invalid custom plan limits: %w", err)
+			}
+		}
+		return limits, nil
+	}
+	limits, ok := Plans[organization.Plan]
+	if !ok {
+		return Limits{}, fmt.Errorf("unknown plan %q", organization.Plan)
+	}
+	return limits, nil
+}
+
+// CheckFunction validates a function's memory and timeout against the
+// plan and, when creating, the number of functions the organization
+// already has
+func CheckFunction(ctx context.Context, organizationID uuid.UUID, memoryMB, timeoutSec int, creating bool) error {
+	limits, err := loadLimits(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+
+	if limits.MaxMemoryMB > 0 && memoryMB > limits.MaxMemoryMB {
+		return &LimitError{Kind: ErrPlanLimit, Limit: "memory_mb", Used: float64(memoryMB), Max: float64(limits.MaxMemoryMB)}
+	}
+	if limits.MaxTimeoutSec > 0 && timeoutSec > limits.MaxTimeoutSec {
+		return &LimitError{Kind: ErrPlanLimit, Limit: "timeout_sec", Used: float64(timeoutSec), Max: float64(limits.MaxTimeoutSec)}
+	}
+
+	if creating && limits.MaxFunctions > 0 {
+		count, err := repos.Functions.CountForOrganization(ctx, organizationID)
+		if err != nil {
+			return err
+		}
+		if count >= int64(limits.MaxFunctions) {
+			return &LimitError{Kind: ErrPlanLimit, Limit: "functions", Used: float64(count + 1), Max: float64(limits.MaxFunctions)}
+		}
+	}
+	return nil
+}
+
+// CheckExecution refuses new executions in an organization once a monthly
+// allowance is used up. Usage is metered only when an execution finishes,
+// so pending and running executions count too, each at the most it can
+// use, and a burst of requests cannot overrun the allowance.
+func CheckExecution(ctx context.Context, organizationID uuid.UUID) error {
+	limits, err := loadLimits(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+	if limits.MonthlyInvocations == 0 && limits.MonthlyGBSeconds == 0 {
+		return nil
+	}
+
+	usage, err := monthlyUsage(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+	inFlight, err := repos.Executions.InFlight(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+	usage.Invocations += inFlight.Invocations
+	usage.GBSeconds += inFlight.GBSeconds
+
+	if limits.MonthlyInvocations > 0 && usage.Invocations >= limits.MonthlyInvocations {
+		return &LimitError{Kind: ErrQuotaExceeded, Limit: "monthly_invocations", Used: float64(usage.Invocations), Max: float64(limits.MonthlyInvocations)}
+	}
+	if limits.MonthlyGBSeconds > 0 && usage.GBSeconds >= limits.MonthlyGBSeconds {
+		return &LimitError{Kind: ErrQuotaExceeded, Limit: "monthly_gb_seconds", Used: usage.GBSeconds, Max: limits.MonthlyGBSeconds}
+	}
+	return nil
+}
+
+// GetStatus reports the organization's plan and usage for the current month
+func GetStatus(ctx context.Context, organization *storage.Organization) (*Status, error) {
+	limits, err := LimitsFor(organization)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := monthlyUsage(ctx, organization.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	functions, err := repos.Functions.CountForOrganization(ctx, organization.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Status{
+		Plan:        organization.Plan,
+		Limits:      limits,
+		PeriodStart: periodStart(time.Now()),
+		Invocations: usage.Invocations,
+		GBSeconds:   usage.GBSeconds,
+		Functions:   functions,
+	}, nil
+}
+
+// loadLimits returns the allowances of an organization's plan
+func loadLimits(ctx context.Context, organizationID uuid.UUID) (Limits, error) {
+	organization, err := repos.Organizations.Get(ctx, organizationID)
+	if err != nil {
+		return Limits{}, err
+	}
+	return LimitsFor(organization)
+}
+
+// monthlyUsage totals the metered usage since the start of the month
+func monthlyUsage(ctx context.Context, organizationID uuid.UUID) (metering.Usage, error) {
+	now := time.Now()
+	rows, err := metering.Query(ctx, organizationID, periodStart(now), now.Add(time.Hour), nil)
+	if err != nil || len(rows) == 0 {
+		return metering.Usage{}, err
+	}
+	return rows[0], nil
+}
+
+// periodStart returns the start of the UTC calendar month quotas reset on
+func periodStart(now time.Time) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,93 @@
+package quotas
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/metering"
+	"github.com/voltrun/backend/internal/storage"
+)
+
+func TestCheckExecution(t *testing.T) {
+	tests := []struct {
+		name      string
+		metered   int64 // finished invocations this month
+		pending   int
+		running   int
+		finished  int
+		wantLimit string
+	}{
+		{name: "under the allowance"},
+		{name: "metered usage exhausts invocations", metered: 10, wantLimit: "monthly_invocations"},
+		{name: "in-flight executions count", metered: 8, pending: 1, running: 1, wantLimit: "monthly_invocations"},
+		{name: "finished executions count once metered", metered: 8, finished: 2},
+		{name: "in-flight executions reserve their timeout", running: 3, wantLimit: "monthly_gb_seconds"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			repositories := storage.NewMemoryRepos()
+			Init(repositories)
+			metering.Init(repositories)
+
+			owner := uuid.New()
+			organization := &storage.Organization{
+				Name:       "acme",
+				Plan:       PlanCustom,
+				PlanLimits: []byte(`{"monthly_invocations":10,"monthly_gb_seconds":100}`),
+			}
+			if err := repositories.Organizations.Create(ctx, organization, owner); err != nil {
+				t.Fatal(err)
+			}
+			// Each execution can use 1 GB for 40 seconds
+			function := &storage.Function{
+				OrganizationID: organization.ID,
+				UserID:         owner,
+				Name:           "fn",
+				MemoryMB:       1024,
+				TimeoutSec:     40,
+			}
+			if err := repositories.Functions.Create(ctx, function); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.metered > 0 {
+				if err := repositories.Usage.Add(ctx, &storage.UsageRollup{
+					OrganizationID: organization.ID,
+					FunctionID:     function.ID,
+					Hour:           time.Now().UTC().Truncate(time.Hour),
+					Invocations:    tt.metered,
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for status, count := range map[string]int{"pending": tt.pending, "running": tt.running, "success": tt.finished} {
+				for i := 0; i < count; i++ {
+					if err := repositories.Executions.Create(ctx, &storage.Execution{
+						OrganizationID: organization.ID,
+						UserID:         owner,
+						FunctionID:     function.ID,
+						Status:         status,
+					}); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			err := CheckExecution(ctx, organization.ID)
+			if tt.wantLimit == "" {
+				if err != nil {
+					t.Fatalf("err = %v, want nil", err)
+				}
+				return
+			}
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) || !errors.Is(err, ErrQuotaExceeded) || limitErr.Limit != tt.wantLimit {
+				t.Fatalf("err = %v, want %s exceeded", err, tt.wantLimit)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/auth"
+	"github.com/voltrun/backend/internal/storage"
+	"github.com/voltrun/backend/internal/utils"
+	"go.uber.org/zap"
+)
+
+// ErrConcurrencyLimit is returned when no execution slot frees up in time
+var ErrConcurrencyLimit = errors.New("concurrency limit reached")
+
+// slotPollInterval is how often a queued execution retries for a slot
+const slotPollInterval = 250 * time.Millisecond
+
+// Limits are the defaults: request rates per user and concurrency per
+// organization. Functions can add their own request rate and reserve part
+// of the organization's concurrency.
+type Limits struct {
+	RequestsPerSecond float64
+	Burst             int
+	MaxConcurrent     int
+	QueueTimeout      time.Duration // how long an execution waits for a slot
+}
+
+type limiter struct {
+	store  Store
+	limits Limits
+}
+
+var active *limiter
+
+// Init enables rate limiting. Until it is called every check passes.
+func Init(store Store, limits Limits) {
+	active = &limiter{store: store, limits: limits}
+}
+
+// MaxConcurrent returns the configured per-organization concurrency, or 0 when
+// limiting is disabled
+func MaxConcurrent() int {
+	if active == nil {
+		return 0
+	}
+	return active.limits.MaxConcurrent
+}
+
+// Middleware enforces the caller's request rate, and the function's own
+// rate when the route has a function :id, answering 429 with Retry-After
+func Middleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if active == nil {
+			return c.Next()
+		}
+		userID, err := auth.GetUserID(c)
+		if err != nil {
+			return c.Status(401).JSON(fiber.Map{"error": "Unauthorized"})
+		}
+
+		ctx := c.UserContext()
+		wait, err := active.take(ctx, "user:"+userID.String(), active.limits.RequestsPerSecond, active.limits.Burst)
+		organizationID, orgErr := auth.GetOrganizationID(c)
+		if err == nil && wait == 0 && orgErr == nil && c.Params("id") != "" {
+			var function storage.Function
+			if storage.DB.Select("id", "rate_limit_rps", "rate_limit_burst").
+				Where("id = ? AND organization_id = ?", c.Params("id"), organizationID).First(&function).Error == nil &&
+				function.RateLimitRPS > 0 {
+				wait, err = active.take(ctx, "function:"+function.ID.String(), function.RateLimitRPS, function.RateLimitBurst)
+			}
+		}
+
+		if err != nil {
+			// Fail open rather than reject traffic when the store is down
+			utils.Error("Rate limit check failed", zap.Error(err))
+			return c.Next()
+		}
+		if wait > 0 {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": "Rate limit exceeded"})
+		}
+		return c.Next()
+	}
+}
+
+// take returns zero when a token was taken, otherwise the time to wait
+func (l *limiter) take(ctx context.Context, key string, rate float64, burst int) (time.Duration, error) {
+	if rate <= 0 {
+		return 0, nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	ok, wait, err := l.store.Take(ctx, key, rate, burst)
+	if err != nil || ok {
+		return 0, err
+	}
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return wait, nil
+}
+
+// Acquire waits for an execution slot for the function. Functions with
+// reserved concurrency use their own pool; the rest share what their
+// organization has not reserved. The returned func releases the slot.
+func Acquire(ctx context.Context, function *storage.Function) (func(), error) {
+	if active == nil || active.limits.MaxConcurrent <= 0 {
+		return func() {}, nil
+	}
+
+	key, limit, err := concurrencyPool(function)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		return nil, ErrConcurrencyLimit
+	}
+
+	// Slots outlive the function timeout so a crashed replica frees them
+	ttl := time.Duration(function.TimeoutSec)*time.Second + time.Minute
+
+	ctx, cancel := context.WithTimeout(ctx, active.limits.QueueTimeout)
+	defer cancel()
+	for {
+		slot, ok, err := active.store.Acquire(ctx, key, limit, ttl)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return func() {
+				if err := active.store.Release(context.Background(), key, slot); err != nil {
+					utils.Error("Failed to release concurrency slot", zap.String("key", key), zap.Error(err))
+				}
+			}, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ErrConcurrencyLimit
+		case <-time.After(slotPollInterval):
+		}
+	}
+}
+
+// concurrencyPool picks the slot pool a function draws from
+func concurrencyPool(function *storage.Function) (string, int, error) {
+	if function.ReservedConcurrency > 0 {
+		return "function:" + function.ID.String(), function.ReservedConcurrency, nil
+	}
+
+	reserved, err := ReservedConcurrency(function.OrganizationID, uuid.Nil)
+	if err != nil {
+		return "", 0, err
+	}
+	return "organization:" + function.OrganizationID.String(), active.limits.MaxConcurrent - reserved, nil
+}
+
+// ReservedConcurrency sums the concurrency the organization has reserved
+// for functions other than except
+func ReservedConcurrency(organizationID, except uuid.UUID) (int, error) {
+	var reserved int
+	err := storage.DB.Model(&storage.Function{}).
+		Where("organization_id = ? AND id <> ?", organizationID, except).
+		Select("COALESCE(SUM(reserved_concurrency), 0)").
+		Scan(&reserved).Error
+	return reserved, err
+}
@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/storage"
+	"gorm.io/gorm"
+)
+
+// PostgresStore shares buckets and slots between replicas through the
+// rate_limit_buckets and concurrency_leases tables
+type PostgresStore struct{}
+
+// NewPostgresStore creates a store backed by storage.DB
+func NewPostgresStore() *PostgresStore {
+	return &PostgresStore{}
+}
+
+// Take implements Store. The refill and the decrement happen in a single
+// upsert, so concurrent requests on different replicas never overspend.
+func (s *PostgresStore) Take(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	var remaining []float64
+	err := storage.DB.WithContext(ctx).Raw(`
+		INSERT INTO rate_limit_buckets (key, tokens, updated_at) VALUES (@key, @burst - 1, now())
+		ON CONFLICT (key) DO UPDATE SET
+			tokens = LEAST(@burst, rate_limit_buckets.tokens + EXTRACT(EPOCH FROM now() - rate_limit_buckets.updated_at) * @rate) - 1,
+			updated_at = now()
+		WHERE LEAST(@burst, rate_limit_buckets.tokens + EXTRACT(EPOCH FROM now() - rate_limit_buckets.updated_at) * @rate) >= 1
+		RETURNING tokens`,
+		map[string]interface{}{"key": key, "burst": burst, "rate": rate},
+	).Scan(&remaining).Error
+	if err != nil {
+		return false, 0, err
+	}
+	if len(remaining) == 1 {
+		return true, 0, nil
+	}
+
+	// The bucket is empty; work out when the next token arrives
+	var current float64
+	if err := storage.DB.WithContext(ctx).Raw(`
+		SELECT LEAST(@burst, tokens + EXTRACT(EPOCH FROM now() - updated_at) * @rate)
+		FROM rate_limit_buckets WHERE key = @key`,
+		map[string]interface{}{"key": key, "burst": burst, "rate": rate},
+	).Scan(&current).Error; err != nil {
+		return false, 0, err
+	}
+	return false, tokenWait(current, rate), nil
+}
+
+// Acquire implements Store. A transaction-scoped advisory lock on the key
+// serialises the count and insert across replicas.
+func (s *PostgresStore) Acquire(ctx context.Context, key string, limit int, ttl time.Duration) (string, bool, error) {
+	slot := uuid.New()
+	acquired := false
+
+	err := storage.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", key).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if err := tx.Where("key = ? AND expires_at < ?", key, now).Delete(&storage.ConcurrencyLease{}).Error; err != nil {
+			return err
+		}
+
+		var held int64
+		if err := tx.Model(&storage.ConcurrencyLease{}).Where("key = ?", key).Count(&held).Error; err != nil {
+			return err
+		}
+		if held >= int64(limit) {
+			return nil
+		}
+
+		acquired = true
+		return tx.Create(&storage.ConcurrencyLease{
+			ID:        slot,
+			Key:       key,
+			ExpiresAt: now.Add(ttl),
+		}).Error
+	})
+	if err != nil || !acquired {
+		return "", false, err
+	}
+	return slot.String(), true, nil
+}
+
+// Release implements Store
+func (s *PostgresStore) Release(ctx context.Context, key, slot string) error {
+	return storage.DB.WithContext(ctx).Where("id = ? AND key = ?", slot, key).Delete(&storage.ConcurrencyLease{}).Error
+}
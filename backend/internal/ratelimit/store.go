@@ -0,0 +1,156 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Store keeps token buckets and concurrency slots. MemoryStore suits a
+// single node; replicas must share a store such as PostgresStore so limits
+// apply across the cluster. Any backend offering an atomic
+// read-modify-write per key (Redis, for example) can implement it.
+type Store interface {
+	// Take consumes one token from the bucket at key, refilled at rate
+	// tokens per second up to burst. When the bucket is empty it reports
+	// how long until a token is available.
+	Take(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error)
+
+	// Acquire holds one of limit slots at key until Release is called or
+	// ttl passes, so slots held by a crashed process are recovered
+	Acquire(ctx context.Context, key string, limit int, ttl time.Duration) (string, bool, error)
+
+	// Release frees a slot returned by Acquire
+	Release(ctx context.Context, key, slot string) error
+}
+
+// sweepInterval is how often MemoryStore forgets refilled buckets and
+// expired slots
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // when it refills to burst and equals a new bucket
+}
+
+// MemoryStore is a process-local Store. Buckets are forgotten once they
+// refill and keys once they hold no slots, so idle keys use no memory.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	slots   map[string]map[string]time.Time
+	swept   time.Time
+	now     func() time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+		slots:   make(map[string]map[string]time.Time),
+		now:     time.Now,
+	}
+}
+
+// Take implements Store
+func (s *MemoryStore) Take(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), updated: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	b.full = now.Add(time.Duration((float64(burst) - b.tokens) / rate * float64(time.Second)))
+	if !allowed {
+		return false, tokenWait(b.tokens, rate), nil
+	}
+	return true, 0, nil
+}
+
+// Acquire implements Store
+func (s *MemoryStore) Acquire(ctx context.Context, key string, limit int, ttl time.Duration) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+	held, ok := s.slots[key]
+	if !ok {
+		held = make(map[string]time.Time)
+		s.slots[key] = held
+	}
+	for slot, expires := range held {
+		if expires.Before(now) {
+			delete(held, slot)
+		}
+	}
+
+	if len(held) >= limit {
+		if len(held) == 0 {
+			delete(s.slots, key)
+		}
+		return "", false, nil
+	}
+	slot := uuid.NewString()
+	held[slot] = now.Add(ttl)
+	return slot, true, nil
+}
+
+// Release implements Store
+func (s *MemoryStore) Release(ctx context.Context, key, slot string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if held, ok := s.slots[key]; ok {
+		delete(held, slot)
+		if len(held) == 0 {
+			delete(s.slots, key)
+		}
+	}
+	return nil
+}
+
+// sweep forgets refilled buckets, expired slots and keys left without
+// slots, at most once per sweepInterval
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.swept) < sweepInterval {
+		return
+	}
+	s.swept = now
+
+	for key, b := range s.buckets {
+		if !b.full.After(now) {
+			delete(s.buckets, key)
+		}
+	}
+	for key, held := range s.slots {
+		for slot, expires := range held {
+			if expires.Before(now) {
+				delete(held, slot)
+			}
+		}
+		if len(held) == 0 {
+			delete(s.slots, key)
+		}
+	}
+}
+
+// tokenWait returns how long a bucket holding tokens needs to reach one
+func tokenWait(tokens, rate float64) time.Duration {
+	return time.Duration((1 - tokens) / rate * float64(time.Second))
+}
@@ -0,0 +1,170 @@
+package ratelimit
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/storage"
+	"gorm.io/gorm/logger"
+)
+
+// stores returns each Store to test. PostgresStore runs against the
+// database in VOLTRUN_TEST_POSTGRES_DSN and is skipped without it.
+func stores() map[string]func(t *testing.T) Store {
+	return map[string]func(t *testing.T) Store{
+		"memory": func(t *testing.T) Store { return NewMemoryStore() },
+		"postgres": func(t *testing.T) Store {
+			dsn := os.Getenv("VOLTRUN_TEST_POSTGRES_DSN")
+			if dsn == "" {
+				t.Skip("VOLTRUN_TEST_POSTGRES_DSN is not set")
+			}
+			if err := storage.InitDB(storage.DriverPostgres, dsn); err != nil {
+				t.Fatal(err)
+			}
+			storage.DB.Logger = logger.Discard
+			if _, err := storage.MigrateUp(); err != nil {
+				t.Fatal(err)
+			}
+			return NewPostgresStore()
+		},
+	}
+}
+
+func TestStoreTake(t *testing.T) {
+	for name, newStore := range stores() {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			ctx := context.Background()
+			key := "test:" + uuid.NewString()
+
+			tests := []struct {
+				name    string
+				allowed bool
+			}{
+				{"first token of the burst", true},
+				{"second token of the burst", true},
+				{"empty bucket", false},
+			}
+			for _, tt := range tests {
+				allowed, wait, err := store.Take(ctx, key, 1, 2)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if allowed != tt.allowed {
+					t.Fatalf("%s: allowed = %v, want %v", tt.name, allowed, tt.allowed)
+				}
+				if !allowed && (wait <= 0 || wait > time.Second) {
+					t.Fatalf("%s: wait = %v, want at most the one second to refill", tt.name, wait)
+				}
+			}
+
+			// Buckets are independent
+			if allowed, _, err := store.Take(ctx, key+":other", 1, 2); err != nil || !allowed {
+				t.Fatalf("other key = %v, %v; want allowed", allowed, err)
+			}
+		})
+	}
+}
+
+func TestStoreAcquire(t *testing.T) {
+	for name, newStore := range stores() {
+		t.Run(name, func(t *testing.T) {
+			store := newStore(t)
+			ctx := context.Background()
+			key := "test:" + uuid.NewString()
+
+			first, ok, err := store.Acquire(ctx, key, 2, time.Minute)
+			if err != nil || !ok {
+				t.Fatalf("first slot = %v, %v", ok, err)
+			}
+			if _, ok, err := store.Acquire(ctx, key, 2, time.Minute); err != nil || !ok {
+				t.Fatalf("second slot = %v, %v", ok, err)
+			}
+			if _, ok, err := store.Acquire(ctx, key, 2, time.Minute); err != nil || ok {
+				t.Fatalf("third slot = %v, %v; want the limit reached", ok, err)
+			}
+
+			if err := store.Release(ctx, key, first); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok, err := store.Acquire(ctx, key, 2, time.Minute); err != nil || !ok {
+				t.Fatalf("slot after release = %v, %v", ok, err)
+			}
+
+			// Slots of a crashed holder expire
+			expiring := key + ":expiring"
+			if _, ok, err := store.Acquire(ctx, expiring, 1, -time.Second); err != nil || !ok {
+				t.Fatalf("expiring slot = %v, %v", ok, err)
+			}
+			if _, ok, err := store.Acquire(ctx, expiring, 1, time.Minute); err != nil || !ok {
+				t.Fatalf("slot after expiry = %v, %v", ok, err)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreEviction(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name    string
+		use     func(s *MemoryStore)
+		advance time.Duration
+		buckets int
+		slots   int
+	}{
+		{
+			name:    "refilled bucket",
+			use:     func(s *MemoryStore) { s.Take(ctx, "a", 1, 5) },
+			advance: sweepInterval,
+		},
+		{
+			name:    "bucket still refilling",
+			use:     func(s *MemoryStore) { s.Take(ctx, "a", 0.001, 5) },
+			advance: sweepInterval,
+			buckets: 1,
+		},
+		{
+			name: "released slot",
+			use: func(s *MemoryStore) {
+				slot, _, _ := s.Acquire(ctx, "a", 1, time.Hour)
+				s.Release(ctx, "a", slot)
+			},
+		},
+		{
+			name:    "expired slot",
+			use:     func(s *MemoryStore) { s.Acquire(ctx, "a", 1, time.Second) },
+			advance: sweepInterval,
+		},
+		{
+			name:    "held slot",
+			use:     func(s *MemoryStore) { s.Acquire(ctx, "a", 1, time.Hour) },
+			advance: sweepInterval,
+			slots:   1,
+		},
+		{
+			name:  "limit of zero",
+			use:   func(s *MemoryStore) { s.Acquire(ctx, "a", 0, time.Hour) },
+			slots: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+			s := NewMemoryStore()
+			s.now = func() time.Time { return now }
+			tt.use(s)
+
+			// Any later call sweeps once the interval has passed
+			now = now.Add(tt.advance)
+			s.Take(ctx, "unrelated", 1, 1)
+			delete(s.buckets, "unrelated")
+
+			if len(s.buckets) != tt.buckets || len(s.slots) != tt.slots {
+				t.Fatalf("buckets = %d, slots = %d; want %d and %d", len(s.buckets), len(s.slots), tt.buckets, tt.slots)
+			}
+		})
+	}
+}
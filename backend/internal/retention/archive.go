@@ -0,0 +1,112 @@
+package retention
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/objectstore"
+	"github.com/voltrun/backend/internal/storage"
+)
+
+// archivedRecord serializes an execution without its empty associations
+type archivedRecord struct {
+	*storage.Execution
+	User     *struct{} `json:"user,omitempty"`
+	Function *struct{} `json:"function,omitempty"`
+}
+
+// archive writes executions of one function to a gzip-compressed JSONL
+// object and returns the index rows pointing at it
+func archive(ctx context.Context, store objectstore.Store, userID, functionID uuid.UUID, executions []storage.Execution) ([]storage.ArchivedExecution, error) {
+	now := time.Now().UTC()
+	key := fmt.Sprintf("executions/%s/%s/%s-%s.jsonl.gz",
+		userID, functionID, now.Format("20060102T150405Z"), uuid.New())
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	encoder := json.NewEncoder(gz)
+	index := make([]storage.ArchivedExecution, 0, len(executions))
+
+	for i := range executions {
+		execution := &executions[i]
+		if err := encoder.Encode(archivedRecord{Execution: execution}); err != nil {
+			return nil, fmt.Errorf("failed to encode execution %s: %w", execution.ID, err)
+		}
+		index = append(index, storage.ArchivedExecution{
+			ID:             execution.ID,
+			UserID:         execution.UserID,
+			OrganizationID: execution.OrganizationID,
+			FunctionID:     execution.FunctionID,
+			Status:         execution.Status,
+			TriggerType:    execution.TriggerType,
+			DurationMS:     execution.DurationMS,
+			CreatedAt:      execution.CreatedAt,
+			ObjectKey:      key,
+			ArchivedAt:     now,
+		})
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := store.Put(ctx, key, buf.Bytes(), "application/gzip"); err != nil {
+		return nil, fmt.Errorf("failed to upload archive: %w", err)
+	}
+	return index, nil
+}
+
+// Fetch reads an archived execution back from the object store
+func Fetch(ctx context.Context, store objectstore.Store, archived *storage.ArchivedExecution) (*storage.Execution, error) {
+	if store == nil {
+		return nil, errors.New("no object store configured")
+	}
+	data, err := store.Get(ctx, archived.ObjectKey)
+	if err != nil {
+		return nil, err
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	decoder := json.NewDecoder(gz)
+	for {
+		var execution storage.Execution
+		if err := decoder.Decode(&execution); err != nil {
+			if err == io.EOF {
+				return nil, objectstore.ErrNotFound
+			}
+			return nil, err
+		}
+		if execution.ID == archived.ID {
+			return &execution, nil
+		}
+	}
+}
+
+// DeleteArchives removes a function's archive objects and index rows
+func DeleteArchives(ctx context.Context, store objectstore.Store, functionID uuid.UUID) error {
+	if store != nil {
+		var keys []string
+		if err := storage.DB.Model(&storage.ArchivedExecution{}).
+			Where("function_id = ?", functionID).
+			Distinct().Pluck("object_key", &keys).Error; err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := store.Delete(ctx, key); err != nil {
+				return fmt.Errorf("failed to delete archive %s: %w", key, err)
+			}
+		}
+	}
+	return storage.DB.Where("function_id = ?", functionID).Delete(&storage.ArchivedExecution{}).Error
+}
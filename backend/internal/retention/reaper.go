@@ -0,0 +1,210 @@
+package retention
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/objectstore"
+	"github.com/voltrun/backend/internal/payloads"
+	"github.com/voltrun/backend/internal/storage"
+	"github.com/voltrun/backend/internal/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultBatchSize bounds how many executions one transaction archives
+const defaultBatchSize = 500
+
+// DeliveryTTL is how long webhook delivery IDs are remembered to reject
+// replays. GitHub redelivers deliveries up to three days old, so IDs
+// outlive that window.
+const DeliveryTTL = 7 * 24 * time.Hour
+
+// Policy bounds how long a function's executions are kept. Zero fields
+// do not limit.
+type Policy struct {
+	Days     int
+	MaxCount int // newest executions kept
+}
+
+// Effective resolves a function's policy field by field: the function's
+// setting wins, then the organization's, then the server default
+func Effective(function, organization, server Policy) Policy {
+	policy := server
+	for _, override := range []Policy{organization, function} {
+		if override.Days > 0 {
+			policy.Days = override.Days
+		}
+		if override.MaxCount > 0 {
+			policy.MaxCount = override.MaxCount
+		}
+	}
+	return policy
+}
+
+// Reaper deletes executions that fall outside their retention policy,
+// archiving them to the object store first when one is configured.
+//
+// Replicas can run a Reaper at once: expired rows are claimed with
+// SELECT ... FOR UPDATE SKIP LOCKED, so each is archived exactly once.
+type Reaper struct {
+	store     objectstore.Store // nil deletes without archiving
+	defaults  Policy
+	interval  time.Duration
+	batchSize int
+}
+
+// NewReaper creates a reaper that sweeps every interval
+func NewReaper(store objectstore.Store, defaults Policy, interval time.Duration) *Reaper {
+	return &Reaper{
+		store:     store,
+		defaults:  defaults,
+		interval:  interval,
+		batchSize: defaultBatchSize,
+	}
+}
+
+// Start runs the sweep loop until ctx is cancelled
+func (r *Reaper) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				reaped, err := r.Sweep(ctx)
+				if err != nil {
+					utils.Error("Retention sweep failed", zap.Error(err))
+				}
+				if reaped > 0 {
+					utils.Info("Retention sweep removed executions", zap.Int("count", reaped))
+				}
+			}
+		}
+	}()
+}
+
+// target is a function with the retention settings that apply to it
+type target struct {
+	ID                            uuid.UUID
+	UserID                        uuid.UUID
+	RetentionDays                 int
+	RetentionMaxCount             int
+	OrganizationRetentionDays     int
+	OrganizationRetentionMaxCount int
+}
+
+// Sweep forgets expired webhook delivery IDs and removes every expired
+// execution, returning how many executions it removed
+func (r *Reaper) Sweep(ctx context.Context) (int, error) {
+	if err := storage.DB.WithContext(ctx).Where("created_at < ?", time.Now().Add(-DeliveryTTL)).
+		Delete(&storage.WebhookDelivery{}).Error; err != nil {
+		return 0, err
+	}
+
+	var targets []target
+	if err := storage.DB.Table("functions").
+		Select("functions.id, functions.user_id, functions.retention_days, functions.retention_max_count, " +
+			"organizations.retention_days AS organization_retention_days, " +
+			"organizations.retention_max_count AS organization_retention_max_count").
+		Joins("JOIN organizations ON organizations.id = functions.organization_id").
+		Scan(&targets).Error; err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, t := range targets {
+		policy := Effective(
+			Policy{Days: t.RetentionDays, MaxCount: t.RetentionMaxCount},
+			Policy{Days: t.OrganizationRetentionDays, MaxCount: t.OrganizationRetentionMaxCount},
+			r.defaults,
+		)
+		if policy.Days == 0 && policy.MaxCount == 0 {
+			continue
+		}
+
+		for {
+			if ctx.Err() != nil {
+				return total, ctx.Err()
+			}
+			reaped, err := r.reap(ctx, t, policy)
+			total += reaped
+			if err != nil {
+				return total, err
+			}
+			if reaped < r.batchSize {
+				break
+			}
+		}
+	}
+	return total, nil
+}
+
+// reap archives and deletes one batch of a function's expired executions
+func (r *Reaper) reap(ctx context.Context, t target, policy Policy) (int, error) {
+	var conditions []string
+	var args []interface{}
+	if policy.Days > 0 {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, time.Now().AddDate(0, 0, -policy.Days))
+	}
+	if policy.MaxCount > 0 {
+		conditions = append(conditions,
+			"id NOT IN (SELECT id FROM executions WHERE function_id = ? ORDER BY created_at DESC, id DESC LIMIT ?)")
+		args = append(args, t.ID, policy.MaxCount)
+	}
+
+	var expired []storage.Execution
+	err := storage.DB.Transaction(func(tx *gorm.DB) error {
+		// Unfinished executions and waiting retries are never reaped
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("function_id = ? AND status IN ? AND next_retry_at IS NULL", t.ID, []string{"success", "failed"}).
+			Where(strings.Join(conditions, " OR "), args...).
+			Order("created_at").
+			Limit(r.batchSize).
+			Find(&expired).Error; err != nil {
+			return err
+		}
+		if len(expired) == 0 {
+			return nil
+		}
+
+		if r.store != nil {
+			// Archives hold complete records, including offloaded payloads
+			for i := range expired {
+				if err := payloads.Hydrate(ctx, &expired[i]); err != nil {
+					return err
+				}
+			}
+			index, err := archive(ctx, r.store, t.UserID, t.ID, expired)
+			if err != nil {
+				return err
+			}
+			if err := tx.Create(&index).Error; err != nil {
+				return err
+			}
+		}
+
+		ids := make([]uuid.UUID, len(expired))
+		for i := range expired {
+			ids[i] = expired[i].ID
+		}
+		return tx.Where("id IN ?", ids).Delete(&storage.Execution{}).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range expired {
+		if err := payloads.Delete(ctx, &expired[i]); err != nil {
+			utils.Error("Failed to delete execution payloads", zap.String("execution_id", expired[i].ID.String()), zap.Error(err))
+		}
+	}
+	return len(expired), nil
+}
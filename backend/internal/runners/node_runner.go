@@ -1,129 +1,204 @@
-package runners
-
-import (
-	"bytes"
-	"context"
-	"encoding/json"
-	"fmt"
-	"os"
-	"os/exec"
-	"path/filepath"
-	"time"
-)
-
-// NodeRunner executes Node.js functions
-type NodeRunner struct{}
-
-// ExecutionResult represents the result of code execution
-type ExecutionResult struct {
-	Output     map[string]interface{} `json:"output"`
-	Logs       string                 `json:"logs"`
-	Error      string                 `json:"error,omitempty"`
-	DurationMS int64                  `json:"duration_ms"`
-	ExitCode   int                    `json:"exit_code"`
-}
-
-// Execute runs Node.js code with the given input
-func (r *NodeRunner) Execute(ctx context.Context, code string, input map[string]interface{}, timeout time.Duration) (*ExecutionResult, error) {
-	start := time.Now()
-
-	// Create temporary directory for execution
-	tempDir, err := os.MkdirTemp("", "voltrun-node-*")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create temp dir: %w", err)
-	}
-	defer os.RemoveAll(tempDir)
-
-	// Write code to file
-	codePath := filepath.Join(tempDir, "index.js")
-	if err := os.WriteFile(codePath, []byte(code), 0644); err != nil {
-		return nil, fmt.Errorf("failed to write code: %w", err)
-	}
-
-	// Write input to file
-	inputJSON, _ := json.Marshal(input)
-	inputPath := filepath.Join(tempDir, "input.json")
-	if err := os.WriteFile(inputPath, inputJSON, 0644); err != nil {
-		return nil, fmt.Errorf("failed to write input: %w", err)
-	}
-
-	// Create wrapper script that loads input and executes handler
-	wrapperCode := `
-const fs = require('fs');
-const handler = require('./index.js');
-
-const input = JSON.parse(fs.readFileSync('./input.json', 'utf8'));
-
-(async () => {
-  try {
-    const result = await handler.handler(input);
-    console.log('__VOLTRUN_OUTPUT_START__');
-    console.log(JSON.stringify(result));
-    console.log('__VOLTRUN_OUTPUT_END__');
-  } catch (error) {
-    console.error('__VOLTRUN_ERROR__', error.message);
-    console.error(error.stack);
-    process.exit(1);
-  }
-})();
-`
-
-	wrapperPath := filepath.Join(tempDir, "wrapper.js")
-	if err := os.WriteFile(wrapperPath, []byte(wrapperCode), 0644); err != nil {
-		return nil, fmt.Errorf("failed to write wrapper: %w", err)
-	}
-
-	// Execute Node.js with timeout
-	ctxWithTimeout, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
-
-	cmd := exec.CommandContext(ctxWithTimeout, "node", "wrapper.js")
-	cmd.Dir = tempDir
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	err = cmd.Run()
-	duration := time.Since(start).Milliseconds()
-
-	result := &ExecutionResult{
-		DurationMS: duration,
-		Logs:       stdout.String() + stderr.String(),
-	}
-
-	if err != nil {
-		if ctxWithTimeout.Err() == context.DeadlineExceeded {
-			result.Error = "Execution timeout exceeded"
-			result.ExitCode = -1
-		} else {
-			result.Error = err.Error()
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				result.ExitCode = exitErr.ExitCode()
-			}
-		}
-		return result, nil
-	}
-
-	// Parse output from stdout
-	outputStr := stdout.String()
-	if startIdx := bytes.Index([]byte(outputStr), []byte("__VOLTRUN_OUTPUT_START__")); startIdx != -1 {
-		if endIdx := bytes.Index([]byte(outputStr), []byte("__VOLTRUN_OUTPUT_END__")); endIdx != -1 {
-			jsonStart := startIdx + len("__VOLTRUN_OUTPUT_START__") + 1
-			outputJSON := outputStr[jsonStart:endIdx]
-			var output map[string]interface{}
-			if err := json.Unmarshal([]byte(outputJSON), &output); err == nil {
-				result.Output = output
-			} else {
-				result.Output = map[string]interface{}{"result": outputJSON}
-			}
-		}
-	}
-
-	if result.Output == nil {
-		result.Output = map[string]interface{}{"message": "Execution completed"}
-	}
-
-	result.ExitCode = 0
-	return result, nil
-}
+package runners
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/voltrun/backend/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ExitCodeTimeout is reported when the runner kills a function at its timeout
+const ExitCodeTimeout = -1
+
+// NodeRunner executes Node.js functions
+type NodeRunner struct{}
+
+// ExecutionResult represents the result of code execution
+type ExecutionResult struct {
+	Output     interface{} `json:"output"`
+	Logs       string      `json:"logs"`
+	Error      string      `json:"error,omitempty"`
+	DurationMS int64       `json:"duration_ms"`
+	ExitCode   int         `json:"exit_code"`
+}
+
+const (
+	outputStartMarker = "__VOLTRUN_OUTPUT_START__"
+	outputEndMarker   = "__VOLTRUN_OUTPUT_END__"
+)
+
+// parseOutput extracts the handler's return value from the wrapper's
+// stdout. Any JSON value is passed through as-is; a handler that returned
+// nothing yields nil.
+func parseOutput(stdout []byte) interface{} {
+	startIdx := bytes.Index(stdout, []byte(outputStartMarker))
+	if startIdx == -1 {
+		return nil
+	}
+	rest := stdout[startIdx+len(outputStartMarker):]
+	endIdx := bytes.Index(rest, []byte(outputEndMarker))
+	if endIdx == -1 {
+		return nil
+	}
+
+	var output interface{}
+	if err := json.Unmarshal(bytes.TrimSpace(rest[:endIdx]), &output); err != nil {
+		return string(bytes.TrimSpace(rest[:endIdx]))
+	}
+	return output
+}
+
+// buildEnv returns the process environment for a function. The backend's
+// own environment is deliberately not inherited so that credentials such
+// as JWT_SECRET never reach user code. TRACEPARENT and TRACESTATE carry
+// the span in ctx so the function can continue the trace.
+func buildEnv(ctx context.Context, env map[string]string, dir string) []string {
+	vars := []string{
+		"PATH=" + os.Getenv("PATH"),
+		"HOME=" + dir,
+	}
+	for key, value := range env {
+		vars = append(vars, key+"="+value)
+	}
+	for key, value := range tracing.Environment(ctx) {
+		vars = append(vars, key+"="+value)
+	}
+	return vars
+}
+
+// Execute runs Node.js code with the given input
+func (r *NodeRunner) Execute(ctx context.Context, code string, input interface{}, env map[string]string, timeout time.Duration) (*ExecutionResult, error) {
+	start := time.Now()
+
+	_, startSpan := tracing.Tracer.Start(ctx, "runner.start", trace.WithAttributes(attribute.String("runtime", "node")))
+	defer startSpan.End()
+
+	// Create temporary directory for execution
+	tempDir, err := os.MkdirTemp("", "voltrun-node-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Write code to file
+	codePath := filepath.Join(tempDir, "index.js")
+	if err := os.WriteFile(codePath, []byte(code), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write code: %w", err)
+	}
+
+	// Write input to file
+	inputJSON, _ := json.Marshal(input)
+	inputPath := filepath.Join(tempDir, "input.json")
+	if err := os.WriteFile(inputPath, inputJSON, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write input: %w", err)
+	}
+
+	// Create wrapper script that loads input and executes handler
+	wrapperCode := `
+const fs = require('fs');
+const handler = require('./index.js');
+
+const input = JSON.parse(fs.readFileSync('./input.json', 'utf8'));
+
+// Buffers and typed arrays come back as a base64 binary envelope; every
+// other value is returned as plain JSON.
+function encodeResult(result) {
+  if (result === undefined) {
+    return null;
+  }
+  if (Buffer.isBuffer(result) || result instanceof Uint8Array) {
+    return {
+      body: Buffer.from(result).toString('base64'),
+      is_base64_encoded: true,
+      content_type: 'application/octet-stream',
+    };
+  }
+  if (result && (Buffer.isBuffer(result.body) || result.body instanceof Uint8Array)) {
+    return {
+      ...result,
+      body: Buffer.from(result.body).toString('base64'),
+      is_base64_encoded: true,
+      content_type: result.content_type || 'application/octet-stream',
+    };
+  }
+  return result;
+}
+const context = {
+  traceparent: process.env.TRACEPARENT || null,
+  tracestate: process.env.TRACESTATE || null,
+};
+
+(async () => {
+  try {
+    const result = await handler.handler(input, context);
+    console.log('__VOLTRUN_OUTPUT_START__');
+    console.log(JSON.stringify(encodeResult(result)));
+    console.log('__VOLTRUN_OUTPUT_END__');
+  } catch (error) {
+    console.error('__VOLTRUN_ERROR__', error.message);
+    console.error(error.stack);
+    process.exit(1);
+  }
+})();
+`
+
+	wrapperPath := filepath.Join(tempDir, "wrapper.js")
+	if err := os.WriteFile(wrapperPath, []byte(wrapperCode), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write wrapper: %w", err)
+	}
+
+	// Execute Node.js with timeout
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// The handler span is what function code sees as its parent
+	handlerCtx, handlerSpan := tracing.Tracer.Start(ctx, "runner.handler", trace.WithAttributes(attribute.String("runtime", "node")))
+	defer handlerSpan.End()
+
+	cmd := exec.CommandContext(ctxWithTimeout, "node", "wrapper.js")
+	cmd.Dir = tempDir
+	cmd.Env = buildEnv(handlerCtx, env, tempDir)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Start()
+	startSpan.End()
+	if err == nil {
+		err = cmd.Wait()
+	}
+	duration := time.Since(start).Milliseconds()
+
+	result := &ExecutionResult{
+		DurationMS: duration,
+		Logs:       stdout.String() + stderr.String(),
+	}
+
+	if err != nil {
+		if ctxWithTimeout.Err() == context.DeadlineExceeded {
+			result.Error = "Execution timeout exceeded"
+			result.ExitCode = ExitCodeTimeout
+		} else {
+			result.Error = err.Error()
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				result.ExitCode = exitErr.ExitCode()
+			}
+		}
+		handlerSpan.SetStatus(codes.Error, result.Error)
+		return result, nil
+	}
+
+	result.Output = parseOutput(stdout.Bytes())
+	result.ExitCode = 0
+	return result, nil
+}
@@ -1,122 +1,158 @@
-package runners
-
-import (
-	"bytes"
-	"context"
-	"encoding/json"
-	"fmt"
-	"os"
-	"os/exec"
-	"path/filepath"
-	"time"
-)
-
-// PythonRunner executes Python functions
-type PythonRunner struct{}
-
-// Execute runs Python code with the given input
-func (r *PythonRunner) Execute(ctx context.Context, code string, input map[string]interface{}, timeout time.Duration) (*ExecutionResult, error) {
-	start := time.Now()
-
-	// Create temporary directory for execution
-	tempDir, err := os.MkdirTemp("", "voltrun-python-*")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create temp dir: %w", err)
-	}
-	defer os.RemoveAll(tempDir)
-
-	// Write code to file
-	codePath := filepath.Join(tempDir, "handler.py")
-	if err := os.WriteFile(codePath, []byte(code), 0644); err != nil {
-		return nil, fmt.Errorf("failed to write code: %w", err)
-	}
-
-	// Write input to file
-	inputJSON, _ := json.Marshal(input)
-	inputPath := filepath.Join(tempDir, "input.json")
-	if err := os.WriteFile(inputPath, inputJSON, 0644); err != nil {
-		return nil, fmt.Errorf("failed to write input: %w", err)
-	}
-
-	// Create wrapper script that loads input and executes handler
-	wrapperCode := `
-import json
-import sys
-import traceback
-from handler import handler
-
-if __name__ == '__main__':
-    try:
-        with open('input.json', 'r') as f:
-            event = json.load(f)
-        
-        result = handler(event)
-        
-        print('__VOLTRUN_OUTPUT_START__')
-        print(json.dumps(result))
-        print('__VOLTRUN_OUTPUT_END__')
-    except Exception as e:
-        print(f'__VOLTRUN_ERROR__ {str(e)}', file=sys.stderr)
-        traceback.print_exc(file=sys.stderr)
-        sys.exit(1)
-`
-
-	wrapperPath := filepath.Join(tempDir, "wrapper.py")
-	if err := os.WriteFile(wrapperPath, []byte(wrapperCode), 0644); err != nil {
-		return nil, fmt.Errorf("failed to write wrapper: %w", err)
-	}
-
-	// Execute Python with timeout
-	ctxWithTimeout, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
-
-	cmd := exec.CommandContext(ctxWithTimeout, "python3", "wrapper.py")
-	cmd.Dir = tempDir
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	err = cmd.Run()
-	duration := time.Since(start).Milliseconds()
-
-	result := &ExecutionResult{
-		DurationMS: duration,
-		Logs:       stdout.String() + stderr.String(),
-	}
-
-	if err != nil {
-		if ctxWithTimeout.Err() == context.DeadlineExceeded {
-			result.Error = "Execution timeout exceeded"
-			result.ExitCode = -1
-		} else {
-			result.Error = err.Error()
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				result.ExitCode = exitErr.ExitCode()
-			}
-		}
-		return result, nil
-	}
-
-	// Parse output from stdout
-	outputStr := stdout.String()
-	if startIdx := bytes.Index([]byte(outputStr), []byte("__VOLTRUN_OUTPUT_START__")); startIdx != -1 {
-		if endIdx := bytes.Index([]byte(outputStr), []byte("__VOLTRUN_OUTPUT_END__")); endIdx != -1 {
-			jsonStart := startIdx + len("__VOLTRUN_OUTPUT_START__") + 1
-			outputJSON := outputStr[jsonStart:endIdx]
-			var output map[string]interface{}
-			if err := json.Unmarshal([]byte(outputJSON), &output); err == nil {
-				result.Output = output
-			} else {
-				result.Output = map[string]interface{}{"result": outputJSON}
-			}
-		}
-	}
-
-	if result.Output == nil {
-		result.Output = map[string]interface{}{"message": "Execution completed"}
-	}
-
-	result.ExitCode = 0
-	return result, nil
-}
+package runners
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/voltrun/backend/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// PythonRunner executes Python functions
+type PythonRunner struct{}
+
+// Execute runs Python code with the given input
+func (r *PythonRunner) Execute(ctx context.Context, code string, input interface{}, env map[string]string, timeout time.Duration) (*ExecutionResult, error) {
+	start := time.Now()
+
+	_, startSpan := tracing.Tracer.Start(ctx, "runner.start", trace.WithAttributes(attribute.String("runtime", "python")))
+	defer startSpan.End()
+
+	// Create temporary directory for execution
+	tempDir, err := os.MkdirTemp("", "voltrun-python-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Write code to file
+	codePath := filepath.Join(tempDir, "handler.py")
+	if err := os.WriteFile(codePath, []byte(code), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write code: %w", err)
+	}
+
+	// Write input to file
+	inputJSON, _ := json.Marshal(input)
+	inputPath := filepath.Join(tempDir, "input.json")
+	if err := os.WriteFile(inputPath, inputJSON, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write input: %w", err)
+	}
+
+	// Create wrapper script that loads input and executes handler
+	wrapperCode := `
+import inspect
+import json
+import os
+import sys
+import base64
+import traceback
+from handler import handler
+
+
+def encode_result(result):
+    """Return bytes as a base64 binary envelope and anything else as-is."""
+    if isinstance(result, (bytes, bytearray, memoryview)):
+        return {
+            'body': base64.b64encode(bytes(result)).decode('ascii'),
+            'is_base64_encoded': True,
+            'content_type': 'application/octet-stream',
+        }
+    if isinstance(result, dict) and isinstance(result.get('body'), (bytes, bytearray, memoryview)):
+        encoded = dict(result)
+        encoded['body'] = base64.b64encode(bytes(result['body'])).decode('ascii')
+        encoded['is_base64_encoded'] = True
+        encoded.setdefault('content_type', 'application/octet-stream')
+        return encoded
+    return result
+
+
+def accepts_context(fn):
+    try:
+        params = list(inspect.signature(fn).parameters.values())
+    except (TypeError, ValueError):
+        return False
+    positional = [p for p in params if p.kind in (p.POSITIONAL_ONLY, p.POSITIONAL_OR_KEYWORD)]
+    return len(positional) >= 2 or any(p.kind == p.VAR_POSITIONAL for p in params)
+
+if __name__ == '__main__':
+    try:
+        with open('input.json', 'r') as f:
+            event = json.load(f)
+        
+        if accepts_context(handler):
+            context = {
+                'traceparent': os.environ.get('TRACEPARENT'),
+                'tracestate': os.environ.get('TRACESTATE'),
+            }
+            result = handler(event, context)
+        else:
+            result = handler(event)
+        
+        print('__VOLTRUN_OUTPUT_START__')
+        print(json.dumps(encode_result(result)))
+        print('__VOLTRUN_OUTPUT_END__')
+    except Exception as e:
+        print(f'__VOLTRUN_ERROR__ {str(e)}', file=sys.stderr)
+        traceback.print_exc(file=sys.stderr)
+        sys.exit(1)
+`
+
+	wrapperPath := filepath.Join(tempDir, "wrapper.py")
+	if err := os.WriteFile(wrapperPath, []byte(wrapperCode), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write wrapper: %w", err)
+	}
+
+	// Execute Python with timeout
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// The handler span is what function code sees as its parent
+	handlerCtx, handlerSpan := tracing.Tracer.Start(ctx, "runner.handler", trace.WithAttributes(attribute.String("runtime", "python")))
+	defer handlerSpan.End()
+
+	cmd := exec.CommandContext(ctxWithTimeout, "python3", "wrapper.py")
+	cmd.Dir = tempDir
+	cmd.Env = buildEnv(handlerCtx, env, tempDir)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Start()
+	startSpan.End()
+	if err == nil {
+		err = cmd.Wait()
+	}
+	duration := time.Since(start).Milliseconds()
+
+	result := &ExecutionResult{
+		DurationMS: duration,
+		Logs:       stdout.String() + stderr.String(),
+	}
+
+	if err != nil {
+		if ctxWithTimeout.Err() == context.DeadlineExceeded {
+			result.Error = "Execution timeout exceeded"
+			result.ExitCode = ExitCodeTimeout
+		} else {
+			result.Error = err.Error()
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				result.ExitCode = exitErr.ExitCode()
+			}
+		}
+		handlerSpan.SetStatus(codes.Error, result.Error)
+		return result, nil
+	}
+
+	result.Output = parseOutput(stdout.Bytes())
+	result.ExitCode = 0
+	return result, nil
+}
@@ -0,0 +1,123 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrUnknownKey        = errors.New("unknown encryption key")
+	ErrInvalidCiphertext = errors.New("invalid ciphertext")
+)
+
+// Keyring holds the master keys used to encrypt secrets at rest.
+// New values are always sealed with the active key; previous keys are
+// kept only so existing values can be decrypted and re-encrypted.
+type Keyring struct {
+	activeID string
+	keys     map[string][]byte
+}
+
+var keyring *Keyring
+
+// InitKeyring configures the package-level keyring.
+//
+// previous is a comma-separated list of "id:key" pairs for retired keys.
+func InitKeyring(activeID, activeKey, previous string) error {
+	if activeID == "" || activeKey == "" {
+		return errors.New("secrets master key and key id are required")
+	}
+
+	ring := &Keyring{
+		activeID: activeID,
+		keys:     map[string][]byte{activeID: deriveKey(activeKey)},
+	}
+
+	for _, entry := range strings.Split(previous, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("invalid previous secrets key entry %q", entry)
+		}
+		if parts[0] == activeID {
+			return fmt.Errorf("previous secrets key %q reuses the active key id", parts[0])
+		}
+		ring.keys[parts[0]] = deriveKey(parts[1])
+	}
+
+	keyring = ring
+	return nil
+}
+
+// ActiveKeyID returns the ID of the key used for new encryptions
+func ActiveKeyID() string {
+	return keyring.activeID
+}
+
+// Encrypt seals plaintext with the active key using AES-256-GCM.
+// The returned ciphertext is base64 encoded and prefixed with the nonce.
+func Encrypt(plaintext string) (ciphertext string, keyID string, err error) {
+	gcm, err := keyring.aead(keyring.activeID)
+	if err != nil {
+		return "", "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), keyring.activeID, nil
+}
+
+// Decrypt opens a ciphertext produced by Encrypt with the given key
+func Decrypt(ciphertext, keyID string) (string, error) {
+	gcm, err := keyring.aead(keyID)
+	if err != nil {
+		return "", err
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil || len(raw) < gcm.NonceSize() {
+		return "", ErrInvalidCiphertext
+	}
+
+	nonce, sealed := raw[:gcm.NonceSize()], raw[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+	return string(plaintext), nil
+}
+
+// aead returns the AES-GCM cipher for a key ID
+func (k *Keyring) aead(keyID string) (cipher.AEAD, error) {
+	if k == nil {
+		return nil, errors.New("secrets keyring not initialized")
+	}
+	key, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, keyID)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// deriveKey stretches a configured master key to 32 bytes for AES-256
+func deriveKey(material string) []byte {
+	sum := sha256.Sum256([]byte(material))
+	return sum[:]
+}
@@ -0,0 +1,98 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestInitKeyring(t *testing.T) {
+	tests := []struct {
+		name      string
+		activeID  string
+		activeKey string
+		previous  string
+		wantErr   bool
+	}{
+		{name: "active key only", activeID: "k2", activeKey: "secret"},
+		{name: "previous keys", activeID: "k3", activeKey: "secret", previous: "k1:old, k2:older,"},
+		{name: "missing key id", activeKey: "secret", wantErr: true},
+		{name: "missing key", activeID: "k1", wantErr: true},
+		{name: "malformed previous entry", activeID: "k2", activeKey: "secret", previous: "k1", wantErr: true},
+		{name: "empty previous key", activeID: "k2", activeKey: "secret", previous: "k1:", wantErr: true},
+		{name: "previous reuses the active id", activeID: "k2", activeKey: "secret", previous: "k2:old", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := InitKeyring(tt.activeID, tt.activeKey, tt.previous)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	if err := InitKeyring("k1", "old", ""); err != nil {
+		t.Fatal(err)
+	}
+	retired, retiredID, err := Encrypt("sealed with k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := InitKeyring("k2", "new", "k1:old"); err != nil {
+		t.Fatal(err)
+	}
+	active, activeID, err := Encrypt("sealed with k2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retiredID != "k1" || activeID != "k2" {
+		t.Fatalf("key ids = %s, %s; want k1, k2", retiredID, activeID)
+	}
+
+	// Flip a bit of the sealed value so authentication fails
+	raw, err := base64.StdEncoding.DecodeString(active)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw[len(raw)-1] ^= 1
+	tampered := base64.StdEncoding.EncodeToString(raw)
+
+	tests := []struct {
+		name       string
+		ciphertext string
+		keyID      string
+		want       string
+		wantErr    error
+	}{
+		{name: "active key", ciphertext: active, keyID: "k2", want: "sealed with k2"},
+		{name: "previous key", ciphertext: retired, keyID: "k1", want: "sealed with k1"},
+		{name: "wrong key", ciphertext: active, keyID: "k1", wantErr: ErrInvalidCiphertext},
+		{name: "unknown key", ciphertext: active, keyID: "k9", wantErr: ErrUnknownKey},
+		{name: "tampered", ciphertext: tampered, keyID: "k2", wantErr: ErrInvalidCiphertext},
+		{name: "not base64", ciphertext: "%%%", keyID: "k2", wantErr: ErrInvalidCiphertext},
+		{name: "shorter than the nonce", ciphertext: "AAAA", keyID: "k2", wantErr: ErrInvalidCiphertext},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decrypt(tt.ciphertext, tt.keyID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("plaintext = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Every encryption uses a fresh nonce
+	again, _, err := Encrypt("sealed with k2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == active || strings.Contains(active, "sealed") {
+		t.Fatalf("ciphertext %q repeats or leaks the plaintext", again)
+	}
+}
@@ -0,0 +1,137 @@
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/storage"
+)
+
+// minRedactLength is the shortest secret value that gets redacted from
+// logs; shorter values would mangle unrelated output.
+const minRedactLength = 4
+
+var namePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidateName checks that name is usable as an environment variable
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid environment variable name: %q", name)
+	}
+	if strings.HasPrefix(strings.ToUpper(name), "VOLTRUN_") {
+		return fmt.Errorf("environment variable name %q uses the reserved VOLTRUN_ prefix", name)
+	}
+	return nil
+}
+
+// NewSecret encrypts value as a secret of the function for the caller to
+// store
+func NewSecret(function *storage.Function, name, value string) (*storage.FunctionSecret, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+
+	ciphertext, keyID, err := Encrypt(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+
+	return &storage.FunctionSecret{
+		FunctionID: function.ID,
+		UserID:     function.UserID,
+		Name:       name,
+		Ciphertext: ciphertext,
+		KeyID:      keyID,
+	}, nil
+}
+
+// ResolveEnvironment builds the environment passed to a function's runner
+// from its variables and its stored secrets. It returns the merged
+// variables and the plaintext secret values so the caller can redact them
+// from captured output.
+func ResolveEnvironment(function *storage.Function, stored []storage.FunctionSecret) (map[string]string, []string, error) {
+	env := map[string]string{}
+	if len(function.Environment) > 0 {
+		if err := json.Unmarshal(function.Environment, &env); err != nil {
+			return nil, nil, fmt.Errorf("invalid function environment: %w", err)
+		}
+	}
+
+	values := make([]string, 0, len(stored))
+	for _, secret := range stored {
+		plaintext, err := Decrypt(secret.Ciphertext, secret.KeyID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decrypt secret %s: %w", secret.Name, err)
+		}
+		env[secret.Name] = plaintext
+		values = append(values, plaintext)
+	}
+
+	return env, values, nil
+}
+
+// Redact replaces every occurrence of the given secret values in text
+func Redact(text string, values []string) string {
+	for _, value := range values {
+		if len(value) < minRedactLength {
+			continue
+		}
+		text = strings.ReplaceAll(text, value, "[REDACTED]")
+	}
+	return text
+}
+
+// encryptedColumns lists every ciphertext/key-id column pair sealed with
+// the keyring
+var encryptedColumns = []struct {
+	table      string
+	ciphertext string
+	keyID      string
+}{
+	{"function_secrets", "ciphertext", "key_id"},
+	{"webhook_triggers", "secret_ciphertext", "secret_key_id"},
+	{"functions", "destination_secret_ciphertext", "destination_secret_key_id"},
+}
+
+// RotateKeys re-encrypts every value not sealed with the active key,
+// including webhook and destination signing secrets. Once it returns,
+// retired keys can be removed from configuration.
+func RotateKeys() (int, error) {
+	rotated := 0
+	for _, column := range encryptedColumns {
+		var stale []struct {
+			ID         uuid.UUID
+			Ciphertext string
+			KeyID      string
+		}
+		if err := storage.DB.Table(column.table).
+			Select("id, "+column.ciphertext+" AS ciphertext, "+column.keyID+" AS key_id").
+			Where(column.keyID+" <> ? AND "+column.keyID+" <> ''", ActiveKeyID()).
+			Scan(&stale).Error; err != nil {
+			return rotated, fmt.Errorf("failed to load %s: %w", column.table, err)
+		}
+
+		for _, row := range stale {
+			plaintext, err := Decrypt(row.Ciphertext, row.KeyID)
+			if err != nil {
+				return rotated, fmt.Errorf("failed to decrypt %s %s: %w", column.table, row.ID, err)
+			}
+			ciphertext, keyID, err := Encrypt(plaintext)
+			if err != nil {
+				return rotated, err
+			}
+			if err := storage.DB.Table(column.table).Where("id = ?", row.ID).Updates(map[string]interface{}{
+				column.ciphertext: ciphertext,
+				column.keyID:      keyID,
+			}).Error; err != nil {
+				return rotated, fmt.Errorf("failed to update %s %s: %w", column.table, row.ID, err)
+			}
+			rotated++
+		}
+	}
+
+	return rotated, nil
+}
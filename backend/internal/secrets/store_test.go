@@ -0,0 +1,135 @@
+package secrets
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/storage"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB migrates a fresh SQLite database into storage.DB
+func openTestDB(t *testing.T) {
+	t.Helper()
+	if err := storage.InitDB(storage.DriverSQLite, filepath.Join(t.TempDir(), "voltrun.db")); err != nil {
+		t.Fatal(err)
+	}
+	storage.DB.Logger = logger.Discard
+	if _, err := storage.MigrateUp(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := storage.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+		storage.DB = nil
+	})
+}
+
+func TestRotateKeys(t *testing.T) {
+	openTestDB(t)
+	if err := InitKeyring("k1", "old", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	seal := func(plaintext string) (string, string) {
+		ciphertext, keyID, err := Encrypt(plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ciphertext, keyID
+	}
+
+	user := storage.User{ID: uuid.New(), Email: "owner@example.com", Password: "x"}
+	function := storage.Function{
+		ID:             uuid.New(),
+		OrganizationID: user.ID,
+		UserID:         user.ID,
+		Name:           "fn",
+		Runtime:        "nodejs20",
+		Code:           "x",
+	}
+	function.DestinationSecretCiphertext, function.DestinationSecretKeyID = seal("destination")
+	secret := storage.FunctionSecret{ID: uuid.New(), FunctionID: function.ID, UserID: user.ID, Name: "API_KEY"}
+	secret.Ciphertext, secret.KeyID = seal("api key")
+	webhook := storage.WebhookTrigger{ID: uuid.New(), FunctionID: function.ID, UserID: user.ID, Name: "hook", Enabled: true}
+	webhook.SecretCiphertext, webhook.SecretKeyID = seal("webhook")
+	for _, row := range []interface{}{&user, &function, &secret, &webhook} {
+		if err := storage.DB.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Rotate to k2, keeping k1 to decrypt what it sealed
+	if err := InitKeyring("k2", "new", "k1:old"); err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := RotateKeys()
+	if err != nil || rotated != 3 {
+		t.Fatalf("rotated = %d, %v; want 3", rotated, err)
+	}
+	if rotated, err := RotateKeys(); err != nil || rotated != 0 {
+		t.Fatalf("second rotation = %d, %v; want nothing left to rotate", rotated, err)
+	}
+
+	// Everything now opens with k2 alone
+	if err := InitKeyring("k2", "new", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.DB.First(&function, "id = ?", function.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.DB.First(&secret, "id = ?", secret.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.DB.First(&webhook, "id = ?", webhook.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		ciphertext string
+		keyID      string
+		want       string
+	}{
+		{"function secret", secret.Ciphertext, secret.KeyID, "api key"},
+		{"webhook signing secret", webhook.SecretCiphertext, webhook.SecretKeyID, "webhook"},
+		{"destination signing secret", function.DestinationSecretCiphertext, function.DestinationSecretKeyID, "destination"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.keyID != "k2" {
+				t.Fatalf("key id = %s, want k2", tt.keyID)
+			}
+			got, err := Decrypt(tt.ciphertext, tt.keyID)
+			if err != nil || got != tt.want {
+				t.Fatalf("plaintext = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRotateKeysMissingKey(t *testing.T) {
+	openTestDB(t)
+	if err := InitKeyring("k1", "old", ""); err != nil {
+		t.Fatal(err)
+	}
+	user := storage.User{ID: uuid.New(), Email: "owner@example.com", Password: "x"}
+	function := storage.Function{ID: uuid.New(), OrganizationID: user.ID, UserID: user.ID, Name: "fn", Runtime: "nodejs20", Code: "x"}
+	secret := storage.FunctionSecret{ID: uuid.New(), FunctionID: function.ID, UserID: user.ID, Name: "API_KEY"}
+	secret.Ciphertext, secret.KeyID, _ = Encrypt("api key")
+	for _, row := range []interface{}{&user, &function, &secret} {
+		if err := storage.DB.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// k1 was dropped from configuration before rotating
+	if err := InitKeyring("k2", "new", ""); err != nil {
+		t.Fatal(err)
+	}
+	if rotated, err := RotateKeys(); err == nil || rotated != 0 {
+		t.Fatalf("rotated = %d, %v; want an error", rotated, err)
+	}
+}
@@ -1,53 +1,90 @@
-package storage
-
-import (
-	"fmt"
-	"log"
-	"os"
-
-	"gorm.io/driver/postgres"
-	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
-)
-
-var DB *gorm.DB
-
-// InitDB initializes the database connection
-func InitDB() error {
-	dsn := os.Getenv("DATABASE_URL")
-	if dsn == "" {
-		dsn = "host=localhost user=voltrun password=voltrun dbname=voltrun port=5432 sslmode=disable"
-	}
-
-	var err error
-	DB, err = gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
-	})
-	if err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
-	}
-
-	log.Println("✅ Database connected successfully")
-
-	// Auto-migrate schemas
-	if err := AutoMigrate(); err != nil {
-		return fmt.Errorf("failed to migrate database: %w", err)
-	}
-
-	return nil
-}
-
-// AutoMigrate runs database migrations
-func AutoMigrate() error {
-	return DB.AutoMigrate(
-		&User{},
-		&Function{},
-		&Execution{},
-		&APIKey{},
-	)
-}
-
-// GetDB returns the database instance
-func GetDB() *gorm.DB {
-	return DB
-}
+package storage
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/voltrun/backend/internal/tracing"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Database drivers accepted by InitDB
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+var DB *gorm.DB
+
+// InitDB initializes the database connection. For postgres dsn is a
+// connection string; for sqlite it is a file path or ":memory:".
+func InitDB(driver, dsn string) error {
+	config := &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Info),
+	}
+
+	var dialector gorm.Dialector
+	switch driver {
+	case DriverPostgres, "":
+		dialector = postgres.Open(dsn)
+	case DriverSQLite:
+		dialector = sqlite.Open(sqliteDSN(dsn))
+		// SQLite stores timestamps as text, so keep them in one zone to
+		// compare correctly
+		config.NowFunc = func() time.Time { return time.Now().UTC() }
+	default:
+		return fmt.Errorf("unsupported database driver %q", driver)
+	}
+
+	var err error
+	DB, err = gorm.Open(dialector, config)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	if IsSQLite() {
+		// A single connection serializes writers, which SQLite requires,
+		// and keeps an in-memory database alive for the process
+		sqlDB, err := DB.DB()
+		if err != nil {
+			return err
+		}
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+	}
+
+	log.Println("✅ Database connected successfully")
+
+	if err := DB.Use(tracing.GormPlugin{}); err != nil {
+		return fmt.Errorf("failed to register tracing: %w", err)
+	}
+
+	return nil
+}
+
+// IsSQLite reports whether DB is backed by SQLite rather than Postgres
+func IsSQLite() bool {
+	return DB != nil && DB.Dialector.Name() == DriverSQLite
+}
+
+// sqliteDSN enables foreign keys and a busy timeout on every connection
+func sqliteDSN(path string) string {
+	if path == "" {
+		path = "voltrun.db"
+	}
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return path + separator + "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+}
+
+// GetDB returns the database instance
+func GetDB() *gorm.DB {
+	return DB
+}
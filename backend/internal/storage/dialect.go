@@ -0,0 +1,21 @@
+package storage
+
+import "fmt"
+
+// EpochBucket returns an SQL expression for the start of the UTC minute,
+// hour, day or month containing column, in unix seconds. unit must be a
+// constant, never user text.
+func EpochBucket(column, unit string) string {
+	if !IsSQLite() {
+		return fmt.Sprintf("CAST(EXTRACT(EPOCH FROM date_trunc('%s', %s AT TIME ZONE 'UTC')) AS bigint)", unit, column)
+	}
+
+	switch unit {
+	case "minute":
+		return fmt.Sprintf("(CAST(strftime('%%s', %s) AS integer) / 60 * 60)", column)
+	case "hour":
+		return fmt.Sprintf("(CAST(strftime('%%s', %s) AS integer) / 3600 * 3600)", column)
+	default:
+		return fmt.Sprintf("CAST(strftime('%%s', %s, 'start of %s') AS integer)", column, unit)
+	}
+}
@@ -0,0 +1,1200 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NewGormRepos returns repositories backed by db
+func NewGormRepos(db *gorm.DB) *Repos {
+	return &Repos{
+		Users:      &gormUserRepo{db: db},
+		Functions:  &gormFunctionRepo{db: db},
+		Executions: &gormExecutionRepo{db: db},
+		APIKeys:    &gormAPIKeyRepo{db: db},
+		Sessions:   &gormSessionRepo{db: db},
+		Identities: &gormIdentityRepo{db: db},
+
+		Organizations: &gormOrganizationRepo{db: db},
+		Invitations:   &gormInvitationRepo{db: db},
+
+		Secrets:       &gormSecretRepo{db: db},
+		Schedules:     &gormScheduleRepo{db: db},
+		Webhooks:      &gormWebhookRepo{db: db},
+		EventTriggers: &gormEventTriggerRepo{db: db},
+		DeadLetters:   &gormDeadLetterRepo{db: db},
+		Workflows:     &gormWorkflowRepo{db: db},
+		Usage:         &gormUsageRepo{db: db},
+		Archives:      &gormArchiveRepo{db: db},
+		Idempotency:   &gormIdempotencyRepo{db: db},
+	}
+}
+
+type gormUserRepo struct {
+	db *gorm.DB
+}
+
+func (r *gormUserRepo) Create(ctx context.Context, user *User) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		return createOrganization(tx, &Organization{ID: user.ID, Name: "Personal", Personal: true}, user.ID)
+	})
+}
+
+// createOrganization stores an organization and its first owner
+func createOrganization(tx *gorm.DB, organization *Organization, ownerID uuid.UUID) error {
+	if err := tx.Create(organization).Error; err != nil {
+		return err
+	}
+	return tx.Create(&OrganizationMember{
+		OrganizationID: organization.ID,
+		UserID:         ownerID,
+		Role:           RoleOwner,
+	}).Error
+}
+
+func (r *gormUserRepo) Get(ctx context.Context, id uuid.UUID) (*User, error) {
+	var user User
+	if err := r.db.WithContext(ctx).First(&user, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *gormUserRepo) GetByEmail(ctx context.Context, email string) (*User, error) {
+	var user User
+	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+type gormFunctionRepo struct {
+	db *gorm.DB
+}
+
+func (r *gormFunctionRepo) Create(ctx context.Context, function *Function) error {
+	return r.db.WithContext(ctx).Create(function).Error
+}
+
+func (r *gormFunctionRepo) Get(ctx context.Context, id uuid.UUID) (*Function, error) {
+	var function Function
+	if err := r.db.WithContext(ctx).First(&function, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &function, nil
+}
+
+func (r *gormFunctionRepo) GetForOrganization(ctx context.Context, id, organizationID uuid.UUID) (*Function, error) {
+	var function Function
+	if err := r.db.WithContext(ctx).Where("id = ? AND organization_id = ?", id, organizationID).First(&function).Error; err != nil {
+		return nil, err
+	}
+	return &function, nil
+}
+
+func (r *gormFunctionRepo) ListForOrganization(ctx context.Context, organizationID uuid.UUID) ([]Function, error) {
+	var functions []Function
+	if err := r.db.WithContext(ctx).Where("organization_id = ?", organizationID).Order("created_at DESC").Find(&functions).Error; err != nil {
+		return nil, err
+	}
+	return functions, nil
+}
+
+func (r *gormFunctionRepo) CountForOrganization(ctx context.Context, organizationID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Function{}).Where("organization_id = ?", organizationID).Count(&count).Error
+	return count, err
+}
+
+func (r *gormFunctionRepo) Save(ctx context.Context, function *Function) error {
+	return r.db.WithContext(ctx).Save(function).Error
+}
+
+func (r *gormFunctionRepo) Delete(ctx context.Context, function *Function) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, dependent := range []interface{}{
+			&FunctionSecret{}, &Schedule{}, &DeadLetter{}, &EventDeadLetter{}, &IdempotencyKey{},
+		} {
+			if err := tx.Where("function_id = ?", function.ID).Delete(dependent).Error; err != nil {
+				return err
+			}
+		}
+		webhookIDs := tx.Model(&WebhookTrigger{}).Select("id").Where("function_id = ?", function.ID)
+		if err := tx.Where("webhook_id IN (?)", webhookIDs).Delete(&WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		triggerIDs := tx.Model(&EventTrigger{}).Select("id").Where("function_id = ?", function.ID)
+		if err := tx.Where("trigger_id IN (?)", triggerIDs).Delete(&EventMessage{}).Error; err != nil {
+			return err
+		}
+		for _, trigger := range []interface{}{&WebhookTrigger{}, &EventTrigger{}} {
+			if err := tx.Where("function_id = ?", function.ID).Delete(trigger).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(function).Error
+	})
+}
+
+type gormExecutionRepo struct {
+	db *gorm.DB
+}
+
+func (r *gormExecutionRepo) Create(ctx context.Context, execution *Execution) error {
+	return r.db.WithContext(ctx).Create(execution).Error
+}
+
+func (r *gormExecutionRepo) Get(ctx context.Context, id uuid.UUID) (*Execution, error) {
+	var execution Execution
+	if err := r.db.WithContext(ctx).First(&execution, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &execution, nil
+}
+
+func (r *gormExecutionRepo) Save(ctx context.Context, execution *Execution) error {
+	return r.db.WithContext(ctx).Save(execution).Error
+}
+
+func (r *gormExecutionRepo) GetForOrganization(ctx context.Context, id, organizationID uuid.UUID) (*Execution, error) {
+	var execution Execution
+	if err := r.db.WithContext(ctx).Where("id = ? AND organization_id = ?", id, organizationID).Preload("Function").First(&execution).Error; err != nil {
+		return nil, err
+	}
+	return &execution, nil
+}
+
+// executionSummaryColumns leaves out input, output and logs, which are
+// fetched per execution
+var executionSummaryColumns = []string{
+	"id", "organization_id", "user_id", "function_id", "status", "trigger_type",
+	"trigger_source", "error", "error_class", "duration_ms", "memory_used",
+	"cold_start", "attempt", "started_at", "completed_at", "created_at",
+}
+
+func (r *gormExecutionRepo) List(ctx context.Context, filter ExecutionFilter) ([]Execution, error) {
+	query := pageExecutions(r.db.WithContext(ctx).Model(&Execution{}).Select(executionSummaryColumns), filter)
+	if len(filter.Statuses) > 0 {
+		query = query.Where("status IN ?", filter.Statuses)
+	}
+	if len(filter.TriggerTypes) > 0 {
+		query = query.Where("trigger_type IN ?", filter.TriggerTypes)
+	}
+	if filter.MinDurationMS != nil {
+		query = query.Where("duration_ms >= ?", *filter.MinDurationMS)
+	}
+	if filter.MaxDurationMS != nil {
+		query = query.Where("duration_ms <= ?", *filter.MaxDurationMS)
+	}
+	if filter.Search != "" {
+		if r.db.Dialector.Name() == DriverSQLite {
+			query = searchLike(query, filter.Search)
+		} else {
+			// Matches the idx_executions_search expression index
+			query = query.Where("to_tsvector('simple', coalesce(logs, '') || ' ' || coalesce(error, '')) @@ websearch_to_tsquery('simple', ?)", filter.Search)
+		}
+	}
+
+	var executions []Execution
+	if err := query.Preload("Function", func(db *gorm.DB) *gorm.DB {
+		return db.Select("id", "name", "runtime")
+	}).Find(&executions).Error; err != nil {
+		return nil, err
+	}
+	return executions, nil
+}
+
+// pageExecutions applies the filters executions and archived executions
+// share, newest first
+func pageExecutions(query *gorm.DB, filter ExecutionFilter) *gorm.DB {
+	query = query.Where("organization_id = ?", filter.OrganizationID)
+	if filter.FunctionID != nil {
+		query = query.Where("function_id = ?", *filter.FunctionID)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+	if filter.After != nil {
+		query = query.Where("(created_at, id) < (?, ?)", filter.After.CreatedAt, filter.After.ID)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	return query.Order("created_at DESC, id DESC")
+}
+
+// searchLike approximates web search syntax on SQLite, which has no
+// full-text index here
+func searchLike(query *gorm.DB, search string) *gorm.DB {
+	const haystack = "(coalesce(logs, '') || ' ' || coalesce(error, ''))"
+	escape := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	included, excluded := searchTerms(search)
+	for _, term := range included {
+		query = query.Where(haystack+` LIKE ? ESCAPE '\'`, "%"+escape.Replace(term)+"%")
+	}
+	for _, term := range excluded {
+		query = query.Where(haystack+` NOT LIKE ? ESCAPE '\'`, "%"+escape.Replace(term)+"%")
+	}
+	return query
+}
+
+// searchTerms splits web search syntax into the words that must appear
+// and those, prefixed with "-", that must not
+func searchTerms(search string) (included, excluded []string) {
+	for _, term := range strings.Fields(search) {
+		negate := strings.HasPrefix(term, "-") && len(term) > 1
+		term = strings.Trim(strings.TrimPrefix(term, "-"), `"`)
+		if term == "" {
+			continue
+		}
+		if negate {
+			excluded = append(excluded, term)
+		} else {
+			included = append(included, term)
+		}
+	}
+	return included, excluded
+}
+
+func (r *gormExecutionRepo) DeleteForFunction(ctx context.Context, functionID uuid.UUID) error {
+	return r.db.WithContext(ctx).Where("function_id = ?", functionID).Delete(&Execution{}).Error
+}
+
+func (r *gormExecutionRepo) ListPendingRetries(ctx context.Context) ([]Execution, error) {
+	var executions []Execution
+	if err := r.db.WithContext(ctx).Where("status = ? AND next_retry_at IS NOT NULL", "pending").Find(&executions).Error; err != nil {
+		return nil, err
+	}
+	return executions, nil
+}
+
+// ClaimRetry clears next_retry_at atomically, so a retry armed on several
+// replicas is claimed once
+func (r *gormExecutionRepo) ClaimRetry(ctx context.Context, id uuid.UUID) (bool, error) {
+	claim := r.db.WithContext(ctx).Model(&Execution{}).
+		Where("id = ? AND status = ? AND next_retry_at IS NOT NULL", id, "pending").
+		Update("next_retry_at", nil)
+	return claim.RowsAffected > 0, claim.Error
+}
+
+// ClaimPending updates the status conditionally, so an execution adopted by
+// several callers runs once
+func (r *gormExecutionRepo) ClaimPending(ctx context.Context, id uuid.UUID, startedAt time.Time) (bool, error) {
+	claim := r.db.WithContext(ctx).Model(&Execution{}).
+		Where("id = ? AND status = ?", id, "pending").
+		Updates(map[string]interface{}{"status": "running", "started_at": startedAt})
+	return claim.RowsAffected > 0, claim.Error
+}
+
+func (r *gormExecutionRepo) InFlight(ctx context.Context, organizationID uuid.UUID) (*UsageTotal, error) {
+	var total UsageTotal
+	err := r.db.WithContext(ctx).Table("executions").
+		Select("COUNT(*) AS invocations, COALESCE(SUM(functions.memory_mb / 1024.0 * functions.timeout_sec), 0) AS gb_seconds").
+		Joins("JOIN functions ON functions.id = executions.function_id").
+		Where("executions.organization_id = ? AND executions.status IN ?", organizationID, []string{"pending", "running"}).
+		Scan(&total).Error
+	if err != nil {
+		return nil, err
+	}
+	return &total, nil
+}
+
+// statsRow is an ExecutionStats as scanned from the database
+type statsRow struct {
+	PeriodUnix  int64
+	Invocations int64
+	Errors      int64
+	ColdStarts  int64
+	P50MS       float64 `gorm:"column:p50_ms"`
+	P90MS       float64 `gorm:"column:p90_ms"`
+	P99MS       float64 `gorm:"column:p99_ms"`
+	AvgMemoryMB float64
+	MaxMemoryMB int
+}
+
+func (row statsRow) stats() ExecutionStats {
+	return ExecutionStats{
+		Invocations: row.Invocations,
+		Errors:      row.Errors,
+		ColdStarts:  row.ColdStarts,
+		P50MS:       row.P50MS,
+		P90MS:       row.P90MS,
+		P99MS:       row.P99MS,
+		AvgMemoryMB: row.AvgMemoryMB,
+		MaxMemoryMB: row.MaxMemoryMB,
+	}
+}
+
+// statsSelect aggregates executions, falling back to the allocated memory
+func statsSelect() string {
+	percentiles := `COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY duration_ms), 0) AS p50_ms,
+	COALESCE(percentile_cont(0.9) WITHIN GROUP (ORDER BY duration_ms), 0) AS p90_ms,
+	COALESCE(percentile_cont(0.99) WITHIN GROUP (ORDER BY duration_ms), 0) AS p99_ms`
+	if IsSQLite() {
+		// SQLite has no percentile_cont; see fillPercentiles
+		percentiles = "0 AS p50_ms, 0 AS p90_ms, 0 AS p99_ms"
+	}
+
+	return `COUNT(*) AS invocations,
+	COUNT(*) FILTER (WHERE status = 'failed') AS errors,
+	COUNT(*) FILTER (WHERE cold_start) AS cold_starts,
+	` + percentiles + `,
+	COALESCE(AVG(COALESCE(NULLIF(memory_used, 0), @allocated)), 0) AS avg_memory_mb,
+	COALESCE(MAX(COALESCE(NULLIF(memory_used, 0), @allocated)), 0) AS max_memory_mb`
+}
+
+func (r *gormExecutionRepo) Stats(ctx context.Context, function *Function, from, to time.Time, bucket string) (*ExecutionStats, []ExecutionStats, error) {
+	db := r.db.WithContext(ctx)
+	args := map[string]interface{}{
+		"function":  function.ID,
+		"from":      from,
+		"to":        to,
+		"allocated": function.MemoryMB,
+	}
+	// Only finished executions have a meaningful duration and status
+	where := "function_id = @function AND created_at >= @from AND created_at < @to AND status IN ('success', 'failed')"
+
+	var summary statsRow
+	if err := db.Raw("SELECT "+statsSelect()+" FROM executions WHERE "+where, args).
+		Scan(&summary).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var rows []statsRow
+	// bucket is minute or hour, never user text
+	if err := db.Raw("SELECT "+EpochBucket("created_at", bucket)+" AS period_unix, "+statsSelect()+
+		" FROM executions WHERE "+where+" GROUP BY period_unix ORDER BY period_unix", args).
+		Scan(&rows).Error; err != nil {
+		return nil, nil, err
+	}
+
+	if IsSQLite() {
+		if err := fillPercentiles(db, bucket, where, args, &summary, rows); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	total := summary.stats()
+	series := make([]ExecutionStats, len(rows))
+	for i, row := range rows {
+		period := time.Unix(row.PeriodUnix, 0).UTC()
+		series[i] = row.stats()
+		series[i].Period = &period
+	}
+	return &total, series, nil
+}
+
+// fillPercentiles computes duration percentiles in Go for SQLite, which
+// lacks percentile_cont
+func fillPercentiles(db *gorm.DB, bucket, where string, args map[string]interface{}, summary *statsRow, rows []statsRow) error {
+	var samples []struct {
+		PeriodUnix int64
+		DurationMS float64
+	}
+	if err := db.Raw("SELECT "+EpochBucket("created_at", bucket)+" AS period_unix, COALESCE(duration_ms, 0) AS duration_ms"+
+		" FROM executions WHERE "+where+" ORDER BY period_unix, duration_ms", args).
+		Scan(&samples).Error; err != nil {
+		return err
+	}
+
+	all := make([]float64, 0, len(samples))
+	byPeriod := make(map[int64][]float64)
+	for _, sample := range samples {
+		all = append(all, sample.DurationMS)
+		byPeriod[sample.PeriodUnix] = append(byPeriod[sample.PeriodUnix], sample.DurationMS)
+	}
+	sort.Float64s(all)
+
+	summary.P50MS, summary.P90MS, summary.P99MS = percentiles(all)
+	for i := range rows {
+		rows[i].P50MS, rows[i].P90MS, rows[i].P99MS = percentiles(byPeriod[rows[i].PeriodUnix])
+	}
+	return nil
+}
+
+// percentiles returns the 50th, 90th and 99th percentiles of sorted
+// durations
+func percentiles(sorted []float64) (float64, float64, float64) {
+	return percentile(sorted, 0.5), percentile(sorted, 0.9), percentile(sorted, 0.99)
+}
+
+// percentile interpolates between the closest ranks like percentile_cont
+func percentile(sorted []float64, fraction float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	position := fraction * float64(len(sorted)-1)
+	lower := int(position)
+	if lower+1 >= len(sorted) {
+		return sorted[lower]
+	}
+	return sorted[lower] + (position-float64(lower))*(sorted[lower+1]-sorted[lower])
+}
+
+type gormAPIKeyRepo struct {
+	db *gorm.DB
+}
+
+func (r *gormAPIKeyRepo) Create(ctx context.Context, key *APIKey) error {
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+func (r *gormAPIKeyRepo) GetForOrganization(ctx context.Context, id, organizationID uuid.UUID) (*APIKey, error) {
+	var key APIKey
+	if err := r.db.WithContext(ctx).Where("id = ? AND organization_id = ?", id, organizationID).First(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *gormAPIKeyRepo) ListForOrganization(ctx context.Context, organizationID uuid.UUID) ([]APIKey, error) {
+	var keys []APIKey
+	if err := r.db.WithContext(ctx).Where("organization_id = ?", organizationID).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *gormAPIKeyRepo) Delete(ctx context.Context, key *APIKey) error {
+	return r.db.WithContext(ctx).Delete(key).Error
+}
+
+type gormSessionRepo struct {
+	db *gorm.DB
+}
+
+// Create also drops the user's expired sessions, whose rotated tokens are
+// no longer needed for reuse detection
+func (r *gormSessionRepo) Create(ctx context.Context, session *Session, token *RefreshToken) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		expired := tx.Model(&Session{}).Select("id").Where("user_id = ? AND expires_at < ?", session.UserID, time.Now())
+		if err := tx.Where("session_id IN (?)", expired).Delete(&RefreshToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ? AND expires_at < ?", session.UserID, time.Now()).Delete(&Session{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Create(session).Error; err != nil {
+			return err
+		}
+		token.SessionID = session.ID
+		return tx.Create(token).Error
+	})
+}
+
+func (r *gormSessionRepo) Rotate(ctx context.Context, tokenHash string, next *RefreshToken, expiresAt time.Time) (*Session, error) {
+	var session Session
+	reused := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var token RefreshToken
+		if err := tx.Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := tx.Where("id = ? AND revoked_at IS NULL AND expires_at > ?", token.SessionID, now).
+			First(&session).Error; err != nil {
+			return err
+		}
+
+		// The used_at guard makes concurrent refreshes with one token
+		// count as reuse rather than both succeeding
+		claim := tx.Model(&RefreshToken{}).Where("id = ? AND used_at IS NULL", token.ID).Update("used_at", now)
+		if claim.Error != nil {
+			return claim.Error
+		}
+		if claim.RowsAffected == 0 {
+			reused = true
+			return tx.Model(&session).Update("revoked_at", now).Error
+		}
+
+		next.SessionID = session.ID
+		if err := tx.Create(next).Error; err != nil {
+			return err
+		}
+		session.ExpiresAt = expiresAt
+		session.LastUsedAt = now
+		return tx.Model(&session).Updates(map[string]interface{}{
+			"expires_at":   expiresAt,
+			"last_used_at": now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, ErrRefreshTokenReused
+	}
+	return &session, nil
+}
+
+func (r *gormSessionRepo) ListActiveForUser(ctx context.Context, userID uuid.UUID) ([]Session, error) {
+	var sessions []Session
+	if err := r.db.WithContext(ctx).Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("created_at DESC").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (r *gormSessionRepo) Revoke(ctx context.Context, id, userID uuid.UUID) error {
+	result := r.db.WithContext(ctx).Model(&Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL AND expires_at > ?", id, userID, time.Now()).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *gormSessionRepo) RevokeAllForUser(ctx context.Context, userID, except uuid.UUID) (int64, error) {
+	result := r.db.WithContext(ctx).Model(&Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL AND expires_at > ?", userID, except, time.Now()).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+type gormIdentityRepo struct {
+	db *gorm.DB
+}
+
+func (r *gormIdentityRepo) Create(ctx context.Context, identity *UserIdentity) error {
+	return r.db.WithContext(ctx).Create(identity).Error
+}
+
+func (r *gormIdentityRepo) Get(ctx context.Context, issuer, subject string) (*UserIdentity, error) {
+	var identity UserIdentity
+	if err := r.db.WithContext(ctx).Where("issuer = ? AND subject = ?", issuer, subject).First(&identity).Error; err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+type gormOrganizationRepo struct {
+	db *gorm.DB
+}
+
+func (r *gormOrganizationRepo) Create(ctx context.Context, organization *Organization, ownerID uuid.UUID) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return createOrganization(tx, organization, ownerID)
+	})
+}
+
+func (r *gormOrganizationRepo) Get(ctx context.Context, id uuid.UUID) (*Organization, error) {
+	var organization Organization
+	if err := r.db.WithContext(ctx).First(&organization, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &organization, nil
+}
+
+func (r *gormOrganizationRepo) Save(ctx context.Context, organization *Organization) error {
+	return r.db.WithContext(ctx).Save(organization).Error
+}
+
+func (r *gormOrganizationRepo) Delete(ctx context.Context, organization *Organization) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, owned := range []interface{}{&Function{}, &Workflow{}} {
+			var count int64
+			if err := tx.Model(owned).Where("organization_id = ?", organization.ID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return ErrOrganizationNotEmpty
+			}
+		}
+		for _, dependent := range []interface{}{&APIKey{}, &OrganizationInvitation{}, &OrganizationMember{}} {
+			if err := tx.Where("organization_id = ?", organization.ID).Delete(dependent).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(organization).Error
+	})
+}
+
+func (r *gormOrganizationRepo) ListForUser(ctx context.Context, userID uuid.UUID) ([]OrganizationMember, error) {
+	var members []OrganizationMember
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Preload("Organization").
+		Order("created_at").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (r *gormOrganizationRepo) GetMember(ctx context.Context, organizationID, userID uuid.UUID) (*OrganizationMember, error) {
+	var member OrganizationMember
+	if err := r.db.WithContext(ctx).Where("organization_id = ? AND user_id = ?", organizationID, userID).
+		First(&member).Error; err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (r *gormOrganizationRepo) ListMembers(ctx context.Context, organizationID uuid.UUID) ([]OrganizationMember, error) {
+	var members []OrganizationMember
+	if err := r.db.WithContext(ctx).Where("organization_id = ?", organizationID).Preload("User").
+		Order("created_at").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (r *gormOrganizationRepo) SaveMember(ctx context.Context, member *OrganizationMember) error {
+	return r.db.WithContext(ctx).Omit("User", "Organization").Save(member).Error
+}
+
+func (r *gormOrganizationRepo) RemoveMember(ctx context.Context, member *OrganizationMember) error {
+	return r.db.WithContext(ctx).Delete(member).Error
+}
+
+func (r *gormOrganizationRepo) CountOwners(ctx context.Context, organizationID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&OrganizationMember{}).
+		Where("organization_id = ? AND role = ?", organizationID, RoleOwner).Count(&count).Error
+	return count, err
+}
+
+type gormInvitationRepo struct {
+	db *gorm.DB
+}
+
+func (r *gormInvitationRepo) Create(ctx context.Context, invitation *OrganizationInvitation) error {
+	return r.db.WithContext(ctx).Create(invitation).Error
+}
+
+func (r *gormInvitationRepo) ListPending(ctx context.Context, organizationID uuid.UUID) ([]OrganizationInvitation, error) {
+	var invitations []OrganizationInvitation
+	if err := r.db.WithContext(ctx).
+		Where("organization_id = ? AND accepted_at IS NULL AND expires_at > ?", organizationID, time.Now()).
+		Order("created_at DESC").Find(&invitations).Error; err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+func (r *gormInvitationRepo) Delete(ctx context.Context, id, organizationID uuid.UUID) error {
+	result := r.db.WithContext(ctx).
+		Where("id = ? AND organization_id = ? AND accepted_at IS NULL AND expires_at > ?", id, organizationID, time.Now()).
+		Delete(&OrganizationInvitation{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *gormInvitationRepo) Accept(ctx context.Context, tokenHash string, user *User) (*OrganizationMember, error) {
+	var member OrganizationMember
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var invitation OrganizationInvitation
+		now := time.Now()
+		if err := tx.Where("token_hash = ? AND accepted_at IS NULL AND expires_at > ?", tokenHash, now).
+			First(&invitation).Error; err != nil {
+			return err
+		}
+		if !strings.EqualFold(invitation.Email, user.Email) {
+			return ErrInvitationEmailMismatch
+		}
+		if err := tx.Where("organization_id = ? AND user_id = ?", invitation.OrganizationID, user.ID).
+			First(&OrganizationMember{}).Error; err == nil {
+			return ErrAlreadyMember
+		} else if !errors.Is(err, ErrNotFound) {
+			return err
+		}
+
+		// The accepted_at guard makes concurrent accepts of one token
+		// add a single member
+		claim := tx.Model(&invitation).Where("accepted_at IS NULL").Update("accepted_at", now)
+		if claim.Error != nil {
+			return claim.Error
+		}
+		if claim.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		member = OrganizationMember{
+			OrganizationID: invitation.OrganizationID,
+			UserID:         user.ID,
+			Role:           invitation.Role,
+		}
+		return tx.Create(&member).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+type gormSecretRepo struct {
+	db *gorm.DB
+}
+
+func (r *gormSecretRepo) ListForFunction(ctx context.Context, functionID uuid.UUID) ([]FunctionSecret, error) {
+	var secrets []FunctionSecret
+	if err := r.db.WithContext(ctx).Where("function_id = ?", functionID).Order("name").Find(&secrets).Error; err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+func (r *gormSecretRepo) Put(ctx context.Context, secret *FunctionSecret) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing FunctionSecret
+		err := tx.Where("function_id = ? AND name = ?", secret.FunctionID, secret.Name).First(&existing).Error
+		if err == nil {
+			secret.ID = existing.ID
+			secret.CreatedAt = existing.CreatedAt
+		} else if !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		return tx.Save(secret).Error
+	})
+}
+
+func (r *gormSecretRepo) Delete(ctx context.Context, functionID uuid.UUID, name string) error {
+	result := r.db.WithContext(ctx).Where("function_id = ? AND name = ?", functionID, name).Delete(&FunctionSecret{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type gormScheduleRepo struct {
+	db *gorm.DB
+}
+
+func (r *gormScheduleRepo) Create(ctx context.Context, schedule *Schedule) error {
+	return r.db.WithContext(ctx).Create(schedule).Error
+}
+
+func (r *gormScheduleRepo) GetForFunction(ctx context.Context, id, functionID uuid.UUID) (*Schedule, error) {
+	var schedule Schedule
+	if err := r.db.WithContext(ctx).Where("id = ? AND function_id = ?", id, functionID).First(&schedule).Error; err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (r *gormScheduleRepo) ListForFunction(ctx context.Context, functionID uuid.UUID) ([]Schedule, error) {
+	var schedules []Schedule
+	if err := r.db.WithContext(ctx).Where("function_id = ?", functionID).Order("created_at DESC").Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (r *gormScheduleRepo) Save(ctx context.Context, schedule *Schedule) error {
+	return r.db.WithContext(ctx).Save(schedule).Error
+}
+
+func (r *gormScheduleRepo) Delete(ctx context.Context, id, functionID uuid.UUID) error {
+	result := r.db.WithContext(ctx).Where("id = ? AND function_id = ?", id, functionID).Delete(&Schedule{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type gormWebhookRepo struct {
+	db *gorm.DB
+}
+
+func (r *gormWebhookRepo) Create(ctx context.Context, webhook *WebhookTrigger) error {
+	return r.db.WithContext(ctx).Create(webhook).Error
+}
+
+func (r *gormWebhookRepo) Get(ctx context.Context, id uuid.UUID) (*WebhookTrigger, error) {
+	var webhook WebhookTrigger
+	if err := r.db.WithContext(ctx).First(&webhook, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (r *gormWebhookRepo) GetForFunction(ctx context.Context, id, functionID uuid.UUID) (*WebhookTrigger, error) {
+	var webhook WebhookTrigger
+	if err := r.db.WithContext(ctx).Where("id = ? AND function_id = ?", id, functionID).First(&webhook).Error; err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (r *gormWebhookRepo) ListForFunction(ctx context.Context, functionID uuid.UUID) ([]WebhookTrigger, error) {
+	var webhooks []WebhookTrigger
+	if err := r.db.WithContext(ctx).Where("function_id = ?", functionID).Order("created_at DESC").Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (r *gormWebhookRepo) Delete(ctx context.Context, webhook *WebhookTrigger) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", webhook.ID).Delete(&WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(webhook).Error
+	})
+}
+
+// ClaimDelivery relies on the primary key, so concurrent deliveries with
+// one ID are claimed once
+func (r *gormWebhookRepo) ClaimDelivery(ctx context.Context, webhookID uuid.UUID, deliveryID string, execution *Execution, beforeCreate func()) (bool, error) {
+	claimed := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		claim := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&WebhookDelivery{
+			WebhookID:  webhookID,
+			DeliveryID: deliveryID,
+		})
+		if claim.Error != nil || claim.RowsAffected == 0 {
+			return claim.Error
+		}
+		claimed = true
+		beforeCreate()
+		return tx.Create(execution).Error
+	})
+	if err != nil {
+		return false, err
+	}
+	return claimed, nil
+}
+
+type gormEventTriggerRepo struct {
+	db *gorm.DB
+}
+
+func (r *gormEventTriggerRepo) Create(ctx context.Context, trigger *EventTrigger) error {
+	return r.db.WithContext(ctx).Create(trigger).Error
+}
+
+func (r *gormEventTriggerRepo) GetForFunction(ctx context.Context, id, functionID, organizationID uuid.UUID) (*EventTrigger, error) {
+	var trigger EventTrigger
+	if err := r.db.WithContext(ctx).Where("id = ? AND function_id = ? AND organization_id = ?", id, functionID, organizationID).
+		First(&trigger).Error; err != nil {
+		return nil, err
+	}
+	return &trigger, nil
+}
+
+func (r *gormEventTriggerRepo) ListForFunction(ctx context.Context, functionID, organizationID uuid.UUID) ([]EventTrigger, error) {
+	var triggers []EventTrigger
+	if err := r.db.WithContext(ctx).Where("function_id = ? AND organization_id = ?", functionID, organizationID).
+		Order("created_at DESC").Find(&triggers).Error; err != nil {
+		return nil, err
+	}
+	return triggers, nil
+}
+
+func (r *gormEventTriggerRepo) Save(ctx context.Context, trigger *EventTrigger) error {
+	return r.db.WithContext(ctx).Save(trigger).Error
+}
+
+func (r *gormEventTriggerRepo) Delete(ctx context.Context, trigger *EventTrigger) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, dependent := range []interface{}{&EventMessage{}, &EventDeadLetter{}} {
+			if err := tx.Where("trigger_id = ?", trigger.ID).Delete(dependent).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(trigger).Error
+	})
+}
+
+func (r *gormEventTriggerRepo) GetDeadLetter(ctx context.Context, id, triggerID uuid.UUID) (*EventDeadLetter, error) {
+	var deadLetter EventDeadLetter
+	if err := r.db.WithContext(ctx).Where("id = ? AND trigger_id = ?", id, triggerID).First(&deadLetter).Error; err != nil {
+		return nil, err
+	}
+	return &deadLetter, nil
+}
+
+func (r *gormEventTriggerRepo) ListDeadLetters(ctx context.Context, triggerID uuid.UUID) ([]EventDeadLetter, error) {
+	var deadLetters []EventDeadLetter
+	if err := r.db.WithContext(ctx).Where("trigger_id = ?", triggerID).Order("created_at DESC").Find(&deadLetters).Error; err != nil {
+		return nil, err
+	}
+	return deadLetters, nil
+}
+
+func (r *gormEventTriggerRepo) DeleteDeadLetter(ctx context.Context, id, triggerID uuid.UUID) error {
+	result := r.db.WithContext(ctx).Where("id = ? AND trigger_id = ?", id, triggerID).Delete(&EventDeadLetter{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *gormEventTriggerRepo) Redrive(ctx context.Context, deadLetter *EventDeadLetter) (*EventMessage, error) {
+	message := EventMessage{
+		TriggerID: deadLetter.TriggerID,
+		Topic:     deadLetter.Topic,
+		Payload:   deadLetter.Payload,
+		VisibleAt: time.Now(),
+	}
+	if err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&message).Error; err != nil {
+			return err
+		}
+		return tx.Delete(deadLetter).Error
+	}); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+type gormDeadLetterRepo struct {
+	db *gorm.DB
+}
+
+func (r *gormDeadLetterRepo) Create(ctx context.Context, deadLetter *DeadLetter) error {
+	return r.db.WithContext(ctx).Create(deadLetter).Error
+}
+
+func (r *gormDeadLetterRepo) GetForFunction(ctx context.Context, id, functionID uuid.UUID) (*DeadLetter, error) {
+	var deadLetter DeadLetter
+	if err := r.db.WithContext(ctx).Where("id = ? AND function_id = ?", id, functionID).First(&deadLetter).Error; err != nil {
+		return nil, err
+	}
+	return &deadLetter, nil
+}
+
+func (r *gormDeadLetterRepo) ListForFunction(ctx context.Context, functionID uuid.UUID) ([]DeadLetter, error) {
+	var deadLetters []DeadLetter
+	if err := r.db.WithContext(ctx).Where("function_id = ?", functionID).Order("created_at DESC").Find(&deadLetters).Error; err != nil {
+		return nil, err
+	}
+	return deadLetters, nil
+}
+
+func (r *gormDeadLetterRepo) MarkReplayed(ctx context.Context, id, executionID uuid.UUID) error {
+	return r.db.WithContext(ctx).Model(&DeadLetter{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"replayed_at":         time.Now(),
+		"replay_execution_id": executionID,
+	}).Error
+}
+
+func (r *gormDeadLetterRepo) Delete(ctx context.Context, deadLetter *DeadLetter) error {
+	return r.db.WithContext(ctx).Delete(deadLetter).Error
+}
+
+type gormWorkflowRepo struct {
+	db *gorm.DB
+}
+
+func (r *gormWorkflowRepo) Create(ctx context.Context, workflow *Workflow) error {
+	return r.db.WithContext(ctx).Create(workflow).Error
+}
+
+func (r *gormWorkflowRepo) GetForOrganization(ctx context.Context, id, organizationID uuid.UUID) (*Workflow, error) {
+	var workflow Workflow
+	if err := r.db.WithContext(ctx).Where("id = ? AND organization_id = ?", id, organizationID).First(&workflow).Error; err != nil {
+		return nil, err
+	}
+	return &workflow, nil
+}
+
+func (r *gormWorkflowRepo) ListForOrganization(ctx context.Context, organizationID uuid.UUID) ([]Workflow, error) {
+	var workflows []Workflow
+	if err := r.db.WithContext(ctx).Where("organization_id = ?", organizationID).Order("created_at DESC").Find(&workflows).Error; err != nil {
+		return nil, err
+	}
+	return workflows, nil
+}
+
+func (r *gormWorkflowRepo) Save(ctx context.Context, workflow *Workflow) error {
+	return r.db.WithContext(ctx).Save(workflow).Error
+}
+
+func (r *gormWorkflowRepo) Delete(ctx context.Context, workflow *Workflow) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		runIDs := tx.Model(&WorkflowRun{}).Select("id").Where("workflow_id = ?", workflow.ID)
+		if err := tx.Where("run_id IN (?)", runIDs).Delete(&WorkflowStep{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("workflow_id = ?", workflow.ID).Delete(&WorkflowRun{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(workflow).Error
+	})
+}
+
+func (r *gormWorkflowRepo) ListRuns(ctx context.Context, workflowID, organizationID uuid.UUID, limit int) ([]WorkflowRun, error) {
+	query := r.db.WithContext(ctx).Where("workflow_id = ? AND organization_id = ?", workflowID, organizationID).Order("started_at DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	var runs []WorkflowRun
+	if err := query.Find(&runs).Error; err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+func (r *gormWorkflowRepo) GetRun(ctx context.Context, id, workflowID, organizationID uuid.UUID) (*WorkflowRun, error) {
+	var run WorkflowRun
+	if err := r.db.WithContext(ctx).Where("id = ? AND workflow_id = ? AND organization_id = ?", id, workflowID, organizationID).
+		First(&run).Error; err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (r *gormWorkflowRepo) ListSteps(ctx context.Context, runID uuid.UUID) ([]WorkflowStep, error) {
+	var steps []WorkflowStep
+	if err := r.db.WithContext(ctx).Where("run_id = ?", runID).Order("started_at").Find(&steps).Error; err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+type gormUsageRepo struct {
+	db *gorm.DB
+}
+
+// Add upserts, so concurrent executions never lose each other's counters
+func (r *gormUsageRepo) Add(ctx context.Context, rollup *UsageRollup) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "organization_id"}, {Name: "function_id"}, {Name: "hour"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"invocations":  gorm.Expr("usage_rollups.invocations + ?", rollup.Invocations),
+			"errors":       gorm.Expr("usage_rollups.errors + ?", rollup.Errors),
+			"duration_ms":  gorm.Expr("usage_rollups.duration_ms + ?", rollup.DurationMS),
+			"gb_seconds":   gorm.Expr("usage_rollups.gb_seconds + ?", rollup.GBSeconds),
+			"egress_bytes": gorm.Expr("usage_rollups.egress_bytes + ?", rollup.EgressBytes),
+			"updated_at":   time.Now(),
+		}),
+	}).Create(rollup).Error
+}
+
+func (r *gormUsageRepo) Sum(ctx context.Context, organizationID uuid.UUID, from, to time.Time, byFunction bool, period string) ([]UsageTotal, error) {
+	selects := []string{
+		"COALESCE(SUM(invocations), 0) AS invocations",
+		"COALESCE(SUM(errors), 0) AS errors",
+		"COALESCE(SUM(duration_ms), 0) AS duration_ms",
+		"COALESCE(SUM(gb_seconds), 0) AS gb_seconds",
+		"COALESCE(SUM(egress_bytes), 0) AS egress_bytes",
+	}
+	var groups, orders []string
+	if byFunction {
+		selects = append(selects, "function_id")
+		groups = append(groups, "function_id")
+	}
+	if period != "" {
+		// period is hour, day or month, never user text
+		selects = append(selects, EpochBucket("hour", period)+" AS period_unix")
+		groups = append(groups, "period_unix")
+		orders = append(orders, "period_unix")
+	}
+	if byFunction {
+		orders = append(orders, "function_id")
+	}
+
+	query := r.db.WithContext(ctx).Model(&UsageRollup{}).
+		Select(strings.Join(selects, ", ")).
+		Where("organization_id = ? AND hour >= ? AND hour < ?", organizationID, from, to)
+	if len(groups) > 0 {
+		query = query.Group(strings.Join(groups, ", "))
+	}
+	if len(orders) > 0 {
+		query = query.Order(strings.Join(orders, ", "))
+	}
+
+	var rows []struct {
+		UsageTotal
+		PeriodUnix *int64
+	}
+	if err := query.Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	totals := make([]UsageTotal, len(rows))
+	for i, row := range rows {
+		totals[i] = row.UsageTotal
+		if row.PeriodUnix != nil {
+			start := time.Unix(*row.PeriodUnix, 0).UTC()
+			totals[i].Period = &start
+		}
+	}
+	return totals, nil
+}
+
+type gormArchiveRepo struct {
+	db *gorm.DB
+}
+
+func (r *gormArchiveRepo) Create(ctx context.Context, archived *ArchivedExecution) error {
+	return r.db.WithContext(ctx).Create(archived).Error
+}
+
+func (r *gormArchiveRepo) GetForOrganization(ctx context.Context, id, organizationID uuid.UUID) (*ArchivedExecution, error) {
+	var archived ArchivedExecution
+	if err := r.db.WithContext(ctx).Where("id = ? AND organization_id = ?", id, organizationID).First(&archived).Error; err != nil {
+		return nil, err
+	}
+	return &archived, nil
+}
+
+func (r *gormArchiveRepo) List(ctx context.Context, filter ExecutionFilter) ([]ArchivedExecution, error) {
+	var archived []ArchivedExecution
+	if err := pageExecutions(r.db.WithContext(ctx), filter).Find(&archived).Error; err != nil {
+		return nil, err
+	}
+	return archived, nil
+}
+
+type gormIdempotencyRepo struct {
+	db *gorm.DB
+}
+
+func (r *gormIdempotencyRepo) Claim(ctx context.Context, key *IdempotencyKey, execution *Execution, beforeCreate func()) (*IdempotencyKey, error) {
+	var existing *IdempotencyKey
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Expired keys may be reused
+		if err := tx.Where("organization_id = ? AND user_id = ? AND expires_at < ?", key.OrganizationID, key.UserID, time.Now()).
+			Delete(&IdempotencyKey{}).Error; err != nil {
+			return err
+		}
+
+		claim := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+		if claim.Error != nil {
+			return claim.Error
+		}
+		if claim.RowsAffected == 1 {
+			beforeCreate()
+			return tx.Create(execution).Error
+		}
+
+		existing = &IdempotencyKey{}
+		return tx.Where("organization_id = ? AND user_id = ? AND function_id = ? AND key = ?",
+			key.OrganizationID, key.UserID, key.FunctionID, key.Key).
+			First(existing).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
@@ -0,0 +1,1602 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// memoryStore holds the rows shared by the in-memory repositories. Rows
+// are copied in and out, so callers never alias stored values.
+type memoryStore struct {
+	mu         sync.Mutex
+	users      map[uuid.UUID]User
+	functions  map[uuid.UUID]Function
+	executions map[uuid.UUID]Execution
+	apiKeys    map[uuid.UUID]APIKey
+	sessions   map[uuid.UUID]Session
+	tokens     map[string]RefreshToken    // by hash
+	identities map[[2]string]UserIdentity // by issuer and subject
+
+	organizations map[uuid.UUID]Organization
+	members       map[[2]uuid.UUID]OrganizationMember // by organization and user
+	invitations   map[uuid.UUID]OrganizationInvitation
+
+	secrets          map[uuid.UUID]FunctionSecret
+	schedules        map[uuid.UUID]Schedule
+	webhooks         map[uuid.UUID]WebhookTrigger
+	deliveries       map[[2]string]WebhookDelivery // by webhook and delivery ID
+	eventTriggers    map[uuid.UUID]EventTrigger
+	eventMessages    map[uuid.UUID]EventMessage
+	eventDeadLetters map[uuid.UUID]EventDeadLetter
+	deadLetters      map[uuid.UUID]DeadLetter
+	workflows        map[uuid.UUID]Workflow
+	workflowRuns     map[uuid.UUID]WorkflowRun
+	workflowSteps    map[uuid.UUID]WorkflowStep
+	usage            map[uuid.UUID]UsageRollup
+	archives         map[uuid.UUID]ArchivedExecution
+	idempotencyKeys  map[uuid.UUID]IdempotencyKey
+}
+
+// NewMemoryRepos returns process-local repositories for tests and tools
+// that run without a database. Only the repository methods are
+// supported.
+func NewMemoryRepos() *Repos {
+	store := &memoryStore{
+		users:      make(map[uuid.UUID]User),
+		functions:  make(map[uuid.UUID]Function),
+		executions: make(map[uuid.UUID]Execution),
+		apiKeys:    make(map[uuid.UUID]APIKey),
+		sessions:   make(map[uuid.UUID]Session),
+		tokens:     make(map[string]RefreshToken),
+		identities: make(map[[2]string]UserIdentity),
+
+		organizations: make(map[uuid.UUID]Organization),
+		members:       make(map[[2]uuid.UUID]OrganizationMember),
+		invitations:   make(map[uuid.UUID]OrganizationInvitation),
+
+		secrets:          make(map[uuid.UUID]FunctionSecret),
+		schedules:        make(map[uuid.UUID]Schedule),
+		webhooks:         make(map[uuid.UUID]WebhookTrigger),
+		deliveries:       make(map[[2]string]WebhookDelivery),
+		eventTriggers:    make(map[uuid.UUID]EventTrigger),
+		eventMessages:    make(map[uuid.UUID]EventMessage),
+		eventDeadLetters: make(map[uuid.UUID]EventDeadLetter),
+		deadLetters:      make(map[uuid.UUID]DeadLetter),
+		workflows:        make(map[uuid.UUID]Workflow),
+		workflowRuns:     make(map[uuid.UUID]WorkflowRun),
+		workflowSteps:    make(map[uuid.UUID]WorkflowStep),
+		usage:            make(map[uuid.UUID]UsageRollup),
+		archives:         make(map[uuid.UUID]ArchivedExecution),
+		idempotencyKeys:  make(map[uuid.UUID]IdempotencyKey),
+	}
+	return &Repos{
+		Users:      &memoryUserRepo{store},
+		Functions:  &memoryFunctionRepo{store},
+		Executions: &memoryExecutionRepo{store},
+		APIKeys:    &memoryAPIKeyRepo{store},
+		Sessions:   &memorySessionRepo{store},
+		Identities: &memoryIdentityRepo{store},
+
+		Organizations: &memoryOrganizationRepo{store},
+		Invitations:   &memoryInvitationRepo{store},
+
+		Secrets:       &memorySecretRepo{store},
+		Schedules:     &memoryScheduleRepo{store},
+		Webhooks:      &memoryWebhookRepo{store},
+		EventTriggers: &memoryEventTriggerRepo{store},
+		DeadLetters:   &memoryDeadLetterRepo{store},
+		Workflows:     &memoryWorkflowRepo{store},
+		Usage:         &memoryUsageRepo{store},
+		Archives:      &memoryArchiveRepo{store},
+		Idempotency:   &memoryIdempotencyRepo{store},
+	}
+}
+
+// stamp fills the ID and timestamps GORM would set on create or save
+func stamp(id *uuid.UUID, createdAt, updatedAt *time.Time) {
+	now := time.Now()
+	if *id == uuid.Nil {
+		*id = uuid.New()
+	}
+	if createdAt != nil && createdAt.IsZero() {
+		*createdAt = now
+	}
+	if updatedAt != nil {
+		*updatedAt = now
+	}
+}
+
+type memoryUserRepo struct {
+	*memoryStore
+}
+
+func (r *memoryUserRepo) Create(ctx context.Context, user *User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.users {
+		if existing.Email == user.Email {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+	stamp(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	r.users[user.ID] = *user
+	r.createOrganization(&Organization{ID: user.ID, Name: "Personal", Personal: true}, user.ID)
+	return nil
+}
+
+// createOrganization stores an organization and its first owner; the
+// caller holds the lock
+func (s *memoryStore) createOrganization(organization *Organization, ownerID uuid.UUID) {
+	stamp(&organization.ID, &organization.CreatedAt, &organization.UpdatedAt)
+	if organization.Plan == "" {
+		organization.Plan = "free" // the column default
+	}
+	s.organizations[organization.ID] = *organization
+	member := OrganizationMember{OrganizationID: organization.ID, UserID: ownerID, Role: RoleOwner}
+	stamp(&member.ID, &member.CreatedAt, &member.UpdatedAt)
+	s.members[[2]uuid.UUID{organization.ID, ownerID}] = member
+}
+
+func (r *memoryUserRepo) Get(ctx context.Context, id uuid.UUID) (*User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (r *memoryUserRepo) GetByEmail(ctx context.Context, email string) (*User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+type memoryFunctionRepo struct {
+	*memoryStore
+}
+
+func (r *memoryFunctionRepo) Create(ctx context.Context, function *Function) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&function.ID, &function.CreatedAt, &function.UpdatedAt)
+	r.functions[function.ID] = *function
+	return nil
+}
+
+func (r *memoryFunctionRepo) Get(ctx context.Context, id uuid.UUID) (*Function, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	function, ok := r.functions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &function, nil
+}
+
+func (r *memoryFunctionRepo) GetForOrganization(ctx context.Context, id, organizationID uuid.UUID) (*Function, error) {
+	function, err := r.Get(ctx, id)
+	if err != nil || function.OrganizationID != organizationID {
+		return nil, ErrNotFound
+	}
+	return function, nil
+}
+
+func (r *memoryFunctionRepo) ListForOrganization(ctx context.Context, organizationID uuid.UUID) ([]Function, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	functions := []Function{}
+	for _, function := range r.functions {
+		if function.OrganizationID == organizationID {
+			functions = append(functions, function)
+		}
+	}
+	sort.Slice(functions, func(i, j int) bool {
+		return functions[i].CreatedAt.After(functions[j].CreatedAt)
+	})
+	return functions, nil
+}
+
+func (r *memoryFunctionRepo) CountForOrganization(ctx context.Context, organizationID uuid.UUID) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var count int64
+	for _, function := range r.functions {
+		if function.OrganizationID == organizationID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryFunctionRepo) Save(ctx context.Context, function *Function) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&function.ID, &function.CreatedAt, &function.UpdatedAt)
+	r.functions[function.ID] = *function
+	return nil
+}
+
+func (r *memoryFunctionRepo) Delete(ctx context.Context, function *Function) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, secret := range r.secrets {
+		if secret.FunctionID == function.ID {
+			delete(r.secrets, id)
+		}
+	}
+	for id, schedule := range r.schedules {
+		if schedule.FunctionID == function.ID {
+			delete(r.schedules, id)
+		}
+	}
+	for id, deadLetter := range r.deadLetters {
+		if deadLetter.FunctionID == function.ID {
+			delete(r.deadLetters, id)
+		}
+	}
+	for id, key := range r.idempotencyKeys {
+		if key.FunctionID == function.ID {
+			delete(r.idempotencyKeys, id)
+		}
+	}
+	for id, webhook := range r.webhooks {
+		if webhook.FunctionID == function.ID {
+			r.deleteWebhook(id)
+		}
+	}
+	for id, trigger := range r.eventTriggers {
+		if trigger.FunctionID == function.ID {
+			r.deleteEventTrigger(id)
+		}
+	}
+	delete(r.functions, function.ID)
+	return nil
+}
+
+type memoryExecutionRepo struct {
+	*memoryStore
+}
+
+func (r *memoryExecutionRepo) Create(ctx context.Context, execution *Execution) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&execution.ID, &execution.CreatedAt, nil)
+	r.executions[execution.ID] = *execution
+	return nil
+}
+
+func (r *memoryExecutionRepo) Get(ctx context.Context, id uuid.UUID) (*Execution, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	execution, ok := r.executions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &execution, nil
+}
+
+func (r *memoryExecutionRepo) Save(ctx context.Context, execution *Execution) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&execution.ID, &execution.CreatedAt, nil)
+	r.executions[execution.ID] = *execution
+	return nil
+}
+
+func (r *memoryExecutionRepo) GetForOrganization(ctx context.Context, id, organizationID uuid.UUID) (*Execution, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	execution, ok := r.executions[id]
+	if !ok || execution.OrganizationID != organizationID {
+		return nil, ErrNotFound
+	}
+	execution.Function = r.functions[execution.FunctionID]
+	return &execution, nil
+}
+
+func (r *memoryExecutionRepo) List(ctx context.Context, filter ExecutionFilter) ([]Execution, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	included, excluded := searchTerms(filter.Search)
+	executions := []Execution{}
+	for _, execution := range r.executions {
+		if !inPage(filter, execution.OrganizationID, execution.FunctionID, execution.CreatedAt, execution.ID) ||
+			(len(filter.Statuses) > 0 && !contains(filter.Statuses, execution.Status)) ||
+			(len(filter.TriggerTypes) > 0 && !contains(filter.TriggerTypes, execution.TriggerType)) ||
+			(filter.MinDurationMS != nil && execution.DurationMS < *filter.MinDurationMS) ||
+			(filter.MaxDurationMS != nil && execution.DurationMS > *filter.MaxDurationMS) ||
+			!matchesSearch(execution.Logs+" "+execution.Error, included, excluded) {
+			continue
+		}
+		function := r.functions[execution.FunctionID]
+		execution.Function = Function{ID: function.ID, Name: function.Name, Runtime: function.Runtime}
+		execution.Input, execution.Output, execution.Logs = nil, nil, ""
+		executions = append(executions, execution)
+	}
+	sort.Slice(executions, func(i, j int) bool {
+		return newerThan(executions[i].CreatedAt, executions[i].ID, executions[j].CreatedAt, executions[j].ID)
+	})
+	if filter.Limit > 0 && len(executions) > filter.Limit {
+		executions = executions[:filter.Limit]
+	}
+	return executions, nil
+}
+
+// inPage reports whether a row passes the filters executions and archived
+// executions share
+func inPage(filter ExecutionFilter, organizationID, functionID uuid.UUID, createdAt time.Time, id uuid.UUID) bool {
+	return organizationID == filter.OrganizationID &&
+		(filter.FunctionID == nil || functionID == *filter.FunctionID) &&
+		(filter.From == nil || !createdAt.Before(*filter.From)) &&
+		(filter.To == nil || createdAt.Before(*filter.To)) &&
+		(filter.After == nil || newerThan(filter.After.CreatedAt, filter.After.ID, createdAt, id))
+}
+
+// newerThan orders rows newest first, by creation time and then ID
+func newerThan(createdAt time.Time, id uuid.UUID, otherCreatedAt time.Time, otherID uuid.UUID) bool {
+	if !createdAt.Equal(otherCreatedAt) {
+		return createdAt.After(otherCreatedAt)
+	}
+	return id.String() > otherID.String()
+}
+
+// matchesSearch reports whether text contains every included word and no
+// excluded one, ignoring case like LIKE does
+func matchesSearch(text string, included, excluded []string) bool {
+	text = strings.ToLower(text)
+	for _, term := range included {
+		if !strings.Contains(text, strings.ToLower(term)) {
+			return false
+		}
+	}
+	for _, term := range excluded {
+		if strings.Contains(text, strings.ToLower(term)) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memoryExecutionRepo) DeleteForFunction(ctx context.Context, functionID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, execution := range r.executions {
+		if execution.FunctionID == functionID {
+			delete(r.executions, id)
+		}
+	}
+	return nil
+}
+
+func (r *memoryExecutionRepo) ListPendingRetries(ctx context.Context) ([]Execution, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	executions := []Execution{}
+	for _, execution := range r.executions {
+		if execution.Status == "pending" && execution.NextRetryAt != nil {
+			executions = append(executions, execution)
+		}
+	}
+	return executions, nil
+}
+
+func (r *memoryExecutionRepo) ClaimRetry(ctx context.Context, id uuid.UUID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	execution, ok := r.executions[id]
+	if !ok || execution.Status != "pending" || execution.NextRetryAt == nil {
+		return false, nil
+	}
+	execution.NextRetryAt = nil
+	r.executions[id] = execution
+	return true, nil
+}
+
+func (r *memoryExecutionRepo) ClaimPending(ctx context.Context, id uuid.UUID, startedAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	execution, ok := r.executions[id]
+	if !ok || execution.Status != "pending" {
+		return false, nil
+	}
+	execution.Status = "running"
+	execution.StartedAt = &startedAt
+	r.executions[id] = execution
+	return true, nil
+}
+
+func (r *memoryExecutionRepo) InFlight(ctx context.Context, organizationID uuid.UUID) (*UsageTotal, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	total := &UsageTotal{}
+	for _, execution := range r.executions {
+		if execution.OrganizationID != organizationID || (execution.Status != "pending" && execution.Status != "running") {
+			continue
+		}
+		function, ok := r.functions[execution.FunctionID]
+		if !ok {
+			continue
+		}
+		total.Invocations++
+		total.GBSeconds += float64(function.MemoryMB) / 1024 * float64(function.TimeoutSec)
+	}
+	return total, nil
+}
+
+func (r *memoryExecutionRepo) Stats(ctx context.Context, function *Function, from, to time.Time, bucket string) (*ExecutionStats, []ExecutionStats, error) {
+	step := time.Hour
+	if bucket == "minute" {
+		step = time.Minute
+	}
+
+	r.mu.Lock()
+	var finished []Execution
+	for _, execution := range r.executions {
+		if execution.FunctionID == function.ID && !execution.CreatedAt.Before(from) && execution.CreatedAt.Before(to) &&
+			(execution.Status == "success" || execution.Status == "failed") {
+			finished = append(finished, execution)
+		}
+	}
+	r.mu.Unlock()
+
+	byPeriod := make(map[time.Time][]Execution)
+	var periods []time.Time
+	for _, execution := range finished {
+		period := execution.CreatedAt.UTC().Truncate(step)
+		if _, ok := byPeriod[period]; !ok {
+			periods = append(periods, period)
+		}
+		byPeriod[period] = append(byPeriod[period], execution)
+	}
+	sort.Slice(periods, func(i, j int) bool {
+		return periods[i].Before(periods[j])
+	})
+
+	summary := aggregateStats(finished, function.MemoryMB)
+	series := make([]ExecutionStats, 0, len(periods))
+	for _, period := range periods {
+		row := aggregateStats(byPeriod[period], function.MemoryMB)
+		start := period
+		row.Period = &start
+		series = append(series, row)
+	}
+	return &summary, series, nil
+}
+
+// aggregateStats summarises executions as the statsSelect query does
+func aggregateStats(executions []Execution, allocatedMB int) ExecutionStats {
+	var stats ExecutionStats
+	durations := make([]float64, 0, len(executions))
+	var memoryTotal int
+	for _, execution := range executions {
+		stats.Invocations++
+		if execution.Status == "failed" {
+			stats.Errors++
+		}
+		if execution.ColdStart {
+			stats.ColdStarts++
+		}
+		durations = append(durations, float64(execution.DurationMS))
+
+		memoryMB := execution.MemoryUsed
+		if memoryMB == 0 {
+			memoryMB = allocatedMB
+		}
+		memoryTotal += memoryMB
+		if memoryMB > stats.MaxMemoryMB {
+			stats.MaxMemoryMB = memoryMB
+		}
+	}
+	if stats.Invocations > 0 {
+		stats.AvgMemoryMB = float64(memoryTotal) / float64(stats.Invocations)
+	}
+	sort.Float64s(durations)
+	stats.P50MS, stats.P90MS, stats.P99MS = percentiles(durations)
+	return stats
+}
+
+type memoryAPIKeyRepo struct {
+	*memoryStore
+}
+
+func (r *memoryAPIKeyRepo) Create(ctx context.Context, key *APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&key.ID, &key.CreatedAt, nil)
+	r.apiKeys[key.ID] = *key
+	return nil
+}
+
+func (r *memoryAPIKeyRepo) GetForOrganization(ctx context.Context, id, organizationID uuid.UUID) (*APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.apiKeys[id]
+	if !ok || key.OrganizationID != organizationID {
+		return nil, ErrNotFound
+	}
+	return &key, nil
+}
+
+func (r *memoryAPIKeyRepo) ListForOrganization(ctx context.Context, organizationID uuid.UUID) ([]APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := []APIKey{}
+	for _, key := range r.apiKeys {
+		if key.OrganizationID == organizationID {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+func (r *memoryAPIKeyRepo) Delete(ctx context.Context, key *APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.apiKeys, key.ID)
+	return nil
+}
+
+type memorySessionRepo struct {
+	*memoryStore
+}
+
+// active reports whether a session can still be used at now
+func (s Session) active(now time.Time) bool {
+	return s.RevokedAt == nil && s.ExpiresAt.After(now)
+}
+
+func (r *memorySessionRepo) Create(ctx context.Context, session *Session, token *RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&session.ID, &session.CreatedAt, nil)
+	stamp(&token.ID, &token.CreatedAt, nil)
+	token.SessionID = session.ID
+	r.sessions[session.ID] = *session
+	r.tokens[token.TokenHash] = *token
+	return nil
+}
+
+func (r *memorySessionRepo) Rotate(ctx context.Context, tokenHash string, next *RefreshToken, expiresAt time.Time) (*Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	token, ok := r.tokens[tokenHash]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	session, ok := r.sessions[token.SessionID]
+	if !ok || !session.active(now) {
+		return nil, ErrNotFound
+	}
+	if token.UsedAt != nil {
+		session.RevokedAt = &now
+		r.sessions[session.ID] = session
+		return nil, ErrRefreshTokenReused
+	}
+
+	token.UsedAt = &now
+	r.tokens[tokenHash] = token
+	stamp(&next.ID, &next.CreatedAt, nil)
+	next.SessionID = session.ID
+	r.tokens[next.TokenHash] = *next
+	session.ExpiresAt = expiresAt
+	session.LastUsedAt = now
+	r.sessions[session.ID] = session
+	return &session, nil
+}
+
+func (r *memorySessionRepo) ListActiveForUser(ctx context.Context, userID uuid.UUID) ([]Session, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	sessions := []Session{}
+	for _, session := range r.sessions {
+		if session.UserID == userID && session.active(now) {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+	return sessions, nil
+}
+
+func (r *memorySessionRepo) Revoke(ctx context.Context, id, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	session, ok := r.sessions[id]
+	if !ok || session.UserID != userID || !session.active(now) {
+		return ErrNotFound
+	}
+	session.RevokedAt = &now
+	r.sessions[id] = session
+	return nil
+}
+
+func (r *memorySessionRepo) RevokeAllForUser(ctx context.Context, userID, except uuid.UUID) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	var revoked int64
+	for id, session := range r.sessions {
+		if session.UserID == userID && id != except && session.active(now) {
+			session.RevokedAt = &now
+			r.sessions[id] = session
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+type memoryIdentityRepo struct {
+	*memoryStore
+}
+
+func (r *memoryIdentityRepo) Create(ctx context.Context, identity *UserIdentity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := [2]string{identity.Issuer, identity.Subject}
+	if _, ok := r.identities[key]; ok {
+		return gorm.ErrDuplicatedKey
+	}
+	stamp(&identity.ID, &identity.CreatedAt, nil)
+	r.identities[key] = *identity
+	return nil
+}
+
+func (r *memoryIdentityRepo) Get(ctx context.Context, issuer, subject string) (*UserIdentity, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	identity, ok := r.identities[[2]string{issuer, subject}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &identity, nil
+}
+
+type memoryOrganizationRepo struct {
+	*memoryStore
+}
+
+func (r *memoryOrganizationRepo) Create(ctx context.Context, organization *Organization, ownerID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.createOrganization(organization, ownerID)
+	return nil
+}
+
+func (r *memoryOrganizationRepo) Get(ctx context.Context, id uuid.UUID) (*Organization, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	organization, ok := r.organizations[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &organization, nil
+}
+
+func (r *memoryOrganizationRepo) Save(ctx context.Context, organization *Organization) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&organization.ID, &organization.CreatedAt, &organization.UpdatedAt)
+	r.organizations[organization.ID] = *organization
+	return nil
+}
+
+func (r *memoryOrganizationRepo) Delete(ctx context.Context, organization *Organization) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, function := range r.functions {
+		if function.OrganizationID == organization.ID {
+			return ErrOrganizationNotEmpty
+		}
+	}
+	for _, workflow := range r.workflows {
+		if workflow.OrganizationID == organization.ID {
+			return ErrOrganizationNotEmpty
+		}
+	}
+	for id, key := range r.apiKeys {
+		if key.OrganizationID == organization.ID {
+			delete(r.apiKeys, id)
+		}
+	}
+	for id, invitation := range r.invitations {
+		if invitation.OrganizationID == organization.ID {
+			delete(r.invitations, id)
+		}
+	}
+	for key := range r.members {
+		if key[0] == organization.ID {
+			delete(r.members, key)
+		}
+	}
+	delete(r.organizations, organization.ID)
+	return nil
+}
+
+func (r *memoryOrganizationRepo) ListForUser(ctx context.Context, userID uuid.UUID) ([]OrganizationMember, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	members := []OrganizationMember{}
+	for _, member := range r.members {
+		if member.UserID == userID {
+			member.Organization = r.organizations[member.OrganizationID]
+			members = append(members, member)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].CreatedAt.Before(members[j].CreatedAt)
+	})
+	return members, nil
+}
+
+func (r *memoryOrganizationRepo) GetMember(ctx context.Context, organizationID, userID uuid.UUID) (*OrganizationMember, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	member, ok := r.members[[2]uuid.UUID{organizationID, userID}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &member, nil
+}
+
+func (r *memoryOrganizationRepo) ListMembers(ctx context.Context, organizationID uuid.UUID) ([]OrganizationMember, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	members := []OrganizationMember{}
+	for _, member := range r.members {
+		if member.OrganizationID == organizationID {
+			member.User = r.users[member.UserID]
+			members = append(members, member)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].CreatedAt.Before(members[j].CreatedAt)
+	})
+	return members, nil
+}
+
+func (r *memoryOrganizationRepo) SaveMember(ctx context.Context, member *OrganizationMember) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&member.ID, &member.CreatedAt, &member.UpdatedAt)
+	stored := *member
+	stored.User, stored.Organization = User{}, Organization{}
+	r.members[[2]uuid.UUID{member.OrganizationID, member.UserID}] = stored
+	return nil
+}
+
+func (r *memoryOrganizationRepo) RemoveMember(ctx context.Context, member *OrganizationMember) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.members, [2]uuid.UUID{member.OrganizationID, member.UserID})
+	return nil
+}
+
+func (r *memoryOrganizationRepo) CountOwners(ctx context.Context, organizationID uuid.UUID) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var count int64
+	for _, member := range r.members {
+		if member.OrganizationID == organizationID && member.Role == RoleOwner {
+			count++
+		}
+	}
+	return count, nil
+}
+
+type memoryInvitationRepo struct {
+	*memoryStore
+}
+
+// pending reports whether an invitation can still be accepted at now
+func (i OrganizationInvitation) pending(now time.Time) bool {
+	return i.AcceptedAt == nil && i.ExpiresAt.After(now)
+}
+
+func (r *memoryInvitationRepo) Create(ctx context.Context, invitation *OrganizationInvitation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.invitations {
+		if existing.TokenHash == invitation.TokenHash {
+			return gorm.ErrDuplicatedKey
+		}
+	}
+	stamp(&invitation.ID, &invitation.CreatedAt, nil)
+	r.invitations[invitation.ID] = *invitation
+	return nil
+}
+
+func (r *memoryInvitationRepo) ListPending(ctx context.Context, organizationID uuid.UUID) ([]OrganizationInvitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	invitations := []OrganizationInvitation{}
+	for _, invitation := range r.invitations {
+		if invitation.OrganizationID == organizationID && invitation.pending(now) {
+			invitations = append(invitations, invitation)
+		}
+	}
+	sort.Slice(invitations, func(i, j int) bool {
+		return invitations[i].CreatedAt.After(invitations[j].CreatedAt)
+	})
+	return invitations, nil
+}
+
+func (r *memoryInvitationRepo) Delete(ctx context.Context, id, organizationID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	invitation, ok := r.invitations[id]
+	if !ok || invitation.OrganizationID != organizationID || !invitation.pending(time.Now()) {
+		return ErrNotFound
+	}
+	delete(r.invitations, id)
+	return nil
+}
+
+func (r *memoryInvitationRepo) Accept(ctx context.Context, tokenHash string, user *User) (*OrganizationMember, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for id, invitation := range r.invitations {
+		if invitation.TokenHash != tokenHash || !invitation.pending(now) {
+			continue
+		}
+		if !strings.EqualFold(invitation.Email, user.Email) {
+			return nil, ErrInvitationEmailMismatch
+		}
+		key := [2]uuid.UUID{invitation.OrganizationID, user.ID}
+		if _, ok := r.members[key]; ok {
+			return nil, ErrAlreadyMember
+		}
+
+		invitation.AcceptedAt = &now
+		r.invitations[id] = invitation
+		member := OrganizationMember{OrganizationID: invitation.OrganizationID, UserID: user.ID, Role: invitation.Role}
+		stamp(&member.ID, &member.CreatedAt, &member.UpdatedAt)
+		r.members[key] = member
+		return &member, nil
+	}
+	return nil, ErrNotFound
+}
+
+type memorySecretRepo struct {
+	*memoryStore
+}
+
+func (r *memorySecretRepo) ListForFunction(ctx context.Context, functionID uuid.UUID) ([]FunctionSecret, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	secrets := []FunctionSecret{}
+	for _, secret := range r.secrets {
+		if secret.FunctionID == functionID {
+			secrets = append(secrets, secret)
+		}
+	}
+	sort.Slice(secrets, func(i, j int) bool {
+		return secrets[i].Name < secrets[j].Name
+	})
+	return secrets, nil
+}
+
+func (r *memorySecretRepo) Put(ctx context.Context, secret *FunctionSecret) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.secrets {
+		if existing.FunctionID == secret.FunctionID && existing.Name == secret.Name {
+			secret.ID = existing.ID
+			secret.CreatedAt = existing.CreatedAt
+		}
+	}
+	stamp(&secret.ID, &secret.CreatedAt, &secret.UpdatedAt)
+	r.secrets[secret.ID] = *secret
+	return nil
+}
+
+func (r *memorySecretRepo) Delete(ctx context.Context, functionID uuid.UUID, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, secret := range r.secrets {
+		if secret.FunctionID == functionID && secret.Name == name {
+			delete(r.secrets, id)
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+type memoryScheduleRepo struct {
+	*memoryStore
+}
+
+func (r *memoryScheduleRepo) Create(ctx context.Context, schedule *Schedule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&schedule.ID, &schedule.CreatedAt, &schedule.UpdatedAt)
+	r.schedules[schedule.ID] = *schedule
+	return nil
+}
+
+func (r *memoryScheduleRepo) GetForFunction(ctx context.Context, id, functionID uuid.UUID) (*Schedule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	schedule, ok := r.schedules[id]
+	if !ok || schedule.FunctionID != functionID {
+		return nil, ErrNotFound
+	}
+	return &schedule, nil
+}
+
+func (r *memoryScheduleRepo) ListForFunction(ctx context.Context, functionID uuid.UUID) ([]Schedule, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	schedules := []Schedule{}
+	for _, schedule := range r.schedules {
+		if schedule.FunctionID == functionID {
+			schedules = append(schedules, schedule)
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].CreatedAt.After(schedules[j].CreatedAt)
+	})
+	return schedules, nil
+}
+
+func (r *memoryScheduleRepo) Save(ctx context.Context, schedule *Schedule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&schedule.ID, &schedule.CreatedAt, &schedule.UpdatedAt)
+	r.schedules[schedule.ID] = *schedule
+	return nil
+}
+
+func (r *memoryScheduleRepo) Delete(ctx context.Context, id, functionID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	schedule, ok := r.schedules[id]
+	if !ok || schedule.FunctionID != functionID {
+		return ErrNotFound
+	}
+	delete(r.schedules, id)
+	return nil
+}
+
+type memoryWebhookRepo struct {
+	*memoryStore
+}
+
+func (r *memoryWebhookRepo) Create(ctx context.Context, webhook *WebhookTrigger) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&webhook.ID, &webhook.CreatedAt, &webhook.UpdatedAt)
+	r.webhooks[webhook.ID] = *webhook
+	return nil
+}
+
+func (r *memoryWebhookRepo) Get(ctx context.Context, id uuid.UUID) (*WebhookTrigger, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	webhook, ok := r.webhooks[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &webhook, nil
+}
+
+func (r *memoryWebhookRepo) GetForFunction(ctx context.Context, id, functionID uuid.UUID) (*WebhookTrigger, error) {
+	webhook, err := r.Get(ctx, id)
+	if err != nil || webhook.FunctionID != functionID {
+		return nil, ErrNotFound
+	}
+	return webhook, nil
+}
+
+func (r *memoryWebhookRepo) ListForFunction(ctx context.Context, functionID uuid.UUID) ([]WebhookTrigger, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	webhooks := []WebhookTrigger{}
+	for _, webhook := range r.webhooks {
+		if webhook.FunctionID == functionID {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].CreatedAt.After(webhooks[j].CreatedAt)
+	})
+	return webhooks, nil
+}
+
+func (r *memoryWebhookRepo) Delete(ctx context.Context, webhook *WebhookTrigger) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.deleteWebhook(webhook.ID)
+	return nil
+}
+
+// deleteWebhook removes a webhook and its deliveries; the caller holds
+// the lock
+func (s *memoryStore) deleteWebhook(id uuid.UUID) {
+	for key, delivery := range s.deliveries {
+		if delivery.WebhookID == id {
+			delete(s.deliveries, key)
+		}
+	}
+	delete(s.webhooks, id)
+}
+
+func (r *memoryWebhookRepo) ClaimDelivery(ctx context.Context, webhookID uuid.UUID, deliveryID string, execution *Execution, beforeCreate func()) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := [2]string{webhookID.String(), deliveryID}
+	if _, ok := r.deliveries[key]; ok {
+		return false, nil
+	}
+	r.deliveries[key] = WebhookDelivery{WebhookID: webhookID, DeliveryID: deliveryID, CreatedAt: time.Now()}
+	beforeCreate()
+	stamp(&execution.ID, &execution.CreatedAt, nil)
+	r.executions[execution.ID] = *execution
+	return true, nil
+}
+
+type memoryEventTriggerRepo struct {
+	*memoryStore
+}
+
+func (r *memoryEventTriggerRepo) Create(ctx context.Context, trigger *EventTrigger) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&trigger.ID, &trigger.CreatedAt, &trigger.UpdatedAt)
+	r.eventTriggers[trigger.ID] = *trigger
+	return nil
+}
+
+func (r *memoryEventTriggerRepo) GetForFunction(ctx context.Context, id, functionID, organizationID uuid.UUID) (*EventTrigger, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	trigger, ok := r.eventTriggers[id]
+	if !ok || trigger.FunctionID != functionID || trigger.OrganizationID != organizationID {
+		return nil, ErrNotFound
+	}
+	return &trigger, nil
+}
+
+func (r *memoryEventTriggerRepo) ListForFunction(ctx context.Context, functionID, organizationID uuid.UUID) ([]EventTrigger, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	triggers := []EventTrigger{}
+	for _, trigger := range r.eventTriggers {
+		if trigger.FunctionID == functionID && trigger.OrganizationID == organizationID {
+			triggers = append(triggers, trigger)
+		}
+	}
+	sort.Slice(triggers, func(i, j int) bool {
+		return triggers[i].CreatedAt.After(triggers[j].CreatedAt)
+	})
+	return triggers, nil
+}
+
+func (r *memoryEventTriggerRepo) Save(ctx context.Context, trigger *EventTrigger) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&trigger.ID, &trigger.CreatedAt, &trigger.UpdatedAt)
+	r.eventTriggers[trigger.ID] = *trigger
+	return nil
+}
+
+func (r *memoryEventTriggerRepo) Delete(ctx context.Context, trigger *EventTrigger) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.deleteEventTrigger(trigger.ID)
+	return nil
+}
+
+// deleteEventTrigger removes a trigger and its queued and dead-lettered
+// messages; the caller holds the lock
+func (s *memoryStore) deleteEventTrigger(id uuid.UUID) {
+	for messageID, message := range s.eventMessages {
+		if message.TriggerID == id {
+			delete(s.eventMessages, messageID)
+		}
+	}
+	for deadLetterID, deadLetter := range s.eventDeadLetters {
+		if deadLetter.TriggerID == id {
+			delete(s.eventDeadLetters, deadLetterID)
+		}
+	}
+	delete(s.eventTriggers, id)
+}
+
+func (r *memoryEventTriggerRepo) GetDeadLetter(ctx context.Context, id, triggerID uuid.UUID) (*EventDeadLetter, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deadLetter, ok := r.eventDeadLetters[id]
+	if !ok || deadLetter.TriggerID != triggerID {
+		return nil, ErrNotFound
+	}
+	return &deadLetter, nil
+}
+
+func (r *memoryEventTriggerRepo) ListDeadLetters(ctx context.Context, triggerID uuid.UUID) ([]EventDeadLetter, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deadLetters := []EventDeadLetter{}
+	for _, deadLetter := range r.eventDeadLetters {
+		if deadLetter.TriggerID == triggerID {
+			deadLetters = append(deadLetters, deadLetter)
+		}
+	}
+	sort.Slice(deadLetters, func(i, j int) bool {
+		return deadLetters[i].CreatedAt.After(deadLetters[j].CreatedAt)
+	})
+	return deadLetters, nil
+}
+
+func (r *memoryEventTriggerRepo) DeleteDeadLetter(ctx context.Context, id, triggerID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deadLetter, ok := r.eventDeadLetters[id]
+	if !ok || deadLetter.TriggerID != triggerID {
+		return ErrNotFound
+	}
+	delete(r.eventDeadLetters, id)
+	return nil
+}
+
+func (r *memoryEventTriggerRepo) Redrive(ctx context.Context, deadLetter *EventDeadLetter) (*EventMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.eventDeadLetters[deadLetter.ID]; !ok {
+		return nil, ErrNotFound
+	}
+	message := EventMessage{
+		TriggerID: deadLetter.TriggerID,
+		Topic:     deadLetter.Topic,
+		Payload:   deadLetter.Payload,
+		VisibleAt: time.Now(),
+	}
+	stamp(&message.ID, &message.CreatedAt, nil)
+	r.eventMessages[message.ID] = message
+	delete(r.eventDeadLetters, deadLetter.ID)
+	return &message, nil
+}
+
+type memoryDeadLetterRepo struct {
+	*memoryStore
+}
+
+func (r *memoryDeadLetterRepo) Create(ctx context.Context, deadLetter *DeadLetter) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&deadLetter.ID, &deadLetter.CreatedAt, nil)
+	r.deadLetters[deadLetter.ID] = *deadLetter
+	return nil
+}
+
+func (r *memoryDeadLetterRepo) GetForFunction(ctx context.Context, id, functionID uuid.UUID) (*DeadLetter, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deadLetter, ok := r.deadLetters[id]
+	if !ok || deadLetter.FunctionID != functionID {
+		return nil, ErrNotFound
+	}
+	return &deadLetter, nil
+}
+
+func (r *memoryDeadLetterRepo) ListForFunction(ctx context.Context, functionID uuid.UUID) ([]DeadLetter, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deadLetters := []DeadLetter{}
+	for _, deadLetter := range r.deadLetters {
+		if deadLetter.FunctionID == functionID {
+			deadLetters = append(deadLetters, deadLetter)
+		}
+	}
+	sort.Slice(deadLetters, func(i, j int) bool {
+		return deadLetters[i].CreatedAt.After(deadLetters[j].CreatedAt)
+	})
+	return deadLetters, nil
+}
+
+func (r *memoryDeadLetterRepo) MarkReplayed(ctx context.Context, id, executionID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deadLetter, ok := r.deadLetters[id]
+	if !ok {
+		return nil // an update matching no rows
+	}
+	now := time.Now()
+	deadLetter.ReplayedAt = &now
+	deadLetter.ReplayExecutionID = &executionID
+	r.deadLetters[id] = deadLetter
+	return nil
+}
+
+func (r *memoryDeadLetterRepo) Delete(ctx context.Context, deadLetter *DeadLetter) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.deadLetters, deadLetter.ID)
+	return nil
+}
+
+type memoryWorkflowRepo struct {
+	*memoryStore
+}
+
+func (r *memoryWorkflowRepo) Create(ctx context.Context, workflow *Workflow) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&workflow.ID, &workflow.CreatedAt, &workflow.UpdatedAt)
+	r.workflows[workflow.ID] = *workflow
+	return nil
+}
+
+func (r *memoryWorkflowRepo) GetForOrganization(ctx context.Context, id, organizationID uuid.UUID) (*Workflow, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	workflow, ok := r.workflows[id]
+	if !ok || workflow.OrganizationID != organizationID {
+		return nil, ErrNotFound
+	}
+	return &workflow, nil
+}
+
+func (r *memoryWorkflowRepo) ListForOrganization(ctx context.Context, organizationID uuid.UUID) ([]Workflow, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	workflows := []Workflow{}
+	for _, workflow := range r.workflows {
+		if workflow.OrganizationID == organizationID {
+			workflows = append(workflows, workflow)
+		}
+	}
+	sort.Slice(workflows, func(i, j int) bool {
+		return workflows[i].CreatedAt.After(workflows[j].CreatedAt)
+	})
+	return workflows, nil
+}
+
+func (r *memoryWorkflowRepo) Save(ctx context.Context, workflow *Workflow) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&workflow.ID, &workflow.CreatedAt, &workflow.UpdatedAt)
+	r.workflows[workflow.ID] = *workflow
+	return nil
+}
+
+func (r *memoryWorkflowRepo) Delete(ctx context.Context, workflow *Workflow) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for runID, run := range r.workflowRuns {
+		if run.WorkflowID != workflow.ID {
+			continue
+		}
+		for stepID, step := range r.workflowSteps {
+			if step.RunID == runID {
+				delete(r.workflowSteps, stepID)
+			}
+		}
+		delete(r.workflowRuns, runID)
+	}
+	delete(r.workflows, workflow.ID)
+	return nil
+}
+
+func (r *memoryWorkflowRepo) ListRuns(ctx context.Context, workflowID, organizationID uuid.UUID, limit int) ([]WorkflowRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	runs := []WorkflowRun{}
+	for _, run := range r.workflowRuns {
+		if run.WorkflowID == workflowID && run.OrganizationID == organizationID {
+			runs = append(runs, run)
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].StartedAt.After(runs[j].StartedAt)
+	})
+	if limit > 0 && len(runs) > limit {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
+
+func (r *memoryWorkflowRepo) GetRun(ctx context.Context, id, workflowID, organizationID uuid.UUID) (*WorkflowRun, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	run, ok := r.workflowRuns[id]
+	if !ok || run.WorkflowID != workflowID || run.OrganizationID != organizationID {
+		return nil, ErrNotFound
+	}
+	return &run, nil
+}
+
+func (r *memoryWorkflowRepo) ListSteps(ctx context.Context, runID uuid.UUID) ([]WorkflowStep, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	steps := []WorkflowStep{}
+	for _, step := range r.workflowSteps {
+		if step.RunID == runID {
+			steps = append(steps, step)
+		}
+	}
+	sort.Slice(steps, func(i, j int) bool {
+		return steps[i].StartedAt.Before(steps[j].StartedAt)
+	})
+	return steps, nil
+}
+
+type memoryUsageRepo struct {
+	*memoryStore
+}
+
+func (r *memoryUsageRepo) Add(ctx context.Context, rollup *UsageRollup) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, stored := range r.usage {
+		if stored.OrganizationID == rollup.OrganizationID && stored.FunctionID == rollup.FunctionID && stored.Hour.Equal(rollup.Hour) {
+			stored.Invocations += rollup.Invocations
+			stored.Errors += rollup.Errors
+			stored.DurationMS += rollup.DurationMS
+			stored.GBSeconds += rollup.GBSeconds
+			stored.EgressBytes += rollup.EgressBytes
+			stored.UpdatedAt = time.Now()
+			r.usage[id] = stored
+			return nil
+		}
+	}
+	stamp(&rollup.ID, nil, &rollup.UpdatedAt)
+	r.usage[rollup.ID] = *rollup
+	return nil
+}
+
+func (r *memoryUsageRepo) Sum(ctx context.Context, organizationID uuid.UUID, from, to time.Time, byFunction bool, period string) ([]UsageTotal, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	type group struct {
+		period   time.Time
+		function uuid.UUID
+	}
+	totals := make(map[group]*UsageTotal)
+	for _, rollup := range r.usage {
+		if rollup.OrganizationID != organizationID || rollup.Hour.Before(from) || !rollup.Hour.Before(to) {
+			continue
+		}
+		var key group
+		if period != "" {
+			key.period = truncatePeriod(rollup.Hour, period)
+		}
+		if byFunction {
+			key.function = rollup.FunctionID
+		}
+		total, ok := totals[key]
+		if !ok {
+			total = &UsageTotal{}
+			if period != "" {
+				start := key.period
+				total.Period = &start
+			}
+			if byFunction {
+				functionID := key.function
+				total.FunctionID = &functionID
+			}
+			totals[key] = total
+		}
+		total.Invocations += rollup.Invocations
+		total.Errors += rollup.Errors
+		total.DurationMS += rollup.DurationMS
+		total.GBSeconds += rollup.GBSeconds
+		total.EgressBytes += rollup.EgressBytes
+	}
+
+	// Without grouping the sums form a single row, as in SQL
+	if period == "" && !byFunction && len(totals) == 0 {
+		return []UsageTotal{{}}, nil
+	}
+
+	keys := make([]group, 0, len(totals))
+	for key := range totals {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].period.Equal(keys[j].period) {
+			return keys[i].period.Before(keys[j].period)
+		}
+		return keys[i].function.String() < keys[j].function.String()
+	})
+	result := make([]UsageTotal, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, *totals[key])
+	}
+	return result, nil
+}
+
+// truncatePeriod returns the start of the UTC hour, day or month holding t
+func truncatePeriod(t time.Time, period string) time.Time {
+	t = t.UTC()
+	switch period {
+	case "day":
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return t.Truncate(time.Hour)
+	}
+}
+
+type memoryArchiveRepo struct {
+	*memoryStore
+}
+
+func (r *memoryArchiveRepo) Create(ctx context.Context, archived *ArchivedExecution) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stamp(&archived.ID, &archived.CreatedAt, nil)
+	r.archives[archived.ID] = *archived
+	return nil
+}
+
+func (r *memoryArchiveRepo) GetForOrganization(ctx context.Context, id, organizationID uuid.UUID) (*ArchivedExecution, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	archived, ok := r.archives[id]
+	if !ok || archived.OrganizationID != organizationID {
+		return nil, ErrNotFound
+	}
+	return &archived, nil
+}
+
+func (r *memoryArchiveRepo) List(ctx context.Context, filter ExecutionFilter) ([]ArchivedExecution, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	archived := []ArchivedExecution{}
+	for _, row := range r.archives {
+		if inPage(filter, row.OrganizationID, row.FunctionID, row.CreatedAt, row.ID) {
+			archived = append(archived, row)
+		}
+	}
+	sort.Slice(archived, func(i, j int) bool {
+		return newerThan(archived[i].CreatedAt, archived[i].ID, archived[j].CreatedAt, archived[j].ID)
+	})
+	if filter.Limit > 0 && len(archived) > filter.Limit {
+		archived = archived[:filter.Limit]
+	}
+	return archived, nil
+}
+
+type memoryIdempotencyRepo struct {
+	*memoryStore
+}
+
+func (r *memoryIdempotencyRepo) Claim(ctx context.Context, key *IdempotencyKey, execution *Execution, beforeCreate func()) (*IdempotencyKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for id, existing := range r.idempotencyKeys {
+		if existing.OrganizationID != key.OrganizationID || existing.UserID != key.UserID {
+			continue
+		}
+		if existing.ExpiresAt.Before(now) {
+			delete(r.idempotencyKeys, id)
+		} else if existing.FunctionID == key.FunctionID && existing.Key == key.Key {
+			return &existing, nil
+		}
+	}
+
+	stamp(&key.ID, &key.CreatedAt, nil)
+	r.idempotencyKeys[key.ID] = *key
+	beforeCreate()
+	stamp(&execution.ID, &execution.CreatedAt, nil)
+	r.executions[execution.ID] = *execution
+	return nil, nil
+}
@@ -0,0 +1,232 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// The baseline models as GORM auto-migration created them before versioned
+// migrations existed
+
+type baselineUser struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Email     string    `gorm:"uniqueIndex;not null"`
+	Password  string    `gorm:"not null"`
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (baselineUser) TableName() string { return "users" }
+
+type baselineFunction struct {
+	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID      uuid.UUID `gorm:"type:uuid;not null;index"`
+	Name        string    `gorm:"not null"`
+	Description string
+	Runtime     string `gorm:"not null"`
+	Code        string `gorm:"type:text;not null"`
+	EntryPoint  string `gorm:"default:index.handler"`
+	MemoryMB    int    `gorm:"default:128"`
+	TimeoutSec  int    `gorm:"default:30"`
+	Status      string `gorm:"default:active"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+
+	User baselineUser `gorm:"foreignKey:UserID"`
+}
+
+func (baselineFunction) TableName() string { return "functions" }
+
+type baselineExecution struct {
+	ID          uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID      uuid.UUID      `gorm:"type:uuid;not null;index"`
+	FunctionID  uuid.UUID      `gorm:"type:uuid;not null;index"`
+	Status      string         `gorm:"default:pending"`
+	Input       datatypes.JSON `gorm:"type:jsonb"`
+	Output      datatypes.JSON `gorm:"type:jsonb"`
+	Error       string         `gorm:"type:text"`
+	Logs        string         `gorm:"type:text"`
+	DurationMS  int64
+	MemoryUsed  int
+	StartedAt   *time.Time
+	CompletedAt *time.Time
+	CreatedAt   time.Time
+
+	User     baselineUser     `gorm:"foreignKey:UserID"`
+	Function baselineFunction `gorm:"foreignKey:FunctionID"`
+}
+
+func (baselineExecution) TableName() string { return "executions" }
+
+type baselineAPIKey struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	Name      string    `gorm:"not null"`
+	Key       string    `gorm:"uniqueIndex;not null"`
+	Prefix    string    `gorm:"not null"`
+	LastUsed  *time.Time
+	ExpiresAt *time.Time
+	CreatedAt time.Time
+
+	User baselineUser `gorm:"foreignKey:UserID"`
+}
+
+func (baselineAPIKey) TableName() string { return "api_keys" }
+
+// currentModels are every model the migrations must create columns for
+var currentModels = []interface{}{
+	&User{}, &Function{}, &FunctionSecret{}, &Execution{}, &Schedule{},
+	&WebhookTrigger{}, &WebhookDelivery{}, &EventTrigger{}, &EventMessage{},
+	&EventDeadLetter{}, &DeadLetter{}, &IdempotencyKey{}, &RateLimitBucket{},
+	&ConcurrencyLease{}, &UsageRollup{}, &ArchivedExecution{}, &Workflow{},
+	&WorkflowRun{}, &WorkflowStep{}, &APIKey{}, &Session{}, &RefreshToken{},
+	&UserIdentity{}, &OIDCLoginState{}, &Organization{}, &OrganizationMember{},
+	&OrganizationInvitation{},
+}
+
+// openMigrationTestDB connects to an empty database: a fresh SQLite file,
+// or the Postgres database in VOLTRUN_TEST_POSTGRES_DSN, whose public
+// schema is dropped first
+func openMigrationTestDB(t *testing.T, driver string) {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "voltrun.db")
+	if driver == DriverPostgres {
+		dsn = os.Getenv("VOLTRUN_TEST_POSTGRES_DSN")
+		if dsn == "" {
+			t.Skip("VOLTRUN_TEST_POSTGRES_DSN is not set")
+		}
+	}
+	if err := InitDB(driver, dsn); err != nil {
+		t.Fatal(err)
+	}
+	DB.Logger = logger.Discard
+	if driver == DriverPostgres {
+		if err := DB.Exec("DROP SCHEMA public CASCADE; CREATE SCHEMA public").Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+		DB = nil
+	})
+}
+
+// autoMigrateBaseline creates the baseline tables the way GORM did. SQLite
+// cannot default a column to gen_random_uuid(), and its databases were
+// never auto-migrated, so there only the shape of the tables matters.
+func autoMigrateBaseline(t *testing.T) {
+	t.Helper()
+	models := []interface{}{&baselineUser{}, &baselineFunction{}, &baselineExecution{}, &baselineAPIKey{}}
+	if IsSQLite() {
+		for _, model := range models {
+			stmt := &gorm.Statement{DB: DB}
+			if err := stmt.Parse(model); err != nil {
+				t.Fatal(err)
+			}
+			id := stmt.Schema.PrioritizedPrimaryField
+			id.DefaultValue, id.HasDefaultValue = "", false
+		}
+	}
+	if err := DB.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expectSchema fails unless every column of every current model exists
+func expectSchema(t *testing.T) {
+	t.Helper()
+	for _, model := range currentModels {
+		stmt := &gorm.Statement{DB: DB}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatal(err)
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && !DB.Migrator().HasColumn(model, field.DBName) {
+				t.Errorf("%s.%s is missing", stmt.Schema.Table, field.DBName)
+			}
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name     string
+		driver   string
+		baseline bool
+	}{
+		{"sqlite fresh database", DriverSQLite, false},
+		{"sqlite auto-migrated baseline", DriverSQLite, true},
+		{"postgres fresh database", DriverPostgres, false},
+		{"postgres auto-migrated baseline", DriverPostgres, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			openMigrationTestDB(t, tt.driver)
+
+			userID := uuid.New()
+			if tt.baseline {
+				autoMigrateBaseline(t)
+				user := baselineUser{ID: userID, Email: "user@example.com", Password: "hash"}
+				if err := DB.Create(&user).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			migrations, err := LoadMigrations()
+			if err != nil {
+				t.Fatal(err)
+			}
+			applied, err := MigrateUp()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if applied != len(migrations) {
+				t.Fatalf("applied %d migrations, want %d", applied, len(migrations))
+			}
+			expectSchema(t)
+
+			if tt.baseline {
+				var user User
+				if err := DB.First(&user, "id = ?", userID).Error; err != nil {
+					t.Fatal(err)
+				}
+				if user.Email != "user@example.com" {
+					t.Fatalf("email = %q, want the baseline row's", user.Email)
+				}
+			}
+
+			if applied, err := MigrateUp(); err != nil || applied != 0 {
+				t.Fatalf("second MigrateUp applied %d, %v; want 0, nil", applied, err)
+			}
+
+			rolledBack, err := MigrateDown(len(migrations))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rolledBack != len(migrations) {
+				t.Fatalf("rolled back %d migrations, want %d", rolledBack, len(migrations))
+			}
+			for _, model := range currentModels {
+				if DB.Migrator().HasTable(model) {
+					t.Errorf("%T still has a table", model)
+				}
+			}
+
+			// The rollback leaves a database the migrations recreate
+			if _, err := MigrateUp(); err != nil {
+				t.Fatal(err)
+			}
+			expectSchema(t)
+		})
+	}
+}
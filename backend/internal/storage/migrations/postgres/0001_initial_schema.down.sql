@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS executions;
+DROP TABLE IF EXISTS functions;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,69 @@
+-- Baseline schema, as GORM auto-migration created it before versioned
+-- migrations. Objects are created only if missing, so such databases
+-- adopt it cleanly; the migrations up to 0016 add every later table and
+-- column the same way.
+
+CREATE TABLE IF NOT EXISTS users (
+    id uuid DEFAULT gen_random_uuid(),
+    email text NOT NULL,
+    password text NOT NULL,
+    name text,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+
+CREATE TABLE IF NOT EXISTS functions (
+    id uuid DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL,
+    name text NOT NULL,
+    description text,
+    runtime text NOT NULL,
+    code text NOT NULL,
+    entry_point text DEFAULT 'index.handler',
+    memory_mb bigint DEFAULT 128,
+    timeout_sec bigint DEFAULT 30,
+    status text DEFAULT 'active',
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_users_functions FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE INDEX IF NOT EXISTS idx_functions_user_id ON functions (user_id);
+
+CREATE TABLE IF NOT EXISTS executions (
+    id uuid DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL,
+    function_id uuid NOT NULL,
+    status text DEFAULT 'pending',
+    input jsonb,
+    output jsonb,
+    error text,
+    logs text,
+    duration_ms bigint,
+    memory_used bigint,
+    started_at timestamptz,
+    completed_at timestamptz,
+    created_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_functions_executions FOREIGN KEY (function_id) REFERENCES functions(id),
+    CONSTRAINT fk_users_executions FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE INDEX IF NOT EXISTS idx_executions_user_id ON executions (user_id);
+CREATE INDEX IF NOT EXISTS idx_executions_function_id ON executions (function_id);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id uuid DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL,
+    name text NOT NULL,
+    key text NOT NULL,
+    prefix text NOT NULL,
+    last_used timestamptz,
+    expires_at timestamptz,
+    created_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_users_api_keys FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_key ON api_keys (key);
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id);
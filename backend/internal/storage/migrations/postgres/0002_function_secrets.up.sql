@@ -0,0 +1,17 @@
+-- Encrypted per-function secrets and plain environment variables.
+
+ALTER TABLE functions ADD COLUMN IF NOT EXISTS environment jsonb;
+
+CREATE TABLE IF NOT EXISTS function_secrets (
+    id uuid DEFAULT gen_random_uuid(),
+    function_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    name text NOT NULL,
+    ciphertext text NOT NULL,
+    key_id text NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_function_secrets_user_id ON function_secrets (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_function_secret_name ON function_secrets (function_id,name);
@@ -0,0 +1,24 @@
+-- Cron schedules, and the trigger that started each execution.
+
+ALTER TABLE executions ADD COLUMN IF NOT EXISTS trigger_type text DEFAULT 'http';
+
+CREATE INDEX IF NOT EXISTS idx_executions_trigger_type ON executions (trigger_type);
+
+CREATE TABLE IF NOT EXISTS schedules (
+    id uuid DEFAULT gen_random_uuid(),
+    function_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    expression text NOT NULL,
+    timezone text NOT NULL DEFAULT 'UTC',
+    input jsonb,
+    enabled boolean NOT NULL,
+    next_run_at timestamptz,
+    last_run_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_schedules_function FOREIGN KEY (function_id) REFERENCES functions(id)
+);
+CREATE INDEX IF NOT EXISTS idx_schedules_next_run_at ON schedules (next_run_at);
+CREATE INDEX IF NOT EXISTS idx_schedules_user_id ON schedules (user_id);
+CREATE INDEX IF NOT EXISTS idx_schedules_function_id ON schedules (function_id);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS webhook_triggers;
+DROP INDEX IF EXISTS idx_executions_trigger_source;
+ALTER TABLE executions DROP COLUMN IF EXISTS trigger_source;
@@ -0,0 +1,26 @@
+-- Signed webhook triggers, and the schedule or webhook that fired each
+-- execution.
+
+ALTER TABLE executions ADD COLUMN IF NOT EXISTS trigger_source text;
+
+CREATE INDEX IF NOT EXISTS idx_executions_trigger_source ON executions (trigger_source);
+
+CREATE TABLE IF NOT EXISTS webhook_triggers (
+    id uuid DEFAULT gen_random_uuid(),
+    function_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    name text NOT NULL,
+    provider text NOT NULL DEFAULT 'generic',
+    secret_ciphertext text NOT NULL,
+    secret_key_id text NOT NULL,
+    signature_header text,
+    algorithm text NOT NULL DEFAULT 'sha256',
+    timestamp_header text,
+    tolerance_sec bigint NOT NULL DEFAULT 300,
+    enabled boolean NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_triggers_user_id ON webhook_triggers (user_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_triggers_function_id ON webhook_triggers (function_id);
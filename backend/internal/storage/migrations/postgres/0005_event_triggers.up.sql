@@ -0,0 +1,27 @@
+-- Event triggers and the queue of messages published to their topics.
+
+CREATE TABLE IF NOT EXISTS event_triggers (
+    id uuid DEFAULT gen_random_uuid(),
+    function_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    topic text NOT NULL,
+    batch_size bigint NOT NULL DEFAULT 1,
+    enabled boolean NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_event_triggers_function_id ON event_triggers (function_id);
+CREATE INDEX IF NOT EXISTS idx_event_trigger_topic ON event_triggers (user_id,topic);
+
+CREATE TABLE IF NOT EXISTS event_messages (
+    id uuid DEFAULT gen_random_uuid(),
+    trigger_id uuid NOT NULL,
+    topic text NOT NULL,
+    payload jsonb,
+    attempts bigint NOT NULL DEFAULT 0,
+    visible_at timestamptz NOT NULL,
+    created_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_event_message_ready ON event_messages (trigger_id,visible_at);
@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS dead_letters;
+DROP INDEX IF EXISTS idx_executions_original_execution_id;
+DROP INDEX IF EXISTS idx_executions_next_retry_at;
+ALTER TABLE executions DROP COLUMN IF EXISTS next_retry_at;
+ALTER TABLE executions DROP COLUMN IF EXISTS original_execution_id;
+ALTER TABLE executions DROP COLUMN IF EXISTS attempt;
+ALTER TABLE executions DROP COLUMN IF EXISTS error_class;
+ALTER TABLE functions DROP COLUMN IF EXISTS retry_on;
+ALTER TABLE functions DROP COLUMN IF EXISTS retry_max_backoff_sec;
+ALTER TABLE functions DROP COLUMN IF EXISTS retry_backoff_sec;
+ALTER TABLE functions DROP COLUMN IF EXISTS retry_max_attempts;
@@ -0,0 +1,34 @@
+-- Retry policies, retry chains of executions and their dead letters.
+
+ALTER TABLE functions ADD COLUMN IF NOT EXISTS retry_max_attempts bigint NOT NULL DEFAULT 1;
+ALTER TABLE functions ADD COLUMN IF NOT EXISTS retry_backoff_sec bigint NOT NULL DEFAULT 2;
+ALTER TABLE functions ADD COLUMN IF NOT EXISTS retry_max_backoff_sec bigint NOT NULL DEFAULT 300;
+ALTER TABLE functions ADD COLUMN IF NOT EXISTS retry_on text NOT NULL DEFAULT 'timeout,system';
+
+ALTER TABLE executions ADD COLUMN IF NOT EXISTS error_class text;
+ALTER TABLE executions ADD COLUMN IF NOT EXISTS attempt bigint NOT NULL DEFAULT 1;
+ALTER TABLE executions ADD COLUMN IF NOT EXISTS original_execution_id uuid;
+ALTER TABLE executions ADD COLUMN IF NOT EXISTS next_retry_at timestamptz;
+
+CREATE INDEX IF NOT EXISTS idx_executions_next_retry_at ON executions (next_retry_at);
+CREATE INDEX IF NOT EXISTS idx_executions_original_execution_id ON executions (original_execution_id);
+
+CREATE TABLE IF NOT EXISTS dead_letters (
+    id uuid DEFAULT gen_random_uuid(),
+    function_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    execution_id uuid NOT NULL,
+    original_execution_id uuid NOT NULL,
+    input jsonb,
+    error text,
+    error_class text,
+    attempts bigint,
+    trigger_type text,
+    trigger_source text,
+    replayed_at timestamptz,
+    replay_execution_id uuid,
+    created_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_dead_letters_user_id ON dead_letters (user_id);
+CREATE INDEX IF NOT EXISTS idx_dead_letters_function_id ON dead_letters (function_id);
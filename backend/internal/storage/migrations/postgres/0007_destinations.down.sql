@@ -0,0 +1,7 @@
+ALTER TABLE executions DROP COLUMN IF EXISTS chain_depth;
+ALTER TABLE executions DROP COLUMN IF EXISTS on_failure;
+ALTER TABLE executions DROP COLUMN IF EXISTS on_success;
+ALTER TABLE functions DROP COLUMN IF EXISTS destination_secret_key_id;
+ALTER TABLE functions DROP COLUMN IF EXISTS destination_secret_ciphertext;
+ALTER TABLE functions DROP COLUMN IF EXISTS on_failure;
+ALTER TABLE functions DROP COLUMN IF EXISTS on_success;
@@ -0,0 +1,10 @@
+-- Completion destinations of functions and of the executions they chain.
+
+ALTER TABLE functions ADD COLUMN IF NOT EXISTS on_success jsonb;
+ALTER TABLE functions ADD COLUMN IF NOT EXISTS on_failure jsonb;
+ALTER TABLE functions ADD COLUMN IF NOT EXISTS destination_secret_ciphertext text;
+ALTER TABLE functions ADD COLUMN IF NOT EXISTS destination_secret_key_id text;
+
+ALTER TABLE executions ADD COLUMN IF NOT EXISTS on_success jsonb;
+ALTER TABLE executions ADD COLUMN IF NOT EXISTS on_failure jsonb;
+ALTER TABLE executions ADD COLUMN IF NOT EXISTS chain_depth bigint NOT NULL DEFAULT 0;
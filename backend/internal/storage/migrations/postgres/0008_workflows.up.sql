@@ -0,0 +1,52 @@
+-- Workflows, their durable runs and the steps each run recorded.
+
+CREATE TABLE IF NOT EXISTS workflows (
+    id uuid DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL,
+    name text NOT NULL,
+    description text,
+    definition jsonb NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_workflows_user_id ON workflows (user_id);
+
+CREATE TABLE IF NOT EXISTS workflow_runs (
+    id uuid DEFAULT gen_random_uuid(),
+    workflow_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    status text NOT NULL,
+    definition jsonb NOT NULL,
+    input jsonb,
+    output jsonb,
+    error text,
+    lease_owner text,
+    lease_until timestamptz,
+    started_at timestamptz,
+    completed_at timestamptz,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_workflow_runs_status ON workflow_runs (status);
+CREATE INDEX IF NOT EXISTS idx_workflow_runs_user_id ON workflow_runs (user_id);
+CREATE INDEX IF NOT EXISTS idx_workflow_runs_workflow_id ON workflow_runs (workflow_id);
+CREATE INDEX IF NOT EXISTS idx_workflow_runs_lease_until ON workflow_runs (lease_until);
+
+CREATE TABLE IF NOT EXISTS workflow_steps (
+    id uuid DEFAULT gen_random_uuid(),
+    run_id uuid NOT NULL,
+    path text NOT NULL,
+    name text NOT NULL,
+    type text NOT NULL,
+    status text NOT NULL,
+    input jsonb,
+    output jsonb,
+    error text,
+    execution_id uuid,
+    wake_at timestamptz,
+    started_at timestamptz,
+    completed_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workflow_step_path ON workflow_steps (run_id,path);
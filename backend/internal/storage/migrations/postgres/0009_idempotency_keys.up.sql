@@ -0,0 +1,15 @@
+-- Idempotency keys of execute requests.
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    id uuid DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL,
+    function_id uuid NOT NULL,
+    key text NOT NULL,
+    request_hash text NOT NULL,
+    execution_id uuid NOT NULL,
+    expires_at timestamptz NOT NULL,
+    created_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_idempotency_key ON idempotency_keys (user_id,function_id,key);
@@ -0,0 +1,21 @@
+-- Per-function rate limits and reserved concurrency, with the shared
+-- token buckets and concurrency leases behind them.
+
+ALTER TABLE functions ADD COLUMN IF NOT EXISTS rate_limit_rps decimal;
+ALTER TABLE functions ADD COLUMN IF NOT EXISTS rate_limit_burst bigint;
+ALTER TABLE functions ADD COLUMN IF NOT EXISTS reserved_concurrency bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key text,
+    tokens decimal NOT NULL,
+    updated_at timestamptz NOT NULL,
+    PRIMARY KEY (key)
+);
+
+CREATE TABLE IF NOT EXISTS concurrency_leases (
+    id uuid,
+    key text NOT NULL,
+    expires_at timestamptz NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_concurrency_leases_key ON concurrency_leases (key);
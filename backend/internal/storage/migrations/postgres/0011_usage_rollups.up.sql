@@ -0,0 +1,16 @@
+-- Hourly usage rollups for metering.
+
+CREATE TABLE IF NOT EXISTS usage_rollups (
+    id uuid DEFAULT gen_random_uuid(),
+    user_id uuid NOT NULL,
+    function_id uuid NOT NULL,
+    hour timestamptz NOT NULL,
+    invocations bigint NOT NULL,
+    errors bigint NOT NULL,
+    duration_ms bigint NOT NULL,
+    gb_seconds decimal NOT NULL,
+    egress_bytes bigint NOT NULL,
+    updated_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_usage_rollup_hour ON usage_rollups (user_id,function_id,hour);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_executions_function_created;
+ALTER TABLE executions DROP COLUMN IF EXISTS cold_start;
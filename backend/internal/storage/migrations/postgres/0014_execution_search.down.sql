@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_executions_search;
+DROP INDEX IF EXISTS idx_executions_user_created;
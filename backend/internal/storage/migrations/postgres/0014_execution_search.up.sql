@@ -0,0 +1,7 @@
+-- Indexes for paginated execution listing and log search.
+
+CREATE INDEX IF NOT EXISTS idx_executions_user_created ON executions (user_id,created_at);
+
+-- Full-text search over execution logs and errors
+CREATE INDEX IF NOT EXISTS idx_executions_search ON executions
+    USING gin (to_tsvector('simple', coalesce(logs, '') || ' ' || coalesce(error, '')));
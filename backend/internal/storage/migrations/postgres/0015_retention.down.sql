@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS archived_executions;
+ALTER TABLE functions DROP COLUMN IF EXISTS retention_max_count;
+ALTER TABLE functions DROP COLUMN IF EXISTS retention_days;
+ALTER TABLE users DROP COLUMN IF EXISTS retention_max_count;
+ALTER TABLE users DROP COLUMN IF EXISTS retention_days;
@@ -0,0 +1,3 @@
+ALTER TABLE executions DROP COLUMN IF EXISTS logs_ref;
+ALTER TABLE executions DROP COLUMN IF EXISTS output_ref;
+ALTER TABLE executions DROP COLUMN IF EXISTS input_ref;
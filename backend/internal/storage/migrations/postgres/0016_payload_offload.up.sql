@@ -0,0 +1,5 @@
+-- Object store keys of offloaded execution payloads and logs.
+
+ALTER TABLE executions ADD COLUMN IF NOT EXISTS input_ref text;
+ALTER TABLE executions ADD COLUMN IF NOT EXISTS output_ref text;
+ALTER TABLE executions ADD COLUMN IF NOT EXISTS logs_ref text;
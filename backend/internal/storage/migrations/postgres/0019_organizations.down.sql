@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS idx_event_trigger_topic;
+CREATE INDEX idx_event_trigger_topic ON event_triggers (user_id,topic);
+
+ALTER TABLE functions DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE executions DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE archived_executions DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE event_triggers DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE workflows DROP COLUMN IF EXISTS organization_id;
+ALTER TABLE workflow_runs DROP COLUMN IF EXISTS organization_id;
+
+DROP TABLE IF EXISTS organization_invitations;
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
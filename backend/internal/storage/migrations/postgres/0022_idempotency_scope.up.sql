@@ -0,0 +1,20 @@
+-- Idempotency keys are scoped to the caller within an organization rather
+-- than to the function's creator. Existing keys cannot be attributed to a
+-- caller and are dropped; they only dedupe retries for a day.
+
+DROP TABLE IF EXISTS idempotency_keys;
+
+CREATE TABLE idempotency_keys (
+    id uuid DEFAULT gen_random_uuid(),
+    organization_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    function_id uuid NOT NULL,
+    key text NOT NULL,
+    request_hash text NOT NULL,
+    execution_id uuid NOT NULL,
+    expires_at timestamptz NOT NULL,
+    created_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
+CREATE UNIQUE INDEX idx_idempotency_key ON idempotency_keys (organization_id,user_id,function_id,key);
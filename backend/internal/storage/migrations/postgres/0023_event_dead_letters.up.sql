@@ -0,0 +1,20 @@
+-- Event messages that reach EVENTS_MAX_DELIVERIES are kept for inspection
+-- and redrive instead of being dropped.
+
+CREATE TABLE event_dead_letters (
+    id uuid DEFAULT gen_random_uuid(),
+    trigger_id uuid NOT NULL,
+    function_id uuid NOT NULL,
+    organization_id uuid NOT NULL,
+    message_id uuid NOT NULL,
+    topic text NOT NULL,
+    payload jsonb,
+    attempts bigint,
+    error text,
+    published_at timestamptz,
+    created_at timestamptz,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_event_dead_letters_trigger_id ON event_dead_letters (trigger_id);
+CREATE INDEX idx_event_dead_letters_function_id ON event_dead_letters (function_id);
+CREATE INDEX idx_event_dead_letters_organization_id ON event_dead_letters (organization_id);
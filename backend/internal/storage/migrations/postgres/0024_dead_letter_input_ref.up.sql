@@ -0,0 +1,4 @@
+-- Dead-letter inputs above the offload threshold live in the object store
+-- like execution payloads.
+
+ALTER TABLE dead_letters ADD COLUMN input_ref text;
@@ -0,0 +1,69 @@
+-- Baseline schema for SQLite. UUIDs and JSON are stored as text, and
+-- timestamps as UTC text that the driver parses back into time values.
+-- SQLite databases were never auto-migrated, so later migrations add
+-- their columns unconditionally.
+
+CREATE TABLE IF NOT EXISTS users (
+    id text,
+    email text NOT NULL,
+    password text NOT NULL,
+    name text,
+    created_at datetime,
+    updated_at datetime,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+
+CREATE TABLE IF NOT EXISTS functions (
+    id text,
+    user_id text NOT NULL,
+    name text NOT NULL,
+    description text,
+    runtime text NOT NULL,
+    code text NOT NULL,
+    entry_point text DEFAULT 'index.handler',
+    memory_mb integer DEFAULT 128,
+    timeout_sec integer DEFAULT 30,
+    status text DEFAULT 'active',
+    created_at datetime,
+    updated_at datetime,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_users_functions FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE INDEX IF NOT EXISTS idx_functions_user_id ON functions (user_id);
+
+CREATE TABLE IF NOT EXISTS executions (
+    id text,
+    user_id text NOT NULL,
+    function_id text NOT NULL,
+    status text DEFAULT 'pending',
+    input text,
+    output text,
+    error text,
+    logs text,
+    duration_ms integer,
+    memory_used integer,
+    started_at datetime,
+    completed_at datetime,
+    created_at datetime,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_functions_executions FOREIGN KEY (function_id) REFERENCES functions(id),
+    CONSTRAINT fk_users_executions FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE INDEX IF NOT EXISTS idx_executions_user_id ON executions (user_id);
+CREATE INDEX IF NOT EXISTS idx_executions_function_id ON executions (function_id);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id text,
+    user_id text NOT NULL,
+    name text NOT NULL,
+    key text NOT NULL,
+    prefix text NOT NULL,
+    last_used datetime,
+    expires_at datetime,
+    created_at datetime,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_users_api_keys FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_key ON api_keys (key);
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS function_secrets;
+ALTER TABLE functions DROP COLUMN environment;
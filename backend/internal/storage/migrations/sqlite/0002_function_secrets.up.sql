@@ -0,0 +1,17 @@
+-- Encrypted per-function secrets and plain environment variables.
+
+ALTER TABLE functions ADD COLUMN environment text;
+
+CREATE TABLE IF NOT EXISTS function_secrets (
+    id text,
+    function_id text NOT NULL,
+    user_id text NOT NULL,
+    name text NOT NULL,
+    ciphertext text NOT NULL,
+    key_id text NOT NULL,
+    created_at datetime,
+    updated_at datetime,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_function_secrets_user_id ON function_secrets (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_function_secret_name ON function_secrets (function_id,name);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS schedules;
+DROP INDEX IF EXISTS idx_executions_trigger_type;
+ALTER TABLE executions DROP COLUMN trigger_type;
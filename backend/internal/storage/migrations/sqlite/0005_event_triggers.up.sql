@@ -0,0 +1,27 @@
+-- Event triggers and the queue of messages published to their topics.
+
+CREATE TABLE IF NOT EXISTS event_triggers (
+    id text,
+    function_id text NOT NULL,
+    user_id text NOT NULL,
+    topic text NOT NULL,
+    batch_size integer NOT NULL DEFAULT 1,
+    enabled boolean NOT NULL,
+    created_at datetime,
+    updated_at datetime,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_event_triggers_function_id ON event_triggers (function_id);
+CREATE INDEX IF NOT EXISTS idx_event_trigger_topic ON event_triggers (user_id,topic);
+
+CREATE TABLE IF NOT EXISTS event_messages (
+    id text,
+    trigger_id text NOT NULL,
+    topic text NOT NULL,
+    payload text,
+    attempts integer NOT NULL DEFAULT 0,
+    visible_at datetime NOT NULL,
+    created_at datetime,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_event_message_ready ON event_messages (trigger_id,visible_at);
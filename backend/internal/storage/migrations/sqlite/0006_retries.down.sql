@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS dead_letters;
+DROP INDEX IF EXISTS idx_executions_original_execution_id;
+DROP INDEX IF EXISTS idx_executions_next_retry_at;
+ALTER TABLE executions DROP COLUMN next_retry_at;
+ALTER TABLE executions DROP COLUMN original_execution_id;
+ALTER TABLE executions DROP COLUMN attempt;
+ALTER TABLE executions DROP COLUMN error_class;
+ALTER TABLE functions DROP COLUMN retry_on;
+ALTER TABLE functions DROP COLUMN retry_max_backoff_sec;
+ALTER TABLE functions DROP COLUMN retry_backoff_sec;
+ALTER TABLE functions DROP COLUMN retry_max_attempts;
@@ -0,0 +1,34 @@
+-- Retry policies, retry chains of executions and their dead letters.
+
+ALTER TABLE functions ADD COLUMN retry_max_attempts integer NOT NULL DEFAULT 1;
+ALTER TABLE functions ADD COLUMN retry_backoff_sec integer NOT NULL DEFAULT 2;
+ALTER TABLE functions ADD COLUMN retry_max_backoff_sec integer NOT NULL DEFAULT 300;
+ALTER TABLE functions ADD COLUMN retry_on text NOT NULL DEFAULT 'timeout,system';
+
+ALTER TABLE executions ADD COLUMN error_class text;
+ALTER TABLE executions ADD COLUMN attempt integer NOT NULL DEFAULT 1;
+ALTER TABLE executions ADD COLUMN original_execution_id text;
+ALTER TABLE executions ADD COLUMN next_retry_at datetime;
+
+CREATE INDEX IF NOT EXISTS idx_executions_next_retry_at ON executions (next_retry_at);
+CREATE INDEX IF NOT EXISTS idx_executions_original_execution_id ON executions (original_execution_id);
+
+CREATE TABLE IF NOT EXISTS dead_letters (
+    id text,
+    function_id text NOT NULL,
+    user_id text NOT NULL,
+    execution_id text NOT NULL,
+    original_execution_id text NOT NULL,
+    input text,
+    error text,
+    error_class text,
+    attempts integer,
+    trigger_type text,
+    trigger_source text,
+    replayed_at datetime,
+    replay_execution_id text,
+    created_at datetime,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_dead_letters_user_id ON dead_letters (user_id);
+CREATE INDEX IF NOT EXISTS idx_dead_letters_function_id ON dead_letters (function_id);
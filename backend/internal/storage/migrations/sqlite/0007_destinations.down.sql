@@ -0,0 +1,7 @@
+ALTER TABLE executions DROP COLUMN chain_depth;
+ALTER TABLE executions DROP COLUMN on_failure;
+ALTER TABLE executions DROP COLUMN on_success;
+ALTER TABLE functions DROP COLUMN destination_secret_key_id;
+ALTER TABLE functions DROP COLUMN destination_secret_ciphertext;
+ALTER TABLE functions DROP COLUMN on_failure;
+ALTER TABLE functions DROP COLUMN on_success;
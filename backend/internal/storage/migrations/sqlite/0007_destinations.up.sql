@@ -0,0 +1,10 @@
+-- Completion destinations of functions and of the executions they chain.
+
+ALTER TABLE functions ADD COLUMN on_success text;
+ALTER TABLE functions ADD COLUMN on_failure text;
+ALTER TABLE functions ADD COLUMN destination_secret_ciphertext text;
+ALTER TABLE functions ADD COLUMN destination_secret_key_id text;
+
+ALTER TABLE executions ADD COLUMN on_success text;
+ALTER TABLE executions ADD COLUMN on_failure text;
+ALTER TABLE executions ADD COLUMN chain_depth integer NOT NULL DEFAULT 0;
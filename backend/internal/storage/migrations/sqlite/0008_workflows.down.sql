@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS workflow_steps;
+DROP TABLE IF EXISTS workflow_runs;
+DROP TABLE IF EXISTS workflows;
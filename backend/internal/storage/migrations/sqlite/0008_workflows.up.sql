@@ -0,0 +1,52 @@
+-- Workflows, their durable runs and the steps each run recorded.
+
+CREATE TABLE IF NOT EXISTS workflows (
+    id text,
+    user_id text NOT NULL,
+    name text NOT NULL,
+    description text,
+    definition text NOT NULL,
+    created_at datetime,
+    updated_at datetime,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_workflows_user_id ON workflows (user_id);
+
+CREATE TABLE IF NOT EXISTS workflow_runs (
+    id text,
+    workflow_id text NOT NULL,
+    user_id text NOT NULL,
+    status text NOT NULL,
+    definition text NOT NULL,
+    input text,
+    output text,
+    error text,
+    lease_owner text,
+    lease_until datetime,
+    started_at datetime,
+    completed_at datetime,
+    updated_at datetime,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_workflow_runs_status ON workflow_runs (status);
+CREATE INDEX IF NOT EXISTS idx_workflow_runs_user_id ON workflow_runs (user_id);
+CREATE INDEX IF NOT EXISTS idx_workflow_runs_workflow_id ON workflow_runs (workflow_id);
+CREATE INDEX IF NOT EXISTS idx_workflow_runs_lease_until ON workflow_runs (lease_until);
+
+CREATE TABLE IF NOT EXISTS workflow_steps (
+    id text,
+    run_id text NOT NULL,
+    path text NOT NULL,
+    name text NOT NULL,
+    type text NOT NULL,
+    status text NOT NULL,
+    input text,
+    output text,
+    error text,
+    execution_id text,
+    wake_at datetime,
+    started_at datetime,
+    completed_at datetime,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workflow_step_path ON workflow_steps (run_id,path);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS concurrency_leases;
+DROP TABLE IF EXISTS rate_limit_buckets;
+ALTER TABLE functions DROP COLUMN reserved_concurrency;
+ALTER TABLE functions DROP COLUMN rate_limit_burst;
+ALTER TABLE functions DROP COLUMN rate_limit_rps;
@@ -0,0 +1,21 @@
+-- Per-function rate limits and reserved concurrency, with the shared
+-- token buckets and concurrency leases behind them.
+
+ALTER TABLE functions ADD COLUMN rate_limit_rps real;
+ALTER TABLE functions ADD COLUMN rate_limit_burst integer;
+ALTER TABLE functions ADD COLUMN reserved_concurrency integer NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key text,
+    tokens real NOT NULL,
+    updated_at datetime NOT NULL,
+    PRIMARY KEY (key)
+);
+
+CREATE TABLE IF NOT EXISTS concurrency_leases (
+    id text,
+    key text NOT NULL,
+    expires_at datetime NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_concurrency_leases_key ON concurrency_leases (key);
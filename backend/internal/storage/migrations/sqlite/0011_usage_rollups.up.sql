@@ -0,0 +1,16 @@
+-- Hourly usage rollups for metering.
+
+CREATE TABLE IF NOT EXISTS usage_rollups (
+    id text,
+    user_id text NOT NULL,
+    function_id text NOT NULL,
+    hour datetime NOT NULL,
+    invocations integer NOT NULL,
+    errors integer NOT NULL,
+    duration_ms integer NOT NULL,
+    gb_seconds real NOT NULL,
+    egress_bytes integer NOT NULL,
+    updated_at datetime,
+    PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_usage_rollup_hour ON usage_rollups (user_id,function_id,hour);
@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN plan_limits;
+ALTER TABLE users DROP COLUMN plan;
@@ -0,0 +1,4 @@
+-- Account plans and custom plan limits.
+
+ALTER TABLE users ADD COLUMN plan text NOT NULL DEFAULT 'free';
+ALTER TABLE users ADD COLUMN plan_limits text;
@@ -0,0 +1,5 @@
+-- Cold starts and the per-function index behind the metrics endpoint.
+
+ALTER TABLE executions ADD COLUMN cold_start boolean NOT NULL DEFAULT false;
+
+CREATE INDEX IF NOT EXISTS idx_executions_function_created ON executions (function_id,created_at);
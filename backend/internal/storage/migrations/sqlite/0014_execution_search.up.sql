@@ -0,0 +1,3 @@
+-- Indexes for paginated execution listing and log search.
+
+CREATE INDEX IF NOT EXISTS idx_executions_user_created ON executions (user_id,created_at);
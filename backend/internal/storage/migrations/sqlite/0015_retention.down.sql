@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS archived_executions;
+ALTER TABLE functions DROP COLUMN retention_max_count;
+ALTER TABLE functions DROP COLUMN retention_days;
+ALTER TABLE users DROP COLUMN retention_max_count;
+ALTER TABLE users DROP COLUMN retention_days;
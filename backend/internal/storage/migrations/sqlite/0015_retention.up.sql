@@ -0,0 +1,23 @@
+-- Execution retention settings and the index of archived executions.
+
+ALTER TABLE users ADD COLUMN retention_days integer NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN retention_max_count integer NOT NULL DEFAULT 0;
+
+ALTER TABLE functions ADD COLUMN retention_days integer NOT NULL DEFAULT 0;
+ALTER TABLE functions ADD COLUMN retention_max_count integer NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS archived_executions (
+    id text,
+    user_id text NOT NULL,
+    function_id text NOT NULL,
+    status text,
+    trigger_type text,
+    duration_ms integer,
+    created_at datetime,
+    object_key text NOT NULL,
+    archived_at datetime NOT NULL,
+    PRIMARY KEY (id)
+);
+CREATE INDEX IF NOT EXISTS idx_archived_executions_object_key ON archived_executions (object_key);
+CREATE INDEX IF NOT EXISTS idx_archived_executions_function_id ON archived_executions (function_id);
+CREATE INDEX IF NOT EXISTS idx_archived_user_created ON archived_executions (user_id,created_at);
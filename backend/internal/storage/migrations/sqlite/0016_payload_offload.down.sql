@@ -0,0 +1,3 @@
+ALTER TABLE executions DROP COLUMN logs_ref;
+ALTER TABLE executions DROP COLUMN output_ref;
+ALTER TABLE executions DROP COLUMN input_ref;
@@ -0,0 +1,5 @@
+-- Object store keys of offloaded execution payloads and logs.
+
+ALTER TABLE executions ADD COLUMN input_ref text;
+ALTER TABLE executions ADD COLUMN output_ref text;
+ALTER TABLE executions ADD COLUMN logs_ref text;
@@ -0,0 +1,29 @@
+-- Sign-in sessions with rotating refresh tokens. Rotated tokens are kept
+-- so that presenting one again can be detected as reuse.
+
+CREATE TABLE sessions (
+    id text,
+    user_id text NOT NULL,
+    user_agent text,
+    ip_address text,
+    expires_at datetime NOT NULL,
+    revoked_at datetime,
+    last_used_at datetime,
+    created_at datetime,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_users_sessions FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE INDEX idx_sessions_user_id ON sessions (user_id);
+CREATE INDEX idx_sessions_expires_at ON sessions (expires_at);
+
+CREATE TABLE refresh_tokens (
+    id text,
+    session_id text NOT NULL,
+    token_hash text NOT NULL,
+    used_at datetime,
+    created_at datetime,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_sessions_refresh_tokens FOREIGN KEY (session_id) REFERENCES sessions(id) ON DELETE CASCADE
+);
+CREATE UNIQUE INDEX idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+CREATE INDEX idx_refresh_tokens_session_id ON refresh_tokens (session_id);
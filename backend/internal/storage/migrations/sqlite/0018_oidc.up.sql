@@ -0,0 +1,27 @@
+-- OpenID Connect logins: identities linked to users, and the state kept
+-- between the redirect to a provider and its callback.
+
+CREATE TABLE user_identities (
+    id text,
+    user_id text NOT NULL,
+    provider text NOT NULL,
+    issuer text NOT NULL,
+    subject text NOT NULL,
+    email text,
+    created_at datetime,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_users_identities FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE INDEX idx_user_identities_user_id ON user_identities (user_id);
+CREATE UNIQUE INDEX idx_user_identity_subject ON user_identities (issuer,subject);
+
+CREATE TABLE oidc_login_states (
+    state text,
+    provider text NOT NULL,
+    code_verifier text NOT NULL,
+    nonce text NOT NULL,
+    expires_at datetime NOT NULL,
+    created_at datetime,
+    PRIMARY KEY (state)
+);
+CREATE INDEX idx_oidc_login_states_expires_at ON oidc_login_states (expires_at);
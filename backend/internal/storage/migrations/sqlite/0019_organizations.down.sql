@@ -0,0 +1,22 @@
+DROP INDEX IF EXISTS idx_event_trigger_topic;
+CREATE INDEX idx_event_trigger_topic ON event_triggers (user_id,topic);
+
+DROP INDEX IF EXISTS idx_functions_organization_id;
+DROP INDEX IF EXISTS idx_api_keys_organization_id;
+DROP INDEX IF EXISTS idx_executions_organization_id;
+DROP INDEX IF EXISTS idx_executions_org_created;
+DROP INDEX IF EXISTS idx_archived_org_created;
+DROP INDEX IF EXISTS idx_workflows_organization_id;
+DROP INDEX IF EXISTS idx_workflow_runs_organization_id;
+
+ALTER TABLE functions DROP COLUMN organization_id;
+ALTER TABLE api_keys DROP COLUMN organization_id;
+ALTER TABLE executions DROP COLUMN organization_id;
+ALTER TABLE archived_executions DROP COLUMN organization_id;
+ALTER TABLE event_triggers DROP COLUMN organization_id;
+ALTER TABLE workflows DROP COLUMN organization_id;
+ALTER TABLE workflow_runs DROP COLUMN organization_id;
+
+DROP TABLE IF EXISTS organization_invitations;
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
@@ -0,0 +1,82 @@
+-- Organizations own functions, API keys, executions, event triggers and
+-- workflows, and grant their members a role. Every existing user gets a
+-- personal organization with the user's ID, so ownership is backfilled
+-- from user_id. SQLite cannot add NOT NULL or foreign key columns to
+-- existing tables, so the models enforce those for the new columns.
+
+CREATE TABLE organizations (
+    id text,
+    name text NOT NULL,
+    personal boolean NOT NULL DEFAULT false,
+    created_at datetime,
+    updated_at datetime,
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE organization_members (
+    id text,
+    organization_id text NOT NULL,
+    user_id text NOT NULL,
+    role text NOT NULL,
+    created_at datetime,
+    updated_at datetime,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_organizations_members FOREIGN KEY (organization_id) REFERENCES organizations(id),
+    CONSTRAINT fk_users_memberships FOREIGN KEY (user_id) REFERENCES users(id)
+);
+CREATE UNIQUE INDEX idx_organization_member ON organization_members (organization_id,user_id);
+CREATE INDEX idx_organization_members_user_id ON organization_members (user_id);
+
+CREATE TABLE organization_invitations (
+    id text,
+    organization_id text NOT NULL,
+    email text NOT NULL,
+    role text NOT NULL,
+    token_hash text NOT NULL,
+    invited_by text NOT NULL,
+    expires_at datetime NOT NULL,
+    accepted_at datetime,
+    created_at datetime,
+    PRIMARY KEY (id),
+    CONSTRAINT fk_organizations_invitations FOREIGN KEY (organization_id) REFERENCES organizations(id)
+);
+CREATE INDEX idx_organization_invitations_organization_id ON organization_invitations (organization_id);
+CREATE UNIQUE INDEX idx_organization_invitations_token_hash ON organization_invitations (token_hash);
+
+INSERT INTO organizations (id, name, personal, created_at, updated_at)
+SELECT id, 'Personal', true, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP FROM users;
+INSERT INTO organization_members (organization_id, user_id, role, created_at, updated_at)
+SELECT id, id, 'owner', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP FROM users;
+
+ALTER TABLE functions ADD COLUMN organization_id text;
+UPDATE functions SET organization_id = user_id;
+
+ALTER TABLE api_keys ADD COLUMN organization_id text;
+UPDATE api_keys SET organization_id = user_id;
+
+ALTER TABLE executions ADD COLUMN organization_id text;
+UPDATE executions SET organization_id = user_id;
+
+ALTER TABLE archived_executions ADD COLUMN organization_id text;
+UPDATE archived_executions SET organization_id = user_id;
+
+ALTER TABLE event_triggers ADD COLUMN organization_id text;
+UPDATE event_triggers SET organization_id = user_id;
+
+ALTER TABLE workflows ADD COLUMN organization_id text;
+UPDATE workflows SET organization_id = user_id;
+
+ALTER TABLE workflow_runs ADD COLUMN organization_id text;
+UPDATE workflow_runs SET organization_id = user_id;
+
+CREATE INDEX idx_functions_organization_id ON functions (organization_id);
+CREATE INDEX idx_api_keys_organization_id ON api_keys (organization_id);
+CREATE INDEX idx_executions_organization_id ON executions (organization_id);
+CREATE INDEX idx_executions_org_created ON executions (organization_id,created_at);
+CREATE INDEX idx_archived_org_created ON archived_executions (organization_id,created_at);
+CREATE INDEX idx_workflows_organization_id ON workflows (organization_id);
+CREATE INDEX idx_workflow_runs_organization_id ON workflow_runs (organization_id);
+
+-- Event topics are namespaced by organization instead of user
+DROP INDEX idx_event_trigger_topic;
+CREATE INDEX idx_event_trigger_topic ON event_triggers (organization_id,topic);
@@ -0,0 +1,14 @@
+-- Webhooks always reject replays: generic webhooks sign a timestamp,
+-- GitHub deliveries are remembered by their delivery ID, and GitHub is
+-- verified with sha256 only.
+
+CREATE TABLE webhook_deliveries (
+    webhook_id text,
+    delivery_id text,
+    created_at datetime,
+    PRIMARY KEY (webhook_id, delivery_id)
+);
+
+UPDATE webhook_triggers SET timestamp_header = 'X-Timestamp'
+WHERE provider = 'generic' AND (timestamp_header IS NULL OR timestamp_header = '');
+UPDATE webhook_triggers SET algorithm = 'sha256' WHERE provider = 'github';
@@ -0,0 +1,21 @@
+ALTER TABLE users ADD COLUMN plan text NOT NULL DEFAULT 'free';
+ALTER TABLE users ADD COLUMN plan_limits text;
+ALTER TABLE users ADD COLUMN retention_days integer NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN retention_max_count integer NOT NULL DEFAULT 0;
+
+UPDATE users SET
+    plan = (SELECT plan FROM organizations WHERE organizations.id = users.id),
+    plan_limits = (SELECT plan_limits FROM organizations WHERE organizations.id = users.id),
+    retention_days = (SELECT retention_days FROM organizations WHERE organizations.id = users.id),
+    retention_max_count = (SELECT retention_max_count FROM organizations WHERE organizations.id = users.id)
+WHERE EXISTS (SELECT 1 FROM organizations WHERE organizations.id = users.id);
+
+-- Usage of shared organizations is returned to the function's creator
+UPDATE usage_rollups SET organization_id = (SELECT user_id FROM functions WHERE functions.id = usage_rollups.function_id)
+WHERE EXISTS (SELECT 1 FROM functions WHERE functions.id = usage_rollups.function_id);
+ALTER TABLE usage_rollups RENAME COLUMN organization_id TO user_id;
+
+ALTER TABLE organizations DROP COLUMN plan;
+ALTER TABLE organizations DROP COLUMN plan_limits;
+ALTER TABLE organizations DROP COLUMN retention_days;
+ALTER TABLE organizations DROP COLUMN retention_max_count;
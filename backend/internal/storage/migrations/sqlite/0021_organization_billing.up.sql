@@ -0,0 +1,39 @@
+-- Organizations become the billing subject: plans, quotas, retention and
+-- metered usage move from users to organizations, so every execution in
+-- an organization counts against the same plan. Personal organizations
+-- inherit their user's settings and shared ones their first owner's plan.
+
+ALTER TABLE organizations ADD COLUMN plan text NOT NULL DEFAULT 'free';
+ALTER TABLE organizations ADD COLUMN plan_limits text;
+ALTER TABLE organizations ADD COLUMN retention_days integer NOT NULL DEFAULT 0;
+ALTER TABLE organizations ADD COLUMN retention_max_count integer NOT NULL DEFAULT 0;
+
+UPDATE organizations SET
+    plan = (SELECT plan FROM users WHERE users.id = organizations.id),
+    plan_limits = (SELECT plan_limits FROM users WHERE users.id = organizations.id),
+    retention_days = (SELECT retention_days FROM users WHERE users.id = organizations.id),
+    retention_max_count = (SELECT retention_max_count FROM users WHERE users.id = organizations.id)
+WHERE personal AND EXISTS (SELECT 1 FROM users WHERE users.id = organizations.id);
+
+UPDATE organizations SET
+    plan = (SELECT users.plan FROM organization_members
+        JOIN users ON users.id = organization_members.user_id
+        WHERE organization_members.organization_id = organizations.id AND organization_members.role = 'owner'
+        ORDER BY organization_members.created_at LIMIT 1),
+    plan_limits = (SELECT users.plan_limits FROM organization_members
+        JOIN users ON users.id = organization_members.user_id
+        WHERE organization_members.organization_id = organizations.id AND organization_members.role = 'owner'
+        ORDER BY organization_members.created_at LIMIT 1)
+WHERE NOT personal AND EXISTS (SELECT 1 FROM organization_members
+    WHERE organization_members.organization_id = organizations.id AND organization_members.role = 'owner');
+
+-- Rollups of functions in shared organizations were kept under the
+-- function's creator
+ALTER TABLE usage_rollups RENAME COLUMN user_id TO organization_id;
+UPDATE usage_rollups SET organization_id = (SELECT organization_id FROM functions WHERE functions.id = usage_rollups.function_id)
+WHERE EXISTS (SELECT 1 FROM functions WHERE functions.id = usage_rollups.function_id);
+
+ALTER TABLE users DROP COLUMN plan;
+ALTER TABLE users DROP COLUMN plan_limits;
+ALTER TABLE users DROP COLUMN retention_days;
+ALTER TABLE users DROP COLUMN retention_max_count;
@@ -0,0 +1,15 @@
+DROP TABLE IF EXISTS idempotency_keys;
+
+CREATE TABLE idempotency_keys (
+    id text,
+    user_id text NOT NULL,
+    function_id text NOT NULL,
+    key text NOT NULL,
+    request_hash text NOT NULL,
+    execution_id text NOT NULL,
+    expires_at datetime NOT NULL,
+    created_at datetime,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at);
+CREATE UNIQUE INDEX idx_idempotency_key ON idempotency_keys (user_id,function_id,key);
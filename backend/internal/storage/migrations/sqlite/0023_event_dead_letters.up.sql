@@ -0,0 +1,20 @@
+-- Event messages that reach EVENTS_MAX_DELIVERIES are kept for inspection
+-- and redrive instead of being dropped.
+
+CREATE TABLE event_dead_letters (
+    id text,
+    trigger_id text NOT NULL,
+    function_id text NOT NULL,
+    organization_id text NOT NULL,
+    message_id text NOT NULL,
+    topic text NOT NULL,
+    payload text,
+    attempts integer,
+    error text,
+    published_at datetime,
+    created_at datetime,
+    PRIMARY KEY (id)
+);
+CREATE INDEX idx_event_dead_letters_trigger_id ON event_dead_letters (trigger_id);
+CREATE INDEX idx_event_dead_letters_function_id ON event_dead_letters (function_id);
+CREATE INDEX idx_event_dead_letters_organization_id ON event_dead_letters (organization_id);
@@ -0,0 +1,3 @@
+-- Lets the retention reaper forget old webhook delivery IDs
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_created_at ON webhook_deliveries (created_at);
@@ -1,108 +1,630 @@
-package storage
-
-import (
-	"time"
-
-	"github.com/google/uuid"
-	"gorm.io/datatypes"
-	"gorm.io/gorm"
-)
-
-// User represents a platform user
-type User struct {
-	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	Email     string    `gorm:"uniqueIndex;not null" json:"email"`
-	Password  string    `gorm:"not null" json:"-"` // bcrypt hash
-	Name      string    `json:"name"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-
-	Functions  []Function  `gorm:"foreignKey:UserID" json:"functions,omitempty"`
-	APIKeys    []APIKey    `gorm:"foreignKey:UserID" json:"api_keys,omitempty"`
-	Executions []Execution `gorm:"foreignKey:UserID" json:"executions,omitempty"`
-}
-
-// Function represents a user-uploaded cloud function
-type Function struct {
-	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	UserID      uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
-	Name        string    `gorm:"not null" json:"name"`
-	Description string    `json:"description"`
-	Runtime     string    `gorm:"not null" json:"runtime"` // nodejs, python, go
-	Code        string    `gorm:"type:text;not null" json:"code"`
-	EntryPoint  string    `gorm:"default:index.handler" json:"entry_point"`
-	MemoryMB    int       `gorm:"default:128" json:"memory_mb"`
-	TimeoutSec  int       `gorm:"default:30" json:"timeout_sec"`
-	Status      string    `gorm:"default:active" json:"status"` // active, inactive, error
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-
-	User       User        `gorm:"foreignKey:UserID" json:"user,omitempty"`
-	Executions []Execution `gorm:"foreignKey:FunctionID" json:"executions,omitempty"`
-}
-
-// Execution represents a single function execution
-type Execution struct {
-	ID         uuid.UUID      `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	UserID     uuid.UUID      `gorm:"type:uuid;not null;index" json:"user_id"`
-	FunctionID uuid.UUID      `gorm:"type:uuid;not null;index" json:"function_id"`
-	Status     string         `gorm:"default:pending" json:"status"` // pending, running, success, failed
-	Input      datatypes.JSON `gorm:"type:jsonb" json:"input"`
-	Output     datatypes.JSON `gorm:"type:jsonb" json:"output"`
-	Error      string         `gorm:"type:text" json:"error,omitempty"`
-	Logs       string         `gorm:"type:text" json:"logs"`
-	DurationMS int64          `json:"duration_ms"`
-	MemoryUsed int            `json:"memory_used"` // in MB
-	StartedAt  *time.Time     `json:"started_at,omitempty"`
-	CompletedAt *time.Time    `json:"completed_at,omitempty"`
-	CreatedAt  time.Time      `json:"created_at"`
-
-	User     User     `gorm:"foreignKey:UserID" json:"user,omitempty"`
-	Function Function `gorm:"foreignKey:FunctionID" json:"function,omitempty"`
-}
-
-// APIKey represents an API key for function invocation
-type APIKey struct {
-	ID        uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	UserID    uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
-	Name      string    `gorm:"not null" json:"name"`
-	Key       string    `gorm:"uniqueIndex;not null" json:"key"` // hashed
-	Prefix    string    `gorm:"not null" json:"prefix"`          // first 8 chars for display
-	LastUsed  *time.Time `json:"last_used,omitempty"`
-	ExpiresAt *time.Time `json:"expires_at,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
-
-	User User `gorm:"foreignKey:UserID" json:"user,omitempty"`
-}
-
-// BeforeCreate hook for User
-func (u *User) BeforeCreate(tx *gorm.DB) error {
-	if u.ID == uuid.Nil {
-		u.ID = uuid.New()
-	}
-	return nil
-}
-
-// BeforeCreate hook for Function
-func (f *Function) BeforeCreate(tx *gorm.DB) error {
-	if f.ID == uuid.Nil {
-		f.ID = uuid.New()
-	}
-	return nil
-}
-
-// BeforeCreate hook for Execution
-func (e *Execution) BeforeCreate(tx *gorm.DB) error {
-	if e.ID == uuid.Nil {
-		e.ID = uuid.New()
-	}
-	return nil
-}
-
-// BeforeCreate hook for APIKey
-func (k *APIKey) BeforeCreate(tx *gorm.DB) error {
-	if k.ID == uuid.Nil {
-		k.ID = uuid.New()
-	}
-	return nil
-}
+package storage
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// User represents a platform user
+type User struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	Email     string    `gorm:"uniqueIndex;not null" json:"email"`
+	Password  string    `gorm:"not null" json:"-"` // bcrypt hash
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Functions  []Function  `gorm:"foreignKey:UserID" json:"functions,omitempty"`
+	APIKeys    []APIKey    `gorm:"foreignKey:UserID" json:"api_keys,omitempty"`
+	Executions []Execution `gorm:"foreignKey:UserID" json:"executions,omitempty"`
+}
+
+// Function represents a user-uploaded cloud function
+type Function struct {
+	ID             uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	OrganizationID uuid.UUID `gorm:"type:uuid;not null;index" json:"organization_id"`
+	UserID         uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"` // creator
+	Name           string    `gorm:"not null" json:"name"`
+	Description    string    `json:"description"`
+	Runtime        string    `gorm:"not null" json:"runtime"` // nodejs, python, go
+	Code           string    `gorm:"type:text;not null" json:"code"`
+	EntryPoint     string    `gorm:"default:index.handler" json:"entry_point"`
+	MemoryMB       int       `gorm:"default:128" json:"memory_mb"`
+	TimeoutSec     int       `gorm:"default:30" json:"timeout_sec"`
+
+	// Retry policy for asynchronous invocations
+	RetryMaxAttempts   int    `gorm:"not null;default:1" json:"retry_max_attempts"` // total attempts, 1 disables retries
+	RetryBackoffSec    int    `gorm:"not null;default:2" json:"retry_backoff_sec"`  // delay before the first retry, doubled per attempt
+	RetryMaxBackoffSec int    `gorm:"not null;default:300" json:"retry_max_backoff_sec"`
+	RetryOn            string `gorm:"not null;default:'timeout,system'" json:"retry_on"` // comma-separated error classes
+
+	// Rate and concurrency limits; zero means the user's defaults apply
+	RateLimitRPS        float64 `json:"rate_limit_rps"`
+	RateLimitBurst      int     `json:"rate_limit_burst"`
+	ReservedConcurrency int     `gorm:"not null;default:0" json:"reserved_concurrency"` // dedicated slots carved out of the user's limit
+
+	// Completion destinations for asynchronous invocations
+	OnSuccess                   datatypes.JSON `json:"on_success,omitempty"`
+	OnFailure                   datatypes.JSON `json:"on_failure,omitempty"`
+	DestinationSecretCiphertext string         `gorm:"type:text" json:"-"` // encrypted HMAC key for http callbacks
+	DestinationSecretKeyID      string         `json:"-"`
+
+	// Execution retention; zero means the account's setting applies
+	RetentionDays     int `gorm:"not null;default:0" json:"retention_days"`
+	RetentionMaxCount int `gorm:"not null;default:0" json:"retention_max_count"`
+
+	Status      string         `gorm:"default:active" json:"status"` // active, inactive, error
+	Environment datatypes.JSON `json:"environment"`                  // plain environment variables
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+
+	// SecretNames lists the function's secrets; values are never serialized
+	SecretNames []string `gorm:"-" json:"secrets,omitempty"`
+
+	User       User        `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Executions []Execution `gorm:"foreignKey:FunctionID" json:"executions,omitempty"`
+}
+
+// FunctionSecret represents an environment variable encrypted at rest
+type FunctionSecret struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	FunctionID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_function_secret_name" json:"function_id"`
+	UserID     uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	Name       string    `gorm:"not null;uniqueIndex:idx_function_secret_name" json:"name"`
+	Ciphertext string    `gorm:"type:text;not null" json:"-"` // base64 nonce + AES-GCM sealed value
+	KeyID      string    `gorm:"not null" json:"key_id"`      // master key used to encrypt
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Execution represents a single function execution
+type Execution struct {
+	ID             uuid.UUID      `gorm:"type:uuid;primary_key" json:"id"`
+	OrganizationID uuid.UUID      `gorm:"type:uuid;not null;index;index:idx_executions_org_created,priority:1" json:"organization_id"`
+	UserID         uuid.UUID      `gorm:"type:uuid;not null;index;index:idx_executions_user_created,priority:1" json:"user_id"`
+	FunctionID     uuid.UUID      `gorm:"type:uuid;not null;index;index:idx_executions_function_created,priority:1" json:"function_id"`
+	Status         string         `gorm:"default:pending" json:"status"`          // pending, running, success, failed
+	TriggerType    string         `gorm:"default:http;index" json:"trigger_type"` // http, schedule, webhook, event
+	TriggerSource  string         `gorm:"index" json:"trigger_source,omitempty"`  // ID of the schedule, webhook or event trigger that fired
+	Input          datatypes.JSON `json:"input"`
+	Output         datatypes.JSON `json:"output"`
+	Error          string         `gorm:"type:text" json:"error,omitempty"`
+	ErrorClass     string         `json:"error_class,omitempty"` // timeout, handler, system
+	Logs           string         `gorm:"type:text" json:"logs"`
+	InputRef       string         `json:"-"` // object store keys of offloaded payloads
+	OutputRef      string         `json:"-"`
+	LogsRef        string         `json:"-"`
+	DurationMS     int64          `json:"duration_ms"`
+	MemoryUsed     int            `json:"memory_used"`                              // in MB
+	ColdStart      bool           `gorm:"not null;default:false" json:"cold_start"` // the execution booted a new VM
+	StartedAt      *time.Time     `json:"started_at,omitempty"`
+	CompletedAt    *time.Time     `json:"completed_at,omitempty"`
+	CreatedAt      time.Time      `gorm:"index:idx_executions_function_created,priority:2;index:idx_executions_user_created,priority:2;index:idx_executions_org_created,priority:2" json:"created_at"`
+
+	// Retry bookkeeping for asynchronous invocations
+	Attempt             int        `gorm:"not null;default:1" json:"attempt"`
+	OriginalExecutionID *uuid.UUID `gorm:"type:uuid;index" json:"original_execution_id,omitempty"` // first attempt of a retry chain
+	NextRetryAt         *time.Time `gorm:"index" json:"next_retry_at,omitempty"`                   // set while a retry is waiting
+
+	// Per-request destinations override the function's
+	OnSuccess  datatypes.JSON `json:"on_success,omitempty"`
+	OnFailure  datatypes.JSON `json:"on_failure,omitempty"`
+	ChainDepth int            `gorm:"not null;default:0" json:"chain_depth,omitempty"` // functions chained before this one
+
+	User     User     `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Function Function `gorm:"foreignKey:FunctionID" json:"function,omitempty"`
+}
+
+// Schedule represents a cron trigger for a function
+type Schedule struct {
+	ID         uuid.UUID      `gorm:"type:uuid;primary_key" json:"id"`
+	FunctionID uuid.UUID      `gorm:"type:uuid;not null;index" json:"function_id"`
+	UserID     uuid.UUID      `gorm:"type:uuid;not null;index" json:"user_id"`
+	Expression string         `gorm:"not null" json:"expression"` // 5-field cron or descriptor such as @every 5m
+	Timezone   string         `gorm:"not null;default:UTC" json:"timezone"`
+	Input      datatypes.JSON `json:"input"`
+	Enabled    bool           `gorm:"not null" json:"enabled"`
+	NextRunAt  *time.Time     `gorm:"index" json:"next_run_at,omitempty"`
+	LastRunAt  *time.Time     `json:"last_run_at,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+
+	Function Function `gorm:"foreignKey:FunctionID" json:"-"`
+}
+
+// WebhookTrigger invokes a function from a signed HTTP request
+type WebhookTrigger struct {
+	ID               uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	FunctionID       uuid.UUID `gorm:"type:uuid;not null;index" json:"function_id"`
+	UserID           uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	Name             string    `gorm:"not null" json:"name"`
+	Provider         string    `gorm:"not null;default:generic" json:"provider"` // generic, github, stripe
+	SecretCiphertext string    `gorm:"type:text;not null" json:"-"`              // encrypted signing secret
+	SecretKeyID      string    `gorm:"not null" json:"-"`
+	SignatureHeader  string    `json:"signature_header,omitempty"`
+	Algorithm        string    `gorm:"not null;default:sha256" json:"algorithm"` // sha1, sha256, sha512
+	TimestampHeader  string    `json:"timestamp_header,omitempty"`               // generic only
+	ToleranceSec     int       `gorm:"not null;default:300" json:"tolerance_sec"`
+	Enabled          bool      `gorm:"not null" json:"enabled"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery remembers a delivery ID of a provider that signs no
+// timestamp, so a captured request cannot be replayed
+type WebhookDelivery struct {
+	WebhookID  uuid.UUID `gorm:"type:uuid;primary_key"`
+	DeliveryID string    `gorm:"primary_key"`
+	CreatedAt  time.Time `gorm:"index"` // forgotten after retention.DeliveryTTL
+}
+
+// EventTrigger invokes a function for messages published to a topic
+type EventTrigger struct {
+	ID             uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	FunctionID     uuid.UUID `gorm:"type:uuid;not null;index" json:"function_id"`
+	UserID         uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`                                       // creator
+	OrganizationID uuid.UUID `gorm:"type:uuid;not null;index:idx_event_trigger_topic" json:"organization_id"` // topics are namespaced by organization
+	Topic          string    `gorm:"not null;index:idx_event_trigger_topic" json:"topic"`
+	BatchSize      int       `gorm:"not null;default:1" json:"batch_size"`
+	Enabled        bool      `gorm:"not null" json:"enabled"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// EventMessage is a published event queued for delivery to one trigger.
+// The row is deleted once the trigger's function has processed it.
+type EventMessage struct {
+	ID        uuid.UUID      `gorm:"type:uuid;primary_key" json:"id"`
+	TriggerID uuid.UUID      `gorm:"type:uuid;not null;index:idx_event_message_ready" json:"trigger_id"`
+	Topic     string         `gorm:"not null" json:"topic"`
+	Payload   datatypes.JSON `json:"payload"`
+	Attempts  int            `gorm:"not null;default:0" json:"attempts"`
+	VisibleAt time.Time      `gorm:"not null;index:idx_event_message_ready" json:"visible_at"` // hidden from consumers until then
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// EventDeadLetter keeps a message its trigger failed to process
+// EVENTS_MAX_DELIVERIES times
+type EventDeadLetter struct {
+	ID             uuid.UUID      `gorm:"type:uuid;primary_key" json:"id"`
+	TriggerID      uuid.UUID      `gorm:"type:uuid;not null;index" json:"trigger_id"`
+	FunctionID     uuid.UUID      `gorm:"type:uuid;not null;index" json:"function_id"`
+	OrganizationID uuid.UUID      `gorm:"type:uuid;not null;index" json:"organization_id"`
+	MessageID      uuid.UUID      `gorm:"type:uuid;not null" json:"message_id"`
+	Topic          string         `gorm:"not null" json:"topic"`
+	Payload        datatypes.JSON `json:"payload"`
+	Attempts       int            `json:"attempts"`
+	Error          string         `gorm:"type:text" json:"error"` // from the last delivery
+	PublishedAt    time.Time      `json:"published_at"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+// DeadLetter records an asynchronous invocation that failed every attempt
+type DeadLetter struct {
+	ID                  uuid.UUID      `gorm:"type:uuid;primary_key" json:"id"`
+	FunctionID          uuid.UUID      `gorm:"type:uuid;not null;index" json:"function_id"`
+	UserID              uuid.UUID      `gorm:"type:uuid;not null;index" json:"user_id"`
+	ExecutionID         uuid.UUID      `gorm:"type:uuid;not null" json:"execution_id"` // last failed attempt
+	OriginalExecutionID uuid.UUID      `gorm:"type:uuid;not null" json:"original_execution_id"`
+	Input               datatypes.JSON `json:"input"`
+	InputRef            string         `json:"-"` // object store key of an offloaded input
+	Error               string         `gorm:"type:text" json:"error"`
+	ErrorClass          string         `json:"error_class"`
+	Attempts            int            `json:"attempts"`
+	TriggerType         string         `json:"trigger_type"`
+	TriggerSource       string         `json:"trigger_source,omitempty"`
+	ReplayedAt          *time.Time     `json:"replayed_at,omitempty"`
+	ReplayExecutionID   *uuid.UUID     `gorm:"type:uuid" json:"replay_execution_id,omitempty"`
+	CreatedAt           time.Time      `json:"created_at"`
+}
+
+// IdempotencyKey maps a client-supplied key to the execution it started
+type IdempotencyKey struct {
+	ID             uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	OrganizationID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_idempotency_key" json:"organization_id"`
+	UserID         uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_idempotency_key" json:"user_id"` // caller
+	FunctionID     uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_idempotency_key" json:"function_id"`
+	Key            string    `gorm:"not null;uniqueIndex:idx_idempotency_key" json:"key"`
+	RequestHash    string    `gorm:"not null" json:"-"` // sha256 of the request body
+	ExecutionID    uuid.UUID `gorm:"type:uuid;not null" json:"execution_id"`
+	ExpiresAt      time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// RateLimitBucket is a token bucket shared by replicas
+type RateLimitBucket struct {
+	Key       string    `gorm:"primaryKey" json:"key"`
+	Tokens    float64   `gorm:"not null" json:"tokens"`
+	UpdatedAt time.Time `gorm:"not null" json:"updated_at"`
+}
+
+// ConcurrencyLease is an execution slot held by a replica until released
+// or expired
+type ConcurrencyLease struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	Key       string    `gorm:"not null;index" json:"key"`
+	ExpiresAt time.Time `gorm:"not null" json:"expires_at"`
+}
+
+// UsageRollup aggregates metered usage per organization and function per
+// hour
+type UsageRollup struct {
+	ID             uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	OrganizationID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_usage_rollup_hour" json:"organization_id"`
+	FunctionID     uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_usage_rollup_hour" json:"function_id"`
+	Hour           time.Time `gorm:"not null;uniqueIndex:idx_usage_rollup_hour" json:"hour"` // start of the UTC hour
+	Invocations    int64     `gorm:"not null" json:"invocations"`
+	Errors         int64     `gorm:"not null" json:"errors"`
+	DurationMS     int64     `gorm:"not null" json:"duration_ms"`
+	GBSeconds      float64   `gorm:"not null" json:"gb_seconds"`
+	EgressBytes    int64     `gorm:"not null" json:"egress_bytes"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// ArchivedExecution indexes an execution moved out of Postgres by the
+// retention reaper. The full record lives in a compressed JSONL object.
+type ArchivedExecution struct {
+	ID             uuid.UUID `gorm:"type:uuid;primary_key" json:"id"` // the original execution ID
+	OrganizationID uuid.UUID `gorm:"type:uuid;not null;index:idx_archived_org_created,priority:1" json:"organization_id"`
+	UserID         uuid.UUID `gorm:"type:uuid;not null;index:idx_archived_user_created,priority:1" json:"user_id"`
+	FunctionID     uuid.UUID `gorm:"type:uuid;not null;index" json:"function_id"`
+	Status         string    `json:"status"`
+	TriggerType    string    `json:"trigger_type"`
+	DurationMS     int64     `json:"duration_ms"`
+	CreatedAt      time.Time `gorm:"index:idx_archived_user_created,priority:2;index:idx_archived_org_created,priority:2" json:"created_at"` // when the execution was created
+	ObjectKey      string    `gorm:"not null;index" json:"-"`
+	ArchivedAt     time.Time `gorm:"not null" json:"archived_at"`
+}
+
+// Workflow is a stored definition that composes functions into steps
+type Workflow struct {
+	ID             uuid.UUID      `gorm:"type:uuid;primary_key" json:"id"`
+	OrganizationID uuid.UUID      `gorm:"type:uuid;not null;index" json:"organization_id"`
+	UserID         uuid.UUID      `gorm:"type:uuid;not null;index" json:"user_id"`
+	Name           string         `gorm:"not null" json:"name"`
+	Description    string         `json:"description"`
+	Definition     datatypes.JSON `gorm:"not null" json:"definition"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// WorkflowRun is one execution of a workflow. The definition is copied at
+// start so edits never affect runs in flight.
+type WorkflowRun struct {
+	ID             uuid.UUID      `gorm:"type:uuid;primary_key" json:"id"`
+	OrganizationID uuid.UUID      `gorm:"type:uuid;not null;index" json:"organization_id"`
+	WorkflowID     uuid.UUID      `gorm:"type:uuid;not null;index" json:"workflow_id"`
+	UserID         uuid.UUID      `gorm:"type:uuid;not null;index" json:"user_id"`
+	Status         string         `gorm:"not null;index" json:"status"` // running, succeeded, failed, cancelled
+	Definition     datatypes.JSON `gorm:"not null" json:"-"`
+	Input          datatypes.JSON `json:"input"`
+	Output         datatypes.JSON `json:"output,omitempty"`
+	Error          string         `gorm:"type:text" json:"error,omitempty"`
+	LeaseOwner     string         `json:"-"`
+	LeaseUntil     *time.Time     `gorm:"index" json:"-"`
+	StartedAt      time.Time      `json:"started_at"`
+	CompletedAt    *time.Time     `json:"completed_at,omitempty"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+}
+
+// WorkflowStep records one step of a run. Completed steps are replayed
+// from their stored output when a run resumes after a restart.
+type WorkflowStep struct {
+	ID          uuid.UUID      `gorm:"type:uuid;primary_key" json:"id"`
+	RunID       uuid.UUID      `gorm:"type:uuid;not null;uniqueIndex:idx_workflow_step_path" json:"run_id"`
+	Path        string         `gorm:"not null;uniqueIndex:idx_workflow_step_path" json:"path"` // e.g. fanout#1/0/resize#1
+	Name        string         `gorm:"not null" json:"name"`
+	Type        string         `gorm:"not null" json:"type"`
+	Status      string         `gorm:"not null" json:"status"` // running, succeeded, failed
+	Input       datatypes.JSON `json:"input"`
+	Output      datatypes.JSON `json:"output,omitempty"`
+	Error       string         `gorm:"type:text" json:"error,omitempty"`
+	ExecutionID *uuid.UUID     `gorm:"type:uuid" json:"execution_id,omitempty"`
+	WakeAt      *time.Time     `json:"wake_at,omitempty"`
+	StartedAt   time.Time      `json:"started_at"`
+	CompletedAt *time.Time     `json:"completed_at,omitempty"`
+}
+
+// APIKey represents an API key for function invocation
+type APIKey struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	OrganizationID uuid.UUID  `gorm:"type:uuid;not null;index" json:"organization_id"`
+	UserID         uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"` // creator
+	Name           string     `gorm:"not null" json:"name"`
+	Key            string     `gorm:"uniqueIndex;not null" json:"key"` // hashed
+	Prefix         string     `gorm:"not null" json:"prefix"`          // first 8 chars for display
+	LastUsed       *time.Time `json:"last_used,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+
+	User User `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+// Session is a signed-in client. Each refresh rotates its refresh token,
+// and presenting a rotated token again revokes the session.
+type Session struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	UserID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	ExpiresAt  time.Time  `gorm:"not null;index" json:"expires_at"` // extended by every refresh
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt time.Time  `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+
+	// Current marks the session of the access token making the request
+	Current bool `gorm:"-" json:"current"`
+}
+
+// RefreshToken is one refresh token issued to a session. Only its SHA-256
+// is stored; rotated tokens are kept to detect reuse.
+type RefreshToken struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	SessionID uuid.UUID  `gorm:"type:uuid;not null;index" json:"session_id"`
+	TokenHash string     `gorm:"not null;uniqueIndex" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"` // set when rotated
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// UserIdentity links a user to their account at an OpenID Connect
+// provider. The issuer and subject identify the account; the provider name
+// may change in configuration.
+type UserIdentity struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	Provider  string    `gorm:"not null" json:"provider"`
+	Issuer    string    `gorm:"not null;uniqueIndex:idx_user_identity_subject" json:"issuer"`
+	Subject   string    `gorm:"not null;uniqueIndex:idx_user_identity_subject" json:"subject"`
+	Email     string    `json:"email"` // as reported by the provider when linked
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OIDCLoginState carries an OpenID Connect login from the redirect to the
+// provider to its callback. Each state is used once.
+type OIDCLoginState struct {
+	State        string    `gorm:"primaryKey"`
+	Provider     string    `gorm:"not null"`
+	CodeVerifier string    `gorm:"not null"` // PKCE verifier
+	Nonce        string    `gorm:"not null"`
+	ExpiresAt    time.Time `gorm:"not null;index"`
+	CreatedAt    time.Time
+}
+
+// TableName keeps GORM from splitting the OIDC initialism
+func (OIDCLoginState) TableName() string {
+	return "oidc_login_states"
+}
+
+// Organization owns functions, API keys, executions, event triggers and
+// workflows, and is billed for everything run in it. Every user has a
+// personal organization that shares the user's ID and is used when a
+// request names no organization.
+type Organization struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	Name      string    `gorm:"not null" json:"name"`
+	Personal  bool      `gorm:"not null;default:false" json:"personal"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Plan selects the organization's quotas; custom plans read PlanLimits
+	Plan       string         `gorm:"not null;default:free" json:"plan"` // free, pro, custom
+	PlanLimits datatypes.JSON `json:"-"`
+
+	// Execution retention; zero means the server default applies
+	RetentionDays     int `gorm:"not null;default:0" json:"retention_days"`
+	RetentionMaxCount int `gorm:"not null;default:0" json:"retention_max_count"` // newest executions kept per function
+}
+
+// Organization roles, from most to least privileged
+const (
+	RoleOwner     = "owner"     // everything, including deleting the organization
+	RoleAdmin     = "admin"     // members, invitations and API keys
+	RoleDeveloper = "developer" // create, change and run functions and workflows
+	RoleViewer    = "viewer"    // read only
+)
+
+// OrganizationMember grants a user a role in an organization
+type OrganizationMember struct {
+	ID             uuid.UUID `gorm:"type:uuid;primary_key" json:"id"`
+	OrganizationID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_organization_member" json:"organization_id"`
+	UserID         uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_organization_member;index" json:"user_id"`
+	Role           string    `gorm:"not null" json:"role"` // owner, admin, developer, viewer
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+
+	User         User         `gorm:"foreignKey:UserID" json:"-"`
+	Organization Organization `gorm:"foreignKey:OrganizationID" json:"-"`
+}
+
+// OrganizationInvitation lets the holder of its token join an
+// organization. Only the SHA-256 hash of the token is stored.
+type OrganizationInvitation struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key" json:"id"`
+	OrganizationID uuid.UUID  `gorm:"type:uuid;not null;index" json:"organization_id"`
+	Email          string     `gorm:"not null" json:"email"` // only this user may accept
+	Role           string     `gorm:"not null" json:"role"`
+	TokenHash      string     `gorm:"not null;uniqueIndex" json:"-"`
+	InvitedBy      uuid.UUID  `gorm:"type:uuid;not null" json:"invited_by"`
+	ExpiresAt      time.Time  `gorm:"not null" json:"expires_at"`
+	AcceptedAt     *time.Time `json:"accepted_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// BeforeCreate hook for User
+func (u *User) BeforeCreate(tx *gorm.DB) error {
+	if u.ID == uuid.Nil {
+		u.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeCreate hook for Function
+func (f *Function) BeforeCreate(tx *gorm.DB) error {
+	if f.ID == uuid.Nil {
+		f.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeCreate hook for Execution
+func (e *Execution) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeCreate hook for FunctionSecret
+func (s *FunctionSecret) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeCreate hook for Schedule
+func (s *Schedule) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeCreate hook for WebhookTrigger
+func (w *WebhookTrigger) BeforeCreate(tx *gorm.DB) error {
+	if w.ID == uuid.Nil {
+		w.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeCreate hook for EventTrigger
+func (t *EventTrigger) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeCreate hook for EventMessage
+func (m *EventMessage) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeCreate hook for EventDeadLetter
+func (d *EventDeadLetter) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeCreate hook for DeadLetter
+func (d *DeadLetter) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeCreate hook for IdempotencyKey
+func (k *IdempotencyKey) BeforeCreate(tx *gorm.DB) error {
+	if k.ID == uuid.Nil {
+		k.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeCreate hook for UsageRollup
+func (u *UsageRollup) BeforeCreate(tx *gorm.DB) error {
+	if u.ID == uuid.Nil {
+		u.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeCreate hook for Workflow
+func (w *Workflow) BeforeCreate(tx *gorm.DB) error {
+	if w.ID == uuid.Nil {
+		w.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeCreate hook for WorkflowRun
+func (r *WorkflowRun) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeCreate hook for WorkflowStep
+func (s *WorkflowStep) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeCreate hook for APIKey
+func (k *APIKey) BeforeCreate(tx *gorm.DB) error {
+	if k.ID == uuid.Nil {
+		k.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeCreate hook for Session
+func (s *Session) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == uuid.Nil {
+		s.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeCreate hook for RefreshToken
+func (t *RefreshToken) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeCreate hook for UserIdentity
+func (i *UserIdentity) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeCreate hook for Organization
+func (o *Organization) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == uuid.Nil {
+		o.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeCreate hook for OrganizationMember
+func (m *OrganizationMember) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == uuid.Nil {
+		m.ID = uuid.New()
+	}
+	return nil
+}
+
+// BeforeCreate hook for OrganizationInvitation
+func (i *OrganizationInvitation) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	return nil
+}
@@ -0,0 +1,350 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned by repositories when no row matches. It is
+// gorm.ErrRecordNotFound, so callers may test for either.
+var ErrNotFound = gorm.ErrRecordNotFound
+
+// ErrRefreshTokenReused is returned by SessionRepo.Rotate for a refresh
+// token that was already rotated; its session has been revoked
+var ErrRefreshTokenReused = errors.New("refresh token reused")
+
+var (
+	// ErrOrganizationNotEmpty is returned when deleting an organization
+	// that still owns functions or workflows
+	ErrOrganizationNotEmpty = errors.New("organization still owns functions or workflows")
+
+	// ErrInvitationEmailMismatch is returned when a user accepts an
+	// invitation sent to another email address
+	ErrInvitationEmailMismatch = errors.New("invitation was sent to another email address")
+
+	// ErrAlreadyMember is returned when accepting an invitation to an
+	// organization the user already belongs to
+	ErrAlreadyMember = errors.New("already a member of the organization")
+)
+
+// UserRepo stores platform users
+type UserRepo interface {
+	// Create also creates the user's personal organization, which shares
+	// the user's ID, with the user as its owner
+	Create(ctx context.Context, user *User) error
+	Get(ctx context.Context, id uuid.UUID) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+}
+
+// FunctionRepo stores functions. Lookups scoped to an organization
+// return ErrNotFound for functions owned by another.
+type FunctionRepo interface {
+	Create(ctx context.Context, function *Function) error
+	Get(ctx context.Context, id uuid.UUID) (*Function, error)
+	GetForOrganization(ctx context.Context, id, organizationID uuid.UUID) (*Function, error)
+	ListForOrganization(ctx context.Context, organizationID uuid.UUID) ([]Function, error) // newest first
+	CountForOrganization(ctx context.Context, organizationID uuid.UUID) (int64, error)
+	Save(ctx context.Context, function *Function) error
+
+	// Delete removes the function with its secrets, triggers, dead
+	// letters and idempotency keys. Executions are removed separately
+	// through ExecutionRepo.DeleteForFunction.
+	Delete(ctx context.Context, function *Function) error
+}
+
+// ExecutionRepo stores execution records
+type ExecutionRepo interface {
+	Create(ctx context.Context, execution *Execution) error
+	Get(ctx context.Context, id uuid.UUID) (*Execution, error)
+	Save(ctx context.Context, execution *Execution) error
+
+	// GetForOrganization returns an execution with its Function loaded
+	GetForOrganization(ctx context.Context, id, organizationID uuid.UUID) (*Execution, error)
+
+	// List returns the executions matching filter, newest first, without
+	// their input, output and logs and with the ID, name and runtime of
+	// their Function loaded
+	List(ctx context.Context, filter ExecutionFilter) ([]Execution, error)
+	DeleteForFunction(ctx context.Context, functionID uuid.UUID) error
+
+	// ListPendingRetries returns the retries still waiting for their
+	// backoff to elapse
+	ListPendingRetries(ctx context.Context) ([]Execution, error)
+
+	// ClaimRetry clears the next_retry_at of a waiting retry, reporting
+	// whether this caller claimed it
+	ClaimRetry(ctx context.Context, id uuid.UUID) (bool, error)
+
+	// ClaimPending moves a pending execution to running as of startedAt,
+	// reporting whether this caller claimed it
+	ClaimPending(ctx context.Context, id uuid.UUID, startedAt time.Time) (bool, error)
+
+	// InFlight totals the organization's pending and running executions,
+	// which are not metered until they finish. GBSeconds is the most they
+	// can use: their function's memory for its whole timeout.
+	InFlight(ctx context.Context, organizationID uuid.UUID) (*UsageTotal, error)
+
+	// Stats aggregates the function's finished executions created in
+	// [from, to) as a summary and as rows per UTC minute or hour bucket
+	// that had executions, oldest first
+	Stats(ctx context.Context, function *Function, from, to time.Time, bucket string) (*ExecutionStats, []ExecutionStats, error)
+}
+
+// ExecutionFilter selects a page of executions, newest first
+type ExecutionFilter struct {
+	OrganizationID uuid.UUID
+	FunctionID     *uuid.UUID
+	From           *time.Time // created at or after
+	To             *time.Time // created before
+
+	// After is the last row of the previous page; the page continues
+	// with older rows
+	After *ExecutionCursor
+	Limit int // 0 for no limit
+
+	// Archived executions cannot be filtered by these
+	Statuses      []string
+	TriggerTypes  []string
+	MinDurationMS *int64
+	MaxDurationMS *int64
+	Search        string // web search syntax over logs and errors
+}
+
+// ExecutionCursor is the position of a row in a newest-first listing
+type ExecutionCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// ExecutionStats summarises finished executions. Memory falls back to the
+// function's allocation when usage was not measured, as billing does.
+type ExecutionStats struct {
+	Period      *time.Time // start of the bucket; nil for a summary
+	Invocations int64
+	Errors      int64
+	ColdStarts  int64
+	P50MS       float64
+	P90MS       float64
+	P99MS       float64
+	AvgMemoryMB float64
+	MaxMemoryMB int
+}
+
+// APIKeyRepo stores hashed API keys
+type APIKeyRepo interface {
+	Create(ctx context.Context, key *APIKey) error
+	GetForOrganization(ctx context.Context, id, organizationID uuid.UUID) (*APIKey, error)
+	ListForOrganization(ctx context.Context, organizationID uuid.UUID) ([]APIKey, error) // newest first
+	Delete(ctx context.Context, key *APIKey) error
+}
+
+// SessionRepo stores sign-in sessions and their refresh tokens. Only
+// sessions that are neither revoked nor expired count as active.
+type SessionRepo interface {
+	// Create stores a session together with its first refresh token
+	Create(ctx context.Context, session *Session, token *RefreshToken) error
+
+	// Rotate marks the refresh token with tokenHash used, stores next in
+	// its place and extends the session until expiresAt. Unknown tokens
+	// and inactive sessions return ErrNotFound; a token that was already
+	// rotated revokes its session and returns ErrRefreshTokenReused.
+	Rotate(ctx context.Context, tokenHash string, next *RefreshToken, expiresAt time.Time) (*Session, error)
+
+	ListActiveForUser(ctx context.Context, userID uuid.UUID) ([]Session, error) // newest first
+	Revoke(ctx context.Context, id, userID uuid.UUID) error                     // ErrNotFound unless active
+
+	// RevokeAllForUser revokes every active session but except, returning
+	// how many were revoked
+	RevokeAllForUser(ctx context.Context, userID, except uuid.UUID) (int64, error)
+}
+
+// IdentityRepo stores OpenID Connect identities linked to users
+type IdentityRepo interface {
+	Create(ctx context.Context, identity *UserIdentity) error
+	Get(ctx context.Context, issuer, subject string) (*UserIdentity, error)
+}
+
+// OrganizationRepo stores organizations and their members
+type OrganizationRepo interface {
+	// Create stores an organization with ownerID as its first owner
+	Create(ctx context.Context, organization *Organization, ownerID uuid.UUID) error
+	Get(ctx context.Context, id uuid.UUID) (*Organization, error)
+	Save(ctx context.Context, organization *Organization) error
+
+	// Delete removes the organization with its members, invitations and
+	// API keys. It returns ErrOrganizationNotEmpty while the organization
+	// owns functions or workflows.
+	Delete(ctx context.Context, organization *Organization) error
+
+	// ListForUser returns the user's memberships with their Organization
+	// loaded, oldest first
+	ListForUser(ctx context.Context, userID uuid.UUID) ([]OrganizationMember, error)
+
+	GetMember(ctx context.Context, organizationID, userID uuid.UUID) (*OrganizationMember, error)
+	ListMembers(ctx context.Context, organizationID uuid.UUID) ([]OrganizationMember, error) // with User loaded, oldest first
+	SaveMember(ctx context.Context, member *OrganizationMember) error
+	RemoveMember(ctx context.Context, member *OrganizationMember) error
+	CountOwners(ctx context.Context, organizationID uuid.UUID) (int64, error)
+}
+
+// InvitationRepo stores invitations to join organizations. Invitations
+// that are accepted or expired are no longer pending.
+type InvitationRepo interface {
+	Create(ctx context.Context, invitation *OrganizationInvitation) error
+	ListPending(ctx context.Context, organizationID uuid.UUID) ([]OrganizationInvitation, error) // newest first
+	Delete(ctx context.Context, id, organizationID uuid.UUID) error                              // ErrNotFound unless pending
+
+	// Accept adds the user to the organization of the pending invitation
+	// with tokenHash. The user's email must match the invitation's.
+	Accept(ctx context.Context, tokenHash string, user *User) (*OrganizationMember, error)
+}
+
+// SecretRepo stores the encrypted secrets of functions
+type SecretRepo interface {
+	ListForFunction(ctx context.Context, functionID uuid.UUID) ([]FunctionSecret, error) // by name
+
+	// Put stores secret, replacing the value of the function's secret
+	// with the same name
+	Put(ctx context.Context, secret *FunctionSecret) error
+	Delete(ctx context.Context, functionID uuid.UUID, name string) error // ErrNotFound if missing
+}
+
+// ScheduleRepo stores the cron schedules of functions
+type ScheduleRepo interface {
+	Create(ctx context.Context, schedule *Schedule) error
+	GetForFunction(ctx context.Context, id, functionID uuid.UUID) (*Schedule, error)
+	ListForFunction(ctx context.Context, functionID uuid.UUID) ([]Schedule, error) // newest first
+	Save(ctx context.Context, schedule *Schedule) error
+	Delete(ctx context.Context, id, functionID uuid.UUID) error // ErrNotFound if missing
+}
+
+// WebhookRepo stores webhook triggers and the delivery IDs they received
+type WebhookRepo interface {
+	Create(ctx context.Context, webhook *WebhookTrigger) error
+	Get(ctx context.Context, id uuid.UUID) (*WebhookTrigger, error)
+	GetForFunction(ctx context.Context, id, functionID uuid.UUID) (*WebhookTrigger, error)
+	ListForFunction(ctx context.Context, functionID uuid.UUID) ([]WebhookTrigger, error) // newest first
+	Delete(ctx context.Context, webhook *WebhookTrigger) error                           // with its deliveries
+
+	// ClaimDelivery records a delivery ID and stores execution in one
+	// transaction, reporting false with nothing stored when the webhook
+	// already received the delivery. beforeCreate runs once the delivery
+	// is claimed, before execution is stored.
+	ClaimDelivery(ctx context.Context, webhookID uuid.UUID, deliveryID string, execution *Execution, beforeCreate func()) (bool, error)
+}
+
+// EventTriggerRepo stores event triggers and the messages they failed to
+// process. Lookups are scoped to a function in an organization.
+type EventTriggerRepo interface {
+	Create(ctx context.Context, trigger *EventTrigger) error
+	GetForFunction(ctx context.Context, id, functionID, organizationID uuid.UUID) (*EventTrigger, error)
+	ListForFunction(ctx context.Context, functionID, organizationID uuid.UUID) ([]EventTrigger, error) // newest first
+	Save(ctx context.Context, trigger *EventTrigger) error
+
+	// Delete removes the trigger with its queued and dead-lettered messages
+	Delete(ctx context.Context, trigger *EventTrigger) error
+
+	GetDeadLetter(ctx context.Context, id, triggerID uuid.UUID) (*EventDeadLetter, error)
+	ListDeadLetters(ctx context.Context, triggerID uuid.UUID) ([]EventDeadLetter, error) // newest first
+	DeleteDeadLetter(ctx context.Context, id, triggerID uuid.UUID) error                 // ErrNotFound if missing
+
+	// Redrive queues the dead-lettered message for its trigger again with
+	// a fresh delivery count and removes the dead letter
+	Redrive(ctx context.Context, deadLetter *EventDeadLetter) (*EventMessage, error)
+}
+
+// ArchiveRepo indexes executions the retention reaper moved to the object
+// store
+type ArchiveRepo interface {
+	Create(ctx context.Context, archived *ArchivedExecution) error
+	GetForOrganization(ctx context.Context, id, organizationID uuid.UUID) (*ArchivedExecution, error)
+
+	// List returns the archived executions matching the organization,
+	// function, time range, cursor and limit of filter, newest first
+	List(ctx context.Context, filter ExecutionFilter) ([]ArchivedExecution, error)
+}
+
+// IdempotencyRepo stores the idempotency keys of execute requests
+type IdempotencyRepo interface {
+	// Claim removes the caller's expired keys, then stores key and
+	// execution in one transaction and returns nil. When the caller holds
+	// an unexpired key with the same organization, function and value,
+	// nothing is stored and that key is returned instead. beforeCreate
+	// runs once key is claimed, before execution is stored.
+	Claim(ctx context.Context, key *IdempotencyKey, execution *Execution, beforeCreate func()) (*IdempotencyKey, error)
+}
+
+// DeadLetterRepo stores asynchronous invocations that failed every attempt
+type DeadLetterRepo interface {
+	Create(ctx context.Context, deadLetter *DeadLetter) error
+	GetForFunction(ctx context.Context, id, functionID uuid.UUID) (*DeadLetter, error)
+	ListForFunction(ctx context.Context, functionID uuid.UUID) ([]DeadLetter, error) // newest first
+	MarkReplayed(ctx context.Context, id, executionID uuid.UUID) error
+	Delete(ctx context.Context, deadLetter *DeadLetter) error
+}
+
+// WorkflowRepo stores workflows with their runs and steps. Lookups scoped
+// to an organization return ErrNotFound for workflows owned by another.
+type WorkflowRepo interface {
+	Create(ctx context.Context, workflow *Workflow) error
+	GetForOrganization(ctx context.Context, id, organizationID uuid.UUID) (*Workflow, error)
+	ListForOrganization(ctx context.Context, organizationID uuid.UUID) ([]Workflow, error) // newest first
+	Save(ctx context.Context, workflow *Workflow) error
+	Delete(ctx context.Context, workflow *Workflow) error // with its runs and their steps
+
+	// ListRuns returns at most limit runs of the workflow, newest first;
+	// a limit of 0 returns every run
+	ListRuns(ctx context.Context, workflowID, organizationID uuid.UUID, limit int) ([]WorkflowRun, error)
+	GetRun(ctx context.Context, id, workflowID, organizationID uuid.UUID) (*WorkflowRun, error)
+	ListSteps(ctx context.Context, runID uuid.UUID) ([]WorkflowStep, error) // in the order they started
+}
+
+// UsageRepo stores the hourly usage rollups billed to organizations
+type UsageRepo interface {
+	// Add adds the counters of rollup to the stored rollup of the same
+	// organization, function and hour, creating it if needed
+	Add(ctx context.Context, rollup *UsageRollup) error
+
+	// Sum totals an organization's rollups in [from, to), per function
+	// when byFunction is set and per UTC hour, day or month unless period
+	// is empty. Rows are ordered by period, then function.
+	Sum(ctx context.Context, organizationID uuid.UUID, from, to time.Time, byFunction bool, period string) ([]UsageTotal, error)
+}
+
+// UsageTotal is one aggregated row of metered usage
+type UsageTotal struct {
+	Period      *time.Time `json:"period,omitempty"` // start of the UTC period when grouped by one
+	FunctionID  *uuid.UUID `json:"function_id,omitempty"`
+	Invocations int64      `json:"invocations"`
+	Errors      int64      `json:"errors"`
+	DurationMS  int64      `json:"duration_ms"`
+	GBSeconds   float64    `json:"gb_seconds"`
+	EgressBytes int64      `json:"egress_bytes"`
+}
+
+// Repos bundles the repositories handed to the API and the engine
+type Repos struct {
+	Users      UserRepo
+	Functions  FunctionRepo
+	Executions ExecutionRepo
+	APIKeys    APIKeyRepo
+	Sessions   SessionRepo
+	Identities IdentityRepo
+
+	Organizations OrganizationRepo
+	Invitations   InvitationRepo
+
+	Secrets       SecretRepo
+	Schedules     ScheduleRepo
+	Webhooks      WebhookRepo
+	EventTriggers EventTriggerRepo
+	DeadLetters   DeadLetterRepo
+	Workflows     WorkflowRepo
+	Usage         UsageRepo
+	Archives      ArchiveRepo
+	Idempotency   IdempotencyRepo
+}
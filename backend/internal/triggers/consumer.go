@@ -0,0 +1,84 @@
+package triggers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/exec"
+	"github.com/voltrun/backend/internal/storage"
+)
+
+// Message is a single event delivered to an event trigger
+type Message struct {
+	ID          uuid.UUID       `json:"id"`
+	Topic       string          `json:"topic"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"`
+	PublishedAt time.Time       `json:"published_at"`
+}
+
+// BatchHandler processes a batch of messages for one trigger. Returning
+// nil acknowledges the whole batch; any error makes it eligible for
+// redelivery.
+type BatchHandler func(ctx context.Context, trigger storage.EventTrigger, batch []Message) error
+
+// Consumer delivers queued messages to event triggers with at-least-once
+// semantics: a message is only acknowledged after its handler succeeds.
+type Consumer interface {
+	// Run blocks, delivering batches to handler until ctx is cancelled
+	Run(ctx context.Context, handler BatchHandler) error
+}
+
+// Publisher enqueues a message for every trigger an organization has on a
+// topic
+type Publisher interface {
+	// Publish returns the number of triggers the message was queued for
+	Publish(ctx context.Context, organizationID uuid.UUID, topic string, payload json.RawMessage) (int, error)
+}
+
+// ExecuteBatch returns a handler that invokes the trigger's function once
+// per batch with {"records": [...]} as input
+func ExecuteBatch(engine *exec.ExecutionEngine) BatchHandler {
+	return func(ctx context.Context, trigger storage.EventTrigger, batch []Message) error {
+		var function storage.Function
+		if err := storage.DB.First(&function, "id = ?", trigger.FunctionID).Error; err != nil {
+			return fmt.Errorf("failed to fetch function: %w", err)
+		}
+		if function.Status != "active" {
+			return fmt.Errorf("function %s is not active", function.ID)
+		}
+
+		records := make([]map[string]interface{}, 0, len(batch))
+		for _, message := range batch {
+			var payload interface{}
+			if err := json.Unmarshal(message.Payload, &payload); err != nil {
+				payload = string(message.Payload)
+			}
+			records = append(records, map[string]interface{}{
+				"id":           message.ID,
+				"topic":        message.Topic,
+				"payload":      payload,
+				"attempts":     message.Attempts,
+				"published_at": message.PublishedAt,
+			})
+		}
+
+		result, err := engine.Execute(ctx, exec.ExecutionRequest{
+			FunctionID:    function.ID,
+			Input:         map[string]interface{}{"records": records},
+			UserID:        function.UserID,
+			TriggerType:   exec.TriggerEvent,
+			TriggerSource: trigger.ID.String(),
+		})
+		if err != nil {
+			return err
+		}
+		if result.Status != "success" {
+			return fmt.Errorf("execution %s failed: %s", result.ExecutionID, result.Error)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,367 @@
+package triggers
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/voltrun/backend/internal/storage"
+	"github.com/voltrun/backend/internal/utils"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// notifyChannel is the LISTEN/NOTIFY channel used to wake consumers
+const notifyChannel = "voltrun_events"
+
+var (
+	_ Consumer  = (*PostgresQueue)(nil)
+	_ Publisher = (*PostgresQueue)(nil)
+)
+
+// PostgresQueue is a Consumer and Publisher backed by the event_messages
+// table. Publishing inserts one row per subscribed trigger and sends a
+// NOTIFY; consumers LISTEN for wake-ups and also poll, so notifications
+// lost during a reconnect only delay delivery.
+//
+// Claimed rows are hidden for the visibility timeout rather than locked,
+// so a replica that dies mid-batch simply lets the batch reappear. While
+// a handler runs, its batch is kept hidden by a heartbeat. Messages that
+// reach the delivery limit move to event_dead_letters.
+type PostgresQueue struct {
+	dsn               string
+	pollInterval      time.Duration
+	visibilityTimeout time.Duration
+	maxDeliveries     int
+
+	mu       sync.Mutex
+	inFlight map[uuid.UUID]bool
+}
+
+// NewPostgresQueue creates a queue; dsn is used for the LISTEN connection.
+// An empty dsn disables LISTEN and the queue only polls, as on SQLite.
+func NewPostgresQueue(dsn string, pollInterval, visibilityTimeout time.Duration, maxDeliveries int) *PostgresQueue {
+	return &PostgresQueue{
+		dsn:               dsn,
+		pollInterval:      pollInterval,
+		visibilityTimeout: visibilityTimeout,
+		maxDeliveries:     maxDeliveries,
+		inFlight:          make(map[uuid.UUID]bool),
+	}
+}
+
+// Publish queues payload for every enabled trigger the organization has on
+// topic
+func (q *PostgresQueue) Publish(ctx context.Context, organizationID uuid.UUID, topic string, payload json.RawMessage) (int, error) {
+	var queued int
+	err := storage.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var subscribed []storage.EventTrigger
+		if err := tx.Where("organization_id = ? AND topic = ? AND enabled = ?", organizationID, topic, true).
+			Find(&subscribed).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, trigger := range subscribed {
+			message := storage.EventMessage{
+				TriggerID: trigger.ID,
+				Topic:     topic,
+				Payload:   datatypes.JSON(payload),
+				VisibleAt: now,
+			}
+			if err := tx.Create(&message).Error; err != nil {
+				return err
+			}
+		}
+
+		queued = len(subscribed)
+		if queued == 0 || storage.IsSQLite() {
+			return nil
+		}
+		// Delivered to listeners when the transaction commits
+		return tx.Exec("SELECT pg_notify(?, ?)", notifyChannel, topic).Error
+	})
+	return queued, err
+}
+
+// Run delivers ready batches until ctx is cancelled
+func (q *PostgresQueue) Run(ctx context.Context, handler BatchHandler) error {
+	wake := make(chan struct{}, 1)
+	if q.dsn != "" {
+		go q.listen(ctx, wake)
+	}
+
+	ticker := time.NewTicker(q.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		q.dispatch(ctx, handler)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// listen keeps a LISTEN connection open, reconnecting on failure
+func (q *PostgresQueue) listen(ctx context.Context, wake chan<- struct{}) {
+	for ctx.Err() == nil {
+		err := q.listenOnce(ctx, wake)
+		if ctx.Err() != nil {
+			return
+		}
+		utils.Warn("Event listener disconnected", zap.Error(err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(q.pollInterval):
+		}
+	}
+}
+
+func (q *PostgresQueue) listenOnce(ctx context.Context, wake chan<- struct{}) error {
+	conn, err := pgx.Connect(ctx, q.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+notifyChannel); err != nil {
+		return err
+	}
+
+	for {
+		if _, err := conn.WaitForNotification(ctx); err != nil {
+			return err
+		}
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// dispatch starts a drain for every enabled trigger with visible messages
+func (q *PostgresQueue) dispatch(ctx context.Context, handler BatchHandler) {
+	var ready []uuid.UUID
+	err := storage.DB.WithContext(ctx).Model(&storage.EventMessage{}).
+		Joins("JOIN event_triggers ON event_triggers.id = event_messages.trigger_id").
+		Where("event_triggers.enabled = ? AND event_messages.visible_at <= ?", true, time.Now()).
+		Distinct().
+		Pluck("event_messages.trigger_id", &ready).Error
+	if err != nil {
+		if ctx.Err() == nil {
+			utils.Error("Failed to poll event messages", zap.Error(err))
+		}
+		return
+	}
+
+	for _, triggerID := range ready {
+		if !q.acquire(triggerID) {
+			continue
+		}
+		go func(triggerID uuid.UUID) {
+			defer q.release(triggerID)
+			q.drain(ctx, triggerID, handler)
+		}(triggerID)
+	}
+}
+
+// drain delivers batches for one trigger until none are visible
+func (q *PostgresQueue) drain(ctx context.Context, triggerID uuid.UUID, handler BatchHandler) {
+	for ctx.Err() == nil {
+		var trigger storage.EventTrigger
+		if err := storage.DB.First(&trigger, "id = ? AND enabled = ?", triggerID, true).Error; err != nil {
+			return
+		}
+
+		batch, err := q.claim(ctx, trigger)
+		if err != nil {
+			utils.Error("Failed to claim event batch", zap.String("trigger_id", triggerID.String()), zap.Error(err))
+			return
+		}
+		if len(batch) == 0 {
+			return
+		}
+
+		messages := make([]Message, len(batch))
+		for i, row := range batch {
+			messages[i] = Message{
+				ID:          row.ID,
+				Topic:       row.Topic,
+				Payload:     json.RawMessage(row.Payload),
+				Attempts:    row.Attempts,
+				PublishedAt: row.CreatedAt,
+			}
+		}
+
+		stop := q.heartbeat(ctx, batch)
+		err = handler(ctx, trigger, messages)
+		stop()
+		if err != nil {
+			utils.Warn("Event batch failed", zap.String("trigger_id", triggerID.String()), zap.Error(err))
+			q.nack(trigger, batch, err)
+			return
+		}
+		q.ack(batch)
+	}
+}
+
+// claim hides up to BatchSize visible messages for the visibility timeout
+func (q *PostgresQueue) claim(ctx context.Context, trigger storage.EventTrigger) ([]storage.EventMessage, error) {
+	batchSize := trigger.BatchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	var batch []storage.EventMessage
+	err := storage.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("trigger_id = ? AND visible_at <= ?", trigger.ID, now).
+			Order("created_at").
+			Limit(batchSize).
+			Find(&batch).Error; err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+
+		ids := make([]uuid.UUID, len(batch))
+		for i := range batch {
+			ids[i] = batch[i].ID
+			batch[i].Attempts++
+		}
+		return tx.Model(&storage.EventMessage{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"visible_at": now.Add(q.visibilityTimeout),
+			"attempts":   gorm.Expr("attempts + 1"),
+		}).Error
+	})
+	return batch, err
+}
+
+// heartbeat extends the visibility of a claimed batch every third of the
+// visibility timeout until the returned func is called, so a handler that
+// runs longer than the timeout does not see its batch redelivered
+func (q *PostgresQueue) heartbeat(ctx context.Context, batch []storage.EventMessage) func() {
+	if q.visibilityTimeout <= 0 {
+		return func() {}
+	}
+
+	ids := messageIDs(batch)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(q.visibilityTimeout / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := storage.DB.Model(&storage.EventMessage{}).Where("id IN ?", ids).
+					Update("visible_at", time.Now().Add(q.visibilityTimeout)).Error; err != nil {
+					utils.Warn("Failed to extend event batch visibility", zap.Error(err))
+				}
+			}
+		}
+	}()
+
+	// Waiting for the goroutine keeps a late extension from overwriting
+	// the nack's retry time
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// ack removes successfully processed messages
+func (q *PostgresQueue) ack(batch []storage.EventMessage) {
+	if err := storage.DB.Where("id IN ?", messageIDs(batch)).Delete(&storage.EventMessage{}).Error; err != nil {
+		// The batch will be redelivered once the visibility timeout expires
+		utils.Error("Failed to acknowledge event batch", zap.Error(err))
+	}
+}
+
+// nack makes failed messages visible again after a linear backoff, moving
+// those that have reached the delivery limit to the dead-letter table
+func (q *PostgresQueue) nack(trigger storage.EventTrigger, batch []storage.EventMessage, cause error) {
+	for _, message := range batch {
+		if q.maxDeliveries > 0 && message.Attempts >= q.maxDeliveries {
+			q.deadLetter(trigger, message, cause)
+			continue
+		}
+
+		retryAt := time.Now().Add(time.Duration(message.Attempts) * q.pollInterval)
+		storage.DB.Model(&message).Update("visible_at", retryAt)
+	}
+}
+
+// deadLetter moves a message out of the queue. If the move fails the
+// message stays queued and is retried once more.
+func (q *PostgresQueue) deadLetter(trigger storage.EventTrigger, message storage.EventMessage, cause error) {
+	err := storage.DB.Transaction(func(tx *gorm.DB) error {
+		deadLetter := storage.EventDeadLetter{
+			TriggerID:      trigger.ID,
+			FunctionID:     trigger.FunctionID,
+			OrganizationID: trigger.OrganizationID,
+			MessageID:      message.ID,
+			Topic:          message.Topic,
+			Payload:        message.Payload,
+			Attempts:       message.Attempts,
+			Error:          cause.Error(),
+			PublishedAt:    message.CreatedAt,
+		}
+		if err := tx.Create(&deadLetter).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&message).Error
+	})
+	if err != nil {
+		utils.Error("Failed to dead-letter event", zap.String("message_id", message.ID.String()), zap.Error(err))
+		storage.DB.Model(&message).Update("visible_at", time.Now().Add(q.pollInterval))
+		return
+	}
+
+	utils.Warn("Event dead-lettered after max deliveries",
+		zap.String("message_id", message.ID.String()),
+		zap.String("trigger_id", message.TriggerID.String()),
+		zap.Int("attempts", message.Attempts))
+}
+
+func messageIDs(batch []storage.EventMessage) []uuid.UUID {
+	ids := make([]uuid.UUID, len(batch))
+	for i := range batch {
+		ids[i] = batch[i].ID
+	}
+	return ids
+}
+
+func (q *PostgresQueue) acquire(triggerID uuid.UUID) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.inFlight[triggerID] {
+		return false
+	}
+	q.inFlight[triggerID] = true
+	return true
+}
+
+func (q *PostgresQueue) release(triggerID uuid.UUID) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.inFlight, triggerID)
+}
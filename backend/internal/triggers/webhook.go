@@ -0,0 +1,240 @@
+package triggers
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Webhook providers
+const (
+	ProviderGeneric = "generic"
+	ProviderGitHub  = "github"
+	ProviderStripe  = "stripe"
+)
+
+// Headers used by generic webhooks without configured ones
+const (
+	DefaultSignatureHeader = "X-Signature"
+	DefaultTimestampHeader = "X-Timestamp"
+)
+
+// GitHubDeliveryHeader uniquely identifies a GitHub delivery. GitHub signs
+// no timestamp, so replays are detected by remembering delivery IDs.
+const GitHubDeliveryHeader = "X-GitHub-Delivery"
+
+var (
+	ErrMissingSignature  = errors.New("missing webhook signature")
+	ErrInvalidSignature  = errors.New("invalid webhook signature")
+	ErrStaleTimestamp    = errors.New("webhook timestamp outside tolerance")
+	ErrMissingDeliveryID = errors.New("missing webhook delivery id")
+)
+
+// WebhookConfig describes how a webhook request is signed
+type WebhookConfig struct {
+	Provider        string
+	Secret          string
+	SignatureHeader string        // generic only
+	Algorithm       string        // sha1, sha256, sha512 for generic; github and stripe use sha256
+	TimestampHeader string        // generic only; defaults to DefaultTimestampHeader
+	Tolerance       time.Duration // maximum age of a signed timestamp
+}
+
+// ValidateWebhookConfig checks provider and algorithm names
+func ValidateWebhookConfig(cfg WebhookConfig) error {
+	switch cfg.Provider {
+	case ProviderGeneric, ProviderGitHub, ProviderStripe:
+	default:
+		return fmt.Errorf("unsupported webhook provider: %s", cfg.Provider)
+	}
+	if _, err := hashFunc(cfg.Algorithm); err != nil {
+		return err
+	}
+	if cfg.Provider != ProviderGeneric && cfg.Algorithm != "sha256" {
+		return fmt.Errorf("%s webhooks are always signed with sha256", cfg.Provider)
+	}
+	if cfg.Provider != ProviderGitHub && cfg.Tolerance <= 0 {
+		return errors.New("tolerance_sec must be positive")
+	}
+	return nil
+}
+
+// VerifyWebhook checks the HMAC signature of a webhook request and that
+// it is recent. GitHub deliveries carry no signed timestamp; callers must
+// reject repeated DeliveryID values instead. header looks up request
+// headers case-insensitively.
+func VerifyWebhook(cfg WebhookConfig, header func(string) string, body []byte, now time.Time) error {
+	switch cfg.Provider {
+	case ProviderGitHub:
+		if err := verifyHex("sha256", cfg.Secret, body, header("X-Hub-Signature-256")); err != nil {
+			return err
+		}
+		if header(GitHubDeliveryHeader) == "" {
+			return ErrMissingDeliveryID
+		}
+		return nil
+
+	case ProviderStripe:
+		return verifyStripe(cfg, header("Stripe-Signature"), body, now)
+
+	case ProviderGeneric:
+		name := cfg.SignatureHeader
+		if name == "" {
+			name = DefaultSignatureHeader
+		}
+		timestampHeader := cfg.TimestampHeader
+		if timestampHeader == "" {
+			timestampHeader = DefaultTimestampHeader
+		}
+		// The timestamp is signed with the body, so it cannot be refreshed
+		// to replay an old delivery
+		timestamp := header(timestampHeader)
+		if err := checkTimestamp(timestamp, cfg.Tolerance, now); err != nil {
+			return err
+		}
+		payload := append([]byte(timestamp+"."), body...)
+		return verifyHex(cfg.Algorithm, cfg.Secret, payload, header(name))
+
+	default:
+		return fmt.Errorf("unsupported webhook provider: %s", cfg.Provider)
+	}
+}
+
+// DeliveryID returns the provider's unique ID of a verified delivery, or
+// "" for providers whose signed timestamp already bounds replays
+func DeliveryID(cfg WebhookConfig, header func(string) string) string {
+	if cfg.Provider == ProviderGitHub {
+		return header(GitHubDeliveryHeader)
+	}
+	return ""
+}
+
+// WebhookEvent converts a webhook request into function input
+func WebhookEvent(provider, method string, headers, query map[string]string, body []byte) map[string]interface{} {
+	event := map[string]interface{}{
+		"provider":          provider,
+		"method":            method,
+		"headers":           headers,
+		"query":             query,
+		"is_base64_encoded": false,
+	}
+
+	// JSON bodies are decoded, text is kept as a string and anything else
+	// is base64 encoded so it survives the trip through JSON
+	var payload interface{}
+	switch {
+	case json.Unmarshal(body, &payload) == nil:
+		event["body"] = payload
+	case utf8.Valid(body):
+		event["body"] = string(body)
+	default:
+		event["body"] = base64.StdEncoding.EncodeToString(body)
+		event["is_base64_encoded"] = true
+	}
+	return event
+}
+
+// verifyStripe checks a "t=<unix>,v1=<hex>" Stripe-Signature header
+func verifyStripe(cfg WebhookConfig, signatureHeader string, body []byte, now time.Time) error {
+	if signatureHeader == "" {
+		return ErrMissingSignature
+	}
+
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(signatureHeader, ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			timestamp = kv[1]
+		case "v1":
+			signatures = append(signatures, kv[1])
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return ErrMissingSignature
+	}
+	if err := checkTimestamp(timestamp, cfg.Tolerance, now); err != nil {
+		return err
+	}
+
+	payload := append([]byte(timestamp+"."), body...)
+	for _, signature := range signatures {
+		if err := verifyHex("sha256", cfg.Secret, payload, signature); err == nil {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+// verifyHex compares a hex HMAC, optionally prefixed with "<algorithm>="
+func verifyHex(algorithm, secret string, payload []byte, signature string) error {
+	if signature == "" {
+		return ErrMissingSignature
+	}
+	newHash, err := hashFunc(algorithm)
+	if err != nil {
+		return err
+	}
+
+	signature = strings.TrimPrefix(signature, algorithm+"=")
+	given, err := hex.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(payload)
+	if !hmac.Equal(given, mac.Sum(nil)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// checkTimestamp rejects unix timestamps further than tolerance from now
+func checkTimestamp(value string, tolerance time.Duration, now time.Time) error {
+	if value == "" {
+		return ErrMissingSignature
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	age := now.Sub(time.Unix(seconds, 0))
+	if age < 0 {
+		age = -age
+	}
+	if age > tolerance {
+		return ErrStaleTimestamp
+	}
+	return nil
+}
+
+// hashFunc maps an algorithm name to its hash constructor
+func hashFunc(algorithm string) (func() hash.Hash, error) {
+	switch algorithm {
+	case "sha1":
+		return sha1.New, nil
+	case "sha256":
+		return sha256.New, nil
+	case "sha512":
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("unsupported signature algorithm: %s", algorithm)
+	}
+}
@@ -0,0 +1,231 @@
+package triggers
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// sign returns the hex HMAC of payload
+func sign(newHash func() hash.Hash, secret, payload string) string {
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	const secret = "whsec_test"
+	const body = `{"action":"opened"}`
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	expired := strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10)
+	future := strconv.FormatInt(now.Add(10*time.Minute).Unix(), 10)
+
+	github := WebhookConfig{Provider: ProviderGitHub, Secret: secret, Algorithm: "sha256"}
+	stripe := WebhookConfig{Provider: ProviderStripe, Secret: secret, Algorithm: "sha256", Tolerance: 5 * time.Minute}
+	generic := WebhookConfig{Provider: ProviderGeneric, Secret: secret, Algorithm: "sha256", Tolerance: 5 * time.Minute}
+
+	tests := []struct {
+		name    string
+		cfg     WebhookConfig
+		headers map[string]string
+		body    string
+		wantErr error
+	}{
+		{
+			name: "github",
+			cfg:  github,
+			headers: map[string]string{
+				"X-Hub-Signature-256": "sha256=" + sign(sha256.New, secret, body),
+				"X-GitHub-Delivery":   "72d3162e",
+			},
+		},
+		{
+			name: "github forged",
+			cfg:  github,
+			headers: map[string]string{
+				"X-Hub-Signature-256": "sha256=" + sign(sha256.New, "guessed", body),
+				"X-GitHub-Delivery":   "72d3162e",
+			},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name: "github tampered body",
+			cfg:  github,
+			headers: map[string]string{
+				"X-Hub-Signature-256": "sha256=" + sign(sha256.New, secret, body),
+				"X-GitHub-Delivery":   "72d3162e",
+			},
+			body:    `{"action":"closed"}`,
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "github unsigned",
+			cfg:     github,
+			headers: map[string]string{"X-GitHub-Delivery": "72d3162e"},
+			wantErr: ErrMissingSignature,
+		},
+		{
+			name:    "github without delivery id",
+			cfg:     github,
+			headers: map[string]string{"X-Hub-Signature-256": "sha256=" + sign(sha256.New, secret, body)},
+			wantErr: ErrMissingDeliveryID,
+		},
+		{
+			name:    "stripe",
+			cfg:     stripe,
+			headers: map[string]string{"Stripe-Signature": "t=" + ts + ",v1=" + sign(sha256.New, secret, ts+"."+body)},
+		},
+		{
+			name:    "stripe with a rolled secret",
+			cfg:     stripe,
+			headers: map[string]string{"Stripe-Signature": "t=" + ts + ",v1=" + sign(sha256.New, "old", ts+"."+body) + ",v1=" + sign(sha256.New, secret, ts+"."+body)},
+		},
+		{
+			name:    "stripe forged",
+			cfg:     stripe,
+			headers: map[string]string{"Stripe-Signature": "t=" + ts + ",v1=" + sign(sha256.New, "guessed", ts+"."+body)},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "stripe refreshed timestamp",
+			cfg:     stripe,
+			headers: map[string]string{"Stripe-Signature": "t=" + ts + ",v1=" + sign(sha256.New, secret, expired+"."+body)},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "stripe expired",
+			cfg:     stripe,
+			headers: map[string]string{"Stripe-Signature": "t=" + expired + ",v1=" + sign(sha256.New, secret, expired+"."+body)},
+			wantErr: ErrStaleTimestamp,
+		},
+		{
+			name:    "stripe from the future",
+			cfg:     stripe,
+			headers: map[string]string{"Stripe-Signature": "t=" + future + ",v1=" + sign(sha256.New, secret, future+"."+body)},
+			wantErr: ErrStaleTimestamp,
+		},
+		{
+			name:    "stripe without v1",
+			cfg:     stripe,
+			headers: map[string]string{"Stripe-Signature": "t=" + ts + ",v0=" + sign(sha256.New, secret, ts+"."+body)},
+			wantErr: ErrMissingSignature,
+		},
+		{
+			name:    "stripe unsigned",
+			cfg:     stripe,
+			wantErr: ErrMissingSignature,
+		},
+		{
+			name:    "generic",
+			cfg:     generic,
+			headers: map[string]string{"X-Signature": sign(sha256.New, secret, ts+"."+body), "X-Timestamp": ts},
+		},
+		{
+			name:    "generic with algorithm prefix",
+			cfg:     generic,
+			headers: map[string]string{"X-Signature": "sha256=" + sign(sha256.New, secret, ts+"."+body), "X-Timestamp": ts},
+		},
+		{
+			name: "generic sha1 with custom headers",
+			cfg: WebhookConfig{Provider: ProviderGeneric, Secret: secret, Algorithm: "sha1", Tolerance: time.Minute,
+				SignatureHeader: "X-Acme-Signature", TimestampHeader: "X-Acme-Time"},
+			headers: map[string]string{"X-Acme-Signature": sign(sha1.New, secret, ts+"."+body), "X-Acme-Time": ts},
+		},
+		{
+			name:    "generic sha512",
+			cfg:     WebhookConfig{Provider: ProviderGeneric, Secret: secret, Algorithm: "sha512", Tolerance: time.Minute},
+			headers: map[string]string{"X-Signature": sign(sha512.New, secret, ts+"."+body), "X-Timestamp": ts},
+		},
+		{
+			name:    "generic forged",
+			cfg:     generic,
+			headers: map[string]string{"X-Signature": sign(sha256.New, "guessed", ts+"."+body), "X-Timestamp": ts},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "generic signature without the timestamp",
+			cfg:     generic,
+			headers: map[string]string{"X-Signature": sign(sha256.New, secret, body), "X-Timestamp": ts},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "generic refreshed timestamp",
+			cfg:     generic,
+			headers: map[string]string{"X-Signature": sign(sha256.New, secret, expired+"."+body), "X-Timestamp": ts},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "generic expired",
+			cfg:     generic,
+			headers: map[string]string{"X-Signature": sign(sha256.New, secret, expired+"."+body), "X-Timestamp": expired},
+			wantErr: ErrStaleTimestamp,
+		},
+		{
+			name:    "generic malformed timestamp",
+			cfg:     generic,
+			headers: map[string]string{"X-Signature": sign(sha256.New, secret, "soon."+body), "X-Timestamp": "soon"},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "generic without timestamp",
+			cfg:     generic,
+			headers: map[string]string{"X-Signature": sign(sha256.New, secret, "."+body)},
+			wantErr: ErrMissingSignature,
+		},
+		{
+			name:    "generic signature not hex",
+			cfg:     generic,
+			headers: map[string]string{"X-Signature": "not-hex", "X-Timestamp": ts},
+			wantErr: ErrInvalidSignature,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for name, value := range tt.headers {
+				header.Set(name, value)
+			}
+			requestBody := tt.body
+			if requestBody == "" {
+				requestBody = body
+			}
+
+			err := VerifyWebhook(tt.cfg, header.Get, []byte(requestBody), now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateWebhookConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     WebhookConfig
+		wantErr bool
+	}{
+		{name: "generic", cfg: WebhookConfig{Provider: ProviderGeneric, Algorithm: "sha512", Tolerance: time.Minute}},
+		{name: "github needs no tolerance", cfg: WebhookConfig{Provider: ProviderGitHub, Algorithm: "sha256"}},
+		{name: "stripe", cfg: WebhookConfig{Provider: ProviderStripe, Algorithm: "sha256", Tolerance: time.Minute}},
+		{name: "unknown provider", cfg: WebhookConfig{Provider: "gitlab", Algorithm: "sha256", Tolerance: time.Minute}, wantErr: true},
+		{name: "unknown algorithm", cfg: WebhookConfig{Provider: ProviderGeneric, Algorithm: "md5", Tolerance: time.Minute}, wantErr: true},
+		{name: "stripe with sha1", cfg: WebhookConfig{Provider: ProviderStripe, Algorithm: "sha1", Tolerance: time.Minute}, wantErr: true},
+		{name: "generic without tolerance", cfg: WebhookConfig{Provider: ProviderGeneric, Algorithm: "sha256"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateWebhookConfig(tt.cfg); (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,65 +1,239 @@
-package utils
-
-import (
-	"os"
-	"strconv"
-)
-
-// Config holds application configuration
-type Config struct {
-	Port           string
-	DatabaseURL    string
-	JWTSecret      string
-	Environment    string
-	FirecrackerBin string
-	KernelPath     string
-	RootFSPath     string
-}
-
-// LoadConfig loads configuration from environment variables
-func LoadConfig() *Config {
-	return &Config{
-		Port:           getEnv("PORT", "8080"),
-		DatabaseURL:    getEnv("DATABASE_URL", "host=localhost user=voltrun password=voltrun dbname=voltrun port=5432 sslmode=disable"),
-		JWTSecret:      getEnv("JWT_SECRET", "voltrun-secret-change-in-production"),
-		Environment:    getEnv("ENVIRONMENT", "development"),
-		FirecrackerBin: getEnv("FIRECRACKER_BIN", "/usr/bin/firecracker"),
-		KernelPath:     getEnv("KERNEL_PATH", "/var/lib/voltrun/vmlinux.bin"),
-		RootFSPath:     getEnv("ROOTFS_PATH", "/var/lib/voltrun/rootfs.ext4"),
-	}
-}
-
-// getEnv gets an environment variable or returns a default value
-func getEnv(key, defaultValue string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		return defaultValue
-	}
-	return value
-}
-
-// getEnvAsInt gets an environment variable as int or returns a default value
-func getEnvAsInt(key string, defaultValue int) int {
-	valueStr := os.Getenv(key)
-	if valueStr == "" {
-		return defaultValue
-	}
-	value, err := strconv.Atoi(valueStr)
-	if err != nil {
-		return defaultValue
-	}
-	return value
-}
-
-// getEnvAsBool gets an environment variable as bool or returns a default value
-func getEnvAsBool(key string, defaultValue bool) bool {
-	valueStr := os.Getenv(key)
-	if valueStr == "" {
-		return defaultValue
-	}
-	value, err := strconv.ParseBool(valueStr)
-	if err != nil {
-		return defaultValue
-	}
-	return value
-}
+package utils
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Config holds application configuration
+type Config struct {
+	Port           string
+	DatabaseDriver string // postgres or sqlite
+	DatabaseURL    string // connection string, or the file path for sqlite
+	MigrateOnStart bool   // apply pending migrations before serving
+	JWTSecret      string
+	Environment    string
+	FirecrackerBin string
+	KernelPath     string
+	RootFSPath     string
+
+	// Sessions
+	AccessTokenTTL  int // minutes
+	RefreshTokenTTL int // hours a session lasts without being refreshed
+
+	// OpenID Connect login
+	OIDCProviders        []OIDCProviderConfig
+	OIDCRedirectBaseURL  string // public URL of this API, used in callback URLs
+	OIDCLoginRedirectURL string // frontend page that receives the tokens
+
+	// Secrets encryption
+	SecretsMasterKey    string
+	SecretsMasterKeyID  string
+	SecretsPreviousKeys string // comma-separated "id:key" pairs kept for rotation
+
+	// Cron scheduler
+	SchedulerEnabled      bool
+	SchedulerPollInterval int // seconds
+
+	// Event triggers
+	EventsEnabled           bool
+	EventsPollInterval      int // seconds
+	EventsVisibilityTimeout int // seconds a claimed batch stays hidden
+	EventsMaxDeliveries     int
+
+	// Rate limiting
+	RateLimitStore       string // memory or postgres
+	RateLimitRPS         float64
+	RateLimitBurst       int
+	MaxConcurrent        int // executions per organization; 0 disables the limit
+	ConcurrencyQueueWait int // seconds an execution waits for a slot
+
+	// Idempotency
+	IdempotencyKeyTTL int // hours an Idempotency-Key returns its original execution
+
+	// Workflows
+	WorkflowsPollInterval int // seconds between checks for orphaned runs
+	WorkflowsLeaseTimeout int // seconds before another replica adopts a run
+
+	// Tracing
+	TracingExporter    string // none, stdout or otlp
+	TracingSampleRatio float64
+
+	// Object storage
+	ObjectStore    string // local or s3
+	ObjectStoreDir string
+	S3Endpoint     string
+	S3Bucket       string
+	S3Region       string
+	S3AccessKey    string
+	S3SecretKey    string
+	S3UseSSL       bool
+
+	// Execution payloads
+	MaxInputBytes           int
+	MaxOutputBytes          int
+	MaxLogBytes             int
+	PayloadOffloadThreshold int // bytes above which payloads move to the object store
+
+	// Execution retention
+	RetentionDays          int // 0 keeps executions indefinitely
+	RetentionMaxCount      int // newest executions kept per function; 0 disables
+	RetentionSweepInterval int // seconds
+	ArchiveEnabled         bool
+}
+
+// LoadConfig loads configuration from environment variables
+func LoadConfig() *Config {
+	return &Config{
+		Port:           getEnv("PORT", "8080"),
+		DatabaseDriver: getEnv("DATABASE_DRIVER", "postgres"),
+		DatabaseURL:    getEnv("DATABASE_URL", defaultDatabaseURL(getEnv("DATABASE_DRIVER", "postgres"))),
+		MigrateOnStart: getEnvAsBool("MIGRATE_ON_START", true),
+		JWTSecret:      getEnv("JWT_SECRET", "voltrun-secret-change-in-production"),
+		Environment:    getEnv("ENVIRONMENT", "development"),
+		FirecrackerBin: getEnv("FIRECRACKER_BIN", "/usr/bin/firecracker"),
+		KernelPath:     getEnv("KERNEL_PATH", "/var/lib/voltrun/vmlinux.bin"),
+		RootFSPath:     getEnv("ROOTFS_PATH", "/var/lib/voltrun/rootfs.ext4"),
+
+		AccessTokenTTL:  getEnvAsInt("ACCESS_TOKEN_TTL", 15),
+		RefreshTokenTTL: getEnvAsInt("REFRESH_TOKEN_TTL", 720),
+
+		OIDCProviders:        loadOIDCProviders(),
+		OIDCRedirectBaseURL:  getEnv("OIDC_REDIRECT_BASE_URL", "http://localhost:8080"),
+		OIDCLoginRedirectURL: getEnv("OIDC_LOGIN_REDIRECT_URL", ""),
+
+		SecretsMasterKey:    getEnv("SECRETS_MASTER_KEY", "voltrun-secrets-change-in-production"),
+		SecretsMasterKeyID:  getEnv("SECRETS_MASTER_KEY_ID", "v1"),
+		SecretsPreviousKeys: getEnv("SECRETS_PREVIOUS_KEYS", ""),
+
+		SchedulerEnabled:      getEnvAsBool("SCHEDULER_ENABLED", true),
+		SchedulerPollInterval: getEnvAsInt("SCHEDULER_POLL_INTERVAL", 10),
+
+		EventsEnabled:           getEnvAsBool("EVENTS_ENABLED", true),
+		EventsPollInterval:      getEnvAsInt("EVENTS_POLL_INTERVAL", 5),
+		EventsVisibilityTimeout: getEnvAsInt("EVENTS_VISIBILITY_TIMEOUT", 900),
+		EventsMaxDeliveries:     getEnvAsInt("EVENTS_MAX_DELIVERIES", 5),
+
+		RateLimitStore:       getEnv("RATE_LIMIT_STORE", "memory"),
+		RateLimitRPS:         getEnvAsFloat("RATE_LIMIT_RPS", 10),
+		RateLimitBurst:       getEnvAsInt("RATE_LIMIT_BURST", 20),
+		MaxConcurrent:        getEnvAsInt("MAX_CONCURRENT_EXECUTIONS", 10),
+		ConcurrencyQueueWait: getEnvAsInt("CONCURRENCY_QUEUE_WAIT", 30),
+
+		IdempotencyKeyTTL: getEnvAsInt("IDEMPOTENCY_KEY_TTL", 24),
+
+		WorkflowsPollInterval: getEnvAsInt("WORKFLOWS_POLL_INTERVAL", 15),
+		WorkflowsLeaseTimeout: getEnvAsInt("WORKFLOWS_LEASE_TIMEOUT", 60),
+
+		TracingExporter:    getEnv("TRACING_EXPORTER", "none"),
+		TracingSampleRatio: getEnvAsFloat("TRACING_SAMPLE_RATIO", 1),
+
+		ObjectStore:    getEnv("OBJECT_STORE", "local"),
+		ObjectStoreDir: getEnv("OBJECT_STORE_DIR", "/var/lib/voltrun/objects"),
+		S3Endpoint:     getEnv("S3_ENDPOINT", ""),
+		S3Bucket:       getEnv("S3_BUCKET", "voltrun"),
+		S3Region:       getEnv("S3_REGION", ""),
+		S3AccessKey:    getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:    getEnv("S3_SECRET_KEY", ""),
+		S3UseSSL:       getEnvAsBool("S3_USE_SSL", true),
+
+		MaxInputBytes:           getEnvAsInt("MAX_INPUT_BYTES", 6<<20),
+		MaxOutputBytes:          getEnvAsInt("MAX_OUTPUT_BYTES", 6<<20),
+		MaxLogBytes:             getEnvAsInt("MAX_LOG_BYTES", 10<<20),
+		PayloadOffloadThreshold: getEnvAsInt("PAYLOAD_OFFLOAD_THRESHOLD", 256<<10),
+
+		RetentionDays:          getEnvAsInt("EXECUTION_RETENTION_DAYS", 30),
+		RetentionMaxCount:      getEnvAsInt("EXECUTION_RETENTION_MAX_COUNT", 0),
+		RetentionSweepInterval: getEnvAsInt("RETENTION_SWEEP_INTERVAL", 3600),
+		ArchiveEnabled:         getEnvAsBool("EXECUTION_ARCHIVE_ENABLED", false),
+	}
+}
+
+// OIDCProviderConfig holds the settings of one OIDC provider
+type OIDCProviderConfig struct {
+	Name         string
+	DisplayName  string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+// loadOIDCProviders reads the providers named in OIDC_PROVIDERS, each
+// configured through OIDC_<NAME>_* variables
+func loadOIDCProviders() []OIDCProviderConfig {
+	var providers []OIDCProviderConfig
+	for _, name := range strings.Split(getEnv("OIDC_PROVIDERS", ""), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		providers = append(providers, OIDCProviderConfig{
+			Name:         name,
+			DisplayName:  getEnv(prefix+"DISPLAY_NAME", name),
+			Issuer:       getEnv(prefix+"ISSUER", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			Scopes:       strings.Fields(getEnv(prefix+"SCOPES", "email profile")),
+		})
+	}
+	return providers
+}
+
+// getEnv gets an environment variable or returns a default value
+func getEnv(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// defaultDatabaseURL returns the local development database for a driver
+func defaultDatabaseURL(driver string) string {
+	if driver == "sqlite" {
+		return "voltrun.db"
+	}
+	return "host=localhost user=voltrun password=voltrun dbname=voltrun port=5432 sslmode=disable"
+}
+
+// getEnvAsInt gets an environment variable as int or returns a default value
+func getEnvAsInt(key string, defaultValue int) int {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.Atoi(valueStr)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvAsFloat gets an environment variable as float64 or returns a default value
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvAsBool gets an environment variable as bool or returns a default value
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := os.Getenv(key)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
@@ -1,48 +1,48 @@
-package utils
-
-import (
-	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
-)
-
-var Logger *zap.Logger
-
-// InitLogger initializes the structured logger
-func InitLogger() error {
-	config := zap.NewProductionConfig()
-	config.EncoderConfig.TimeKey = "timestamp"
-	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-
-	var err error
-	Logger, err = config.Build()
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-// GetLogger returns the logger instance
-func GetLogger() *zap.Logger {
-	return Logger
-}
-
-// Info logs an info message
-func Info(msg string, fields ...zap.Field) {
-	Logger.Info(msg, fields...)
-}
-
-// Error logs an error message
-func Error(msg string, fields ...zap.Field) {
-	Logger.Error(msg, fields...)
-}
-
-// Debug logs a debug message
-func Debug(msg string, fields ...zap.Field) {
-	Logger.Debug(msg, fields...)
-}
-
-// Warn logs a warning message
-func Warn(msg string, fields ...zap.Field) {
-	Logger.Warn(msg, fields...)
-}
+package utils
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var Logger *zap.Logger
+
+// InitLogger initializes the structured logger
+func InitLogger() error {
+	config := zap.NewProductionConfig()
+	config.EncoderConfig.TimeKey = "timestamp"
+	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	var err error
+	Logger, err = config.Build()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetLogger returns the logger instance
+func GetLogger() *zap.Logger {
+	return Logger
+}
+
+// Info logs an info message
+func Info(msg string, fields ...zap.Field) {
+	Logger.Info(msg, fields...)
+}
+
+// Error logs an error message
+func Error(msg string, fields ...zap.Field) {
+	Logger.Error(msg, fields...)
+}
+
+// Debug logs a debug message
+func Debug(msg string, fields ...zap.Field) {
+	Logger.Debug(msg, fields...)
+}
+
+// Warn logs a warning message
+func Warn(msg string, fields ...zap.Field) {
+	Logger.Warn(msg, fields...)
+}
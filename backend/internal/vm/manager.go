@@ -1,88 +1,122 @@
-package vm
-
-import (
-	"context"
-	"fmt"
-	"time"
-
-	"github.com/google/uuid"
-)
-
-// VMConfig represents configuration for a Firecracker VM
-type VMConfig struct {
-	ID          string
-	MemoryMB    int
-	CPUs        int
-	KernelPath  string
-	RootFSPath  string
-	TimeoutSec  int
-	Environment map[string]string
-}
-
-// VMManager manages Firecracker VM lifecycle
-type VMManager struct {
-	// TODO: Add Firecracker SDK client
-}
-
-// NewVMManager creates a new VM manager instance
-func NewVMManager() *VMManager {
-	return &VMManager{}
-}
-
-// CreateVM creates and starts a new Firecracker VM
-func (m *VMManager) CreateVM(ctx context.Context, config VMConfig) (*VM, error) {
-	// TODO: Implement Firecracker VM creation
-	// This is a placeholder implementation
-	vm := &VM{
-		ID:        config.ID,
-		Status:    VMStatusStarting,
-		CreatedAt: time.Now(),
-	}
-
-	// Simulate VM startup
-	vm.Status = VMStatusRunning
-
-	return vm, nil
-}
-
-// DestroyVM stops and removes a VM
-func (m *VMManager) DestroyVM(ctx context.Context, vmID string) error {
-	// TODO: Implement Firecracker VM destruction
-	return nil
-}
-
-// GetVM retrieves VM information
-func (m *VMManager) GetVM(vmID string) (*VM, error) {
-	// TODO: Implement VM lookup
-	return nil, fmt.Errorf("VM not found: %s", vmID)
-}
-
-// ListVMs lists all running VMs
-func (m *VMManager) ListVMs() ([]*VM, error) {
-	// TODO: Implement VM listing
-	return []*VM{}, nil
-}
-
-// VM represents a Firecracker VM instance
-type VM struct {
-	ID        string
-	Status    VMStatus
-	IPAddress string
-	CreatedAt time.Time
-}
-
-// VMStatus represents VM lifecycle status
-type VMStatus string
-
-const (
-	VMStatusStarting VMStatus = "starting"
-	VMStatusRunning  VMStatus = "running"
-	VMStatusStopping VMStatus = "stopping"
-	VMStatusStopped  VMStatus = "stopped"
-	VMStatusError    VMStatus = "error"
-)
-
-// GenerateVMID generates a unique VM ID
-func GenerateVMID() string {
-	return fmt.Sprintf("vm-%s", uuid.New().String())
-}
+package vm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// VMConfig represents configuration for a Firecracker VM
+type VMConfig struct {
+	ID          string
+	MemoryMB    int
+	CPUs        int
+	KernelPath  string
+	RootFSPath  string
+	TimeoutSec  int
+	Environment map[string]string
+}
+
+// VMManager manages Firecracker VM lifecycle
+type VMManager struct {
+	// TODO: Add Firecracker SDK client
+
+	mu  sync.Mutex
+	vms map[string]*VM // VMs created and not yet destroyed
+}
+
+// NewVMManager creates a new VM manager instance
+func NewVMManager() *VMManager {
+	return &VMManager{vms: make(map[string]*VM)}
+}
+
+// CreateVM creates and starts a new Firecracker VM. VMs are not pooled
+// yet, so the VM it returns is never Reused.
+func (m *VMManager) CreateVM(ctx context.Context, config VMConfig) (*VM, error) {
+	_, span := tracing.Tracer.Start(ctx, "VMManager.CreateVM", trace.WithAttributes(
+		attribute.String("vm.id", config.ID),
+		attribute.Int("vm.memory_mb", config.MemoryMB),
+	))
+	defer span.End()
+
+	// TODO: Implement Firecracker VM creation
+	// This is a placeholder implementation
+	vm := &VM{
+		ID:        config.ID,
+		Status:    VMStatusStarting,
+		CreatedAt: time.Now(),
+	}
+
+	// Simulate VM startup
+	vm.Status = VMStatusRunning
+
+	m.mu.Lock()
+	m.vms[vm.ID] = vm
+	m.mu.Unlock()
+
+	return vm, nil
+}
+
+// DestroyVM stops and removes a VM
+func (m *VMManager) DestroyVM(ctx context.Context, vmID string) error {
+	// TODO: Implement Firecracker VM destruction
+	m.mu.Lock()
+	delete(m.vms, vmID)
+	m.mu.Unlock()
+	return nil
+}
+
+// GetVM retrieves VM information
+func (m *VMManager) GetVM(vmID string) (*VM, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	vm, ok := m.vms[vmID]
+	if !ok {
+		return nil, fmt.Errorf("VM not found: %s", vmID)
+	}
+	return vm, nil
+}
+
+// ListVMs lists all running VMs
+func (m *VMManager) ListVMs() ([]*VM, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	vms := make([]*VM, 0, len(m.vms))
+	for _, vm := range m.vms {
+		vms = append(vms, vm)
+	}
+	return vms, nil
+}
+
+// VM represents a Firecracker VM instance
+type VM struct {
+	ID        string
+	Status    VMStatus
+	IPAddress string
+	CreatedAt time.Time
+	Reused    bool // taken warm from a pool rather than booted by CreateVM
+}
+
+// VMStatus represents VM lifecycle status
+type VMStatus string
+
+const (
+	VMStatusStarting VMStatus = "starting"
+	VMStatusRunning  VMStatus = "running"
+	VMStatusStopping VMStatus = "stopping"
+	VMStatusStopped  VMStatus = "stopped"
+	VMStatusError    VMStatus = "error"
+)
+
+// GenerateVMID generates a unique VM ID
+func GenerateVMID() string {
+	return fmt.Sprintf("vm-%s", uuid.New().String())
+}
@@ -0,0 +1,306 @@
+package workflows
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// Step types
+const (
+	StepTask     = "task"
+	StepParallel = "parallel"
+	StepChoice   = "choice"
+	StepWait     = "wait"
+	StepMap      = "map"
+	StepSucceed  = "succeed"
+	StepFail     = "fail"
+)
+
+const (
+	defaultMapConcurrency = 10
+	maxMapConcurrency     = 100
+)
+
+// Definition describes a workflow as named steps linked by "next".
+// A step without "next" ends its workflow (or branch) with its output.
+type Definition struct {
+	StartAt string           `json:"start_at"`
+	Steps   map[string]*Step `json:"steps"`
+}
+
+// Step is a single state of a workflow. Each step receives the previous
+// step's output as its input.
+type Step struct {
+	Type string `json:"type"`
+	Next string `json:"next,omitempty"`
+
+	// task: invoke a function with the step input
+	FunctionID *uuid.UUID `json:"function_id,omitempty"`
+
+	// parallel: run every branch on the same input; output is an array
+	Branches []*Definition `json:"branches,omitempty"`
+
+	// choice: follow the first matching rule, else default
+	Choices []Choice `json:"choices,omitempty"`
+	Default string   `json:"default,omitempty"`
+
+	// wait: pause for seconds or until an RFC 3339 timestamp
+	Seconds int    `json:"seconds,omitempty"`
+	Until   string `json:"until,omitempty"`
+
+	// map: run the iterator for every element of the array at items_path
+	ItemsPath      string      `json:"items_path,omitempty"`
+	Iterator       *Definition `json:"iterator,omitempty"`
+	MaxConcurrency int         `json:"max_concurrency,omitempty"`
+
+	// fail: end the run with an error
+	Error string `json:"error,omitempty"`
+	Cause string `json:"cause,omitempty"`
+}
+
+// Choice is one rule of a choice step. Variable is a dot path into the
+// step input such as "order.total" or "items.0.sku"; exactly one
+// comparison must be set.
+type Choice struct {
+	Variable    string      `json:"variable"`
+	Equals      interface{} `json:"equals,omitempty"`
+	NotEquals   interface{} `json:"not_equals,omitempty"`
+	GreaterThan *float64    `json:"greater_than,omitempty"`
+	LessThan    *float64    `json:"less_than,omitempty"`
+	Exists      *bool       `json:"exists,omitempty"`
+	Next        string      `json:"next"`
+}
+
+// Parse decodes a definition written as JSON or YAML and validates it
+func Parse(data []byte) (*Definition, error) {
+	data = bytes.TrimSpace(data)
+	if !json.Valid(data) {
+		// YAML is normalised through JSON so both formats decode identically
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("invalid workflow definition: %w", err)
+		}
+		converted, err := json.Marshal(doc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid workflow definition: %w", err)
+		}
+		data = converted
+	}
+
+	var definition Definition
+	if err := json.Unmarshal(data, &definition); err != nil {
+		return nil, fmt.Errorf("invalid workflow definition: %w", err)
+	}
+	if err := definition.Validate(); err != nil {
+		return nil, err
+	}
+	return &definition, nil
+}
+
+// Validate checks step types, required fields and that every transition
+// points at an existing step
+func (d *Definition) Validate() error {
+	if len(d.Steps) == 0 {
+		return errors.New("workflow must define at least one step")
+	}
+	if _, ok := d.Steps[d.StartAt]; !ok {
+		return fmt.Errorf("start_at %q is not a step", d.StartAt)
+	}
+
+	for name, step := range d.Steps {
+		if step == nil {
+			return fmt.Errorf("step %q is empty", name)
+		}
+		if strings.ContainsAny(name, "/#") {
+			return fmt.Errorf("step name %q must not contain '/' or '#'", name)
+		}
+		if err := d.validateStep(name, step); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Definition) validateStep(name string, step *Step) error {
+	if step.Next != "" {
+		if _, ok := d.Steps[step.Next]; !ok {
+			return fmt.Errorf("step %q: next %q is not a step", name, step.Next)
+		}
+	}
+
+	switch step.Type {
+	case StepTask:
+		if step.FunctionID == nil || *step.FunctionID == uuid.Nil {
+			return fmt.Errorf("step %q: task requires function_id", name)
+		}
+
+	case StepParallel:
+		if len(step.Branches) == 0 {
+			return fmt.Errorf("step %q: parallel requires branches", name)
+		}
+		for i, branch := range step.Branches {
+			if branch == nil {
+				return fmt.Errorf("step %q: branch %d is empty", name, i)
+			}
+			if err := branch.Validate(); err != nil {
+				return fmt.Errorf("step %q branch %d: %w", name, i, err)
+			}
+		}
+
+	case StepChoice:
+		if len(step.Choices) == 0 {
+			return fmt.Errorf("step %q: choice requires choices", name)
+		}
+		if step.Next != "" {
+			return fmt.Errorf("step %q: choice uses choices and default instead of next", name)
+		}
+		for i, choice := range step.Choices {
+			if err := choice.validate(); err != nil {
+				return fmt.Errorf("step %q choice %d: %w", name, i, err)
+			}
+			if _, ok := d.Steps[choice.Next]; !ok {
+				return fmt.Errorf("step %q choice %d: next %q is not a step", name, i, choice.Next)
+			}
+		}
+		if step.Default != "" {
+			if _, ok := d.Steps[step.Default]; !ok {
+				return fmt.Errorf("step %q: default %q is not a step", name, step.Default)
+			}
+		}
+
+	case StepWait:
+		if (step.Seconds > 0) == (step.Until != "") {
+			return fmt.Errorf("step %q: wait requires either seconds or until", name)
+		}
+		if step.Until != "" {
+			if _, err := time.Parse(time.RFC3339, step.Until); err != nil {
+				return fmt.Errorf("step %q: until must be an RFC 3339 timestamp", name)
+			}
+		}
+
+	case StepMap:
+		if step.Iterator == nil {
+			return fmt.Errorf("step %q: map requires iterator", name)
+		}
+		if err := step.Iterator.Validate(); err != nil {
+			return fmt.Errorf("step %q iterator: %w", name, err)
+		}
+		if step.MaxConcurrency < 0 || step.MaxConcurrency > maxMapConcurrency {
+			return fmt.Errorf("step %q: max_concurrency must be between 1 and %d", name, maxMapConcurrency)
+		}
+
+	case StepSucceed, StepFail:
+		if step.Next != "" {
+			return fmt.Errorf("step %q: %s steps cannot have next", name, step.Type)
+		}
+
+	default:
+		return fmt.Errorf("step %q: unsupported type %q", name, step.Type)
+	}
+	return nil
+}
+
+func (c *Choice) validate() error {
+	set := 0
+	for _, present := range []bool{
+		c.Equals != nil, c.NotEquals != nil, c.GreaterThan != nil, c.LessThan != nil, c.Exists != nil,
+	} {
+		if present {
+			set++
+		}
+	}
+	if set != 1 {
+		return errors.New("exactly one of equals, not_equals, greater_than, less_than or exists is required")
+	}
+	return nil
+}
+
+// FunctionIDs returns every function invoked by the definition, including
+// those in nested branches and iterators
+func (d *Definition) FunctionIDs() []uuid.UUID {
+	var ids []uuid.UUID
+	for _, step := range d.Steps {
+		if step.FunctionID != nil {
+			ids = append(ids, *step.FunctionID)
+		}
+		for _, branch := range step.Branches {
+			ids = append(ids, branch.FunctionIDs()...)
+		}
+		if step.Iterator != nil {
+			ids = append(ids, step.Iterator.FunctionIDs()...)
+		}
+	}
+	return ids
+}
+
+// matches reports whether the rule holds for the given input
+func (c *Choice) matches(input interface{}) bool {
+	value, found := lookup(input, c.Variable)
+
+	switch {
+	case c.Exists != nil:
+		return found == *c.Exists
+	case c.Equals != nil:
+		return found && jsonEqual(value, c.Equals)
+	case c.NotEquals != nil:
+		return !found || !jsonEqual(value, c.NotEquals)
+	case c.GreaterThan != nil:
+		number, ok := value.(float64)
+		return found && ok && number > *c.GreaterThan
+	case c.LessThan != nil:
+		number, ok := value.(float64)
+		return found && ok && number < *c.LessThan
+	}
+	return false
+}
+
+// lookup resolves a dot path against decoded JSON. An empty path or "$"
+// selects the whole value.
+func lookup(data interface{}, path string) (interface{}, bool) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return data, true
+	}
+
+	current := data
+	for _, key := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// jsonEqual compares two decoded JSON values by their encoding
+func jsonEqual(a, b interface{}) bool {
+	left, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	right, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(left, right)
+}
@@ -0,0 +1,290 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/voltrun/backend/internal/exec"
+	"github.com/voltrun/backend/internal/storage"
+)
+
+// maxTransitions stops a looping definition that never reaches an end
+const maxTransitions = 1000
+
+// interpreter walks a run's definition. Every step is recorded under a
+// path that is stable across restarts, so a resumed run returns stored
+// outputs for completed steps instead of executing them again. Steps that
+// were in flight when a replica stopped are executed again.
+type interpreter struct {
+	engine *exec.ExecutionEngine
+	run    *storage.WorkflowRun
+}
+
+// execute runs a definition from its start step and returns the output
+// of its last step. prefix namespaces the step paths of nested branches.
+func (in *interpreter) execute(ctx context.Context, definition *Definition, prefix string, input interface{}) (interface{}, error) {
+	visits := make(map[string]int)
+	name := definition.StartAt
+	data := input
+
+	for transitions := 0; ; transitions++ {
+		if transitions >= maxTransitions {
+			return nil, fmt.Errorf("workflow exceeded %d transitions", maxTransitions)
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		step := definition.Steps[name]
+		visits[name]++
+		path := prefix + name + "#" + strconv.Itoa(visits[name])
+
+		output, err := in.record(ctx, path, name, step, data)
+		if err != nil {
+			return nil, err
+		}
+
+		next := step.Next
+		if step.Type == StepChoice {
+			next = step.Default
+			for _, choice := range step.Choices {
+				if choice.matches(data) {
+					next = choice.Next
+					break
+				}
+			}
+			if next == "" {
+				return nil, fmt.Errorf("step %q: no choice matched", name)
+			}
+		}
+
+		data = output
+		if next == "" {
+			return data, nil
+		}
+		name = next
+	}
+}
+
+// record returns the stored output of a completed step, or runs the step
+// and stores its outcome
+func (in *interpreter) record(ctx context.Context, path, name string, step *Step, input interface{}) (interface{}, error) {
+	var row storage.WorkflowStep
+	err := storage.DB.Where("run_id = ? AND path = ?", in.run.ID, path).First(&row).Error
+	if err == nil && row.Status == stepSucceeded {
+		var output interface{}
+		if len(row.Output) > 0 {
+			json.Unmarshal(row.Output, &output)
+		}
+		return output, nil
+	}
+
+	row.Status = stepRunning
+	row.Error = ""
+	if err != nil {
+		row = storage.WorkflowStep{
+			RunID:     in.run.ID,
+			Path:      path,
+			Name:      name,
+			Type:      step.Type,
+			Status:    stepRunning,
+			Input:     marshalJSON(input),
+			StartedAt: time.Now(),
+		}
+	}
+	if err := storage.DB.Save(&row).Error; err != nil {
+		return nil, fmt.Errorf("failed to record step %q: %w", name, err)
+	}
+
+	output, stepErr := in.runStep(ctx, &row, path, step, input)
+
+	completedAt := time.Now()
+	row.CompletedAt = &completedAt
+	if stepErr != nil {
+		row.Status = stepFailed
+		row.Error = stepErr.Error()
+	} else {
+		row.Status = stepSucceeded
+		row.Output = marshalJSON(output)
+	}
+	if err := storage.DB.Save(&row).Error; err != nil {
+		return nil, fmt.Errorf("failed to record step %q: %w", name, err)
+	}
+
+	if stepErr != nil {
+		return nil, fmt.Errorf("step %q: %w", name, stepErr)
+	}
+	return output, nil
+}
+
+// runStep performs a single step
+func (in *interpreter) runStep(ctx context.Context, row *storage.WorkflowStep, path string, step *Step, input interface{}) (interface{}, error) {
+	switch step.Type {
+	case StepTask:
+		return in.runTask(ctx, row, step, input)
+	case StepParallel:
+		return in.runParallel(ctx, path, step, input)
+	case StepMap:
+		return in.runMap(ctx, path, step, input)
+	case StepWait:
+		return input, in.runWait(ctx, row, step)
+	case StepFail:
+		if step.Cause != "" {
+			return nil, fmt.Errorf("%s: %s", step.Error, step.Cause)
+		}
+		if step.Error != "" {
+			return nil, errors.New(step.Error)
+		}
+		return nil, errors.New("workflow failed")
+	}
+
+	// choice and succeed pass their input through
+	return input, nil
+}
+
+// runTask invokes the step's function through the execution engine
+func (in *interpreter) runTask(ctx context.Context, row *storage.WorkflowStep, step *Step, input interface{}) (interface{}, error) {
+	var count int64
+	storage.DB.Model(&storage.Function{}).
+		Where("id = ? AND organization_id = ? AND status = ?", *step.FunctionID, in.run.OrganizationID, "active").
+		Count(&count)
+	if count == 0 {
+		return nil, errors.New("function not found or inactive")
+	}
+
+	result, err := in.engine.Execute(ctx, exec.ExecutionRequest{
+		FunctionID:    *step.FunctionID,
+		Input:         input,
+		UserID:        in.run.UserID,
+		TriggerType:   exec.TriggerWorkflow,
+		TriggerSource: in.run.ID.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	row.ExecutionID = &result.ExecutionID
+	if result.Status != "success" {
+		return nil, fmt.Errorf("%s error: %s", result.ErrorClass, result.Error)
+	}
+	return normalize(result.Output), nil
+}
+
+// runParallel executes every branch concurrently; the first failure
+// cancels the remaining branches
+func (in *interpreter) runParallel(ctx context.Context, path string, step *Step, input interface{}) (interface{}, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	outputs := make([]interface{}, len(step.Branches))
+	errs := make([]error, len(step.Branches))
+	var wg sync.WaitGroup
+	for i, branch := range step.Branches {
+		wg.Add(1)
+		go func(i int, branch *Definition) {
+			defer wg.Done()
+			outputs[i], errs[i] = in.execute(ctx, branch, path+"/"+strconv.Itoa(i)+"/", input)
+			if errs[i] != nil {
+				cancel()
+			}
+		}(i, branch)
+	}
+	wg.Wait()
+
+	if err := firstError(errs); err != nil {
+		return nil, err
+	}
+	return outputs, nil
+}
+
+// runMap executes the iterator once per item with bounded concurrency
+func (in *interpreter) runMap(ctx context.Context, path string, step *Step, input interface{}) (interface{}, error) {
+	value, found := lookup(input, step.ItemsPath)
+	items, ok := value.([]interface{})
+	if !found || !ok {
+		return nil, fmt.Errorf("items_path %q is not an array", step.ItemsPath)
+	}
+
+	concurrency := step.MaxConcurrency
+	if concurrency == 0 {
+		concurrency = defaultMapConcurrency
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	outputs := make([]interface{}, len(items))
+	errs := make([]error, len(items))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, item := range items {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int, item interface{}) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			outputs[i], errs[i] = in.execute(ctx, step.Iterator, path+"/"+strconv.Itoa(i)+"/", item)
+			if errs[i] != nil {
+				cancel()
+			}
+		}(i, item)
+	}
+	wg.Wait()
+
+	if err := firstError(errs); err != nil {
+		return nil, err
+	}
+	return outputs, nil
+}
+
+// runWait sleeps until the step's wake time. The wake time is stored on
+// first entry so a resumed run only waits for what remains.
+func (in *interpreter) runWait(ctx context.Context, row *storage.WorkflowStep, step *Step) error {
+	if row.WakeAt == nil {
+		wakeAt := time.Now().Add(time.Duration(step.Seconds) * time.Second)
+		if step.Until != "" {
+			wakeAt, _ = time.Parse(time.RFC3339, step.Until)
+		}
+		row.WakeAt = &wakeAt
+		if err := storage.DB.Model(row).Update("wake_at", wakeAt).Error; err != nil {
+			return err
+		}
+	}
+
+	timer := time.NewTimer(time.Until(*row.WakeAt))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// firstError returns the first error that is not a cancellation caused by
+// a sibling failing, falling back to any error
+func firstError(errs []error) error {
+	var fallback error
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, context.Canceled) {
+			return err
+		}
+		fallback = err
+	}
+	return fallback
+}
+
+// normalize round-trips a value through JSON so live and replayed step
+// outputs have identical types
+func normalize(value interface{}) interface{} {
+	var normalized interface{}
+	json.Unmarshal(marshalJSON(value), &normalized)
+	return normalized
+}
@@ -0,0 +1,250 @@
+package workflows
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/voltrun/backend/internal/exec"
+	"github.com/voltrun/backend/internal/storage"
+	"github.com/voltrun/backend/internal/utils"
+	"go.uber.org/zap"
+	"gorm.io/datatypes"
+)
+
+// Run statuses
+const (
+	RunRunning   = "running"
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+	RunCancelled = "cancelled"
+)
+
+// Step statuses
+const (
+	stepRunning   = "running"
+	stepSucceeded = "succeeded"
+	stepFailed    = "failed"
+)
+
+// resumeBatchSize bounds how many orphaned runs one replica adopts per tick
+const resumeBatchSize = 20
+
+// ErrRunNotActive is returned when cancelling a run that already finished
+var ErrRunNotActive = errors.New("workflow run is not running")
+
+// Runner executes workflow runs.
+//
+// A replica holds a lease on every run it executes and renews it while the
+// run progresses. Runs whose lease expires, because their replica stopped,
+// are adopted by the next poll on any replica and resumed from their last
+// completed step.
+type Runner struct {
+	engine   *exec.ExecutionEngine
+	owner    string
+	interval time.Duration
+	lease    time.Duration
+}
+
+// NewRunner creates a runner that looks for orphaned runs every interval
+func NewRunner(engine *exec.ExecutionEngine, interval, lease time.Duration) *Runner {
+	hostname, _ := os.Hostname()
+	return &Runner{
+		engine:   engine,
+		owner:    hostname + "/" + uuid.NewString(),
+		interval: interval,
+		lease:    lease,
+	}
+}
+
+// Start runs the polling loop until ctx is cancelled
+func (r *Runner) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := r.adoptOrphans(); err != nil {
+					utils.Error("Workflow poll failed", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// StartRun persists a new run and begins executing it
+func (r *Runner) StartRun(workflow *storage.Workflow, input interface{}) (*storage.WorkflowRun, error) {
+	if _, err := Parse(workflow.Definition); err != nil {
+		return nil, err
+	}
+
+	run := storage.WorkflowRun{
+		WorkflowID:     workflow.ID,
+		UserID:         workflow.UserID,
+		OrganizationID: workflow.OrganizationID,
+		Status:         RunRunning,
+		Definition:     workflow.Definition,
+		Input:          marshalJSON(input),
+		StartedAt:      time.Now(),
+	}
+	if err := storage.DB.Create(&run).Error; err != nil {
+		return nil, fmt.Errorf("failed to create workflow run: %w", err)
+	}
+
+	go r.resume(run.ID)
+	return &run, nil
+}
+
+// Cancel stops a running run. The replica executing it notices at its next
+// lease renewal and abandons in-flight steps.
+func Cancel(runID, organizationID uuid.UUID) error {
+	now := time.Now()
+	result := storage.DB.Model(&storage.WorkflowRun{}).
+		Where("id = ? AND organization_id = ? AND status = ?", runID, organizationID, RunRunning).
+		Updates(map[string]interface{}{
+			"status":       RunCancelled,
+			"completed_at": now,
+			"lease_until":  nil,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRunNotActive
+	}
+	return nil
+}
+
+// adoptOrphans resumes running runs whose lease has lapsed
+func (r *Runner) adoptOrphans() error {
+	var ids []uuid.UUID
+	if err := storage.DB.Model(&storage.WorkflowRun{}).
+		Where("status = ? AND (lease_until IS NULL OR lease_until < ?)", RunRunning, time.Now()).
+		Order("started_at").
+		Limit(resumeBatchSize).
+		Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		go r.resume(id)
+	}
+	return nil
+}
+
+// claim takes the lease on a run if no live replica holds it
+func (r *Runner) claim(runID uuid.UUID) bool {
+	now := time.Now()
+	result := storage.DB.Model(&storage.WorkflowRun{}).
+		Where("id = ? AND status = ? AND (lease_until IS NULL OR lease_until < ?)", runID, RunRunning, now).
+		Updates(map[string]interface{}{
+			"lease_owner": r.owner,
+			"lease_until": now.Add(r.lease),
+		})
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// renew extends the lease. It reports false once the run was cancelled or
+// another replica took it over.
+func (r *Runner) renew(runID uuid.UUID) (bool, error) {
+	result := storage.DB.Model(&storage.WorkflowRun{}).
+		Where("id = ? AND lease_owner = ? AND status = ?", runID, r.owner, RunRunning).
+		Update("lease_until", time.Now().Add(r.lease))
+	if result.Error != nil {
+		return true, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// resume claims a run and executes it from its last completed step
+func (r *Runner) resume(runID uuid.UUID) {
+	if !r.claim(runID) {
+		return
+	}
+
+	var run storage.WorkflowRun
+	if err := storage.DB.First(&run, "id = ?", runID).Error; err != nil {
+		utils.Error("Failed to load workflow run", zap.String("run_id", runID.String()), zap.Error(err))
+		return
+	}
+
+	definition, err := Parse(run.Definition)
+	if err != nil {
+		r.finish(&run, nil, err)
+		return
+	}
+
+	var input interface{}
+	if len(run.Input) > 0 {
+		json.Unmarshal(run.Input, &input)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.keepAlive(ctx, cancel, runID)
+
+	in := &interpreter{engine: r.engine, run: &run}
+	output, err := in.execute(ctx, definition, "", input)
+	r.finish(&run, output, err)
+}
+
+// keepAlive renews the lease until ctx ends, cancelling it when the run
+// is no longer ours
+func (r *Runner) keepAlive(ctx context.Context, cancel context.CancelFunc, runID uuid.UUID) {
+	ticker := time.NewTicker(r.lease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			held, err := r.renew(runID)
+			if err != nil {
+				utils.Error("Failed to renew workflow lease", zap.String("run_id", runID.String()), zap.Error(err))
+				continue
+			}
+			if !held {
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// finish records the outcome unless the run was cancelled or adopted by
+// another replica in the meantime
+func (r *Runner) finish(run *storage.WorkflowRun, output interface{}, runErr error) {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"completed_at": now,
+		"lease_until":  nil,
+	}
+	if runErr != nil {
+		updates["status"] = RunFailed
+		updates["error"] = runErr.Error()
+	} else {
+		updates["status"] = RunSucceeded
+		updates["output"] = marshalJSON(output)
+	}
+
+	if err := storage.DB.Model(&storage.WorkflowRun{}).
+		Where("id = ? AND lease_owner = ? AND status = ?", run.ID, r.owner, RunRunning).
+		Updates(updates).Error; err != nil {
+		utils.Error("Failed to record workflow result", zap.String("run_id", run.ID.String()), zap.Error(err))
+	}
+}
+
+// marshalJSON encodes a value for a JSONB column
+func marshalJSON(data interface{}) datatypes.JSON {
+	bytes, _ := json.Marshal(data)
+	return datatypes.JSON(bytes)
+}
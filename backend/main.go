@@ -1,7 +1,7 @@
-package main
-
-import "fmt"
-
-func main() {
-    fmt.Println("backend service placeholder")
-}
+package main
+
+import "fmt"
+
+func main() {
+    fmt.Println("backend service placeholder")
+}
@@ -1,3 +1,3 @@
-package models
-
-// DB schema placeholders
+package models
+
+// DB schema placeholders
@@ -1,3 +1,3 @@
-package routes
-
-// placeholder for HTTP handlers
+package routes
+
+// placeholder for HTTP handlers
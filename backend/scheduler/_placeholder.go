@@ -1,3 +0,0 @@
-package scheduler
-
-// queue execution requests
@@ -0,0 +1,44 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// parser accepts standard 5-field expressions and descriptors such as
+// @hourly or @every 5m
+var parser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// ParseSchedule validates a cron expression and timezone
+func ParseSchedule(expression, timezone string) (cron.Schedule, *time.Location, error) {
+	if strings.HasPrefix(expression, "CRON_TZ=") || strings.HasPrefix(expression, "TZ=") {
+		return nil, nil, fmt.Errorf("set the timezone field instead of embedding it in the expression")
+	}
+
+	sched, err := parser.Parse(expression)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid cron expression: %w", err)
+	}
+
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid timezone: %w", err)
+	}
+
+	return sched, loc, nil
+}
+
+// NextRun returns the first activation of a schedule strictly after t
+func NextRun(expression, timezone string, after time.Time) (time.Time, error) {
+	sched, loc, err := ParseSchedule(expression, timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return sched.Next(after.In(loc)).UTC(), nil
+}
@@ -0,0 +1,79 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata" // timezones resolve without the host's zoneinfo
+)
+
+func TestParseSchedule(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		timezone   string
+		wantErr    bool
+	}{
+		{name: "five fields", expression: "*/5 * * * *"},
+		{name: "ranges and lists", expression: "0 9-17 * * MON-FRI"},
+		{name: "descriptor", expression: "@hourly"},
+		{name: "interval", expression: "@every 90s"},
+		{name: "timezone", expression: "0 9 * * *", timezone: "Europe/Paris"},
+		{name: "seconds field", expression: "0 */5 * * * *", wantErr: true},
+		{name: "too few fields", expression: "* * *", wantErr: true},
+		{name: "out of range", expression: "60 * * * *", wantErr: true},
+		{name: "empty", expression: "", wantErr: true},
+		{name: "embedded CRON_TZ", expression: "CRON_TZ=Europe/Paris 0 9 * * *", wantErr: true},
+		{name: "embedded TZ", expression: "TZ=Europe/Paris 0 9 * * *", wantErr: true},
+		{name: "unknown timezone", expression: "0 9 * * *", timezone: "Mars/Olympus", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, loc, err := ParseSchedule(tt.expression, tt.timezone)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && tt.timezone == "" && loc != time.UTC {
+				t.Fatalf("location = %v, want UTC by default", loc)
+			}
+		})
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	winter := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC) // a Thursday
+	summer := time.Date(2026, 7, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		expression string
+		timezone   string
+		after      time.Time
+		want       time.Time
+	}{
+		{"next minute step", "*/5 * * * *", "", winter.Add(time.Minute), winter.Add(5 * time.Minute)},
+		{"strictly after", "0 12 * * *", "", winter, winter.AddDate(0, 0, 1)},
+		{"later today", "30 18 * * *", "", winter, time.Date(2026, 1, 15, 18, 30, 0, 0, time.UTC)},
+		{"weekdays skip the weekend", "0 9 * * MON-FRI", "", time.Date(2026, 1, 16, 10, 0, 0, 0, time.UTC), time.Date(2026, 1, 19, 9, 0, 0, 0, time.UTC)},
+		{"day of month", "0 0 1 * *", "", winter, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"descriptor", "@daily", "", winter, time.Date(2026, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{"interval", "@every 90s", "", winter, winter.Add(90 * time.Second)},
+		{"timezone in winter", "0 9 * * *", "America/New_York", winter, time.Date(2026, 1, 15, 14, 0, 0, 0, time.UTC)},
+		{"timezone in summer", "0 9 * * *", "America/New_York", summer, time.Date(2026, 7, 15, 13, 0, 0, 0, time.UTC)},
+		{"timezone ahead of UTC", "0 9 * * *", "Asia/Tokyo", winter, time.Date(2026, 1, 16, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NextRun(tt.expression, tt.timezone, tt.after)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) || got.Location() != time.UTC {
+				t.Fatalf("next run = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NextRun("not cron", "", winter); err == nil {
+		t.Fatal("invalid expression accepted")
+	}
+}
@@ -0,0 +1,130 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/voltrun/backend/internal/exec"
+	"github.com/voltrun/backend/internal/storage"
+	"github.com/voltrun/backend/internal/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultBatchSize bounds how many due schedules one replica claims per tick
+const defaultBatchSize = 50
+
+// Scheduler fires cron schedules stored in the database.
+//
+// Several backend replicas can run a Scheduler at once: due rows are
+// claimed with SELECT ... FOR UPDATE SKIP LOCKED and advanced in the same
+// transaction, so each activation is fired by exactly one replica.
+type Scheduler struct {
+	engine    *exec.ExecutionEngine
+	interval  time.Duration
+	batchSize int
+}
+
+// New creates a scheduler that polls for due schedules every interval
+func New(engine *exec.ExecutionEngine, interval time.Duration) *Scheduler {
+	return &Scheduler{
+		engine:    engine,
+		interval:  interval,
+		batchSize: defaultBatchSize,
+	}
+}
+
+// Start runs the polling loop until ctx is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.tick(ctx); err != nil {
+					utils.Error("Scheduler tick failed", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// tick claims every due schedule and fires it
+func (s *Scheduler) tick(ctx context.Context) error {
+	now := time.Now().UTC()
+	var due []storage.Schedule
+
+	err := storage.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("enabled = ? AND next_run_at <= ?", true, now).
+			Order("next_run_at").
+			Limit(s.batchSize).
+			Find(&due).Error; err != nil {
+			return err
+		}
+
+		for i := range due {
+			// Missed activations are fired once rather than replayed
+			updates := map[string]interface{}{"last_run_at": now}
+			next, err := NextRun(due[i].Expression, due[i].Timezone, now)
+			if err != nil {
+				utils.Error("Disabling schedule with invalid expression",
+					zap.String("schedule_id", due[i].ID.String()), zap.Error(err))
+				updates["enabled"] = false
+				updates["next_run_at"] = nil
+			} else {
+				updates["next_run_at"] = next
+			}
+			if err := tx.Model(&due[i]).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, schedule := range due {
+		go s.fire(ctx, schedule)
+	}
+	return nil
+}
+
+// fire executes the function behind a claimed schedule
+func (s *Scheduler) fire(ctx context.Context, schedule storage.Schedule) {
+	var function storage.Function
+	if err := storage.DB.First(&function, "id = ?", schedule.FunctionID).Error; err != nil {
+		utils.Error("Scheduled function not found", zap.String("schedule_id", schedule.ID.String()))
+		return
+	}
+	if function.Status != "active" {
+		return
+	}
+
+	input := map[string]interface{}{}
+	if len(schedule.Input) > 0 {
+		if err := json.Unmarshal(schedule.Input, &input); err != nil {
+			utils.Error("Invalid schedule input", zap.String("schedule_id", schedule.ID.String()), zap.Error(err))
+			return
+		}
+	}
+
+	_, err := s.engine.Execute(ctx, exec.ExecutionRequest{
+		FunctionID:    function.ID,
+		Input:         input,
+		UserID:        function.UserID,
+		TriggerType:   exec.TriggerSchedule,
+		TriggerSource: schedule.ID.String(),
+	})
+	if err != nil {
+		utils.Error("Scheduled execution failed",
+			zap.String("schedule_id", schedule.ID.String()), zap.Error(err))
+	}
+}
@@ -1,3 +1,3 @@
-package storage
-
-// upload & store user code
+package storage
+
+// upload & store user code
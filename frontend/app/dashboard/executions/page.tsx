@@ -28,15 +28,19 @@ export default function ExecutionsPage() {
   const [selectedExecution, setSelectedExecution] = useState<Execution | null>(
     null
   );
+  const [nextCursor, setNextCursor] = useState("");
 
   useEffect(() => {
     loadExecutions();
   }, []);
 
-  const loadExecutions = async () => {
+  const loadExecutions = async (cursor = "") => {
     try {
-      const data = await apiClient.listExecutions();
-      setExecutions(Array.isArray(data) ? data : []);
+      const data = await apiClient.listExecutions(cursor ? { cursor } : {});
+      setExecutions((previous) =>
+        cursor ? [...previous, ...data.executions] : data.executions
+      );
+      setNextCursor(data.next_cursor);
     } catch (err: unknown) {
       const errorMessage =
         err instanceof Error ? err.message : "Failed to load executions";
@@ -46,6 +50,19 @@ export default function ExecutionsPage() {
     }
   };
 
+  // The list omits input, output and logs; fetch them for the modal
+  const showExecution = async (execution: Execution) => {
+    try {
+      setSelectedExecution(
+        (await apiClient.getExecution(execution.id)) as Execution
+      );
+    } catch (err: unknown) {
+      const errorMessage =
+        err instanceof Error ? err.message : "Failed to load execution";
+      setError(errorMessage);
+    }
+  };
+
   const filteredExecutions = executions.filter((ex) => {
     if (filter === "all") return true;
     return ex.status === filter;
@@ -130,7 +147,7 @@ export default function ExecutionsPage() {
                 <tr
                   key={execution.id}
                   className="hover:bg-gray-50 cursor-pointer"
-                  onClick={() => setSelectedExecution(execution)}
+                  onClick={() => showExecution(execution)}
                 >
                   <td className="px-6 py-4 whitespace-nowrap">
                     <div className="text-sm font-medium text-gray-900">
@@ -165,7 +182,7 @@ export default function ExecutionsPage() {
                     <button
                       onClick={(e) => {
                         e.stopPropagation();
-                        setSelectedExecution(execution);
+                        showExecution(execution);
                       }}
                       className="text-indigo-600 hover:text-indigo-900"
                     >
@@ -176,6 +193,16 @@ export default function ExecutionsPage() {
               ))}
             </tbody>
           </table>
+          {nextCursor && (
+            <div className="px-6 py-4 border-t border-gray-200 text-center">
+              <button
+                onClick={() => loadExecutions(nextCursor)}
+                className="text-indigo-600 hover:text-indigo-900 text-sm font-medium"
+              >
+                Load more
+              </button>
+            </div>
+          )}
         </div>
       )}
 
@@ -67,12 +67,8 @@ export default function FunctionDetailPage({
 
   const loadExecutions = async () => {
     try {
-      const data = await apiClient.listExecutions();
-      // Filter executions for this function
-      const filtered = Array.isArray(data)
-        ? data.filter((ex: any) => ex.function_id === id)
-        : [];
-      setExecutions(filtered);
+      const data = await apiClient.listExecutions({ function_id: id });
+      setExecutions(data.executions);
     } catch (err) {
       console.error("Failed to load executions:", err);
     }
@@ -0,0 +1,53 @@
+"use client";
+
+import { useAuth } from "@/lib/auth-context";
+import Link from "next/link";
+import { useRouter } from "next/navigation";
+import { useEffect, useState } from "react";
+
+// Single sign-on lands here with the tokens, or an error, in the URL
+// fragment so they never reach server logs
+export default function LoginCallbackPage() {
+  const [error, setError] = useState("");
+  const { completeOIDCLogin } = useAuth();
+  const router = useRouter();
+
+  useEffect(() => {
+    const params = new URLSearchParams(window.location.hash.slice(1));
+    window.history.replaceState(null, "", window.location.pathname);
+
+    const token = params.get("token");
+    const refreshToken = params.get("refresh_token");
+    if (!token || !refreshToken) {
+      setError(params.get("error") || "Sign in failed");
+      return;
+    }
+    completeOIDCLogin(token, refreshToken)
+      .then(() => router.push("/dashboard"))
+      .catch((err: any) => setError(err.message || "Sign in failed"));
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, []);
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-gradient-to-br from-blue-50 to-indigo-100 px-4">
+      <div className="max-w-md w-full space-y-6 bg-white p-8 rounded-xl shadow-lg text-center">
+        <h2 className="text-3xl font-extrabold text-gray-900">⚡ VoltRun</h2>
+        {error ? (
+          <>
+            <div className="bg-red-50 border border-red-200 text-red-700 px-4 py-3 rounded">
+              {error}
+            </div>
+            <Link
+              href="/login"
+              className="font-medium text-indigo-600 hover:text-indigo-500"
+            >
+              Back to sign in
+            </Link>
+          </>
+        ) : (
+          <p className="text-sm text-gray-600">Signing you in...</p>
+        )}
+      </div>
+    </div>
+  );
+}
@@ -1,18 +1,27 @@
 "use client";
 
+import { apiClient, OIDCProvider } from "@/lib/api";
 import { useAuth } from "@/lib/auth-context";
 import Link from "next/link";
 import { useRouter } from "next/navigation";
-import { useState } from "react";
+import { useEffect, useState } from "react";
 
 export default function LoginPage() {
   const [email, setEmail] = useState("");
   const [password, setPassword] = useState("");
   const [error, setError] = useState("");
   const [isLoading, setIsLoading] = useState(false);
+  const [providers, setProviders] = useState<OIDCProvider[]>([]);
   const { login } = useAuth();
   const router = useRouter();
 
+  useEffect(() => {
+    apiClient
+      .listOIDCProviders()
+      .then(setProviders)
+      .catch(() => setProviders([]));
+  }, []);
+
   const handleSubmit = async (e: React.FormEvent) => {
     e.preventDefault();
     setError("");
@@ -90,6 +99,20 @@ export default function LoginPage() {
             </button>
           </div>
 
+          {providers.length > 0 && (
+            <div className="space-y-2">
+              {providers.map((provider) => (
+                <a
+                  key={provider.name}
+                  href={apiClient.oidcLoginURL(provider.name)}
+                  className="w-full flex justify-center py-2 px-4 border border-gray-300 text-sm font-medium rounded-md text-gray-700 bg-white hover:bg-gray-50"
+                >
+                  Sign in with {provider.display_name}
+                </a>
+              ))}
+            </div>
+          )}
+
           <div className="text-center">
             <Link
               href="/register"
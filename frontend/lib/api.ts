@@ -20,21 +20,91 @@ export interface RegisterRequest {
 
 export interface AuthResponse {
   token: string;
+  refresh_token: string;
+  expires_in: number;
   user: User;
 }
 
+export interface OIDCProvider {
+  name: string;
+  display_name: string;
+  login_url: string;
+}
+
+export type OrganizationRole = "owner" | "admin" | "developer" | "viewer";
+
+export interface Organization {
+  id: string;
+  name: string;
+  personal: boolean;
+  role: OrganizationRole;
+  created_at: string;
+}
+
+export interface ExecutionPage {
+  executions: any[];
+  next_cursor: string;
+}
+
 class ApiClient {
   private baseURL: string;
   private token: string | null = null;
+  private refreshToken: string | null = null;
+  private refreshing: Promise<boolean> | null = null;
+  private organizationID: string | null = null;
 
   constructor() {
     this.baseURL = API_BASE_URL;
-    // Load token from localStorage on client side
+    // Load tokens from localStorage on client side
     if (typeof window !== "undefined") {
       this.token = localStorage.getItem("token");
+      this.refreshToken = localStorage.getItem("refresh_token");
+      this.organizationID = localStorage.getItem("organization_id");
     }
   }
 
+  private setSession(
+    response: Pick<AuthResponse, "token" | "refresh_token"> | null
+  ) {
+    this.setToken(response ? response.token : null);
+    this.refreshToken = response ? response.refresh_token : null;
+    if (typeof window !== "undefined") {
+      if (this.refreshToken) {
+        localStorage.setItem("refresh_token", this.refreshToken);
+      } else {
+        localStorage.removeItem("refresh_token");
+      }
+    }
+  }
+
+  // Exchanges the refresh token for new tokens; concurrent callers share
+  // one request because each refresh token works only once
+  private refreshSession(): Promise<boolean> {
+    if (!this.refreshToken) {
+      return Promise.resolve(false);
+    }
+    if (!this.refreshing) {
+      this.refreshing = fetch(`${this.baseURL}/auth/refresh`, {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        body: JSON.stringify({ refresh_token: this.refreshToken }),
+      })
+        .then(async (response) => {
+          if (!response.ok) {
+            this.setSession(null);
+            return false;
+          }
+          this.setSession(await response.json());
+          return true;
+        })
+        .catch(() => false)
+        .finally(() => {
+          this.refreshing = null;
+        });
+    }
+    return this.refreshing;
+  }
+
   setToken(token: string | null) {
     this.token = token;
     if (typeof window !== "undefined") {
@@ -50,9 +120,27 @@ class ApiClient {
     return this.token;
   }
 
+  // Resource requests act on this organization; null means the user's
+  // personal one
+  setOrganization(organizationID: string | null) {
+    this.organizationID = organizationID;
+    if (typeof window !== "undefined") {
+      if (organizationID) {
+        localStorage.setItem("organization_id", organizationID);
+      } else {
+        localStorage.removeItem("organization_id");
+      }
+    }
+  }
+
+  getOrganization() {
+    return this.organizationID;
+  }
+
   private async request<T>(
     endpoint: string,
-    options: RequestInit = {}
+    options: RequestInit = {},
+    retry = true
   ): Promise<T> {
     const headers: HeadersInit = {
       "Content-Type": "application/json",
@@ -62,12 +150,25 @@ class ApiClient {
     if (this.token) {
       headers["Authorization"] = `Bearer ${this.token}`;
     }
+    if (this.organizationID) {
+      headers["X-Organization-ID"] = this.organizationID;
+    }
 
     const response = await fetch(`${this.baseURL}${endpoint}`, {
       ...options,
       headers,
     });
 
+    // The access token expired; refresh it and try once more
+    if (
+      response.status === 401 &&
+      retry &&
+      this.token &&
+      (await this.refreshSession())
+    ) {
+      return this.request<T>(endpoint, options, false);
+    }
+
     if (!response.ok) {
       const error = await response
         .json()
@@ -80,10 +181,12 @@ class ApiClient {
 
   // Auth endpoints
   async register(data: RegisterRequest): Promise<AuthResponse> {
-    return this.request<AuthResponse>("/auth/register", {
+    const response = await this.request<AuthResponse>("/auth/register", {
       method: "POST",
       body: JSON.stringify(data),
     });
+    this.setSession(response);
+    return response;
   }
 
   async login(data: LoginRequest): Promise<AuthResponse> {
@@ -91,18 +194,74 @@ class ApiClient {
       method: "POST",
       body: JSON.stringify(data),
     });
-    this.setToken(response.token);
+    this.setSession(response);
     return response;
   }
 
-  logout() {
-    this.setToken(null);
+  async logout() {
+    if (this.token) {
+      await this.request("/auth/logout", { method: "POST" }, false).catch(
+        () => undefined
+      );
+    }
+    this.setSession(null);
+    this.setOrganization(null);
+  }
+
+  // Single sign-on: the browser leaves for the provider and comes back to
+  // /login/callback with the tokens in the URL fragment
+  async listOIDCProviders(): Promise<OIDCProvider[]> {
+    return this.request<OIDCProvider[]>("/auth/oidc/providers");
+  }
+
+  oidcLoginURL(provider: string) {
+    return `${this.baseURL}/auth/oidc/${encodeURIComponent(provider)}/login`;
+  }
+
+  async completeOIDCLogin(token: string, refreshToken: string): Promise<User> {
+    this.setSession({ token, refresh_token: refreshToken });
+    const response = await this.getCurrentUser();
+    return response.user;
   }
 
   async getCurrentUser(): Promise<{ user: User }> {
     return this.request<{ user: User }>("/auth/me");
   }
 
+  // Organization endpoints
+  async listOrganizations(): Promise<Organization[]> {
+    return this.request<Organization[]>("/organizations");
+  }
+
+  async createOrganization(name: string): Promise<Organization> {
+    return this.request<Organization>("/organizations", {
+      method: "POST",
+      body: JSON.stringify({ name }),
+    });
+  }
+
+  async listMembers(organizationID: string) {
+    return this.request(`/organizations/${organizationID}/members`);
+  }
+
+  async inviteMember(
+    organizationID: string,
+    email: string,
+    role: OrganizationRole
+  ) {
+    return this.request(`/organizations/${organizationID}/invitations`, {
+      method: "POST",
+      body: JSON.stringify({ email, role }),
+    });
+  }
+
+  async acceptInvitation(token: string): Promise<Organization> {
+    return this.request<Organization>("/invitations/accept", {
+      method: "POST",
+      body: JSON.stringify({ token }),
+    });
+  }
+
   // Function endpoints
   async listFunctions() {
     return this.request("/functions");
@@ -140,8 +299,13 @@ class ApiClient {
   }
 
   // Execution endpoints
-  async listExecutions() {
-    return this.request("/executions");
+  async listExecutions(
+    params: Record<string, string> = {}
+  ): Promise<ExecutionPage> {
+    const query = new URLSearchParams(params).toString();
+    return this.request<ExecutionPage>(
+      `/executions${query ? `?${query}` : ""}`
+    );
   }
 
   async getExecution(id: string) {
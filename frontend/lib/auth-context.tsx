@@ -13,6 +13,7 @@ interface AuthContextType {
   user: User | null;
   isLoading: boolean;
   login: (email: string, password: string) => Promise<void>;
+  completeOIDCLogin: (token: string, refreshToken: string) => Promise<void>;
   register: (email: string, password: string, name: string) => Promise<void>;
   logout: () => void;
   isAuthenticated: boolean;
@@ -52,9 +53,12 @@ export function AuthProvider({ children }: { children: ReactNode }) {
     setUser(response.user);
   };
 
+  const completeOIDCLogin = async (token: string, refreshToken: string) => {
+    setUser(await apiClient.completeOIDCLogin(token, refreshToken));
+  };
+
   const register = async (email: string, password: string, name: string) => {
     const response = await apiClient.register({ email, password, name });
-    apiClient.setToken(response.token);
     setUser(response.user);
   };
 
@@ -69,6 +73,7 @@ export function AuthProvider({ children }: { children: ReactNode }) {
         user,
         isLoading,
         login,
+        completeOIDCLogin,
         register,
         logout,
         isAuthenticated: !!user,